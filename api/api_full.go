@@ -35,6 +35,18 @@ type FullNode interface {
 	ChainGetGenesis(context.Context) (*types.TipSet, error)
 	ChainTipSetWeight(context.Context, *types.TipSet) (types.BigInt, error)
 
+	// ChainExport returns a stream of chunks of a CAR dump of chain data
+	// ending at the given tipset. State trees and receipts are only included
+	// if the flag is set. The stream ends with a chunk with Done set, or with
+	// Err set if the export failed.
+	ChainExport(context.Context, types.TipSetKey, bool) (<-chan ExportChunk, error)
+
+	// ChainPrune removes state trees and receipts older than the given number
+	// of finality windows from the blockstore. With dryRun set nothing is
+	// removed, and the result describes what would be reclaimed. Results of
+	// messages executed in pruned tipsets can't be looked up anymore.
+	ChainPrune(ctx context.Context, retainFinalities uint64, dryRun bool) (*store.PruneResult, error)
+
 	// syncer
 	SyncState(context.Context) (*SyncState, error)
 	SyncSubmitBlock(ctx context.Context, blk *types.BlockMsg) error
@@ -47,6 +59,10 @@ type FullNode interface {
 	MpoolGetNonce(context.Context, address.Address) (uint64, error)
 	MpoolSub(context.Context) (<-chan MpoolUpdate, error)
 
+	// MpoolEstimateGasPrice suggests a gas price based on messages included
+	// in the given number of tipsets before the given one
+	MpoolEstimateGasPrice(context.Context, uint64, types.TipSetKey) (types.BigInt, error)
+
 	// FullNodeStruct
 
 	// miner
@@ -81,6 +97,8 @@ type FullNode interface {
 	ClientHasLocal(ctx context.Context, root cid.Cid) (bool, error)
 	ClientFindData(ctx context.Context, root cid.Cid) ([]QueryOffer, error)
 	ClientRetrieve(ctx context.Context, order RetrievalOrder, path string) error
+	// ClientListRetrievals lists the retrievals made by the client
+	ClientListRetrievals(ctx context.Context) ([]RetrievalInfo, error)
 	ClientQueryAsk(ctx context.Context, p peer.ID, miner address.Address) (*types.SignedStorageAsk, error)
 
 	// ClientUnimport removes references to the specified file from filestore
@@ -92,8 +110,15 @@ type FullNode interface {
 	//ClientListAsks() []Ask
 
 	// if tipset is nil, we'll use heaviest
-	StateCall(context.Context, *types.Message, *types.TipSet) (*types.MessageReceipt, error)
-	StateReplay(context.Context, *types.TipSet, cid.Cid) (*ReplayResults, error)
+	StateCall(context.Context, *types.Message, *types.TipSet) (*InvocResult, error)
+	StateReplay(context.Context, *types.TipSet, cid.Cid) (*InvocResult, error)
+	// StateCompute applies the given messages on top of the state of the
+	// tipset, without modifying the chain, and returns the result of each
+	// message. The resulting state is discarded
+	StateCompute(context.Context, []*types.Message, *types.TipSet) (*ComputeStateOutput, error)
+	// StateEstimateGas returns the gas limit needed to execute the message
+	// on top of pending messages of its sender, including a safety margin
+	StateEstimateGas(context.Context, *types.Message, types.TipSetKey) (types.BigInt, error)
 	StateGetActor(ctx context.Context, actor address.Address, ts *types.TipSet) (*types.Actor, error)
 	StateReadState(ctx context.Context, act *types.Actor, ts *types.TipSet) (*ActorState, error)
 
@@ -101,11 +126,27 @@ type FullNode interface {
 	StateMinerProvingSet(context.Context, address.Address, *types.TipSet) ([]*ChainSectorInfo, error)
 	StateMinerPower(context.Context, address.Address, *types.TipSet) (MinerPower, error)
 	StateMinerWorker(context.Context, address.Address, *types.TipSet) (address.Address, error)
+	// StateMinerInfo returns the owner, worker and pending key changes of the
+	// miner, with worker changes in effect at the tipset height applied
+	StateMinerInfo(context.Context, address.Address, *types.TipSet) (*MinerInfo, error)
 	StateMinerPeerID(ctx context.Context, m address.Address, ts *types.TipSet) (peer.ID, error)
 	StateMinerProvingPeriodEnd(ctx context.Context, actor address.Address, ts *types.TipSet) (uint64, error)
 	StateMinerSectorSize(context.Context, address.Address, *types.TipSet) (uint64, error)
+	// StateMinerFaults returns the sectors the miner declared as faulty
+	StateMinerFaults(context.Context, address.Address, *types.TipSet) (*MinerFaults, error)
+	// StateMinerSectorExpiration returns the height at which a sector of the
+	// miner expires, or 0 if it doesn't expire
+	StateMinerSectorExpiration(ctx context.Context, actor address.Address, sectorID uint64, ts *types.TipSet) (uint64, error)
 	StatePledgeCollateral(context.Context, *types.TipSet) (types.BigInt, error)
 	StateWaitMsg(context.Context, cid.Cid) (*MsgWait, error)
+	// StateSearchMsg looks up the tipset in which the message was executed on
+	// the current chain, without waiting for it. Nil is returned if the
+	// message wasn't found.
+	StateSearchMsg(context.Context, cid.Cid) (*MsgWait, error)
+	// StateListMessages lists messages matching the filter which were
+	// included in the chain of the given tipset from fromHeight on, and were
+	// executed by it
+	StateListMessages(ctx context.Context, match *MessageMatch, tsk types.TipSetKey, fromHeight uint64) ([]cid.Cid, error)
 	StateListMiners(context.Context, *types.TipSet) ([]address.Address, error)
 	StateListActors(context.Context, *types.TipSet) ([]address.Address, error)
 	StateMarketBalance(context.Context, address.Address, *types.TipSet) (actors.StorageParticipantBalance, error)
@@ -119,6 +160,20 @@ type FullNode interface {
 	MarketEnsureAvailable(context.Context, address.Address, types.BigInt) error
 	// MarketFreeBalance
 
+	// MsigGetAvailableBalance returns the balance of a multisig wallet which
+	// isn't locked by vesting
+	MsigGetAvailableBalance(context.Context, address.Address, *types.TipSet) (types.BigInt, error)
+	// MsigGetPending returns the transactions of a multisig wallet which were
+	// neither completed nor canceled
+	MsigGetPending(context.Context, address.Address, *types.TipSet) ([]*actors.MTransaction, error)
+	// MsigCreate creates a multisig wallet requiring req approvals from the
+	// given signers, funded with val from src. The returned cid is that of the
+	// creation message.
+	MsigCreate(ctx context.Context, req uint64, signers []address.Address, unlockDuration uint64, val types.BigInt, src address.Address) (cid.Cid, error)
+	MsigPropose(ctx context.Context, msig address.Address, to address.Address, amt types.BigInt, src address.Address, method uint64, params []byte) (cid.Cid, error)
+	MsigApprove(ctx context.Context, msig address.Address, txID uint64, src address.Address) (cid.Cid, error)
+	MsigCancel(ctx context.Context, msig address.Address, txID uint64, src address.Address) (cid.Cid, error)
+
 	PaychGet(ctx context.Context, from, to address.Address, ensureFunds types.BigInt) (*ChannelInfo, error)
 	PaychList(context.Context) ([]address.Address, error)
 	PaychStatus(context.Context, address.Address) (*PaychStatus, error)
@@ -152,11 +207,28 @@ type DealInfo struct {
 	Duration      uint64
 }
 
+// ExportChunk is a part of a chain export stream
+type ExportChunk struct {
+	Data []byte
+
+	// Err is set if the export failed, it's the last chunk of the stream
+	Err string
+	// Done is set on the last chunk of a successful export
+	Done bool
+}
+
 type MsgWait struct {
 	Receipt types.MessageReceipt
 	TipSet  *types.TipSet
 }
 
+// MessageMatch selects messages by sender and recipient. At least one of the
+// fields must be set.
+type MessageMatch struct {
+	To   address.Address
+	From address.Address
+}
+
 type BlockMessages struct {
 	BlsMessages   []*types.Message
 	SecpkMessages []*types.SignedMessage
@@ -212,11 +284,35 @@ type VoucherSpec struct {
 	Extra *types.ModVerifyParams
 }
 
+// MinerInfo is the info of a miner, along with its pending key changes
+type MinerInfo struct {
+	actors.MinerInfo
+
+	WorkerChange *actors.WorkerKeyChange
+	OwnerChange  *actors.OwnerChange
+}
+
+// WorkerAt returns the worker in effect at the given height
+func (mi *MinerInfo) WorkerAt(height uint64) address.Address {
+	if mi.WorkerChange != nil && height >= mi.WorkerChange.EffectiveAt {
+		return mi.WorkerChange.NewWorker
+	}
+	return mi.Worker
+}
+
 type MinerPower struct {
 	MinerPower types.BigInt
 	TotalPower types.BigInt
 }
 
+type MinerFaults struct {
+	// Current faults are excluded from the PoSt of the current proving period
+	Current []uint64
+	// Next faults were declared after the PoSt challenge, and become current
+	// in the next proving period
+	Next []uint64
+}
+
 type QueryOffer struct {
 	Err string
 
@@ -225,6 +321,12 @@ type QueryOffer struct {
 	Size     uint64
 	MinPrice types.BigInt
 
+	// Terms from the miner retrieval ask
+	PricePerByte            types.BigInt
+	UnsealPrice             types.BigInt
+	PaymentInterval         uint64
+	PaymentIntervalIncrease uint64
+
 	Miner       address.Address
 	MinerPeerID peer.ID
 }
@@ -235,6 +337,11 @@ func (o *QueryOffer) Order() RetrievalOrder {
 		Size:  o.Size,
 		Total: o.MinPrice,
 
+		PricePerByte:            o.PricePerByte,
+		UnsealPrice:             o.UnsealPrice,
+		PaymentInterval:         o.PaymentInterval,
+		PaymentIntervalIncrease: o.PaymentIntervalIncrease,
+
 		Miner:       o.Miner,
 		MinerPeerID: o.MinerPeerID,
 	}
@@ -244,18 +351,58 @@ type RetrievalOrder struct {
 	// TODO: make this less unixfs specific
 	Root cid.Cid
 	Size uint64
-	// TODO: support offset
+	// Offset and Length select the byte range of the file to retrieve, a
+	// Length of 0 retrieves everything after Offset
+	Offset uint64
+	Length uint64
+	// Total is the most the client is willing to pay
 	Total types.BigInt
 
+	PricePerByte            types.BigInt
+	UnsealPrice             types.BigInt
+	PaymentInterval         uint64
+	PaymentIntervalIncrease uint64
+
+	// Selector is a dag-cbor encoded IPLD selector. When set, the blocks it
+	// selects under Root are retrieved instead of a unixfs file, and Size
+	// limits the amount of data paid for
+	Selector []byte
+
+	Client      address.Address
+	Miner       address.Address
+	MinerPeerID peer.ID
+}
+
+// RetrievalInfo describes a retrieval made by the client
+type RetrievalInfo struct {
+	ID    uint64
+	State RetrievalState
+
+	Root   cid.Cid
+	Offset uint64
+	Length uint64
+
 	Client      address.Address
 	Miner       address.Address
 	MinerPeerID peer.ID
+
+	// FundsSpent is the total of the payment vouchers sent to the miner
+	FundsSpent    types.BigInt
+	BytesReceived uint64
+
+	// Message is the failure reason
+	Message string
+}
+
+type InvocResult struct {
+	Msg            *types.Message
+	Receipt        *types.MessageReceipt
+	ExecutionTrace *types.ExecutionTrace
+	Error          string
 }
 
-type ReplayResults struct {
-	Msg     *types.Message
-	Receipt *types.MessageReceipt
-	Error   string
+type ComputeStateOutput struct {
+	Trace []*InvocResult
 }
 
 type ActiveSync struct {
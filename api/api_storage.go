@@ -3,8 +3,14 @@ package api
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	"github.com/filecoin-project/lotus/chain/actors"
 	"github.com/filecoin-project/lotus/chain/address"
+	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/lib/sectorbuilder"
 )
 
@@ -23,37 +29,82 @@ const (
 	Committing
 	Proving
 
+	// error modes, sectors in these states are retried with a backoff
+
+	PackingFailed
+	SealFailed
+	PreCommitFailed
+	CommitFailed
+
+	// FailedUnrecoverable sectors ran out of retries, and need to be moved
+	// to another state manually with SectorsUpdate
+	FailedUnrecoverable
+
+	// WaitDeals sectors are open for more deal pieces, they are sealed when
+	// full, or after waiting for deals for long enough
+	WaitDeals
+
+	// Expiring sectors are close to their on-chain expiration, they go back
+	// to Proving if the expiration is extended
+	Expiring
+	// Expired sectors were removed from the chain, their files are deleted
+	Expired
+
+	// CommitWait sectors wait for the commit message to land on chain
+	CommitWait
+
 	SectorNoUpdate = UndefinedSectorState
 )
 
+var SectorStates = []string{
+	UndefinedSectorState: "UndefinedSectorState",
+	Empty:                "Empty",
+	Packing:              "Packing",
+	Unsealed:             "Unsealed",
+	PreCommitting:        "PreCommitting",
+	PreCommitted:         "PreCommitted",
+	Committing:           "Committing",
+	Proving:              "Proving",
+
+	PackingFailed:       "PackingFailed",
+	SealFailed:          "SealFailed",
+	PreCommitFailed:     "PreCommitFailed",
+	CommitFailed:        "CommitFailed",
+	FailedUnrecoverable: "FailedUnrecoverable",
+
+	WaitDeals: "WaitDeals",
+
+	Expiring: "Expiring",
+	Expired:  "Expired",
+
+	CommitWait: "CommitWait",
+}
+
 func SectorStateStr(s SectorState) string {
-	switch s {
-	case UndefinedSectorState:
-		return "UndefinedSectorState"
-	case Empty:
-		return "Empty"
-	case Packing:
-		return "Packing"
-	case Unsealed:
-		return "Unsealed"
-	case PreCommitting:
-		return "PreCommitting"
-	case PreCommitted:
-		return "PreCommitted"
-	case Committing:
-		return "Committing"
-	case Proving:
-		return "Proving"
+	if s < SectorState(len(SectorStates)) {
+		return SectorStates[s]
 	}
 	return fmt.Sprintf("<Unknown %d>", s)
 }
 
+// SectorStateFromStr parses a sector state name, as returned by SectorStateStr
+func SectorStateFromStr(str string) (SectorState, bool) {
+	for s, name := range SectorStates {
+		if name == str {
+			return SectorState(s), true
+		}
+	}
+	return UndefinedSectorState, false
+}
+
 // StorageMiner is a low-level interface to the Filecoin network storage miner node
 type StorageMiner interface {
 	Common
 
 	ActorAddress(context.Context) (address.Address, error)
 
+	ActorSectorSize(context.Context, address.Address) (uint64, error)
+
 	// Temp api for testing
 	StoreGarbageData(context.Context) error
 
@@ -65,13 +116,72 @@ type StorageMiner interface {
 
 	SectorsRefs(context.Context) (map[string][]SealedRef, error)
 
+	// SectorsUpdate moves the sector to the given state, restarting its
+	// processing from there. Meant for manual recovery of failed sectors.
+	SectorsUpdate(context.Context, uint64, SectorState) error
+
+	// SectorsFaults lists sectors in the proving set with inaccessible files
+	SectorsFaults(context.Context) ([]SectorFault, error)
+
+	// PoStHistory lists the PoSts computed by the miner, oldest first
+	PoStHistory(context.Context) ([]PoStRecord, error)
+
+	// MarketListDeals lists the storage deals tracked by the provider
+	MarketListDeals(context.Context) ([]MinerDeal, error)
+	MarketGetDeal(context.Context, cid.Cid) (*MinerDeal, error)
+	// MarketSetAsk sets the storage price, in attoFIL per GiB per epoch, for
+	// the given number of seconds
+	MarketSetAsk(ctx context.Context, price types.BigInt, ttlSecs int64) error
+	MarketGetAsk(context.Context) (*types.SignedStorageAsk, error)
+	// MarketSetRetrievalAsk sets the retrieval prices, in attoFIL, and the
+	// number of bytes sent for each payment, for the given number of seconds
+	MarketSetRetrievalAsk(ctx context.Context, pricePerByte, unsealPrice types.BigInt, paymentInterval, paymentIntervalIncrease uint64, ttlSecs int64) error
+	MarketGetRetrievalAsk(context.Context) (*types.SignedRetrievalAsk, error)
+	// MarketListRetrievalDeals lists the retrieval deals served by the miner
+	MarketListRetrievalDeals(context.Context) ([]RetrievalDeal, error)
+
 	WorkerStats(context.Context) (WorkerStats, error)
+
+	// StorageList lists the paths sealed sectors are stored in
+	StorageList(context.Context) ([]StoragePathStat, error)
+	// StorageAttach adds a path for storing sealed sectors
+	StorageAttach(context.Context, StoragePath) error
+
+	// WorkerQueue registers a remote seal worker, sealing tasks for it are
+	// sent over the returned channel
+	WorkerQueue(context.Context) (<-chan sectorbuilder.WorkerTask, error)
+	// WorkerDone reports the result of a task to the miner
+	WorkerDone(ctx context.Context, task uint64, res sectorbuilder.SealRes) error
 }
 
-type WorkerStats struct {
-	Free     int
-	Reserved int // for PoSt
-	Total    int
+type WorkerStats = sectorbuilder.WorkerStats
+
+type StoragePath = sectorbuilder.StoragePath
+
+type StoragePathStat = sectorbuilder.StoragePathStat
+
+type SectorFault struct {
+	SectorID uint64
+	Err      string
+	// Declared is set when the fault was declared on chain
+	Declared bool
+}
+
+// PoStRecord describes the PoSt computed for a proving period
+type PoStRecord struct {
+	ProvingPeriodEnd uint64
+	// RandHeight is the height of the challenge randomness
+	RandHeight uint64
+
+	Attempts int
+	// Duration is the time it took to generate the proof
+	Duration time.Duration
+
+	Message *cid.Cid
+	Receipt *types.MessageReceipt
+
+	// Err is the error of the last attempt, if it failed
+	Err string
 }
 
 type SectorInfo struct {
@@ -81,8 +191,52 @@ type SectorInfo struct {
 	CommR    []byte
 	Proof    []byte
 	Deals    []uint64
+	Pieces   []SectorPiece
 	Ticket   sectorbuilder.SealTicket
 	Seed     sectorbuilder.SealSeed
+
+	Retries uint64
+	LastErr string
+}
+
+type SectorPiece struct {
+	DealID uint64
+	Ref    string
+	Size   uint64
+	CommP  []byte
+}
+
+// MinerDeal describes a storage deal tracked by the storage provider
+type MinerDeal struct {
+	ProposalCid cid.Cid
+	State       DealState
+	Client      peer.ID
+	Proposal    actors.StorageDealProposal
+
+	Ref cid.Cid
+
+	DealID   uint64
+	SectorID uint64
+
+	// Transferred is the amount of deal data received so far, while the data
+	// transfer is in progress
+	Transferred uint64
+}
+
+// RetrievalDeal describes a retrieval deal served by the miner
+type RetrievalDeal struct {
+	ID     uint64
+	State  RetrievalState
+	Root   cid.Cid
+	Client peer.ID
+
+	Paych address.Address
+	// FundsReceived is the total of the payment vouchers received
+	FundsReceived types.BigInt
+	BytesSent     uint64
+
+	// Message is the failure reason
+	Message string
 }
 
 type SealedRef struct {
@@ -11,6 +11,7 @@ import (
 	"github.com/filecoin-project/lotus/chain/address"
 	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/sectorbuilder"
 )
 
 // All permissions are listed in permissioned.go
@@ -50,6 +51,8 @@ type FullNodeStruct struct {
 		ChainSetHead           func(context.Context, *types.TipSet) error                                   `perm:"admin"`
 		ChainGetGenesis        func(context.Context) (*types.TipSet, error)                                 `perm:"read"`
 		ChainTipSetWeight      func(context.Context, *types.TipSet) (types.BigInt, error)                   `perm:"read"`
+		ChainExport            func(context.Context, types.TipSetKey, bool) (<-chan ExportChunk, error)     `perm:"read"`
+		ChainPrune             func(context.Context, uint64, bool) (*store.PruneResult, error)              `perm:"admin"`
 
 		SyncState          func(context.Context) (*SyncState, error)                    `perm:"read"`
 		SyncSubmitBlock    func(ctx context.Context, blk *types.BlockMsg) error         `perm:"write"`
@@ -61,6 +64,8 @@ type FullNodeStruct struct {
 		MpoolGetNonce    func(context.Context, address.Address) (uint64, error)               `perm:"read"`
 		MpoolSub         func(context.Context) (<-chan MpoolUpdate, error)                    `perm:"read"`
 
+		MpoolEstimateGasPrice func(context.Context, uint64, types.TipSetKey) (types.BigInt, error) `perm:"read"`
+
 		MinerRegister    func(context.Context, address.Address) error                                                                                                         `perm:"admin"`
 		MinerUnregister  func(context.Context, address.Address) error                                                                                                         `perm:"admin"`
 		MinerAddresses   func(context.Context) ([]address.Address, error)                                                                                                     `perm:"write"`
@@ -77,29 +82,37 @@ type FullNodeStruct struct {
 		WalletExport         func(context.Context, address.Address) (*types.KeyInfo, error)                       `perm:"admin"`
 		WalletImport         func(context.Context, *types.KeyInfo) (address.Address, error)                       `perm:"admin"`
 
-		ClientImport      func(ctx context.Context, path string) (cid.Cid, error)                                                                     `perm:"admin"`
-		ClientListImports func(ctx context.Context) ([]Import, error)                                                                                 `perm:"write"`
-		ClientHasLocal    func(ctx context.Context, root cid.Cid) (bool, error)                                                                       `perm:"write"`
-		ClientFindData    func(ctx context.Context, root cid.Cid) ([]QueryOffer, error)                                                               `perm:"read"`
-		ClientStartDeal   func(ctx context.Context, data cid.Cid, miner address.Address, price types.BigInt, blocksDuration uint64) (*cid.Cid, error) `perm:"admin"`
-		ClientGetDealInfo func(context.Context, cid.Cid) (*DealInfo, error)                                                                           `perm:"read"`
-		ClientListDeals   func(ctx context.Context) ([]DealInfo, error)                                                                               `perm:"write"`
-		ClientRetrieve    func(ctx context.Context, order RetrievalOrder, path string) error                                                          `perm:"admin"`
-		ClientQueryAsk    func(ctx context.Context, p peer.ID, miner address.Address) (*types.SignedStorageAsk, error)                                `perm:"read"`
+		ClientImport         func(ctx context.Context, path string) (cid.Cid, error)                                                                     `perm:"admin"`
+		ClientListImports    func(ctx context.Context) ([]Import, error)                                                                                 `perm:"write"`
+		ClientHasLocal       func(ctx context.Context, root cid.Cid) (bool, error)                                                                       `perm:"write"`
+		ClientFindData       func(ctx context.Context, root cid.Cid) ([]QueryOffer, error)                                                               `perm:"read"`
+		ClientStartDeal      func(ctx context.Context, data cid.Cid, miner address.Address, price types.BigInt, blocksDuration uint64) (*cid.Cid, error) `perm:"admin"`
+		ClientGetDealInfo    func(context.Context, cid.Cid) (*DealInfo, error)                                                                           `perm:"read"`
+		ClientListDeals      func(ctx context.Context) ([]DealInfo, error)                                                                               `perm:"write"`
+		ClientRetrieve       func(ctx context.Context, order RetrievalOrder, path string) error                                                          `perm:"admin"`
+		ClientListRetrievals func(ctx context.Context) ([]RetrievalInfo, error)                                                                          `perm:"read"`
+		ClientQueryAsk       func(ctx context.Context, p peer.ID, miner address.Address) (*types.SignedStorageAsk, error)                                `perm:"read"`
 
 		StateMinerSectors          func(context.Context, address.Address, *types.TipSet) ([]*ChainSectorInfo, error)               `perm:"read"`
 		StateMinerProvingSet       func(context.Context, address.Address, *types.TipSet) ([]*ChainSectorInfo, error)               `perm:"read"`
 		StateMinerPower            func(context.Context, address.Address, *types.TipSet) (MinerPower, error)                       `perm:"read"`
 		StateMinerWorker           func(context.Context, address.Address, *types.TipSet) (address.Address, error)                  `perm:"read"`
+		StateMinerInfo             func(context.Context, address.Address, *types.TipSet) (*MinerInfo, error)                       `perm:"read"`
 		StateMinerPeerID           func(ctx context.Context, m address.Address, ts *types.TipSet) (peer.ID, error)                 `perm:"read"`
 		StateMinerProvingPeriodEnd func(ctx context.Context, actor address.Address, ts *types.TipSet) (uint64, error)              `perm:"read"`
 		StateMinerSectorSize       func(context.Context, address.Address, *types.TipSet) (uint64, error)                           `perm:"read"`
-		StateCall                  func(context.Context, *types.Message, *types.TipSet) (*types.MessageReceipt, error)             `perm:"read"`
-		StateReplay                func(context.Context, *types.TipSet, cid.Cid) (*ReplayResults, error)                           `perm:"read"`
+		StateMinerFaults           func(context.Context, address.Address, *types.TipSet) (*MinerFaults, error)                     `perm:"read"`
+		StateMinerSectorExpiration func(context.Context, address.Address, uint64, *types.TipSet) (uint64, error)                   `perm:"read"`
+		StateCall                  func(context.Context, *types.Message, *types.TipSet) (*InvocResult, error)                      `perm:"read"`
+		StateReplay                func(context.Context, *types.TipSet, cid.Cid) (*InvocResult, error)                             `perm:"read"`
+		StateCompute               func(context.Context, []*types.Message, *types.TipSet) (*ComputeStateOutput, error)             `perm:"read"`
+		StateEstimateGas           func(context.Context, *types.Message, types.TipSetKey) (types.BigInt, error)                    `perm:"read"`
 		StateGetActor              func(context.Context, address.Address, *types.TipSet) (*types.Actor, error)                     `perm:"read"`
 		StateReadState             func(context.Context, *types.Actor, *types.TipSet) (*ActorState, error)                         `perm:"read"`
 		StatePledgeCollateral      func(context.Context, *types.TipSet) (types.BigInt, error)                                      `perm:"read"`
 		StateWaitMsg               func(context.Context, cid.Cid) (*MsgWait, error)                                                `perm:"read"`
+		StateSearchMsg             func(context.Context, cid.Cid) (*MsgWait, error)                                                `perm:"read"`
+		StateListMessages          func(context.Context, *MessageMatch, types.TipSetKey, uint64) ([]cid.Cid, error)                `perm:"read"`
 		StateListMiners            func(context.Context, *types.TipSet) ([]address.Address, error)                                 `perm:"read"`
 		StateListActors            func(context.Context, *types.TipSet) ([]address.Address, error)                                 `perm:"read"`
 		StateMarketBalance         func(context.Context, address.Address, *types.TipSet) (actors.StorageParticipantBalance, error) `perm:"read"`
@@ -112,6 +125,13 @@ type FullNodeStruct struct {
 
 		MarketEnsureAvailable func(context.Context, address.Address, types.BigInt) error `perm:"sign"`
 
+		MsigGetAvailableBalance func(context.Context, address.Address, *types.TipSet) (types.BigInt, error)                                             `perm:"read"`
+		MsigGetPending          func(context.Context, address.Address, *types.TipSet) ([]*actors.MTransaction, error)                                   `perm:"read"`
+		MsigCreate              func(context.Context, uint64, []address.Address, uint64, types.BigInt, address.Address) (cid.Cid, error)                `perm:"sign"`
+		MsigPropose             func(context.Context, address.Address, address.Address, types.BigInt, address.Address, uint64, []byte) (cid.Cid, error) `perm:"sign"`
+		MsigApprove             func(context.Context, address.Address, uint64, address.Address) (cid.Cid, error)                                        `perm:"sign"`
+		MsigCancel              func(context.Context, address.Address, uint64, address.Address) (cid.Cid, error)                                        `perm:"sign"`
+
 		PaychGet                   func(ctx context.Context, from, to address.Address, ensureFunds types.BigInt) (*ChannelInfo, error)      `perm:"sign"`
 		PaychList                  func(context.Context) ([]address.Address, error)                                                         `perm:"read"`
 		PaychStatus                func(context.Context, address.Address) (*PaychStatus, error)                                             `perm:"read"`
@@ -132,15 +152,35 @@ type StorageMinerStruct struct {
 	CommonStruct
 
 	Internal struct {
-		ActorAddress func(context.Context) (address.Address, error) `perm:"read"`
+		ActorAddress    func(context.Context) (address.Address, error)         `perm:"read"`
+		ActorSectorSize func(context.Context, address.Address) (uint64, error) `perm:"read"`
 
 		StoreGarbageData func(context.Context) error `perm:"write"`
 
 		SectorsStatus func(context.Context, uint64) (SectorInfo, error)     `perm:"read"`
 		SectorsList   func(context.Context) ([]uint64, error)               `perm:"read"`
 		SectorsRefs   func(context.Context) (map[string][]SealedRef, error) `perm:"read"`
+		SectorsUpdate func(context.Context, uint64, SectorState) error      `perm:"admin"`
+		SectorsFaults func(context.Context) ([]SectorFault, error)          `perm:"read"`
+
+		PoStHistory func(context.Context) ([]PoStRecord, error) `perm:"read"`
+
+		MarketListDeals func(context.Context) ([]MinerDeal, error)             `perm:"read"`
+		MarketGetDeal   func(context.Context, cid.Cid) (*MinerDeal, error)     `perm:"read"`
+		MarketSetAsk    func(context.Context, types.BigInt, int64) error       `perm:"admin"`
+		MarketGetAsk    func(context.Context) (*types.SignedStorageAsk, error) `perm:"read"`
+
+		MarketSetRetrievalAsk    func(context.Context, types.BigInt, types.BigInt, uint64, uint64, int64) error `perm:"admin"`
+		MarketGetRetrievalAsk    func(context.Context) (*types.SignedRetrievalAsk, error)                       `perm:"read"`
+		MarketListRetrievalDeals func(context.Context) ([]RetrievalDeal, error)                                 `perm:"read"`
 
 		WorkerStats func(context.Context) (WorkerStats, error) `perm:"read"`
+
+		StorageList   func(context.Context) ([]StoragePathStat, error) `perm:"read"`
+		StorageAttach func(context.Context, StoragePath) error         `perm:"admin"`
+
+		WorkerQueue func(context.Context) (<-chan sectorbuilder.WorkerTask, error)          `perm:"admin"`
+		WorkerDone  func(ctx context.Context, task uint64, res sectorbuilder.SealRes) error `perm:"admin"`
 	}
 }
 
@@ -213,6 +253,10 @@ func (c *FullNodeStruct) ClientRetrieve(ctx context.Context, order RetrievalOrde
 	return c.Internal.ClientRetrieve(ctx, order, path)
 }
 
+func (c *FullNodeStruct) ClientListRetrievals(ctx context.Context) ([]RetrievalInfo, error) {
+	return c.Internal.ClientListRetrievals(ctx)
+}
+
 func (c *FullNodeStruct) ClientQueryAsk(ctx context.Context, p peer.ID, miner address.Address) (*types.SignedStorageAsk, error) {
 	return c.Internal.ClientQueryAsk(ctx, p, miner)
 }
@@ -233,6 +277,10 @@ func (c *FullNodeStruct) MpoolSub(ctx context.Context) (<-chan MpoolUpdate, erro
 	return c.Internal.MpoolSub(ctx)
 }
 
+func (c *FullNodeStruct) MpoolEstimateGasPrice(ctx context.Context, nblocks uint64, tsk types.TipSetKey) (types.BigInt, error) {
+	return c.Internal.MpoolEstimateGasPrice(ctx, nblocks, tsk)
+}
+
 func (c *FullNodeStruct) MinerRegister(ctx context.Context, addr address.Address) error {
 	return c.Internal.MinerRegister(ctx, addr)
 }
@@ -345,6 +393,14 @@ func (c *FullNodeStruct) ChainTipSetWeight(ctx context.Context, ts *types.TipSet
 	return c.Internal.ChainTipSetWeight(ctx, ts)
 }
 
+func (c *FullNodeStruct) ChainExport(ctx context.Context, tsk types.TipSetKey, inclState bool) (<-chan ExportChunk, error) {
+	return c.Internal.ChainExport(ctx, tsk, inclState)
+}
+
+func (c *FullNodeStruct) ChainPrune(ctx context.Context, retainFinalities uint64, dryRun bool) (*store.PruneResult, error) {
+	return c.Internal.ChainPrune(ctx, retainFinalities, dryRun)
+}
+
 func (c *FullNodeStruct) SyncState(ctx context.Context) (*SyncState, error) {
 	return c.Internal.SyncState(ctx)
 }
@@ -373,10 +429,22 @@ func (c *FullNodeStruct) StateMinerWorker(ctx context.Context, m address.Address
 	return c.Internal.StateMinerWorker(ctx, m, ts)
 }
 
+func (c *FullNodeStruct) StateMinerInfo(ctx context.Context, m address.Address, ts *types.TipSet) (*MinerInfo, error) {
+	return c.Internal.StateMinerInfo(ctx, m, ts)
+}
+
 func (c *FullNodeStruct) StateMinerPeerID(ctx context.Context, m address.Address, ts *types.TipSet) (peer.ID, error) {
 	return c.Internal.StateMinerPeerID(ctx, m, ts)
 }
 
+func (c *FullNodeStruct) StateMinerFaults(ctx context.Context, actor address.Address, ts *types.TipSet) (*MinerFaults, error) {
+	return c.Internal.StateMinerFaults(ctx, actor, ts)
+}
+
+func (c *FullNodeStruct) StateMinerSectorExpiration(ctx context.Context, actor address.Address, sectorID uint64, ts *types.TipSet) (uint64, error) {
+	return c.Internal.StateMinerSectorExpiration(ctx, actor, sectorID, ts)
+}
+
 func (c *FullNodeStruct) StateMinerProvingPeriodEnd(ctx context.Context, actor address.Address, ts *types.TipSet) (uint64, error) {
 	return c.Internal.StateMinerProvingPeriodEnd(ctx, actor, ts)
 }
@@ -385,14 +453,22 @@ func (c *FullNodeStruct) StateMinerSectorSize(ctx context.Context, actor address
 	return c.Internal.StateMinerSectorSize(ctx, actor, ts)
 }
 
-func (c *FullNodeStruct) StateCall(ctx context.Context, msg *types.Message, ts *types.TipSet) (*types.MessageReceipt, error) {
+func (c *FullNodeStruct) StateCall(ctx context.Context, msg *types.Message, ts *types.TipSet) (*InvocResult, error) {
 	return c.Internal.StateCall(ctx, msg, ts)
 }
 
-func (c *FullNodeStruct) StateReplay(ctx context.Context, ts *types.TipSet, mc cid.Cid) (*ReplayResults, error) {
+func (c *FullNodeStruct) StateReplay(ctx context.Context, ts *types.TipSet, mc cid.Cid) (*InvocResult, error) {
 	return c.Internal.StateReplay(ctx, ts, mc)
 }
 
+func (c *FullNodeStruct) StateCompute(ctx context.Context, msgs []*types.Message, ts *types.TipSet) (*ComputeStateOutput, error) {
+	return c.Internal.StateCompute(ctx, msgs, ts)
+}
+
+func (c *FullNodeStruct) StateEstimateGas(ctx context.Context, msg *types.Message, tsk types.TipSetKey) (types.BigInt, error) {
+	return c.Internal.StateEstimateGas(ctx, msg, tsk)
+}
+
 func (c *FullNodeStruct) StateGetActor(ctx context.Context, actor address.Address, ts *types.TipSet) (*types.Actor, error) {
 	return c.Internal.StateGetActor(ctx, actor, ts)
 }
@@ -408,6 +484,14 @@ func (c *FullNodeStruct) StatePledgeCollateral(ctx context.Context, ts *types.Ti
 func (c *FullNodeStruct) StateWaitMsg(ctx context.Context, msgc cid.Cid) (*MsgWait, error) {
 	return c.Internal.StateWaitMsg(ctx, msgc)
 }
+
+func (c *FullNodeStruct) StateSearchMsg(ctx context.Context, msgc cid.Cid) (*MsgWait, error) {
+	return c.Internal.StateSearchMsg(ctx, msgc)
+}
+
+func (c *FullNodeStruct) StateListMessages(ctx context.Context, match *MessageMatch, tsk types.TipSetKey, fromHeight uint64) ([]cid.Cid, error) {
+	return c.Internal.StateListMessages(ctx, match, tsk, fromHeight)
+}
 func (c *FullNodeStruct) StateListMiners(ctx context.Context, ts *types.TipSet) ([]address.Address, error) {
 	return c.Internal.StateListMiners(ctx, ts)
 }
@@ -448,6 +532,30 @@ func (c *FullNodeStruct) MarketEnsureAvailable(ctx context.Context, addr address
 	return c.Internal.MarketEnsureAvailable(ctx, addr, amt)
 }
 
+func (c *FullNodeStruct) MsigGetAvailableBalance(ctx context.Context, a address.Address, ts *types.TipSet) (types.BigInt, error) {
+	return c.Internal.MsigGetAvailableBalance(ctx, a, ts)
+}
+
+func (c *FullNodeStruct) MsigGetPending(ctx context.Context, a address.Address, ts *types.TipSet) ([]*actors.MTransaction, error) {
+	return c.Internal.MsigGetPending(ctx, a, ts)
+}
+
+func (c *FullNodeStruct) MsigCreate(ctx context.Context, req uint64, signers []address.Address, unlockDuration uint64, val types.BigInt, src address.Address) (cid.Cid, error) {
+	return c.Internal.MsigCreate(ctx, req, signers, unlockDuration, val, src)
+}
+
+func (c *FullNodeStruct) MsigPropose(ctx context.Context, msig address.Address, to address.Address, amt types.BigInt, src address.Address, method uint64, params []byte) (cid.Cid, error) {
+	return c.Internal.MsigPropose(ctx, msig, to, amt, src, method, params)
+}
+
+func (c *FullNodeStruct) MsigApprove(ctx context.Context, msig address.Address, txID uint64, src address.Address) (cid.Cid, error) {
+	return c.Internal.MsigApprove(ctx, msig, txID, src)
+}
+
+func (c *FullNodeStruct) MsigCancel(ctx context.Context, msig address.Address, txID uint64, src address.Address) (cid.Cid, error) {
+	return c.Internal.MsigCancel(ctx, msig, txID, src)
+}
+
 func (c *FullNodeStruct) PaychGet(ctx context.Context, from, to address.Address, ensureFunds types.BigInt) (*ChannelInfo, error) {
 	return c.Internal.PaychGet(ctx, from, to, ensureFunds)
 }
@@ -500,6 +608,10 @@ func (c *StorageMinerStruct) ActorAddress(ctx context.Context) (address.Address,
 	return c.Internal.ActorAddress(ctx)
 }
 
+func (c *StorageMinerStruct) ActorSectorSize(ctx context.Context, addr address.Address) (uint64, error) {
+	return c.Internal.ActorSectorSize(ctx, addr)
+}
+
 func (c *StorageMinerStruct) StoreGarbageData(ctx context.Context) error {
 	return c.Internal.StoreGarbageData(ctx)
 }
@@ -518,10 +630,66 @@ func (c *StorageMinerStruct) SectorsRefs(ctx context.Context) (map[string][]Seal
 	return c.Internal.SectorsRefs(ctx)
 }
 
+func (c *StorageMinerStruct) SectorsUpdate(ctx context.Context, id uint64, state SectorState) error {
+	return c.Internal.SectorsUpdate(ctx, id, state)
+}
+
+func (c *StorageMinerStruct) SectorsFaults(ctx context.Context) ([]SectorFault, error) {
+	return c.Internal.SectorsFaults(ctx)
+}
+
+func (c *StorageMinerStruct) PoStHistory(ctx context.Context) ([]PoStRecord, error) {
+	return c.Internal.PoStHistory(ctx)
+}
+
+func (c *StorageMinerStruct) MarketListDeals(ctx context.Context) ([]MinerDeal, error) {
+	return c.Internal.MarketListDeals(ctx)
+}
+
+func (c *StorageMinerStruct) MarketGetDeal(ctx context.Context, proposal cid.Cid) (*MinerDeal, error) {
+	return c.Internal.MarketGetDeal(ctx, proposal)
+}
+
+func (c *StorageMinerStruct) MarketSetAsk(ctx context.Context, price types.BigInt, ttlSecs int64) error {
+	return c.Internal.MarketSetAsk(ctx, price, ttlSecs)
+}
+
+func (c *StorageMinerStruct) MarketGetAsk(ctx context.Context) (*types.SignedStorageAsk, error) {
+	return c.Internal.MarketGetAsk(ctx)
+}
+
+func (c *StorageMinerStruct) MarketSetRetrievalAsk(ctx context.Context, pricePerByte, unsealPrice types.BigInt, paymentInterval, paymentIntervalIncrease uint64, ttlSecs int64) error {
+	return c.Internal.MarketSetRetrievalAsk(ctx, pricePerByte, unsealPrice, paymentInterval, paymentIntervalIncrease, ttlSecs)
+}
+
+func (c *StorageMinerStruct) MarketGetRetrievalAsk(ctx context.Context) (*types.SignedRetrievalAsk, error) {
+	return c.Internal.MarketGetRetrievalAsk(ctx)
+}
+
+func (c *StorageMinerStruct) MarketListRetrievalDeals(ctx context.Context) ([]RetrievalDeal, error) {
+	return c.Internal.MarketListRetrievalDeals(ctx)
+}
+
 func (c *StorageMinerStruct) WorkerStats(ctx context.Context) (WorkerStats, error) {
 	return c.Internal.WorkerStats(ctx)
 }
 
+func (c *StorageMinerStruct) StorageList(ctx context.Context) ([]StoragePathStat, error) {
+	return c.Internal.StorageList(ctx)
+}
+
+func (c *StorageMinerStruct) StorageAttach(ctx context.Context, p StoragePath) error {
+	return c.Internal.StorageAttach(ctx, p)
+}
+
+func (c *StorageMinerStruct) WorkerQueue(ctx context.Context) (<-chan sectorbuilder.WorkerTask, error) {
+	return c.Internal.WorkerQueue(ctx)
+}
+
+func (c *StorageMinerStruct) WorkerDone(ctx context.Context, task uint64, res sectorbuilder.SealRes) error {
+	return c.Internal.WorkerDone(ctx, task, res)
+}
+
 var _ Common = &CommonStruct{}
 var _ FullNode = &FullNodeStruct{}
 var _ StorageMiner = &StorageMinerStruct{}
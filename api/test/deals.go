@@ -1,14 +1,17 @@
 package test
 
 import (
+	"bytes"
 	"context"
 	"fmt"
-	"io"
+	"io/ioutil"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log"
 
 	"github.com/filecoin-project/lotus/api"
@@ -18,6 +21,18 @@ import (
 )
 
 func TestDealFlow(t *testing.T, b APIBuilder) {
+	testDealFlow(t, b, time.Second, false)
+}
+
+// TestDealFlowPoStRetrieval makes a deal, waits for the miner to submit a PoSt
+// for the sector holding it, and retrieves the data. Reaching the PoSt takes a
+// full proving period, so it's meant for miners using the mock sectorbuilder
+// with a short blocktime
+func TestDealFlowPoStRetrieval(t *testing.T, b APIBuilder, blocktime time.Duration) {
+	testDealFlow(t, b, blocktime, true)
+}
+
+func testDealFlow(t *testing.T, b APIBuilder, blocktime time.Duration, postAndRetrieve bool) {
 	os.Setenv("BELLMAN_NO_GPU", "1")
 
 	logging.SetAllLoggers(logging.LevelInfo)
@@ -36,8 +51,10 @@ func TestDealFlow(t *testing.T, b APIBuilder) {
 	}
 	time.Sleep(time.Second)
 
-	r := io.LimitReader(rand.New(rand.NewSource(17)), 1000)
-	fcid, err := client.ClientImportLocal(ctx, r)
+	data := make([]byte, 1000)
+	rand.New(rand.NewSource(17)).Read(data)
+
+	fcid, err := client.ClientImportLocal(ctx, bytes.NewReader(data))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -55,7 +72,7 @@ func TestDealFlow(t *testing.T, b APIBuilder) {
 	go func() {
 		defer close(done)
 		for mine {
-			time.Sleep(time.Second)
+			time.Sleep(blocktime)
 			fmt.Println("mining a block now")
 			if err := n[0].MineOne(ctx); err != nil {
 				t.Fatal(err)
@@ -90,7 +107,89 @@ loop:
 		time.Sleep(time.Second / 2)
 	}
 
+	mdeal, err := miner.MarketGetDeal(ctx, *deal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mdeal.ProposalCid != *deal {
+		t.Fatalf("miner returned deal %s, expected %s", mdeal.ProposalCid, *deal)
+	}
+
+	if postAndRetrieve {
+		waitPoSt(ctx, t, client, maddr, blocktime)
+		testRetrieval(ctx, t, client, fcid, data)
+	}
+
 	mine = false
 	fmt.Println("shutting down mining")
 	<-done
 }
+
+// waitPoSt waits for the miner to submit a PoSt after sealing its first
+// sectors, which moves its proving period end
+func waitPoSt(ctx context.Context, t *testing.T, client *impl.FullNodeAPI, maddr address.Address, blocktime time.Duration) {
+	var firstEnd uint64
+	for {
+		pset, err := client.StateMinerProvingSet(ctx, maddr, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ppe, err := client.StateMinerProvingPeriodEnd(ctx, maddr, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(pset) > 0 {
+			if firstEnd == 0 {
+				firstEnd = ppe
+			}
+			if ppe > firstEnd {
+				fmt.Println("PoSt submitted, proving period end: ", ppe)
+				return
+			}
+		}
+
+		time.Sleep(blocktime)
+	}
+}
+
+func testRetrieval(ctx context.Context, t *testing.T, client *impl.FullNodeAPI, fcid cid.Cid, data []byte) {
+	offers, err := client.ClientFindData(ctx, fcid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(offers) < 1 {
+		t.Fatal("no retrieval offers")
+	}
+	if offers[0].Err != "" {
+		t.Fatalf("retrieval offer error: %s", offers[0].Err)
+	}
+
+	payer, err := client.WalletDefaultAddress(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdir, err := ioutil.TempDir("", "lotus-retrieve-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rdir)
+
+	order := offers[0].Order()
+	order.Client = payer
+
+	rpath := filepath.Join(rdir, "ret")
+	if err := client.ClientRetrieve(ctx, order, rpath); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	rdata, err := ioutil.ReadFile(rpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rdata, data) {
+		t.Fatal("retrieved data doesn't match the imported file")
+	}
+}
@@ -36,6 +36,24 @@ var DealStates = []string{
 	"DealError",
 }
 
+type RetrievalState = uint64
+
+const (
+	RetrievalUnknown  = RetrievalState(iota)
+	RetrievalNew      // Reading local data, setting up payment
+	RetrievalOngoing  // Blocks being transferred
+	RetrievalComplete // All requested data was transferred
+	RetrievalFailed   // Stopped with an error, see the record message
+)
+
+var RetrievalStates = []string{
+	"RetrievalUnknown",
+	"RetrievalNew",
+	"RetrievalOngoing",
+	"RetrievalComplete",
+	"RetrievalFailed",
+}
+
 // TODO: check if this exists anywhere else
 type MultiaddrSlice []ma.Multiaddr
 
@@ -13,3 +13,15 @@ const ProvingPeriodDuration uint64 = 40
 func init() {
 	os.Setenv("TRUST_PARAMS", "1")
 }
+
+// Blocks
+const UpgradeMinerKeyChangeHeight = 0
+
+// Blocks
+const UpgradeSectorExpirationHeight = 0
+
+// Blocks
+const UpgradeDeclareFaultsHeight = 0
+
+// Blocks
+const UpgradeMultisigVestingHeight = 0
@@ -7,3 +7,23 @@ const BlockDelay = 12
 
 // Blocks
 const ProvingPeriodDuration uint64 = 300
+
+// Height from which miners can change their worker key and owner. Nodes have
+// to be upgraded before the devnet reaches it.
+// Blocks
+const UpgradeMinerKeyChangeHeight = 40000
+
+// Height from which sectors expire with their deals, and expired sectors are
+// removed on PoSt submission. Sectors committed before it don't expire.
+// Blocks
+const UpgradeSectorExpirationHeight = 40000
+
+// Height from which miners can declare sector faults. Before it, DeclareFaults
+// calls fail as the method didn't work on earlier nodes.
+// Blocks
+const UpgradeDeclareFaultsHeight = 40000
+
+// Height from which multisig wallets keep their vesting funds locked. Before
+// it, multisigs only reject spends which leave more than the vested amount.
+// Blocks
+const UpgradeMultisigVestingHeight = 40000
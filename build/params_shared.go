@@ -79,6 +79,11 @@ const SealRandomnessLookbackLimit = SealRandomnessLookback + 2000
 // Blocks
 const EcRandomnessLookback = 300
 
+// WorkerKeyChangeDelay is the number of blocks after which a new miner worker
+// key takes effect
+// Blocks
+const WorkerKeyChangeDelay = Finality
+
 const PowerCollateralProportion = 5
 const PerCapitaCollateralProportion = 1
 const CollateralPrecision = 1000
@@ -86,6 +91,12 @@ const CollateralPrecision = 1000
 // Blocks
 const InteractivePoRepDelay = 10
 
+// Limits on the messages a miner will include in a single block
+const BlockGasLimit = 100_000_000
+
+// Bytes
+const BlockMessagesSizeLimit = 1 << 20
+
 // /////
 // Devnet settings
 
@@ -39,7 +39,6 @@ type StorageMinerActorState struct {
 
 	// TODO: these:
 	//    SectorTable
-	//    SectorExpirationQueue
 	//    ChallengeStatus
 
 	// Contains mostly static info about this miner
@@ -70,6 +69,10 @@ type StorageMinerActorState struct {
 	SlashedAt uint64
 
 	ProvingPeriodEnd uint64
+
+	// Miner state added after genesis, see MinerExtState. Unset until the
+	// miner uses any of it.
+	Ext cid.Cid
 }
 
 type MinerInfo struct {
@@ -93,6 +96,48 @@ type MinerInfo struct {
 	// SubsectorCount
 }
 
+// MinerExtState holds miner state added after genesis. It's kept out of
+// StorageMinerActorState and MinerInfo so the state of miners that don't use
+// it is encoded the same as before.
+type MinerExtState struct {
+	// Pending change of the worker key, set by ChangeWorker.
+	WorkerChange *WorkerKeyChange
+
+	// Pending change of the owner, set by ChangeOwner. The nominated owner
+	// has to accept it before it takes effect.
+	OwnerChange *OwnerChange
+
+	// AMT of the heights at which sectors expire, indexed by sector ID.
+	// Sectors without an entry don't expire, which includes all sectors
+	// committed before UpgradeSectorExpirationHeight. Expired sectors are
+	// removed when the next PoSt is submitted. Nil when there are none.
+	SectorExpirations *cid.Cid
+}
+
+type WorkerKeyChange struct {
+	NewWorker   address.Address
+	EffectiveAt uint64
+}
+
+type OwnerChange struct {
+	NewOwner address.Address
+}
+
+// ApplyWorkerChange replaces the worker in mi with the pending new worker key
+// if the change has taken effect at the given height
+func (ext *MinerExtState) ApplyWorkerChange(mi *MinerInfo, height uint64) {
+	if ext.WorkerChange == nil || height < ext.WorkerChange.EffectiveAt {
+		return
+	}
+
+	mi.Worker = ext.WorkerChange.NewWorker
+	ext.WorkerChange = nil
+}
+
+func (ext *MinerExtState) empty() bool {
+	return ext.WorkerChange == nil && ext.OwnerChange == nil && ext.SectorExpirations == nil
+}
+
 type PreCommittedSector struct {
 	Info          SectorPreCommitInfo
 	ReceivedEpoch uint64
@@ -114,28 +159,30 @@ type SectorPreCommitInfo struct {
 }
 
 type maMethods struct {
-	Constructor          uint64
-	PreCommitSector      uint64
-	ProveCommitSector    uint64
-	SubmitPoSt           uint64
-	SlashStorageFault    uint64
-	GetCurrentProvingSet uint64
-	ArbitrateDeal        uint64
-	DePledge             uint64
-	GetOwner             uint64
-	GetWorkerAddr        uint64
-	GetPower             uint64
-	GetPeerID            uint64
-	GetSectorSize        uint64
-	UpdatePeerID         uint64
-	ChangeWorker         uint64
-	IsSlashed            uint64
-	CheckMiner           uint64
-	DeclareFaults        uint64
-	SlashConsensusFault  uint64
-}
-
-var MAMethods = maMethods{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19}
+	Constructor            uint64
+	PreCommitSector        uint64
+	ProveCommitSector      uint64
+	SubmitPoSt             uint64
+	SlashStorageFault      uint64
+	GetCurrentProvingSet   uint64
+	ArbitrateDeal          uint64
+	DePledge               uint64
+	GetOwner               uint64
+	GetWorkerAddr          uint64
+	GetPower               uint64
+	GetPeerID              uint64
+	GetSectorSize          uint64
+	UpdatePeerID           uint64
+	ChangeWorker           uint64
+	IsSlashed              uint64
+	CheckMiner             uint64
+	DeclareFaults          uint64
+	SlashConsensusFault    uint64
+	ChangeOwner            uint64
+	ExtendSectorExpiration uint64
+}
+
+var MAMethods = maMethods{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21}
 
 func (sma StorageMinerActor) Exports() []interface{} {
 	return []interface{}{
@@ -153,11 +200,13 @@ func (sma StorageMinerActor) Exports() []interface{} {
 		12: sma.GetPeerID,
 		13: sma.GetSectorSize,
 		14: sma.UpdatePeerID,
-		//15: sma.ChangeWorker,
+		15: sma.ChangeWorker,
 		16: sma.IsSlashed,
 		17: sma.CheckMiner,
 		18: sma.DeclareFaults,
 		19: sma.SlashConsensusFault,
+		20: sma.ChangeOwner,
+		21: sma.ExtendSectorExpiration,
 	}
 }
 
@@ -172,12 +221,56 @@ func loadState(vmctx types.VMContext) (cid.Cid, *StorageMinerActorState, ActorEr
 }
 
 func loadMinerInfo(vmctx types.VMContext, m *StorageMinerActorState) (*MinerInfo, ActorError) {
+	mi, _, err := loadMinerInfoExt(vmctx, m)
+	return mi, err
+}
+
+// loadMinerInfoExt loads the miner info along with the extended state
+func loadMinerInfoExt(vmctx types.VMContext, m *StorageMinerActorState) (*MinerInfo, *MinerExtState, ActorError) {
 	var mi MinerInfo
 	if err := vmctx.Storage().Get(m.Info, &mi); err != nil {
+		return nil, nil, err
+	}
+
+	ext, err := loadExt(vmctx, m)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// pending worker changes are applied lazily, the stored info is only
+	// updated when it's next written
+	ext.ApplyWorkerChange(&mi, vmctx.BlockHeight())
+
+	return &mi, ext, nil
+}
+
+func loadExt(vmctx types.VMContext, m *StorageMinerActorState) (*MinerExtState, ActorError) {
+	var ext MinerExtState
+	if !m.Ext.Defined() {
+		return &ext, nil
+	}
+
+	if err := vmctx.Storage().Get(m.Ext, &ext); err != nil {
 		return nil, err
 	}
 
-	return &mi, nil
+	return &ext, nil
+}
+
+// saveExt stores the extended state, unsetting the link to it when it's empty
+func saveExt(vmctx types.VMContext, m *StorageMinerActorState, ext *MinerExtState) ActorError {
+	if ext.empty() {
+		m.Ext = cid.Undef
+		return nil
+	}
+
+	c, err := vmctx.Storage().Put(ext)
+	if err != nil {
+		return err
+	}
+
+	m.Ext = c
+	return nil
 }
 
 func (sma StorageMinerActor) StorageMinerConstructor(act *types.Actor, vmctx types.VMContext, params *StorageMinerConstructorParams) ([]byte, ActorError) {
@@ -351,6 +444,12 @@ func (sma StorageMinerActor) ProveCommitSector(act *types.Actor, vmctx types.VMC
 	}
 	self.Sectors = nssroot
 
+	if vmctx.BlockHeight() >= build.UpgradeSectorExpirationHeight {
+		if err := sma.setDealsExpiration(vmctx, self, params.SectorID, params.DealIDs); err != nil {
+			return nil, err
+		}
+	}
+
 	// if miner is not mining, start their proving period now
 	// Note: As written here, every miners first PoSt will only be over one sector.
 	// We could set up a 'grace period' for starting mining that would allow miners
@@ -468,6 +567,7 @@ func (sma StorageMinerActor) SubmitPoSt(act *types.Actor, vmctx types.VMContext,
 		return nil, aerrors.HandleExternalError(lerr, "could not load proving set node")
 	}
 
+	proven := map[uint64]bool{}
 	var sectorInfos []sectorbuilder.SectorInfo
 	if err := pss.ForEach(func(id uint64, v *cbg.Deferred) error {
 		var comms [][]byte
@@ -484,6 +584,7 @@ func (sma StorageMinerActor) SubmitPoSt(act *types.Actor, vmctx types.VMContext,
 		copy(si.CommR[:], commR)
 
 		sectorInfos = append(sectorInfos, si)
+		proven[id] = true
 
 		return nil
 	}); err != nil {
@@ -523,8 +624,30 @@ func (sma StorageMinerActor) SubmitPoSt(act *types.Actor, vmctx types.VMContext,
 		return nil, aerrors.HandleExternalError(lerr, "could not flush AMT")
 	}
 
+	var expired []uint64
+	if vmctx.BlockHeight() >= build.UpgradeSectorExpirationHeight {
+		expired, err = sma.removeExpiredSectors(vmctx, self)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	faulty := map[uint64]bool{}
+	for _, id := range faults {
+		faulty[id] = true
+	}
+
+	// expired sectors stop contributing power right away. Faulty sectors
+	// already didn't, and sectors not in the proving set didn't yet.
+	var expiredProven uint64
+	for _, id := range expired {
+		if proven[id] && !faulty[id] {
+			expiredProven++
+		}
+	}
+
 	oldPower := self.Power
-	self.Power = types.BigMul(types.NewInt(pss.Count-uint64(len(faults))),
+	self.Power = types.BigMul(types.NewInt(pss.Count-uint64(len(faults))-expiredProven),
 		types.NewInt(mi.SectorSize))
 
 	delta := types.BigSub(self.Power, oldPower)
@@ -566,9 +689,235 @@ func (sma StorageMinerActor) SubmitPoSt(act *types.Actor, vmctx types.VMContext,
 		return nil, err
 	}
 
+	if expiredProven > 0 {
+		// release the collateral held for the power of the expired sectors
+		released := CollateralForPower(types.BigMul(types.NewInt(expiredProven), types.NewInt(mi.SectorSize)))
+		if released.GreaterThan(act.Balance) {
+			released = act.Balance
+		}
+
+		if _, err := vmctx.Send(mi.Owner, 0, released, nil); err != nil {
+			return nil, aerrors.Wrap(err, "failed to release collateral of expired sectors")
+		}
+	}
+
 	return nil, nil
 }
 
+// setDealsExpiration sets the expiration of a new sector, which expires
+// together with the last of its deals
+func (sma StorageMinerActor) setDealsExpiration(vmctx types.VMContext, self *StorageMinerActorState, sectorID uint64, dealIDs []uint64) ActorError {
+	expEnc, err := SerializeParams(&GetLastExpirationFromDealIDsParams{
+		DealIDs: dealIDs,
+	})
+	if err != nil {
+		return aerrors.Wrap(err, "failed to serialize GetLastExpirationFromDealIDsParams")
+	}
+
+	expRet, err := vmctx.Send(StorageMarketAddress, SMAMethods.GetLastExpirationFromDealIDs, types.NewInt(0), expEnc)
+	if err != nil {
+		return aerrors.Wrap(err, "failed to get sector expiration")
+	}
+
+	expiration := types.BigFromBytes(expRet).Uint64()
+	if expiration == 0 {
+		return nil
+	}
+
+	ext, err := loadExt(vmctx, self)
+	if err != nil {
+		return err
+	}
+
+	nexp, err := setSectorExpiration(vmctx.Storage(), ext.SectorExpirations, sectorID, expiration)
+	if err != nil {
+		return err
+	}
+	ext.SectorExpirations = &nexp
+
+	return saveExt(vmctx, self, ext)
+}
+
+// removeExpiredSectors removes the sectors which expired at the current
+// height from the sector set, and returns their IDs
+func (sma StorageMinerActor) removeExpiredSectors(vmctx types.VMContext, self *StorageMinerActorState) ([]uint64, ActorError) {
+	ext, aerr := loadExt(vmctx, self)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if ext.SectorExpirations == nil {
+		return nil, nil
+	}
+
+	exps, err := amt.LoadAMT(types.WrapStorage(vmctx.Storage()), *ext.SectorExpirations)
+	if err != nil {
+		return nil, aerrors.HandleExternalError(err, "could not load sector expirations")
+	}
+
+	var expired []uint64
+	if err := exps.ForEach(func(id uint64, v *cbg.Deferred) error {
+		var expiration uint64
+		if err := cbor.DecodeInto(v.Raw, &expiration); err != nil {
+			return xerrors.Errorf("could not decode expiration of sector %d: %w", id, err)
+		}
+
+		if expiration <= vmctx.BlockHeight() {
+			expired = append(expired, id)
+		}
+		return nil
+	}); err != nil {
+		return nil, aerrors.HandleExternalError(err, "could not iterate sector expirations")
+	}
+
+	if len(expired) == 0 {
+		return nil, nil
+	}
+
+	if err := exps.BatchDelete(expired); err != nil {
+		return nil, aerrors.HandleExternalError(err, "failed to delete expirations")
+	}
+
+	ext.SectorExpirations = nil
+	if exps.Count > 0 {
+		nexp, err := exps.Flush()
+		if err != nil {
+			return nil, aerrors.HandleExternalError(err, "could not flush sector expirations")
+		}
+		ext.SectorExpirations = &nexp
+	}
+
+	if aerr := saveExt(vmctx, self, ext); aerr != nil {
+		return nil, aerr
+	}
+
+	ss, err := amt.LoadAMT(types.WrapStorage(vmctx.Storage()), self.Sectors)
+	if err != nil {
+		return nil, aerrors.HandleExternalError(err, "could not load sector set")
+	}
+
+	if err := ss.BatchDelete(expired); err != nil {
+		return nil, aerrors.HandleExternalError(err, "failed to delete expired sectors")
+	}
+
+	self.Sectors, err = ss.Flush()
+	if err != nil {
+		return nil, aerrors.HandleExternalError(err, "could not flush sector set")
+	}
+
+	return expired, nil
+}
+
+type ExtendSectorExpirationParams struct {
+	SectorID   uint64
+	Expiration uint64
+}
+
+// ExtendSectorExpiration moves the expiration of a sector to a later height
+func (sma StorageMinerActor) ExtendSectorExpiration(act *types.Actor, vmctx types.VMContext, params *ExtendSectorExpirationParams) ([]byte, ActorError) {
+	oldstate, self, err := loadState(vmctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mi, err := loadMinerInfo(vmctx, self)
+	if err != nil {
+		return nil, err
+	}
+
+	if vmctx.Message().From != mi.Worker {
+		return nil, aerrors.New(1, "only the worker may extend sector expiration")
+	}
+
+	found, _, _, err := GetFromSectorSet(vmctx.Context(), vmctx.Storage(), self.Sectors, params.SectorID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, aerrors.New(2, "sector not found in sector set")
+	}
+
+	ext, err := loadExt(vmctx, self)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := GetSectorExpiration(vmctx.Storage(), ext.SectorExpirations, params.SectorID)
+	if err != nil {
+		return nil, err
+	}
+	if current == 0 {
+		return nil, aerrors.New(3, "sector doesn't expire")
+	}
+	if params.Expiration <= current {
+		return nil, aerrors.Newf(4, "new expiration must be after the current one (%d <= %d)", params.Expiration, current)
+	}
+
+	nexp, err := setSectorExpiration(vmctx.Storage(), ext.SectorExpirations, params.SectorID, params.Expiration)
+	if err != nil {
+		return nil, err
+	}
+	ext.SectorExpirations = &nexp
+
+	if err := saveExt(vmctx, self, ext); err != nil {
+		return nil, err
+	}
+
+	nstate, err := vmctx.Storage().Put(self)
+	if err != nil {
+		return nil, err
+	}
+	if err := vmctx.Storage().Commit(oldstate, nstate); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// GetSectorExpiration returns the expiration height of a sector, or 0 if it
+// doesn't expire
+func GetSectorExpiration(s types.Storage, exps *cid.Cid, sectorID uint64) (uint64, ActorError) {
+	if exps == nil {
+		return 0, nil
+	}
+
+	a, err := amt.LoadAMT(types.WrapStorage(s), *exps)
+	if err != nil {
+		return 0, aerrors.HandleExternalError(err, "could not load sector expirations")
+	}
+
+	var expiration uint64
+	if err := a.Get(sectorID, &expiration); err != nil {
+		if _, ok := err.(*amt.ErrNotFound); ok {
+			return 0, nil
+		}
+		return 0, aerrors.HandleExternalError(err, "failed to get sector expiration")
+	}
+
+	return expiration, nil
+}
+
+func setSectorExpiration(s types.Storage, exps *cid.Cid, sectorID uint64, expiration uint64) (cid.Cid, ActorError) {
+	a := amt.NewAMT(types.WrapStorage(s))
+	if exps != nil {
+		var err error
+		a, err = amt.LoadAMT(types.WrapStorage(s), *exps)
+		if err != nil {
+			return cid.Undef, aerrors.HandleExternalError(err, "could not load sector expirations")
+		}
+	}
+
+	if err := a.Set(sectorID, expiration); err != nil {
+		return cid.Undef, aerrors.HandleExternalError(err, "failed to set sector expiration")
+	}
+
+	ncid, err := a.Flush()
+	if err != nil {
+		return cid.Undef, aerrors.HandleExternalError(err, "failed to flush sector expirations")
+	}
+
+	return ncid, nil
+}
+
 func (sma StorageMinerActor) GetPower(act *types.Actor, vmctx types.VMContext, params *struct{}) ([]byte, ActorError) {
 	_, self, err := loadState(vmctx)
 	if err != nil {
@@ -734,6 +1083,104 @@ func (sma StorageMinerActor) UpdatePeerID(act *types.Actor, vmctx types.VMContex
 	return nil, nil
 }
 
+type ChangeWorkerParams struct {
+	NewWorker address.Address
+}
+
+func (sma StorageMinerActor) ChangeWorker(act *types.Actor, vmctx types.VMContext, params *ChangeWorkerParams) ([]byte, ActorError) {
+	oldstate, self, err := loadState(vmctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mi, ext, err := loadMinerInfoExt(vmctx, self)
+	if err != nil {
+		return nil, err
+	}
+
+	if vmctx.Message().From != mi.Owner {
+		return nil, aerrors.New(1, "only the owner may change the worker address")
+	}
+
+	// blocks and election proofs are signed by the worker
+	if params.NewWorker.Protocol() != address.BLS {
+		return nil, aerrors.New(2, "worker address must be a BLS address")
+	}
+
+	ext.WorkerChange = &WorkerKeyChange{
+		NewWorker:   params.NewWorker,
+		EffectiveAt: vmctx.BlockHeight() + build.WorkerKeyChangeDelay,
+	}
+
+	if err := saveMinerInfo(vmctx, oldstate, self, mi, ext); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+type ChangeOwnerParams struct {
+	NewOwner address.Address
+}
+
+// ChangeOwner is called by the owner to nominate a new owner, and then by the
+// nominee with its own address to accept. Nominating the current owner
+// cancels a pending change.
+func (sma StorageMinerActor) ChangeOwner(act *types.Actor, vmctx types.VMContext, params *ChangeOwnerParams) ([]byte, ActorError) {
+	oldstate, self, err := loadState(vmctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mi, ext, err := loadMinerInfoExt(vmctx, self)
+	if err != nil {
+		return nil, err
+	}
+
+	from := vmctx.Message().From
+	switch {
+	case from == mi.Owner:
+		if params.NewOwner == mi.Owner {
+			ext.OwnerChange = nil
+		} else {
+			ext.OwnerChange = &OwnerChange{NewOwner: params.NewOwner}
+		}
+	case ext.OwnerChange != nil && from == ext.OwnerChange.NewOwner && params.NewOwner == from:
+		mi.Owner = from
+		ext.OwnerChange = nil
+	default:
+		return nil, aerrors.New(1, "not authorized to change the owner")
+	}
+
+	if err := saveMinerInfo(vmctx, oldstate, self, mi, ext); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// saveMinerInfo writes the miner info and extended state, and commits the
+// updated miner state
+func saveMinerInfo(vmctx types.VMContext, oldstate cid.Cid, self *StorageMinerActorState, mi *MinerInfo, ext *MinerExtState) ActorError {
+	mic, err := vmctx.Storage().Put(mi)
+	if err != nil {
+		return err
+	}
+
+	self.Info = mic
+
+	if err := saveExt(vmctx, self, ext); err != nil {
+		return err
+	}
+
+	c, err := vmctx.Storage().Put(self)
+	if err != nil {
+		return err
+	}
+
+	return vmctx.Storage().Commit(oldstate, c)
+}
+
 func (sma StorageMinerActor) GetSectorSize(act *types.Actor, vmctx types.VMContext, params *struct{}) ([]byte, ActorError) {
 	_, self, err := loadState(vmctx)
 	if err != nil {
@@ -822,6 +1269,15 @@ func (sma StorageMinerActor) DeclareFaults(act *types.Actor, vmctx types.VMConte
 		return nil, aerr
 	}
 
+	mi, aerr := loadMinerInfo(vmctx, self)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	if vmctx.Message().From != mi.Worker {
+		return nil, aerrors.New(1, "only the miner worker may declare faults")
+	}
+
 	challengeHeight := self.ProvingPeriodEnd - build.PoStChallangeTime
 
 	if vmctx.BlockHeight() < challengeHeight {
@@ -0,0 +1,317 @@
+package actors
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+)
+
+// StorageMinerActorState isn't generated with the other types, its encoding
+// is written by hand so the Ext field can be left out. Miners which never set
+// it keep the encoding (and state root) they had before it was added, which
+// is also what the genesis state contains.
+
+// storageMinerActorStateFields is the number of fields always encoded
+const storageMinerActorStateFields = 11
+
+func (t *StorageMinerActorState) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	fields := byte(storageMinerActorStateFields)
+	if t.Ext.Defined() {
+		fields++
+	}
+	if _, err := w.Write([]byte{128 + fields}); err != nil {
+		return err
+	}
+
+	// t.t.PreCommittedSectors (map[string]*actors.PreCommittedSector) (map)
+	{
+		if err := cbg.CborWriteHeader(w, cbg.MajMap, uint64(len(t.PreCommittedSectors))); err != nil {
+			return err
+		}
+
+		keys := make([]string, 0, len(t.PreCommittedSectors))
+		for k := range t.PreCommittedSectors {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := t.PreCommittedSectors[k]
+
+			if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(k)))); err != nil {
+				return err
+			}
+			if _, err := w.Write([]byte(k)); err != nil {
+				return err
+			}
+
+			if err := v.MarshalCBOR(w); err != nil {
+				return err
+			}
+
+		}
+	}
+
+	// t.t.Sectors (cid.Cid) (struct)
+
+	if err := cbg.WriteCid(w, t.Sectors); err != nil {
+		return xerrors.Errorf("failed to write cid field t.Sectors: %w", err)
+	}
+
+	// t.t.ProvingSet (cid.Cid) (struct)
+
+	if err := cbg.WriteCid(w, t.ProvingSet); err != nil {
+		return xerrors.Errorf("failed to write cid field t.ProvingSet: %w", err)
+	}
+
+	// t.t.Info (cid.Cid) (struct)
+
+	if err := cbg.WriteCid(w, t.Info); err != nil {
+		return xerrors.Errorf("failed to write cid field t.Info: %w", err)
+	}
+
+	// t.t.CurrentFaultSet (types.BitField) (struct)
+	if err := t.CurrentFaultSet.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.t.NextFaultSet (types.BitField) (struct)
+	if err := t.NextFaultSet.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.t.NextDoneSet (types.BitField) (struct)
+	if err := t.NextDoneSet.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.t.Power (types.BigInt) (struct)
+	if err := t.Power.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.t.Active (bool) (bool)
+	if err := cbg.WriteBool(w, t.Active); err != nil {
+		return err
+	}
+
+	// t.t.SlashedAt (uint64) (uint64)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.SlashedAt))); err != nil {
+		return err
+	}
+
+	// t.t.ProvingPeriodEnd (uint64) (uint64)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.ProvingPeriodEnd))); err != nil {
+		return err
+	}
+
+	// t.t.Ext (cid.Cid) (struct), only written when set
+
+	if t.Ext.Defined() {
+		if err := cbg.WriteCid(w, t.Ext); err != nil {
+			return xerrors.Errorf("failed to write cid field t.Ext: %w", err)
+		}
+	}
+	return nil
+}
+
+func (t *StorageMinerActorState) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != storageMinerActorStateFields && extra != storageMinerActorStateFields+1 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+	hasExt := extra == storageMinerActorStateFields+1
+
+	// t.t.PreCommittedSectors (map[string]*actors.PreCommittedSector) (map)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajMap {
+		return fmt.Errorf("expected a map (major type 5)")
+	}
+	if extra > 4096 {
+		return fmt.Errorf("t.PreCommittedSectors: map too large")
+	}
+
+	t.PreCommittedSectors = make(map[string]*PreCommittedSector, extra)
+
+	for i, l := 0, int(extra); i < l; i++ {
+
+		var k string
+
+		{
+			sval, err := cbg.ReadString(br)
+			if err != nil {
+				return err
+			}
+
+			k = string(sval)
+		}
+
+		var v *PreCommittedSector
+
+		{
+
+			pb, err := br.PeekByte()
+			if err != nil {
+				return err
+			}
+			if pb == cbg.CborNull[0] {
+				var nbuf [1]byte
+				if _, err := br.Read(nbuf[:]); err != nil {
+					return err
+				}
+			} else {
+				v = new(PreCommittedSector)
+				if err := v.UnmarshalCBOR(br); err != nil {
+					return err
+				}
+			}
+
+		}
+
+		t.PreCommittedSectors[k] = v
+
+	}
+	// t.t.Sectors (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.Sectors: %w", err)
+		}
+
+		t.Sectors = c
+
+	}
+	// t.t.ProvingSet (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.ProvingSet: %w", err)
+		}
+
+		t.ProvingSet = c
+
+	}
+	// t.t.Info (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.Info: %w", err)
+		}
+
+		t.Info = c
+
+	}
+	// t.t.CurrentFaultSet (types.BitField) (struct)
+
+	{
+
+		if err := t.CurrentFaultSet.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+	}
+	// t.t.NextFaultSet (types.BitField) (struct)
+
+	{
+
+		if err := t.NextFaultSet.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+	}
+	// t.t.NextDoneSet (types.BitField) (struct)
+
+	{
+
+		if err := t.NextDoneSet.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+	}
+	// t.t.Power (types.BigInt) (struct)
+
+	{
+
+		if err := t.Power.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+	}
+	// t.t.Active (bool) (bool)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajOther {
+		return fmt.Errorf("booleans must be major type 7")
+	}
+	switch extra {
+	case 20:
+		t.Active = false
+	case 21:
+		t.Active = true
+	default:
+		return fmt.Errorf("booleans are either major type 7, value 20 or 21 (got %d)", extra)
+	}
+	// t.t.SlashedAt (uint64) (uint64)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajUnsignedInt {
+		return fmt.Errorf("wrong type for uint64 field")
+	}
+	t.SlashedAt = uint64(extra)
+	// t.t.ProvingPeriodEnd (uint64) (uint64)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajUnsignedInt {
+		return fmt.Errorf("wrong type for uint64 field")
+	}
+	t.ProvingPeriodEnd = uint64(extra)
+	// t.t.Ext (cid.Cid) (struct)
+
+	t.Ext = cid.Undef
+	if hasExt {
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.Ext: %w", err)
+		}
+
+		t.Ext = c
+
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+// +build mockproofs
+
+package actors_test
+
+import (
+	"context"
+	"testing"
+
+	amt "github.com/filecoin-project/go-amt-ipld"
+	hamt "github.com/ipfs/go-hamt-ipld"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/lotus/build"
+	. "github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/address"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/sectorbuilder"
+)
+
+// cheatMinerSector adds a proven sector expiring at the given height to the
+// miner, skipping the precommit and commit flow
+func cheatMinerSector(t *testing.T, h *Harness, minerAddr address.Address, sectorID uint64, expiration uint64) {
+	t.Helper()
+	ctx := context.TODO()
+	bs := h.cs.Blockstore()
+	cst := hamt.CSTFromBstore(bs)
+
+	act, mstate := loadMinerState(t, h, minerAddr)
+
+	ss, err := amt.LoadAMT(amt.WrapBlockstore(bs), mstate.Sectors)
+	require.NoError(t, err)
+	require.NoError(t, ss.Set(sectorID, [][]byte{make([]byte, 32), make([]byte, 32)}))
+	mstate.Sectors, err = ss.Flush()
+	require.NoError(t, err)
+	mstate.ProvingSet = mstate.Sectors
+
+	exps := amt.NewAMT(amt.WrapBlockstore(bs))
+	require.NoError(t, exps.Set(sectorID, expiration))
+	expc, err := exps.Flush()
+	require.NoError(t, err)
+
+	mstate.Ext, err = cst.Put(ctx, &MinerExtState{SectorExpirations: &expc})
+	require.NoError(t, err)
+
+	act.Head, err = cst.Put(ctx, mstate)
+	require.NoError(t, err)
+	require.NoError(t, h.vm.StateTree().SetActor(minerAddr, act))
+}
+
+// mockPoSt builds a PoSt of the miner's proving set at the given height,
+// which is accepted in mockproofs builds
+func mockPoSt(t *testing.T, h *Harness, minerAddr address.Address, height uint64) *SubmitPoStParams {
+	t.Helper()
+
+	_, mstate := loadMinerState(t, h, minerAddr)
+
+	end, _ := ProvingPeriodEnd(mstate.ProvingPeriodEnd, height)
+	rand, err := fakeRand{}.GetRandomness(context.TODO(), int64(end-build.PoStChallangeTime-build.PoStRandomnessLookback))
+	require.NoError(t, err)
+
+	var seed [sectorbuilder.CommLen]byte
+	copy(seed[:], rand)
+
+	pss, err := amt.LoadAMT(amt.WrapBlockstore(h.cs.Blockstore()), mstate.ProvingSet)
+	require.NoError(t, err)
+
+	var sectorInfos []sectorbuilder.SectorInfo
+	err = pss.ForEach(func(id uint64, v *cbg.Deferred) error {
+		var comms [][]byte
+		if err := cbor.DecodeInto(v.Raw, &comms); err != nil {
+			return err
+		}
+
+		si := sectorbuilder.SectorInfo{SectorID: id}
+		copy(si.CommR[:], comms[0])
+		sectorInfos = append(sectorInfos, si)
+		return nil
+	})
+	require.NoError(t, err)
+
+	proof := sectorbuilder.MockPoStProof(sectorbuilder.NewSortedSectorInfo(sectorInfos), seed, mstate.CurrentFaultSet.All())
+	return &SubmitPoStParams{Proof: proof, DoneSet: types.NewBitField()}
+}
+
+func TestMinerSectorExpiration(t *testing.T) {
+	var ownerAddr, workerAddr address.Address
+
+	h := NewHarness(t,
+		HarnessAddr(&ownerAddr, 1000000000),
+		HarnessAddr(&workerAddr, 100000),
+	)
+
+	sectorSize := build.SectorSizes[0]
+	collateral := CollateralForPower(types.NewInt(sectorSize))
+
+	var minerAddr address.Address
+	{
+		cheatStorageMarketTotal(t, h.vm, h.cs.Blockstore())
+
+		ret, _ := h.InvokeWithValue(t, ownerAddr, StoragePowerAddress, SPAMethods.CreateStorageMiner,
+			types.BigAdd(collateral, types.NewInt(500000)),
+			&CreateStorageMinerParams{
+				Owner:      ownerAddr,
+				Worker:     workerAddr,
+				SectorSize: sectorSize,
+				PeerID:     "fakepeerid",
+			})
+		ApplyOK(t, ret)
+		var err error
+		minerAddr, err = address.NewFromBytes(ret.Return)
+		assert.NoError(t, err)
+	}
+
+	pp := build.ProvingPeriodDuration
+	start := (build.UpgradeSectorExpirationHeight/pp + 1) * pp
+
+	h.vm.SetBlockHeight(start + 10)
+	cheatMinerSector(t, h, minerAddr, 1, start+pp)
+
+	// first PoSt is late as the miner never set its proving period
+	ret, _ := h.InvokeWithValue(t, workerAddr, minerAddr, MAMethods.SubmitPoSt, types.NewInt(1000), mockPoSt(t, h, minerAddr, start+10))
+	ApplyOK(t, ret)
+
+	_, mstate := loadMinerState(t, h, minerAddr)
+	assert.Equal(t, types.NewInt(sectorSize).String(), mstate.Power.String(), "sector not expired yet")
+
+	ownerBalance, err := h.vm.ActorBalance(ownerAddr)
+	require.NoError(t, err)
+
+	h.vm.SetBlockHeight(start + pp + 10)
+	ret, _ = h.Invoke(t, workerAddr, minerAddr, MAMethods.SubmitPoSt, mockPoSt(t, h, minerAddr, start+pp+10))
+	ApplyOK(t, ret)
+
+	_, mstate = loadMinerState(t, h, minerAddr)
+	assert.Equal(t, "0", mstate.Power.String(), "expired sector still has power")
+	assert.False(t, mstate.Ext.Defined(), "expiration not removed")
+
+	ss, err := amt.LoadAMT(amt.WrapBlockstore(h.cs.Blockstore()), mstate.Sectors)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), ss.Count, "expired sector not removed")
+
+	h.AssertBalance(t, ownerAddr, types.BigAdd(ownerBalance, collateral).Uint64())
+}
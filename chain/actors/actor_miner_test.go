@@ -0,0 +1,248 @@
+package actors_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/ipfs/go-car"
+	"github.com/ipfs/go-datastore"
+	hamt "github.com/ipfs/go-hamt-ipld"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/build"
+	. "github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/address"
+	"github.com/filecoin-project/lotus/chain/state"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func TestMinerChangeWorkerAndOwner(t *testing.T) {
+	var ownerAddr, workerAddr, newOwnerAddr address.Address
+
+	h := NewHarness(t,
+		HarnessAddr(&ownerAddr, 1000000),
+		HarnessAddr(&workerAddr, 100000),
+		HarnessAddr(&newOwnerAddr, 100000),
+	)
+
+	var minerAddr address.Address
+	{
+		cheatStorageMarketTotal(t, h.vm, h.cs.Blockstore())
+
+		ret, _ := h.InvokeWithValue(t, ownerAddr, StoragePowerAddress, SPAMethods.CreateStorageMiner,
+			types.NewInt(500000),
+			&CreateStorageMinerParams{
+				Owner:      ownerAddr,
+				Worker:     workerAddr,
+				SectorSize: build.SectorSizes[0],
+				PeerID:     "fakepeerid",
+			})
+		ApplyOK(t, ret)
+		var err error
+		minerAddr, err = address.NewFromBytes(ret.Return)
+		assert.NoError(t, err)
+	}
+
+	getAddr := func(method uint64) address.Address {
+		ret, _ := h.Invoke(t, ownerAddr, minerAddr, method, nil)
+		ApplyOK(t, ret)
+		a, err := address.NewFromBytes(ret.Return)
+		assert.NoError(t, err)
+		return a
+	}
+
+	newWorker, err := h.w.GenerateKey(types.KTBLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if build.UpgradeMinerKeyChangeHeight > 0 {
+		h.vm.SetBlockHeight(build.UpgradeMinerKeyChangeHeight - 1)
+		ret, _ := h.Invoke(t, ownerAddr, minerAddr, MAMethods.ChangeWorker, &ChangeWorkerParams{NewWorker: newWorker})
+		assert.Equal(t, byte(255), ret.ExitCode, "worker changes aren't enabled before the upgrade")
+	}
+	start := build.UpgradeMinerKeyChangeHeight
+	h.vm.SetBlockHeight(start)
+
+	{
+		ret, _ := h.Invoke(t, workerAddr, minerAddr, MAMethods.ChangeWorker, &ChangeWorkerParams{NewWorker: newWorker})
+		assert.Equal(t, byte(1), ret.ExitCode, "only the owner may change the worker")
+
+		ret, _ = h.Invoke(t, ownerAddr, minerAddr, MAMethods.ChangeWorker, &ChangeWorkerParams{NewWorker: newOwnerAddr})
+		assert.Equal(t, byte(2), ret.ExitCode, "worker must be a BLS address")
+
+		ret, _ = h.Invoke(t, ownerAddr, minerAddr, MAMethods.ChangeWorker, &ChangeWorkerParams{NewWorker: newWorker})
+		ApplyOK(t, ret)
+	}
+
+	assert.Equal(t, workerAddr, getAddr(MAMethods.GetWorkerAddr), "worker changed before the delay")
+
+	h.vm.SetBlockHeight(start + build.WorkerKeyChangeDelay)
+	assert.Equal(t, newWorker, getAddr(MAMethods.GetWorkerAddr), "worker not changed after the delay")
+
+	{
+		ret, _ := h.Invoke(t, newOwnerAddr, minerAddr, MAMethods.ChangeOwner, &ChangeOwnerParams{NewOwner: newOwnerAddr})
+		assert.Equal(t, byte(1), ret.ExitCode, "owner change must be nominated by the owner")
+
+		ret, _ = h.Invoke(t, ownerAddr, minerAddr, MAMethods.ChangeOwner, &ChangeOwnerParams{NewOwner: newOwnerAddr})
+		ApplyOK(t, ret)
+		assert.Equal(t, ownerAddr, getAddr(MAMethods.GetOwner), "owner changed before accepting")
+
+		ret, _ = h.Invoke(t, newOwnerAddr, minerAddr, MAMethods.ChangeOwner, &ChangeOwnerParams{NewOwner: newOwnerAddr})
+		ApplyOK(t, ret)
+		assert.Equal(t, newOwnerAddr, getAddr(MAMethods.GetOwner), "owner not changed after accepting")
+	}
+}
+
+func TestMinerExtendSectorExpiration(t *testing.T) {
+	var ownerAddr, workerAddr address.Address
+
+	h := NewHarness(t,
+		HarnessAddr(&ownerAddr, 1000000),
+		HarnessAddr(&workerAddr, 100000),
+	)
+
+	var minerAddr address.Address
+	{
+		cheatStorageMarketTotal(t, h.vm, h.cs.Blockstore())
+
+		ret, _ := h.InvokeWithValue(t, ownerAddr, StoragePowerAddress, SPAMethods.CreateStorageMiner,
+			types.NewInt(500000),
+			&CreateStorageMinerParams{
+				Owner:      ownerAddr,
+				Worker:     workerAddr,
+				SectorSize: build.SectorSizes[0],
+				PeerID:     "fakepeerid",
+			})
+		ApplyOK(t, ret)
+		var err error
+		minerAddr, err = address.NewFromBytes(ret.Return)
+		assert.NoError(t, err)
+	}
+
+	h.vm.SetBlockHeight(build.UpgradeSectorExpirationHeight)
+
+	params := &ExtendSectorExpirationParams{SectorID: 1, Expiration: build.UpgradeSectorExpirationHeight + 1000}
+
+	ret, _ := h.Invoke(t, ownerAddr, minerAddr, MAMethods.ExtendSectorExpiration, params)
+	assert.Equal(t, byte(1), ret.ExitCode, "only the worker may extend sectors")
+
+	ret, _ = h.Invoke(t, workerAddr, minerAddr, MAMethods.ExtendSectorExpiration, params)
+	assert.Equal(t, byte(2), ret.ExitCode, "sector must exist")
+}
+
+func TestMinerDeclareFaults(t *testing.T) {
+	var ownerAddr, workerAddr address.Address
+
+	h := NewHarness(t,
+		HarnessAddr(&ownerAddr, 1000000),
+		HarnessAddr(&workerAddr, 100000),
+	)
+
+	var minerAddr address.Address
+	{
+		cheatStorageMarketTotal(t, h.vm, h.cs.Blockstore())
+
+		ret, _ := h.InvokeWithValue(t, ownerAddr, StoragePowerAddress, SPAMethods.CreateStorageMiner,
+			types.NewInt(500000),
+			&CreateStorageMinerParams{
+				Owner:      ownerAddr,
+				Worker:     workerAddr,
+				SectorSize: build.SectorSizes[0],
+				PeerID:     "fakepeerid",
+			})
+		ApplyOK(t, ret)
+		var err error
+		minerAddr, err = address.NewFromBytes(ret.Return)
+		assert.NoError(t, err)
+	}
+
+	params := &DeclareFaultsParams{Faults: types.BitFieldFromSet([]uint64{3, 5})}
+
+	if build.UpgradeDeclareFaultsHeight > 0 {
+		h.vm.SetBlockHeight(build.UpgradeDeclareFaultsHeight - 1)
+		ret, _ := h.Invoke(t, workerAddr, minerAddr, MAMethods.DeclareFaults, params)
+		assert.Equal(t, byte(255), ret.ExitCode, "faults can't be declared before the upgrade")
+	}
+	h.vm.SetBlockHeight(build.UpgradeDeclareFaultsHeight)
+
+	ret, _ := h.Invoke(t, ownerAddr, minerAddr, MAMethods.DeclareFaults, params)
+	assert.Equal(t, byte(1), ret.ExitCode, "only the worker may declare faults")
+
+	_, mstate := loadMinerState(t, h, minerAddr)
+	assert.Empty(t, append(mstate.CurrentFaultSet.All(), mstate.NextFaultSet.All()...))
+
+	ret, _ = h.Invoke(t, workerAddr, minerAddr, MAMethods.DeclareFaults, params)
+	ApplyOK(t, ret)
+
+	_, mstate = loadMinerState(t, h, minerAddr)
+	assert.Equal(t, []uint64{3, 5}, append(mstate.CurrentFaultSet.All(), mstate.NextFaultSet.All()...))
+}
+
+func loadMinerState(t *testing.T, h *Harness, minerAddr address.Address) (*types.Actor, *StorageMinerActorState) {
+	t.Helper()
+
+	act, err := h.vm.StateTree().GetActor(minerAddr)
+	require.NoError(t, err)
+
+	var mstate StorageMinerActorState
+	require.NoError(t, hamt.CSTFromBstore(h.cs.Blockstore()).Get(context.TODO(), act.Head, &mstate))
+	return act, &mstate
+}
+
+// TestGenesisMinerState checks the miners in the devnet genesis still decode,
+// and encode to the same state
+func TestGenesisMinerState(t *testing.T) {
+	ctx := context.Background()
+
+	f, err := os.Open("../../build/genesis/devnet.car")
+	require.NoError(t, err)
+	defer f.Close()
+
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	header, err := car.LoadCar(bs, f)
+	require.NoError(t, err)
+
+	blk, err := bs.Get(header.Roots[0])
+	require.NoError(t, err)
+	genesis, err := types.DecodeBlock(blk.RawData())
+	require.NoError(t, err)
+
+	cst := hamt.CSTFromBstore(bs)
+	st, err := state.LoadStateTree(cst, genesis.ParentStateRoot)
+	require.NoError(t, err)
+	root, err := hamt.LoadNode(ctx, cst, genesis.ParentStateRoot)
+	require.NoError(t, err)
+
+	miners := 0
+	err = root.ForEach(ctx, func(k string, _ interface{}) error {
+		addr, err := address.NewFromBytes([]byte(k))
+		require.NoError(t, err)
+		act, err := st.GetActor(addr)
+		require.NoError(t, err)
+		if act.Code != StorageMinerCodeCid {
+			return nil
+		}
+		miners++
+
+		var mas StorageMinerActorState
+		require.NoError(t, cst.Get(ctx, act.Head, &mas))
+		assert.False(t, mas.Ext.Defined())
+
+		head, err := cst.Put(ctx, &mas)
+		require.NoError(t, err)
+		assert.Equal(t, act.Head, head, "miner state encoding changed")
+
+		var mi MinerInfo
+		require.NoError(t, cst.Get(ctx, mas.Info, &mi))
+		info, err := cst.Put(ctx, &mi)
+		require.NoError(t, err)
+		assert.Equal(t, mas.Info, info, "miner info encoding changed")
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotZero(t, miners)
+}
@@ -3,6 +3,7 @@ package actors
 import (
 	"github.com/ipfs/go-cid"
 
+	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/actors/aerrors"
 	"github.com/filecoin-project/lotus/chain/address"
 	"github.com/filecoin-project/lotus/chain/types"
@@ -24,7 +25,38 @@ type MultiSigActorState struct {
 	Transactions []MTransaction
 }
 
+// AvailableBalance returns how much of the balance can be spent at the given
+// height. From build.UpgradeMultisigVestingHeight, the part of the initial
+// balance which didn't vest yet is locked. Before it, the whole balance can be
+// spent at once, see canSpend.
+func (msas MultiSigActorState) AvailableBalance(balance types.BigInt, height uint64) types.BigInt {
+	if height < build.UpgradeMultisigVestingHeight || msas.UnlockDuration == 0 {
+		return balance
+	}
+
+	locked := msas.InitialBalance
+	if height >= msas.StartingBlock {
+		offset := height - msas.StartingBlock
+		if offset >= msas.UnlockDuration {
+			return balance
+		}
+
+		unlocked := types.BigDiv(types.BigMul(msas.InitialBalance, types.NewInt(offset)), types.NewInt(msas.UnlockDuration))
+		locked = types.BigSub(msas.InitialBalance, unlocked)
+	}
+
+	if balance.LessThan(locked) {
+		return types.NewInt(0)
+	}
+	return types.BigSub(balance, locked)
+}
+
 func (msas MultiSigActorState) canSpend(act *types.Actor, amnt types.BigInt, height uint64) bool {
+	if height >= build.UpgradeMultisigVestingHeight {
+		return !msas.AvailableBalance(act.Balance, height).LessThan(amnt)
+	}
+
+	// the original rule only limits the balance left after the spend
 	if msas.UnlockDuration == 0 {
 		return true
 	}
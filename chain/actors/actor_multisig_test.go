@@ -1,12 +1,16 @@
 package actors_test
 
 import (
+	"context"
 	"testing"
 
+	hamt "github.com/ipfs/go-hamt-ipld"
 	cbor "github.com/ipfs/go-ipld-cbor"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	cbg "github.com/whyrusleeping/cbor-gen"
 
+	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/actors"
 	"github.com/filecoin-project/lotus/chain/address"
 	"github.com/filecoin-project/lotus/chain/types"
@@ -95,3 +99,77 @@ func TestMultiSigOps(t *testing.T) {
 	}
 
 }
+
+// TestMultiSigAvailableBalance checks the balance reported by
+// MsigGetAvailableBalance is exactly what the actor lets signers spend
+func TestMultiSigAvailableBalance(t *testing.T) {
+	var creatorAddr, outsideAddr address.Address
+	h := NewHarness(t,
+		HarnessAddr(&creatorAddr, 100000),
+		HarnessAddr(&outsideAddr, 100000),
+	)
+
+	const unlockDuration = 300
+	initialBalance := types.NewInt(1000)
+
+	newMsig := func() address.Address {
+		ret, _ := h.Apply(t, types.Message{
+			To:     actors.InitAddress,
+			From:   creatorAddr,
+			Method: actors.IAMethods.Exec,
+			Params: DumpObject(t, &actors.ExecParams{
+				Code: actors.MultisigCodeCid,
+				Params: DumpObject(t, &actors.MultiSigConstructorParams{
+					Signers:        []address.Address{creatorAddr},
+					Required:       1,
+					UnlockDuration: unlockDuration,
+				}),
+			}),
+			GasPrice: types.NewInt(1),
+			GasLimit: types.NewInt(testGasLimit),
+			Value:    initialBalance,
+		})
+		ApplyOK(t, ret)
+		addr, err := address.NewFromBytes(ret.Return)
+		require.NoError(t, err)
+		return addr
+	}
+
+	available := func(addr address.Address, height uint64) types.BigInt {
+		act, err := h.vm.StateTree().GetActor(addr)
+		require.NoError(t, err)
+		var st actors.MultiSigActorState
+		require.NoError(t, hamt.CSTFromBstore(h.cs.Blockstore()).Get(context.TODO(), act.Head, &st))
+		return st.AvailableBalance(act.Balance, height)
+	}
+
+	spend := func(addr address.Address, amount types.BigInt) uint8 {
+		ret, _ := h.Invoke(t, creatorAddr, addr, actors.MultiSigMethods.Propose, &actors.MultiSigProposeParams{
+			To:    outsideAddr,
+			Value: amount,
+		})
+		return ret.ExitCode
+	}
+
+	if build.UpgradeMultisigVestingHeight > 0 {
+		h.vm.SetBlockHeight(build.UpgradeMultisigVestingHeight - unlockDuration)
+		msig := newMsig()
+		h.vm.SetBlockHeight(build.UpgradeMultisigVestingHeight - 1)
+		assert.Equal(t, initialBalance.String(), available(msig, build.UpgradeMultisigVestingHeight-1).String(), "nothing is locked before the upgrade")
+		assert.Equal(t, uint8(0), spend(msig, initialBalance))
+	}
+
+	start := build.UpgradeMultisigVestingHeight + 1
+	for _, offset := range []uint64{0, 1, 2, 3, 150, 299, 300, 301} {
+		h.vm.SetBlockHeight(start)
+		msig := newMsig()
+		h.vm.SetBlockHeight(start + offset)
+
+		avail := available(msig, start+offset)
+		if avail.LessThan(initialBalance) {
+			assert.Equal(t, uint8(100), spend(msig, types.BigAdd(avail, types.NewInt(1))), "offset %d: spent more than available", offset)
+		}
+		assert.Equal(t, uint8(0), spend(msig, avail), "offset %d: couldn't spend the available balance", offset)
+		assert.Equal(t, "0", available(msig, start+offset).String(), "offset %d", offset)
+	}
+}
@@ -45,7 +45,7 @@ func (sma StorageMarketActor) Exports() []interface{} {
 		// 7: sma.SettleExpiredDeals,
 		// 8: sma.ProcessStorageDealsPayment,
 		// 9: sma.SlashStorageDealCollateral,
-		// 10: sma.GetLastExpirationFromDealIDs,
+		10: sma.GetLastExpirationFromDealIDs,
 		11: sma.ActivateStorageDeals, // TODO: move under PublishStorageDeals after specs team approves
 		12: sma.ComputeDataCommitment,
 	}
@@ -646,8 +646,46 @@ func (sma StorageMarketActor) SettleExpiredDeals(act *types.Actor, vmctx types.V
 func (sma StorageMarketActor) SlashStorageDealCollateral(act *types.Actor, vmctx types.VMContext, params *struct{}) ([]byte, ActorError) {
 
 }
+*/
+
+type GetLastExpirationFromDealIDsParams struct {
+	DealIDs []uint64
+}
+
+// GetLastExpirationFromDealIDs returns the height at which the last of the
+// given deals expires. Deals which aren't active yet are assumed to be
+// activated at the current height.
+func (sma StorageMarketActor) GetLastExpirationFromDealIDs(act *types.Actor, vmctx types.VMContext, params *GetLastExpirationFromDealIDsParams) ([]byte, ActorError) {
+	var self StorageMarketState
+	old := vmctx.Storage().GetHead()
+	if err := vmctx.Storage().Get(old, &self); err != nil {
+		return nil, err
+	}
+
+	deals, err := amt.LoadAMT(types.WrapStorage(vmctx.Storage()), self.Deals)
+	if err != nil {
+		return nil, aerrors.HandleExternalError(err, "loading deals amt")
+	}
+
+	var last uint64
+	for _, deal := range params.DealIDs {
+		var dealInfo OnChainDeal
+		if err := deals.Get(deal, &dealInfo); err != nil {
+			if _, is := err.(*amt.ErrNotFound); is {
+				return nil, aerrors.New(1, "deal not found")
+			}
+			return nil, aerrors.HandleExternalError(err, "getting deal info failed")
+		}
 
-func (sma StorageMarketActor) GetLastExpirationFromDealIDs(act *types.Actor, vmctx types.VMContext, params *struct{}) ([]byte, ActorError) {
+		start := dealInfo.ActivationEpoch
+		if start == 0 {
+			start = vmctx.BlockHeight()
+		}
 
+		if exp := start + dealInfo.Deal.Proposal.Duration; exp > last {
+			last = exp
+		}
+	}
+
+	return types.NewInt(last).Bytes(), nil
 }
-*/
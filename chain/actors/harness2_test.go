@@ -3,6 +3,7 @@ package actors_test
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"testing"
 
 	"github.com/ipfs/go-cid"
@@ -57,6 +58,14 @@ type Harness struct {
 
 var HarnessMinerFunds = types.NewInt(1000000)
 
+type fakeRand struct{}
+
+func (fakeRand) GetRandomness(ctx context.Context, h int64) ([]byte, error) {
+	out := make([]byte, 32)
+	binary.BigEndian.PutUint64(out, uint64(h))
+	return out, nil
+}
+
 func HarnessAddr(addr *address.Address, value uint64) HarnessOpt {
 	return func(t testing.TB, h *Harness) error {
 		if h.Stage != HarnessPreInit {
@@ -158,7 +167,7 @@ func NewHarness(t *testing.T, options ...HarnessOpt) *Harness {
 		t.Fatal(err)
 	}
 	h.cs = store.NewChainStore(h.bs, nil)
-	h.vm, err = vm.NewVM(stateroot, 1, nil, h.HI.Miner, h.cs.Blockstore())
+	h.vm, err = vm.NewVM(stateroot, 1, fakeRand{}, h.HI.Miner, h.cs.Blockstore())
 	if err != nil {
 		t.Fatal(err)
 	}
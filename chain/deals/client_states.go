@@ -50,7 +50,7 @@ func (c *Client) new(ctx context.Context, deal ClientDeal) (func(*ClientDeal), e
 
 	/* data transfer happens */
 	if resp.State != api.DealAccepted {
-		return nil, xerrors.Errorf("deal wasn't accepted (State=%d)", resp.State)
+		return nil, xerrors.Errorf("deal wasn't accepted (State=%d): %s", resp.State, resp.Message)
 	}
 
 	return func(info *ClientDeal) {
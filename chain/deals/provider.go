@@ -45,6 +45,8 @@ type Provider struct {
 	ask   *types.SignedStorageAsk
 	askLk sync.Mutex
 
+	policy DealPolicy
+
 	secb   *sectorblocks.SectorBlocks
 	sminer *storage.Miner
 	full   api.FullNode
@@ -61,6 +63,11 @@ type Provider struct {
 
 	conns map[cid.Cid]inet.Stream
 
+	// bytes of deal data received so far, by proposal cid, for deals with
+	// data transfers in progress
+	transferLk  sync.Mutex
+	transferred map[cid.Cid]uint64
+
 	actor address.Address
 
 	incoming chan MinerDeal
@@ -81,7 +88,7 @@ var (
 	ErrDataTransferFailed = errors.New("Deal data transfer failed")
 )
 
-func NewProvider(ds dtypes.MetadataDS, sminer *storage.Miner, secb *sectorblocks.SectorBlocks, dag dtypes.StagingDAG, dataTransfer dtypes.ProviderDataTransfer, fullNode api.FullNode) (*Provider, error) {
+func NewProvider(ds dtypes.MetadataDS, sminer *storage.Miner, secb *sectorblocks.SectorBlocks, dag dtypes.StagingDAG, dataTransfer dtypes.ProviderDataTransfer, fullNode api.FullNode, policy DealPolicy) (*Provider, error) {
 	addr, err := ds.Get(datastore.NewKey("miner-address"))
 	if err != nil {
 		return nil, err
@@ -97,11 +104,13 @@ func NewProvider(ds dtypes.MetadataDS, sminer *storage.Miner, secb *sectorblocks
 		dataTransfer: dataTransfer,
 		full:         fullNode,
 		secb:         secb,
+		policy:       policy,
 
 		pricePerByteBlock: types.NewInt(3), // TODO: allow setting
 		minPieceSize:      256,             // TODO: allow setting (BUT KEEP MIN 256! (because of how we fill sectors up))
 
-		conns: map[cid.Cid]inet.Stream{},
+		conns:       map[cid.Cid]inet.Stream{},
+		transferred: map[cid.Cid]uint64{},
 
 		incoming: make(chan MinerDeal),
 		updated:  make(chan minerDealUpdate),
@@ -223,6 +232,11 @@ func (p *Provider) onDataTransferEvent(event datatransfer.Event, channelState da
 	var next api.DealState
 	var err error
 	switch event {
+	case datatransfer.Progress:
+		p.transferLk.Lock()
+		p.transferred[voucher.Proposal] = channelState.Received()
+		p.transferLk.Unlock()
+		return
 	case datatransfer.Complete:
 		next = api.DealStaged
 		err = nil
@@ -230,10 +244,14 @@ func (p *Provider) onDataTransferEvent(event datatransfer.Event, channelState da
 		next = api.DealFailed
 		err = ErrDataTransferFailed
 	default:
-		// the only events we care about are complete and error
+		// the only events affecting deal state are complete and error
 		return
 	}
 
+	p.transferLk.Lock()
+	delete(p.transferred, voucher.Proposal)
+	p.transferLk.Unlock()
+
 	select {
 	case p.updated <- minerDealUpdate{
 		newState: next,
@@ -283,6 +301,32 @@ func (p *Provider) HandleStream(s inet.Stream) {
 	p.incoming <- deal
 }
 
+// ListDeals returns the deals tracked by the provider
+func (p *Provider) ListDeals() ([]MinerDeal, error) {
+	var out []MinerDeal
+	if err := p.deals.List(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (p *Provider) GetDeal(proposal cid.Cid) (*MinerDeal, error) {
+	var out MinerDeal
+	if err := p.deals.Get(proposal, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Transferred returns how much data was received for the deal, while the data
+// transfer is in progress
+func (p *Provider) Transferred(proposal cid.Cid) uint64 {
+	p.transferLk.Lock()
+	defer p.transferLk.Unlock()
+
+	return p.transferred[proposal]
+}
+
 func (p *Provider) Stop() {
 	close(p.stop)
 	<-p.stopped
@@ -41,6 +41,11 @@ func (p *Provider) SetPrice(price types.BigInt, ttlsecs int64) error {
 	return p.saveAsk(ssa)
 }
 
+// GetAsk returns the current storage ask of the provider
+func (p *Provider) GetAsk() *types.SignedStorageAsk {
+	return p.getAsk(p.actor)
+}
+
 func (p *Provider) getAsk(m address.Address) *types.SignedStorageAsk {
 	p.askLk.Lock()
 	defer p.askLk.Unlock()
@@ -143,9 +148,9 @@ func (p *Provider) saveAsk(a *types.SignedStorageAsk) error {
 }
 
 func (c *Client) checkAskSignature(ask *types.SignedStorageAsk) error {
-	tss := c.sm.ChainStore().GetHeaviestTipSet().ParentState()
+	ts := c.sm.ChainStore().GetHeaviestTipSet()
 
-	w, err := stmgr.GetMinerWorkerRaw(context.TODO(), c.sm, tss, ask.Ask.Miner)
+	w, err := stmgr.GetMinerWorkerRaw(context.TODO(), c.sm, ts.ParentState(), ts.Height(), ask.Ask.Miner)
 	if err != nil {
 		return xerrors.Errorf("failed to get worker for miner in ask", err)
 	}
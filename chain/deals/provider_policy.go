@@ -0,0 +1,182 @@
+package deals
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/address"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// dealFilterTimeout is how long the external deal filter can take to decide
+const dealFilterTimeout = 30 * time.Second
+
+// DealPolicy decides which storage deal proposals the provider accepts, on
+// top of the checks against the storage ask. Zero values disable the
+// corresponding check.
+type DealPolicy struct {
+	// MinPricePerGiBEpoch is the minimum price per GiB per epoch, in attoFIL
+	MinPricePerGiBEpoch types.BigInt
+
+	// MinDuration and MaxDuration bound the deal duration, in epochs
+	MinDuration uint64
+	MaxDuration uint64
+
+	MaxPieceSize uint64
+
+	// AllowedClients, if not empty, are the only clients deals are accepted from
+	AllowedClients []address.Address
+	BlockedClients []address.Address
+
+	// FilterCommand is run through the shell for each proposal, with a
+	// DealFilterInput as JSON on stdin. The deal is accepted if it exits with
+	// status 0, its output is sent to the client as the rejection reason
+	// otherwise.
+	FilterCommand string
+}
+
+// PolicyStateAPI resolves the client allow and block lists, which may hold
+// any address of an actor, to ID addresses
+type PolicyStateAPI interface {
+	StateLookupID(context.Context, address.Address, *types.TipSet) (address.Address, error)
+}
+
+// DealFilterInput is passed to the external deal filter
+type DealFilterInput struct {
+	ProposalCid cid.Cid
+	ClientPeer  peer.ID
+	Proposal    actors.StorageDealProposal
+}
+
+// rejectionError is returned when the provider refuses a proposal, the
+// client is notified with a DealRejected response
+type rejectionError struct {
+	reason string
+}
+
+func (e *rejectionError) Error() string {
+	return e.reason
+}
+
+func rejectf(format string, args ...interface{}) error {
+	return &rejectionError{reason: fmt.Sprintf(format, args...)}
+}
+
+func isRejection(err error) bool {
+	var rerr *rejectionError
+	return xerrors.As(err, &rerr)
+}
+
+// Check returns a rejection error if the deal doesn't satisfy the policy
+func (dp *DealPolicy) Check(ctx context.Context, sapi PolicyStateAPI, deal *MinerDeal) error {
+	proposal := &deal.Proposal
+
+	if !dp.MinPricePerGiBEpoch.Nil() {
+		minPrice := types.BigDiv(types.BigMul(dp.MinPricePerGiBEpoch, types.NewInt(proposal.PieceSize)), types.NewInt(1<<30))
+		if proposal.StoragePricePerEpoch.LessThan(minPrice) {
+			return rejectf("storage price per epoch less than minimum price: %s < %s", proposal.StoragePricePerEpoch, minPrice)
+		}
+	}
+
+	if dp.MinDuration != 0 && proposal.Duration < dp.MinDuration {
+		return rejectf("deal duration too short: %d < %d", proposal.Duration, dp.MinDuration)
+	}
+	if dp.MaxDuration != 0 && proposal.Duration > dp.MaxDuration {
+		return rejectf("deal duration too long: %d > %d", proposal.Duration, dp.MaxDuration)
+	}
+
+	if dp.MaxPieceSize != 0 && proposal.PieceSize > dp.MaxPieceSize {
+		return rejectf("piece size more than maximum allowed size: %d > %d", proposal.PieceSize, dp.MaxPieceSize)
+	}
+
+	if err := dp.checkClient(ctx, sapi, proposal.Client); err != nil {
+		return err
+	}
+
+	if dp.FilterCommand != "" {
+		return dp.runFilter(ctx, deal)
+	}
+
+	return nil
+}
+
+func (dp *DealPolicy) runFilter(ctx context.Context, deal *MinerDeal) error {
+	in, err := json.Marshal(&DealFilterInput{
+		ProposalCid: deal.ProposalCid,
+		ClientPeer:  deal.Client,
+		Proposal:    deal.Proposal,
+	})
+	if err != nil {
+		return xerrors.Errorf("marshaling deal filter input: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dealFilterTimeout)
+	defer cancel()
+
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", dp.FilterCommand)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err = cmd.Run()
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := err.(*exec.ExitError); !ok {
+		return xerrors.Errorf("running deal filter: %w", err)
+	}
+
+	reason := strings.TrimSpace(out.String())
+	if reason == "" {
+		reason = err.Error()
+	}
+	return rejectf("deal rejected by filter: %s", reason)
+}
+
+func (dp *DealPolicy) checkClient(ctx context.Context, sapi PolicyStateAPI, client address.Address) error {
+	if len(dp.AllowedClients) == 0 && len(dp.BlockedClients) == 0 {
+		return nil
+	}
+
+	id, err := sapi.StateLookupID(ctx, client, nil)
+	if err != nil {
+		return xerrors.Errorf("looking up client ID address: %w", err)
+	}
+
+	if len(dp.AllowedClients) > 0 && !hasAddr(ctx, sapi, dp.AllowedClients, id) {
+		return rejectf("client %s not allowed", client)
+	}
+	if hasAddr(ctx, sapi, dp.BlockedClients, id) {
+		return rejectf("client %s is blocked", client)
+	}
+
+	return nil
+}
+
+// hasAddr checks if any address in the list resolves to the given ID address.
+// Addresses which can't be resolved aren't on chain, so they can't match.
+func hasAddr(ctx context.Context, sapi PolicyStateAPI, list []address.Address, id address.Address) bool {
+	for _, l := range list {
+		lid, err := sapi.StateLookupID(ctx, l, nil)
+		if err != nil {
+			log.Warnf("looking up ID address of %s in deal policy: %s", l, err)
+			continue
+		}
+		if lid == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package deals
+
+import (
+	"context"
+	"testing"
+
+	blocksutil "github.com/ipfs/go-ipfs-blocksutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/address"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// policyTestState resolves key addresses to ID addresses
+type policyTestState map[address.Address]address.Address
+
+func (st policyTestState) StateLookupID(ctx context.Context, addr address.Address, ts *types.TipSet) (address.Address, error) {
+	if addr.Protocol() == address.ID {
+		return addr, nil
+	}
+	id, ok := st[addr]
+	if !ok {
+		return address.Undef, xerrors.Errorf("actor %s not found", addr)
+	}
+	return id, nil
+}
+
+func TestDealPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	client, err := address.NewIDAddress(100)
+	require.NoError(t, err)
+	other, err := address.NewIDAddress(101)
+	require.NoError(t, err)
+	clientKey, err := address.NewSecp256k1Address([]byte("client"))
+	require.NoError(t, err)
+	otherKey, err := address.NewSecp256k1Address([]byte("other"))
+	require.NoError(t, err)
+	unknownKey, err := address.NewSecp256k1Address([]byte("unknown"))
+	require.NoError(t, err)
+
+	st := policyTestState{clientKey: client, otherKey: other}
+
+	deal := &MinerDeal{
+		ProposalCid: blocksutil.NewBlockGenerator().Next().Cid(),
+		Proposal: actors.StorageDealProposal{
+			PieceSize:            1 << 20,
+			Client:               client,
+			Duration:             1000,
+			StoragePricePerEpoch: types.NewInt(1000),
+			StorageCollateral:    types.NewInt(0),
+		},
+	}
+
+	check := func(dp DealPolicy) error {
+		err := dp.Check(ctx, st, deal)
+		if err != nil {
+			assert.True(t, isRejection(err), "not a rejection: %s", err)
+		}
+		return err
+	}
+
+	assert.NoError(t, check(DealPolicy{}))
+
+	// 1000 per MiB per epoch is 1024000 per GiB
+	assert.NoError(t, check(DealPolicy{MinPricePerGiBEpoch: types.NewInt(1024000)}))
+	assert.Error(t, check(DealPolicy{MinPricePerGiBEpoch: types.NewInt(1025024)}))
+
+	assert.NoError(t, check(DealPolicy{MinDuration: 1000, MaxDuration: 1000}))
+	assert.Error(t, check(DealPolicy{MinDuration: 1001}))
+	assert.Error(t, check(DealPolicy{MaxDuration: 999}))
+
+	assert.NoError(t, check(DealPolicy{MaxPieceSize: 1 << 20}))
+	assert.Error(t, check(DealPolicy{MaxPieceSize: 1 << 19}))
+
+	assert.NoError(t, check(DealPolicy{AllowedClients: []address.Address{other, client}}))
+	assert.Error(t, check(DealPolicy{AllowedClients: []address.Address{other}}))
+	assert.NoError(t, check(DealPolicy{BlockedClients: []address.Address{other}}))
+	assert.Error(t, check(DealPolicy{BlockedClients: []address.Address{client}}))
+
+	// lists match any address of the client
+	assert.NoError(t, check(DealPolicy{AllowedClients: []address.Address{clientKey}}))
+	assert.Error(t, check(DealPolicy{AllowedClients: []address.Address{otherKey, unknownKey}}))
+	assert.Error(t, check(DealPolicy{BlockedClients: []address.Address{unknownKey, clientKey}}))
+
+	deal.Proposal.Client = clientKey
+	assert.NoError(t, check(DealPolicy{AllowedClients: []address.Address{client}}))
+	assert.Error(t, check(DealPolicy{AllowedClients: []address.Address{other}}))
+	assert.NoError(t, check(DealPolicy{BlockedClients: []address.Address{otherKey}}))
+	assert.Error(t, check(DealPolicy{BlockedClients: []address.Address{client}}))
+	deal.Proposal.Client = client
+
+	assert.NoError(t, check(DealPolicy{FilterCommand: "grep -q PieceSize"}))
+	err = check(DealPolicy{FilterCommand: "cat > /dev/null; echo not today; exit 1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not today")
+}
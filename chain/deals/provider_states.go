@@ -47,7 +47,7 @@ func (p *Provider) accept(ctx context.Context, deal MinerDeal) (func(*MinerDeal)
 	//case SerializationIPLD:
 	case actors.SerializationUnixFSv0:
 	default:
-		return nil, xerrors.Errorf("deal proposal with unsupported serialization: %s", deal.Proposal.PieceSerialization)
+		return nil, rejectf("deal proposal with unsupported serialization: %d", deal.Proposal.PieceSerialization)
 	}
 
 	head, err := p.full.ChainHead(ctx)
@@ -55,18 +55,22 @@ func (p *Provider) accept(ctx context.Context, deal MinerDeal) (func(*MinerDeal)
 		return nil, err
 	}
 	if head.Height() >= deal.Proposal.ProposalExpiration {
-		return nil, xerrors.Errorf("deal proposal already expired")
+		return nil, rejectf("deal proposal already expired")
 	}
 
 	// TODO: check StorageCollateral
 
 	minPrice := types.BigDiv(types.BigMul(p.ask.Ask.Price, types.NewInt(deal.Proposal.PieceSize)), types.NewInt(1<<30))
 	if deal.Proposal.StoragePricePerEpoch.LessThan(minPrice) {
-		return nil, xerrors.Errorf("storage price per epoch less than asking price: %s < %s", deal.Proposal.StoragePricePerEpoch, minPrice)
+		return nil, rejectf("storage price per epoch less than asking price: %s < %s", deal.Proposal.StoragePricePerEpoch, minPrice)
 	}
 
 	if deal.Proposal.PieceSize < p.ask.Ask.MinPieceSize {
-		return nil, xerrors.Errorf("piece size less than minimum required size: %d < %d", deal.Proposal.PieceSize, p.ask.Ask.MinPieceSize)
+		return nil, rejectf("piece size less than minimum required size: %d < %d", deal.Proposal.PieceSize, p.ask.Ask.MinPieceSize)
+	}
+
+	if err := p.policy.Check(ctx, p.full, &deal); err != nil {
+		return nil, err
 	}
 
 	// check market funds
@@ -78,7 +82,7 @@ func (p *Provider) accept(ctx context.Context, deal MinerDeal) (func(*MinerDeal)
 	// This doesn't guarantee that the client won't withdraw / lock those funds
 	// but it's a decent first filter
 	if clientMarketBalance.Available.LessThan(deal.Proposal.TotalStoragePrice()) {
-		return nil, xerrors.New("clientMarketBalance.Available too small")
+		return nil, rejectf("client market balance too small: %s < %s", clientMarketBalance.Available, deal.Proposal.TotalStoragePrice())
 	}
 
 	waddr, err := p.full.StateMinerWorker(ctx, deal.Proposal.Provider, nil)
@@ -168,6 +172,9 @@ func (p *Provider) accept(ctx context.Context, deal MinerDeal) (func(*MinerDeal)
 		deal.Ref,
 		allSelector,
 	)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to open pull data channel: %w", err)
+	}
 
 	return func(deal *MinerDeal) {
 		deal.DealID = resp.DealIDs[0]
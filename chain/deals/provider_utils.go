@@ -32,10 +32,16 @@ func (p *Provider) failDeal(id cid.Cid, cerr error) {
 		cerr = xerrors.Errorf("unknown error (fail called at %s:%d)", f, l)
 	}
 
-	log.Warnf("deal %s failed: %s", id, cerr)
+	state := api.DealFailed
+	if isRejection(cerr) {
+		state = api.DealRejected
+		log.Infof("deal %s rejected: %s", id, cerr)
+	} else {
+		log.Warnf("deal %s failed: %s", id, cerr)
+	}
 
 	err := p.sendSignedResponse(&Response{
-		State:    api.DealFailed,
+		State:    state,
 		Message:  cerr.Error(),
 		Proposal: id,
 	})
@@ -126,11 +132,11 @@ func (p *Provider) getWorker(miner address.Address) (address.Address, error) {
 		return address.Undef, xerrors.Errorf("getting worker address: %w", err)
 	}
 
-	if r.ExitCode != 0 {
-		return address.Undef, xerrors.Errorf("getWorker call failed: %d", r.ExitCode)
+	if r.Receipt.ExitCode != 0 {
+		return address.Undef, xerrors.Errorf("getWorker call failed: %d", r.Receipt.ExitCode)
 	}
 
-	return address.NewFromBytes(r.Return)
+	return address.NewFromBytes(r.Receipt.Return)
 }
 
 var _ datatransfer.RequestValidator = &ProviderRequestValidator{}
@@ -181,6 +181,19 @@ func (cg *ChainGen) Genesis() *types.BlockHeader {
 	return cg.genesis
 }
 
+func (cg *ChainGen) ChainStore() *store.ChainStore {
+	return cg.cs
+}
+
+// Banker returns the funded account the generated messages are sent from
+func (cg *ChainGen) Banker() address.Address {
+	return cg.banker
+}
+
+func (cg *ChainGen) Wallet() *wallet.Wallet {
+	return cg.w
+}
+
 func (cg *ChainGen) GenesisCar() ([]byte, error) {
 	offl := offline.Exchange(cg.bs)
 	blkserv := blockservice.New(cg.bs, offl)
@@ -205,8 +218,9 @@ func (cg *ChainGen) nextBlockProof(ctx context.Context, pts *types.TipSet, m add
 	}
 
 	st := pts.ParentState()
+	height := pts.Height() + uint64(len(ticks)) + 1
 
-	worker, err := stmgr.GetMinerWorkerRaw(ctx, cg.sm, st, m)
+	worker, err := stmgr.GetMinerWorkerRaw(ctx, cg.sm, st, height, m)
 	if err != nil {
 		return nil, nil, xerrors.Errorf("get miner worker: %w", err)
 	}
@@ -358,7 +372,7 @@ type MiningCheckAPI interface {
 
 	StateMinerPower(context.Context, address.Address, *types.TipSet) (api.MinerPower, error)
 
-	StateMinerWorker(context.Context, address.Address, *types.TipSet) (address.Address, error)
+	StateMinerInfo(context.Context, address.Address, *types.TipSet) (*api.MinerInfo, error)
 
 	WalletSign(context.Context, address.Address, []byte) (*types.Signature, error)
 }
@@ -384,8 +398,17 @@ func (mca mca) StateMinerPower(ctx context.Context, maddr address.Address, ts *t
 	}, err
 }
 
-func (mca mca) StateMinerWorker(ctx context.Context, maddr address.Address, ts *types.TipSet) (address.Address, error) {
-	return stmgr.GetMinerWorkerRaw(ctx, mca.sm, ts.ParentState(), maddr)
+func (mca mca) StateMinerInfo(ctx context.Context, maddr address.Address, ts *types.TipSet) (*api.MinerInfo, error) {
+	mi, ext, err := stmgr.GetMinerInfo(ctx, mca.sm, ts, maddr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.MinerInfo{
+		MinerInfo:    *mi,
+		WorkerChange: ext.WorkerChange,
+		OwnerChange:  ext.OwnerChange,
+	}, nil
 }
 
 func (mca mca) WalletSign(ctx context.Context, a address.Address, v []byte) (*types.Signature, error) {
@@ -398,12 +421,15 @@ func IsRoundWinner(ctx context.Context, ts *types.TipSet, ticks []*types.Ticket,
 		return false, nil, xerrors.Errorf("chain get randomness: %w", err)
 	}
 
-	mworker, err := a.StateMinerWorker(ctx, miner, ts)
+	mi, err := a.StateMinerInfo(ctx, miner, ts)
 	if err != nil {
-		return false, nil, xerrors.Errorf("failed to get miner worker: %w", err)
+		return false, nil, xerrors.Errorf("failed to get miner info: %w", err)
 	}
 
-	vrfout, err := ComputeVRF(ctx, a.WalletSign, mworker, r)
+	// the worker signing the block is the one in effect at its height
+	worker := mi.WorkerAt(ts.Height() + uint64(len(ticks)))
+
+	vrfout, err := ComputeVRF(ctx, a.WalletSign, worker, r)
 	if err != nil {
 		return false, nil, xerrors.Errorf("failed to compute VRF: %w", err)
 	}
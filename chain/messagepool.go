@@ -35,6 +35,12 @@ var (
 	ErrNotEnoughFunds = errors.New("not enough funds to execute transaction")
 
 	ErrInvalidToAddr = errors.New("message had invalid to address")
+
+	ErrRBFTooLowPrice = errors.New("replace by fee has too low GasPrice")
+
+	ErrTooManyPendingMessages = errors.New("too many pending messages for sender")
+
+	ErrMpoolFull = errors.New("message pool is full and message gas price is too low")
 )
 
 const (
@@ -45,6 +51,23 @@ const (
 	localUpdates = "update"
 )
 
+// MpoolConfig holds the message replacement policy and pool size limits. The
+// defaults are set in the node config.
+type MpoolConfig struct {
+	// ReplaceByFeePercent is the minimum percentage by which the GasPrice of a
+	// message has to exceed the GasPrice of a pending message with the same
+	// sender and nonce in order to replace it
+	ReplaceByFeePercent uint64
+
+	// MaxPendingPerSender limits the number of pending messages a single
+	// sender can have in the pool
+	MaxPendingPerSender int
+
+	// MaxPending limits the total number of messages in the pool. When the
+	// pool is full, the lowest priced messages are evicted to make room
+	MaxPending int
+}
+
 type MessagePool struct {
 	lk sync.Mutex
 
@@ -62,7 +85,7 @@ type MessagePool struct {
 
 	minGasPrice types.BigInt
 
-	maxTxPoolSize int
+	cfg *MpoolConfig
 
 	blsSigCache *lru.TwoQueueCache
 
@@ -83,35 +106,55 @@ func newMsgSet() *msgSet {
 	}
 }
 
-func (ms *msgSet) add(m *types.SignedMessage) error {
+// add adds a message to the set. If a message with the same nonce is already
+// in the set, m replaces it only if its GasPrice is higher by at least
+// rbfPercent percent, in which case the replaced message is returned.
+func (ms *msgSet) add(m *types.SignedMessage, rbfPercent uint64) (*types.SignedMessage, error) {
+	exms, has := ms.msgs[m.Message.Nonce]
+	if has {
+		if m.Cid() == exms.Cid() {
+			return nil, nil
+		}
+
+		minPrice := types.BigDiv(types.BigMul(exms.Message.GasPrice, types.NewInt(100+rbfPercent)), types.NewInt(100))
+		if !m.Message.GasPrice.GreaterThan(exms.Message.GasPrice) || m.Message.GasPrice.LessThan(minPrice) {
+			return nil, xerrors.Errorf("message from %s with nonce %d already in mpool, increase GasPrice to at least %s to replace it: %w", m.Message.From, m.Message.Nonce, minPrice, ErrRBFTooLowPrice)
+		}
+	}
+
 	if len(ms.msgs) == 0 || m.Message.Nonce >= ms.nextNonce {
 		ms.nextNonce = m.Message.Nonce + 1
 	}
-	if _, has := ms.msgs[m.Message.Nonce]; has {
-		if m.Cid() != ms.msgs[m.Message.Nonce].Cid() {
-			log.Error("Add with duplicate nonce")
-			return xerrors.Errorf("message to %s with nonce %d already in mpool")
-		}
-	}
 	ms.msgs[m.Message.Nonce] = m
 
-	return nil
+	return exms, nil
 }
 
-func NewMessagePool(sm *stmgr.StateManager, ps *pubsub.PubSub, ds dtypes.MetadataDS) (*MessagePool, error) {
+// tail returns the pending message with the highest nonce
+func (ms *msgSet) tail() *types.SignedMessage {
+	var out *types.SignedMessage
+	for _, m := range ms.msgs {
+		if out == nil || m.Message.Nonce > out.Message.Nonce {
+			out = m
+		}
+	}
+	return out
+}
+
+func NewMessagePool(sm *stmgr.StateManager, ps *pubsub.PubSub, ds dtypes.MetadataDS, cfg *MpoolConfig) (*MessagePool, error) {
 	cache, _ := lru.New2Q(build.BlsSignatureCacheSize)
 	mp := &MessagePool{
-		closer:        make(chan struct{}),
-		repubTk:       time.NewTicker(build.BlockDelay * 10 * time.Second),
-		localAddrs:    make(map[address.Address]struct{}),
-		pending:       make(map[address.Address]*msgSet),
-		sm:            sm,
-		ps:            ps,
-		minGasPrice:   types.NewInt(0),
-		maxTxPoolSize: 5000,
-		blsSigCache:   cache,
-		changes:       lps.New(50),
-		localMsgs:     namespace.Wrap(ds, datastore.NewKey(localMsgsDs)),
+		closer:      make(chan struct{}),
+		repubTk:     time.NewTicker(build.BlockDelay * 10 * time.Second),
+		localAddrs:  make(map[address.Address]struct{}),
+		pending:     make(map[address.Address]*msgSet),
+		sm:          sm,
+		ps:          ps,
+		minGasPrice: types.NewInt(0),
+		cfg:         cfg,
+		blsSigCache: cache,
+		changes:     lps.New(50),
+		localMsgs:   namespace.Wrap(ds, datastore.NewKey(localMsgsDs)),
 	}
 
 	if err := mp.loadLocal(); err != nil {
@@ -265,11 +308,42 @@ func (mp *MessagePool) addLocked(m *types.SignedMessage) error {
 	mset, ok := mp.pending[m.Message.From]
 	if !ok {
 		mset = newMsgSet()
-		mp.pending[m.Message.From] = mset
 	}
 
-	if err := mset.add(m); err != nil {
-		log.Error(err)
+	if exms, has := mset.msgs[m.Message.Nonce]; has && exms.Cid() == m.Cid() {
+		return nil
+	}
+
+	if _, replacing := mset.msgs[m.Message.Nonce]; !replacing {
+		if mp.cfg.MaxPendingPerSender > 0 && len(mset.msgs) >= mp.cfg.MaxPendingPerSender {
+			return xerrors.Errorf("sender %s has %d pending messages: %w", m.Message.From, len(mset.msgs), ErrTooManyPendingMessages)
+		}
+
+		if mp.cfg.MaxPending > 0 && mp.pendingCount >= mp.cfg.MaxPending {
+			if err := mp.evictLocked(m); err != nil {
+				return err
+			}
+		}
+	}
+
+	replaced, err := mset.add(m, mp.cfg.ReplaceByFeePercent)
+	if err != nil {
+		return err
+	}
+	mp.pending[m.Message.From] = mset
+
+	if replaced != nil {
+		mp.changes.Pub(api.MpoolUpdate{
+			Type:    api.MpoolRemove,
+			Message: replaced,
+		}, localUpdates)
+
+		// the replaced message will never be valid again, don't republish it
+		if err := mp.localMsgs.Delete(datastore.NewKey(string(replaced.Cid().Bytes()))); err != nil {
+			log.Errorf("removing replaced local message: %s", err)
+		}
+	} else {
+		mp.pendingCount++
 	}
 
 	mp.changes.Pub(api.MpoolUpdate{
@@ -279,6 +353,39 @@ func (mp *MessagePool) addLocked(m *types.SignedMessage) error {
 	return nil
 }
 
+// evictLocked makes room for m in a full pool by removing the cheapest
+// message which can be dropped without leaving a nonce gap, that is the last
+// pending message of some other sender. Messages from local addresses are
+// never evicted.
+func (mp *MessagePool) evictLocked(m *types.SignedMessage) error {
+	var evict *types.SignedMessage
+	for a, mset := range mp.pending {
+		if a == m.Message.From {
+			continue
+		}
+		if _, local := mp.localAddrs[a]; local {
+			continue
+		}
+
+		t := mset.tail()
+		if t == nil {
+			continue
+		}
+
+		if evict == nil || t.Message.GasPrice.LessThan(evict.Message.GasPrice) {
+			evict = t
+		}
+	}
+
+	if evict == nil || !m.Message.GasPrice.GreaterThan(evict.Message.GasPrice) {
+		return xerrors.Errorf("pool has %d messages: %w", mp.pendingCount, ErrMpoolFull)
+	}
+
+	log.Debugf("mpool full, evicting message %s (from %s, nonce %d)", evict.Cid(), evict.Message.From, evict.Message.Nonce)
+	mp.removeLocked(evict.Message.From, evict.Message.Nonce, false)
+	return nil
+}
+
 func (mp *MessagePool) GetNonce(addr address.Address) (uint64, error) {
 	mp.lk.Lock()
 	defer mp.lk.Unlock()
@@ -357,6 +464,13 @@ func (mp *MessagePool) Remove(from address.Address, nonce uint64) {
 	mp.lk.Lock()
 	defer mp.lk.Unlock()
 
+	mp.removeLocked(from, nonce, true)
+}
+
+// removeLocked removes the message with the given nonce. Included is set when
+// the nonce was used by a message on chain, in which case the next nonce is
+// past it, even if the message wasn't in the pool.
+func (mp *MessagePool) removeLocked(from address.Address, nonce uint64, included bool) {
 	mset, ok := mp.pending[from]
 	if !ok {
 		return
@@ -367,6 +481,7 @@ func (mp *MessagePool) Remove(from address.Address, nonce uint64) {
 			Type:    api.MpoolRemove,
 			Message: m,
 		}, localUpdates)
+		mp.pendingCount--
 	}
 
 	// NB: This deletes any message with the given nonce. This makes sense
@@ -382,7 +497,7 @@ func (mp *MessagePool) Remove(from address.Address, nonce uint64) {
 				max = nonce
 			}
 		}
-		if max < nonce {
+		if included && max < nonce {
 			max = nonce // we could have not seen the removed message before
 		}
 
@@ -401,6 +516,13 @@ func (mp *MessagePool) Pending() []*types.SignedMessage {
 	return out
 }
 
+// PendingFor returns pending messages from the given sender, ordered by nonce
+func (mp *MessagePool) PendingFor(a address.Address) []*types.SignedMessage {
+	mp.lk.Lock()
+	defer mp.lk.Unlock()
+	return mp.pendingFor(a)
+}
+
 func (mp *MessagePool) pendingFor(a address.Address) []*types.SignedMessage {
 	mset := mp.pending[a]
 	if mset == nil || len(mset.msgs) == 0 {
@@ -526,6 +648,9 @@ func (mp *MessagePool) loadLocal() error {
 			if xerrors.Is(err, ErrNonceTooLow) {
 				continue // todo: drop the message from local cache (if above certain confidence threshold)
 			}
+			if xerrors.Is(err, ErrRBFTooLowPrice) {
+				continue // a replacement for this message was loaded first
+			}
 
 			return xerrors.Errorf("adding local message: %w", err)
 		}
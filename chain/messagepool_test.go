@@ -0,0 +1,248 @@
+package chain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/ipfs/go-datastore"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	lps "github.com/whyrusleeping/pubsub"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/address"
+	"github.com/filecoin-project/lotus/chain/stmgr"
+	"github.com/filecoin-project/lotus/chain/store"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func mkTestMessage(t *testing.T, nonce uint64, gasPrice uint64) *types.SignedMessage {
+	return mkTestMessageFrom(t, 1001, nonce, gasPrice)
+}
+
+func mkTestMessageFrom(t *testing.T, from uint64, nonce uint64, gasPrice uint64) *types.SignedMessage {
+	fromAddr, err := address.NewIDAddress(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := address.NewIDAddress(1002)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &types.SignedMessage{
+		Message: types.Message{
+			To:       to,
+			From:     fromAddr,
+			Nonce:    nonce,
+			Value:    types.NewInt(1),
+			GasPrice: types.NewInt(gasPrice),
+			GasLimit: types.NewInt(1000),
+		},
+		Signature: types.Signature{
+			Type: types.KTBLS,
+		},
+	}
+}
+
+func TestMsgSetReplaceByFee(t *testing.T) {
+	ms := newMsgSet()
+
+	orig := mkTestMessage(t, 0, 100)
+	if _, err := ms.add(orig, 25); err != nil {
+		t.Fatal(err)
+	}
+
+	// re-adding the same message is a noop
+	replaced, err := ms.add(orig, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced != nil {
+		t.Fatal("adding the same message shouldn't replace anything")
+	}
+
+	if _, err := ms.add(mkTestMessage(t, 0, 110), 25); !xerrors.Is(err, ErrRBFTooLowPrice) {
+		t.Fatalf("expected ErrRBFTooLowPrice, got %v", err)
+	}
+
+	bumped := mkTestMessage(t, 0, 125)
+	replaced, err = ms.add(bumped, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced == nil || replaced.Cid() != orig.Cid() {
+		t.Fatal("expected original message to be replaced")
+	}
+	if ms.msgs[0].Cid() != bumped.Cid() {
+		t.Fatal("expected bumped message in the set")
+	}
+	if ms.nextNonce != 1 {
+		t.Fatalf("expected nextNonce to be 1, got %d", ms.nextNonce)
+	}
+}
+
+func TestMsgSetTail(t *testing.T) {
+	ms := newMsgSet()
+	for i := uint64(0); i < 5; i++ {
+		if _, err := ms.add(mkTestMessage(t, i, 10-i), 25); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := ms.tail().Message.Nonce; n != 4 {
+		t.Fatalf("expected tail nonce to be 4, got %d", n)
+	}
+}
+
+// newTestMpool returns a message pool which doesn't check messages against
+// the chain state, messages have to be added with addLocked
+func newTestMpool(cfg *MpoolConfig) *MessagePool {
+	ds := datastore.NewMapDatastore()
+	cache, _ := lru.New2Q(100)
+
+	return &MessagePool{
+		localAddrs:  make(map[address.Address]struct{}),
+		pending:     make(map[address.Address]*msgSet),
+		sm:          stmgr.NewStateManager(store.NewChainStore(blockstore.NewBlockstore(ds), ds)),
+		cfg:         cfg,
+		blsSigCache: cache,
+		changes:     lps.New(50),
+		localMsgs:   datastore.NewMapDatastore(),
+	}
+}
+
+func TestMpoolEviction(t *testing.T) {
+	mp := newTestMpool(&MpoolConfig{ReplaceByFeePercent: 25, MaxPending: 3})
+
+	for _, m := range []*types.SignedMessage{
+		mkTestMessageFrom(t, 1, 0, 10),
+		mkTestMessageFrom(t, 1, 1, 10),
+		mkTestMessageFrom(t, 2, 0, 5),
+	} {
+		if err := mp.addLocked(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the pool is full, cheaper messages are rejected
+	if err := mp.addLocked(mkTestMessageFrom(t, 3, 0, 5)); !xerrors.Is(err, ErrMpoolFull) {
+		t.Fatalf("expected ErrMpoolFull, got %v", err)
+	}
+
+	// the cheapest message goes
+	if err := mp.addLocked(mkTestMessageFrom(t, 3, 0, 20)); err != nil {
+		t.Fatal(err)
+	}
+	from2, _ := address.NewIDAddress(2)
+	if len(mp.PendingFor(from2)) != 0 {
+		t.Fatal("expected the message from 2 to be evicted")
+	}
+
+	// evicting the last message of a sender doesn't leave a nonce gap
+	if err := mp.addLocked(mkTestMessageFrom(t, 4, 0, 30)); err != nil {
+		t.Fatal(err)
+	}
+	from1, _ := address.NewIDAddress(1)
+	if n := len(mp.PendingFor(from1)); n != 1 {
+		t.Fatalf("expected one message from 1 left, got %d", n)
+	}
+	if n := mp.pending[from1].nextNonce; n != 1 {
+		t.Fatalf("expected nextNonce to be 1 after eviction, got %d", n)
+	}
+
+	if mp.pendingCount != 3 || len(mp.Pending()) != 3 {
+		t.Fatalf("expected 3 pending messages, got %d (counted %d)", len(mp.Pending()), mp.pendingCount)
+	}
+
+	// messages included on chain move the nonce past them
+	mp.Remove(from1, 5)
+	if n := mp.pending[from1].nextNonce; n != 6 {
+		t.Fatalf("expected nextNonce to be 6, got %d", n)
+	}
+}
+
+func TestMpoolEvictionSkipsLocal(t *testing.T) {
+	mp := newTestMpool(&MpoolConfig{ReplaceByFeePercent: 25, MaxPending: 1})
+
+	local := mkTestMessageFrom(t, 1, 0, 5)
+	if err := mp.addLocked(local); err != nil {
+		t.Fatal(err)
+	}
+	mp.localAddrs[local.Message.From] = struct{}{}
+
+	if err := mp.addLocked(mkTestMessageFrom(t, 2, 0, 20)); !xerrors.Is(err, ErrMpoolFull) {
+		t.Fatalf("expected ErrMpoolFull, got %v", err)
+	}
+}
+
+func TestMpoolMaxPendingPerSender(t *testing.T) {
+	mp := newTestMpool(&MpoolConfig{ReplaceByFeePercent: 25, MaxPendingPerSender: 2})
+
+	for i := uint64(0); i < 2; i++ {
+		if err := mp.addLocked(mkTestMessage(t, i, 10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := mp.addLocked(mkTestMessage(t, 2, 10)); !xerrors.Is(err, ErrTooManyPendingMessages) {
+		t.Fatalf("expected ErrTooManyPendingMessages, got %v", err)
+	}
+
+	// replacing a message doesn't count against the limit
+	if err := mp.addLocked(mkTestMessage(t, 1, 20)); err != nil {
+		t.Fatal(err)
+	}
+
+	// other senders aren't affected
+	if err := mp.addLocked(mkTestMessageFrom(t, 2, 0, 10)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMpoolReplaceByFeeUpdates(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mp := newTestMpool(&MpoolConfig{ReplaceByFeePercent: 25})
+
+	updates, err := mp.Updates(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := func() api.MpoolUpdate {
+		select {
+		case u := <-updates:
+			return u
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for mpool update")
+		}
+		return api.MpoolUpdate{}
+	}
+
+	orig := mkTestMessage(t, 0, 100)
+	if err := mp.addLocked(orig); err != nil {
+		t.Fatal(err)
+	}
+	if u := next(); u.Type != api.MpoolAdd || u.Message.Cid() != orig.Cid() {
+		t.Fatalf("expected MpoolAdd of the original message, got %v", u)
+	}
+
+	bumped := mkTestMessage(t, 0, 125)
+	if err := mp.addLocked(bumped); err != nil {
+		t.Fatal(err)
+	}
+	if u := next(); u.Type != api.MpoolRemove || u.Message.Cid() != orig.Cid() {
+		t.Fatalf("expected MpoolRemove of the original message, got %v", u)
+	}
+	if u := next(); u.Type != api.MpoolAdd || u.Message.Cid() != bumped.Cid() {
+		t.Fatalf("expected MpoolAdd of the replacement, got %v", u)
+	}
+
+	if mp.pendingCount != 1 {
+		t.Fatalf("expected one pending message, got %d", mp.pendingCount)
+	}
+}
@@ -15,6 +15,14 @@ import (
 )
 
 func (sm *StateManager) CallRaw(ctx context.Context, msg *types.Message, bstate cid.Cid, r vm.Rand, bheight uint64) (*types.MessageReceipt, error) {
+	ret, err := sm.callRaw(ctx, msg, bstate, r, bheight, false)
+	if err != nil {
+		return nil, err
+	}
+	return &ret.MessageReceipt, nil
+}
+
+func (sm *StateManager) callRaw(ctx context.Context, msg *types.Message, bstate cid.Cid, r vm.Rand, bheight uint64, tracing bool) (*vm.ApplyRet, error) {
 	ctx, span := trace.StartSpan(ctx, "statemanager.CallRaw")
 	defer span.End()
 
@@ -22,6 +30,7 @@ func (sm *StateManager) CallRaw(ctx context.Context, msg *types.Message, bstate
 	if err != nil {
 		return nil, xerrors.Errorf("failed to set up vm: %w", err)
 	}
+	vmi.SetTracing(tracing)
 
 	if msg.GasLimit == types.EmptyInt {
 		msg.GasLimit = types.NewInt(10000000000)
@@ -57,7 +66,7 @@ func (sm *StateManager) CallRaw(ctx context.Context, msg *types.Message, bstate
 	if ret.ActorErr != nil {
 		log.Warnf("chain call failed: %s", ret.ActorErr)
 	}
-	return &ret.MessageReceipt, nil
+	return ret, nil
 
 }
 
@@ -73,6 +82,69 @@ func (sm *StateManager) Call(ctx context.Context, msg *types.Message, ts *types.
 	return sm.CallRaw(ctx, msg, state, r, ts.Height())
 }
 
+// CallWithTrace is like Call, but records an execution trace of all the sends
+// made while applying msg
+func (sm *StateManager) CallWithTrace(ctx context.Context, msg *types.Message, ts *types.TipSet) (*vm.ApplyRet, error) {
+	if ts == nil {
+		ts = sm.cs.GetHeaviestTipSet()
+	}
+
+	state := ts.ParentState()
+
+	r := store.NewChainRand(sm.cs, ts.Cids(), ts.Height(), nil)
+
+	return sm.callRaw(ctx, msg, state, r, ts.Height(), true)
+}
+
+// CallWithGas applies priorMsgs and then msg on top of the state computed for
+// ts, and returns the receipt of msg. Unlike Call, the nonce of msg is taken
+// from the sender state after applying priorMsgs, so this can be used to
+// execute a message as if the pending messages of its sender were mined.
+func (sm *StateManager) CallWithGas(ctx context.Context, msg *types.Message, priorMsgs []store.ChainMsg, ts *types.TipSet) (*types.MessageReceipt, error) {
+	ctx, span := trace.StartSpan(ctx, "statemanager.CallWithGas")
+	defer span.End()
+
+	if ts == nil {
+		ts = sm.cs.GetHeaviestTipSet()
+	}
+
+	state, _, err := sm.TipSetState(ctx, ts)
+	if err != nil {
+		return nil, xerrors.Errorf("computing tipset state: %w", err)
+	}
+
+	r := store.NewChainRand(sm.cs, ts.Cids(), ts.Height(), nil)
+
+	vmi, err := vm.NewVM(state, ts.Height()+1, r, actors.NetworkAddress, sm.cs.Blockstore())
+	if err != nil {
+		return nil, xerrors.Errorf("failed to set up vm: %w", err)
+	}
+
+	for i, m := range priorMsgs {
+		_, err := vmi.ApplyMessage(ctx, m.VMMessage())
+		if err != nil {
+			return nil, xerrors.Errorf("applying prior message (%d, %s): %w", i, m.Cid(), err)
+		}
+	}
+
+	fromActor, err := vmi.StateTree().GetActor(msg.From)
+	if err != nil {
+		return nil, xerrors.Errorf("call with gas get actor: %w", err)
+	}
+
+	msg.Nonce = fromActor.Nonce
+
+	ret, err := vmi.ApplyMessage(ctx, msg)
+	if err != nil {
+		return nil, xerrors.Errorf("apply message failed: %w", err)
+	}
+
+	if ret.ActorErr != nil {
+		log.Warnf("chain call failed: %s", ret.ActorErr)
+	}
+	return &ret.MessageReceipt, nil
+}
+
 var errHaltExecution = fmt.Errorf("halt")
 
 func (sm *StateManager) Replay(ctx context.Context, ts *types.TipSet, mcid cid.Cid) (*types.Message, *vm.ApplyRet, error) {
@@ -93,3 +165,43 @@ func (sm *StateManager) Replay(ctx context.Context, ts *types.TipSet, mcid cid.C
 
 	return outm, outr, nil
 }
+
+// ComputeState applies msgs in order on top of the state computed for ts, as
+// if they were included in a block at the next height, and returns the result
+// of each message. Block rewards and the end of block cron are not applied,
+// and the chain head is not affected. The resulting state is discarded, it is
+// not written to the chain blockstore.
+func (sm *StateManager) ComputeState(ctx context.Context, msgs []*types.Message, ts *types.TipSet) ([]*vm.ApplyRet, error) {
+	ctx, span := trace.StartSpan(ctx, "statemanager.ComputeState")
+	defer span.End()
+
+	if ts == nil {
+		ts = sm.cs.GetHeaviestTipSet()
+	}
+
+	state, _, err := sm.TipSetState(ctx, ts)
+	if err != nil {
+		return nil, xerrors.Errorf("computing tipset state: %w", err)
+	}
+
+	r := store.NewChainRand(sm.cs, ts.Cids(), ts.Height(), nil)
+
+	// the vm buffers state writes, it's not flushed so nothing reaches the
+	// chain blockstore
+	vmi, err := vm.NewVM(state, ts.Height()+1, r, actors.NetworkAddress, sm.cs.Blockstore())
+	if err != nil {
+		return nil, xerrors.Errorf("failed to set up vm: %w", err)
+	}
+	vmi.SetTracing(true)
+
+	rets := make([]*vm.ApplyRet, 0, len(msgs))
+	for i, m := range msgs {
+		ret, err := vmi.ApplyMessage(ctx, m)
+		if err != nil {
+			return nil, xerrors.Errorf("applying message %d (%s): %w", i, m.Cid(), err)
+		}
+		rets = append(rets, ret)
+	}
+
+	return rets, nil
+}
@@ -0,0 +1,74 @@
+package stmgr_test
+
+import (
+	"context"
+	"testing"
+
+	hamt "github.com/ipfs/go-hamt-ipld"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/address"
+	"github.com/filecoin-project/lotus/chain/gen"
+	"github.com/filecoin-project/lotus/chain/state"
+	"github.com/filecoin-project/lotus/chain/stmgr"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func TestComputeStateDiscardsState(t *testing.T) {
+	ctx := context.Background()
+
+	cg, err := gen.NewGenerator()
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err := cg.NextTipSet()
+		require.NoError(t, err)
+	}
+
+	cs := cg.ChainStore()
+	ts := cg.CurTipset.TipSet()
+	sm := stmgr.NewStateManager(cs)
+
+	base, _, err := sm.TipSetState(ctx, ts)
+	require.NoError(t, err)
+
+	st, err := state.LoadStateTree(hamt.CSTFromBstore(cs.Blockstore()), base)
+	require.NoError(t, err)
+	from, err := st.GetActor(actors.NetworkAddress)
+	require.NoError(t, err)
+
+	// sending to a new address creates an account actor
+	to, err := address.NewSecp256k1Address([]byte("compute state test"))
+	require.NoError(t, err)
+
+	msg := &types.Message{
+		From:     actors.NetworkAddress,
+		To:       to,
+		Nonce:    from.Nonce,
+		Value:    types.NewInt(1000),
+		GasPrice: types.NewInt(0),
+		GasLimit: types.NewInt(10000),
+	}
+
+	before := countBlocks(t, cs.Blockstore())
+
+	rets, err := sm.ComputeState(ctx, []*types.Message{msg}, ts)
+	require.NoError(t, err)
+	require.Len(t, rets, 1)
+	require.Equal(t, uint8(0), rets[0].ExitCode)
+
+	require.Equal(t, before, countBlocks(t, cs.Blockstore()), "computed state was written to the chain blockstore")
+}
+
+func countBlocks(t *testing.T, bs blockstore.Blockstore) int {
+	keys, err := bs.AllKeysChan(context.Background())
+	require.NoError(t, err)
+
+	var n int
+	for range keys {
+		n++
+	}
+	return n
+}
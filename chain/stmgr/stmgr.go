@@ -104,10 +104,16 @@ func (sm *StateManager) computeTipSetState(ctx context.Context, blks []*types.Bl
 
 	r := store.NewChainRand(sm.cs, cids, blks[0].Height, nil)
 
+	lk := sm.cs.StateWriteLock()
+	lk.Lock()
+	defer lk.Unlock()
+
 	vmi, err := vm.NewVM(pstate, blks[0].Height, r, address.Undef, sm.cs.Blockstore())
 	if err != nil {
 		return cid.Undef, cid.Undef, xerrors.Errorf("instantiating VM failed: %w", err)
 	}
+	// traces are only useful to callers inspecting individual message results
+	vmi.SetTracing(cb != nil)
 
 	netact, err := vmi.StateTree().GetActor(actors.NetworkAddress)
 	if err != nil {
@@ -231,6 +237,7 @@ func (sm *StateManager) computeTipSetState(ctx context.Context, blks []*types.Bl
 	if err != nil {
 		return cid.Undef, cid.Undef, xerrors.Errorf("vm flush failed: %w", err)
 	}
+	sm.cs.StateWritten(st, rectroot)
 
 	return st, rectroot, nil
 }
@@ -377,6 +384,15 @@ func (sm *StateManager) WaitForMessage(ctx context.Context, mcid cid.Cid) (*type
 		return head[0].Val, r, nil
 	}
 
+	fts, r, err := sm.SearchForMessage(ctx, mcid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if r != nil {
+		return fts, r, nil
+	}
+
 	var backTs *types.TipSet
 	var backRcp *types.MessageReceipt
 	backSearchWait := make(chan struct{})
@@ -423,6 +439,32 @@ func (sm *StateManager) WaitForMessage(ctx context.Context, mcid cid.Cid) (*type
 	}
 }
 
+// SearchForMessage looks up the tipset in which the message was executed on
+// the current chain, and its receipt, in the message index. Nil is returned
+// if the message wasn't found.
+func (sm *StateManager) SearchForMessage(ctx context.Context, mcid cid.Cid) (*types.TipSet, *types.MessageReceipt, error) {
+	info, err := sm.cs.MsgIndex().GetMsgInfo(mcid)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("looking up message in index: %w", err)
+	}
+
+	if info == nil {
+		return nil, nil, nil
+	}
+
+	ts, err := sm.cs.LoadTipSet(info.TipSet.Cids())
+	if err != nil {
+		return nil, nil, xerrors.Errorf("loading execution tipset: %w", err)
+	}
+
+	r, err := sm.cs.GetParentReceipt(ts.Blocks()[0], info.Index)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("loading receipt: %w", err)
+	}
+
+	return ts, r, nil
+}
+
 func (sm *StateManager) searchBackForMsg(ctx context.Context, from *types.TipSet, m store.ChainMsg) (*types.TipSet, *types.MessageReceipt, error) {
 
 	cur := from
@@ -551,3 +593,34 @@ func (sm *StateManager) MarketBalance(ctx context.Context, addr address.Address,
 
 	return b[0], nil
 }
+
+// ValidateChain recomputes the state of every tipset from genesis up to ts,
+// checking that it matches the parent state root referenced by its child.
+func (sm *StateManager) ValidateChain(ctx context.Context, ts *types.TipSet) error {
+	tschain := []*types.TipSet{ts}
+	for ts.Height() != 0 {
+		next, err := sm.cs.LoadTipSet(ts.Parents())
+		if err != nil {
+			return err
+		}
+
+		tschain = append(tschain, next)
+		ts = next
+	}
+
+	lastState := tschain[len(tschain)-1].ParentState()
+	for i := len(tschain) - 1; i >= 0; i-- {
+		cur := tschain[i]
+		log.Infof("computing state (height: %d, ts=%s)", cur.Height(), cur.Cids())
+		if cur.ParentState() != lastState {
+			return xerrors.Errorf("tipset chain had state mismatch at height %d", cur.Height())
+		}
+		st, _, err := sm.TipSetState(ctx, cur)
+		if err != nil {
+			return err
+		}
+		lastState = st
+	}
+
+	return nil
+}
@@ -18,12 +18,14 @@ import (
 	"golang.org/x/xerrors"
 )
 
-func GetMinerWorkerRaw(ctx context.Context, sm *StateManager, st cid.Cid, maddr address.Address) (address.Address, error) {
+// GetMinerWorkerRaw returns the worker of the miner in state st, as of the
+// given height. Pending worker changes take effect depending on the height.
+func GetMinerWorkerRaw(ctx context.Context, sm *StateManager, st cid.Cid, height uint64, maddr address.Address) (address.Address, error) {
 	recp, err := sm.CallRaw(ctx, &types.Message{
 		To:     maddr,
 		From:   maddr,
 		Method: actors.MAMethods.GetWorkerAddr,
-	}, st, nil, 0)
+	}, st, nil, height)
 	if err != nil {
 		return address.Undef, xerrors.Errorf("callRaw failed: %w", err)
 	}
@@ -167,6 +169,21 @@ func GetMinerProvingSet(ctx context.Context, sm *StateManager, ts *types.TipSet,
 	return LoadSectorsFromSet(ctx, sm.ChainStore().Blockstore(), mas.ProvingSet)
 }
 
+// GetMinerFaults returns the sectors declared faulty for the current proving
+// period, and those declared too late to be included in it
+func GetMinerFaults(ctx context.Context, sm *StateManager, ts *types.TipSet, maddr address.Address) (*api.MinerFaults, error) {
+	var mas actors.StorageMinerActorState
+	_, err := sm.LoadActorState(ctx, maddr, &mas, ts)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load miner actor state: %w", err)
+	}
+
+	return &api.MinerFaults{
+		Current: mas.CurrentFaultSet.All(),
+		Next:    mas.NextFaultSet.All(),
+	}, nil
+}
+
 func GetMinerSectorSet(ctx context.Context, sm *StateManager, ts *types.TipSet, maddr address.Address) ([]*api.ChainSectorInfo, error) {
 	var mas actors.StorageMinerActorState
 	_, err := sm.LoadActorState(ctx, maddr, &mas, ts)
@@ -177,6 +194,44 @@ func GetMinerSectorSet(ctx context.Context, sm *StateManager, ts *types.TipSet,
 	return LoadSectorsFromSet(ctx, sm.ChainStore().Blockstore(), mas.Sectors)
 }
 
+// GetMinerSectorExpiration returns the height at which a sector of the miner
+// expires, or 0 if it doesn't expire
+func GetMinerSectorExpiration(ctx context.Context, sm *StateManager, ts *types.TipSet, maddr address.Address, sectorID uint64) (uint64, error) {
+	var mas actors.StorageMinerActorState
+	_, err := sm.LoadActorState(ctx, maddr, &mas, ts)
+	if err != nil {
+		return 0, xerrors.Errorf("failed to load miner actor state: %w", err)
+	}
+
+	if !mas.Ext.Defined() {
+		return 0, nil
+	}
+
+	var ext actors.MinerExtState
+	cst := hamt.CSTFromBstore(sm.cs.Blockstore())
+	if err := cst.Get(ctx, mas.Ext, &ext); err != nil {
+		return 0, xerrors.Errorf("failed to read miner extended state: %w", err)
+	}
+	if ext.SectorExpirations == nil {
+		return 0, nil
+	}
+
+	a, err := amt.LoadAMT(amt.WrapBlockstore(sm.ChainStore().Blockstore()), *ext.SectorExpirations)
+	if err != nil {
+		return 0, xerrors.Errorf("failed to load sector expirations: %w", err)
+	}
+
+	var expiration uint64
+	if err := a.Get(sectorID, &expiration); err != nil {
+		if _, ok := err.(*amt.ErrNotFound); ok {
+			return 0, nil
+		}
+		return 0, xerrors.Errorf("failed to get sector expiration: %w", err)
+	}
+
+	return expiration, nil
+}
+
 func GetMinerSectorSize(ctx context.Context, sm *StateManager, ts *types.TipSet, maddr address.Address) (uint64, error) {
 	var mas actors.StorageMinerActorState
 	_, err := sm.LoadActorState(ctx, maddr, &mas, ts)
@@ -193,6 +248,37 @@ func GetMinerSectorSize(ctx context.Context, sm *StateManager, ts *types.TipSet,
 	return minfo.SectorSize, nil
 }
 
+// GetMinerInfo returns the info and extended state of the miner as of ts,
+// with worker changes that took effect by the height of ts applied
+func GetMinerInfo(ctx context.Context, sm *StateManager, ts *types.TipSet, maddr address.Address) (*actors.MinerInfo, *actors.MinerExtState, error) {
+	if ts == nil {
+		ts = sm.cs.GetHeaviestTipSet()
+	}
+
+	var mas actors.StorageMinerActorState
+	_, err := sm.LoadActorState(ctx, maddr, &mas, ts)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to load miner actor state: %w", err)
+	}
+
+	cst := hamt.CSTFromBstore(sm.cs.Blockstore())
+	var minfo actors.MinerInfo
+	if err := cst.Get(ctx, mas.Info, &minfo); err != nil {
+		return nil, nil, xerrors.Errorf("failed to read miner info: %w", err)
+	}
+
+	var ext actors.MinerExtState
+	if mas.Ext.Defined() {
+		if err := cst.Get(ctx, mas.Ext, &ext); err != nil {
+			return nil, nil, xerrors.Errorf("failed to read miner extended state: %w", err)
+		}
+	}
+
+	ext.ApplyWorkerChange(&minfo, ts.Height())
+
+	return &minfo, &ext, nil
+}
+
 func GetMinerSlashed(ctx context.Context, sm *StateManager, ts *types.TipSet, maddr address.Address) (uint64, error) {
 	var mas actors.StorageMinerActorState
 	_, err := sm.LoadActorState(ctx, maddr, &mas, ts)
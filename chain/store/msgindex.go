@@ -0,0 +1,399 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	dstore "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	hamt "github.com/ipfs/go-hamt-ipld"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/address"
+	"github.com/filecoin-project/lotus/chain/state"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+var (
+	msgIndexHeadKey    = dstore.NewKey("/msgindex/head")
+	msgIndexMsgPrefix  = dstore.NewKey("/msgindex/msg")
+	msgIndexFromPrefix = dstore.NewKey("/msgindex/from")
+	msgIndexToPrefix   = dstore.NewKey("/msgindex/to")
+)
+
+// MsgIndex keeps track of the tipsets in which messages on the current chain
+// were executed, and of the messages sent and received by each actor, by ID
+// address. It follows head changes of the chain store in the background,
+// removing the entries of reverted tipsets. Messages aren't found until the
+// index catches up with the head, which takes a while when it's first built
+// for a long chain.
+type MsgIndex struct {
+	cs *ChainStore
+	ds dstore.Datastore
+
+	// head is the tipset to bring the index up to, updateCh signals that it
+	// changed
+	headLk   sync.Mutex
+	head     *types.TipSet
+	updateCh chan struct{}
+}
+
+// MsgInfo describes where a message was executed
+type MsgInfo struct {
+	// TipSet is the tipset in which the message was executed. Its parent
+	// tipset includes the message, and its blocks reference the receipt
+	TipSet types.TipSetKey
+	Height uint64
+
+	// Index is the position of the message in the messages of the parent
+	// tipset, which is also the position of its receipt
+	Index int
+}
+
+func newMsgIndex(cs *ChainStore, ds dstore.Datastore) *MsgIndex {
+	mi := &MsgIndex{
+		cs: cs,
+		ds: ds,
+
+		updateCh: make(chan struct{}, 1),
+	}
+
+	go mi.updateLoop()
+
+	return mi
+}
+
+func msgIndexMsgKey(c cid.Cid) dstore.Key {
+	return msgIndexMsgPrefix.ChildString(c.String())
+}
+
+func msgIndexAddrKey(prefix dstore.Key, a address.Address, height uint64, c cid.Cid) dstore.Key {
+	// heights are zero padded so that keys sort by inclusion height
+	return prefix.ChildString(a.String()).ChildString(fmt.Sprintf("%020d", height)).ChildString(c.String())
+}
+
+func (mi *MsgIndex) headChange(rev, app []*types.TipSet) error {
+	var head *types.TipSet
+	switch {
+	case len(app) > 0:
+		head = app[len(app)-1]
+	case len(rev) > 0:
+		pts, err := mi.cs.LoadTipSet(rev[len(rev)-1].Parents())
+		if err != nil {
+			return xerrors.Errorf("loading parent of reverted tipset: %w", err)
+		}
+		head = pts
+	default:
+		return nil
+	}
+
+	mi.setHead(head)
+	return nil
+}
+
+// setHead makes the index catch up with head in the background
+func (mi *MsgIndex) setHead(head *types.TipSet) {
+	mi.headLk.Lock()
+	mi.head = head
+	mi.headLk.Unlock()
+
+	select {
+	case mi.updateCh <- struct{}{}:
+	default:
+	}
+}
+
+func (mi *MsgIndex) updateLoop() {
+	for range mi.updateCh {
+		mi.headLk.Lock()
+		head := mi.head
+		mi.headLk.Unlock()
+
+		if err := mi.update(head); err != nil {
+			log.Errorf("failed to update message index: %s", err)
+		}
+	}
+}
+
+// update brings the index from the last indexed tipset to head, it's only
+// called from updateLoop
+func (mi *MsgIndex) update(head *types.TipSet) error {
+	last, err := mi.loadHead()
+	if err != nil {
+		return err
+	}
+
+	var revert, apply []*types.TipSet
+	if last == nil {
+		log.Infof("building message index up to height %d", head.Height())
+		for cur := head; cur.Height() > 0; {
+			apply = append(apply, cur)
+
+			cur, err = mi.cs.LoadTipSet(cur.Parents())
+			if err != nil {
+				return xerrors.Errorf("loading parent tipset: %w", err)
+			}
+		}
+	} else {
+		revert, apply, err = mi.cs.ReorgOps(last, head)
+		if err != nil {
+			return xerrors.Errorf("computing reorg ops: %w", err)
+		}
+	}
+
+	for _, ts := range revert {
+		if err := mi.revertTipSet(ts); err != nil {
+			return xerrors.Errorf("reverting tipset %s: %w", ts.Cids(), err)
+		}
+		if err := mi.writeHead(ts.Parents()); err != nil {
+			return err
+		}
+	}
+
+	// apply is ordered from the head down
+	for i := len(apply) - 1; i >= 0; i-- {
+		ts := apply[i]
+		if err := mi.applyTipSet(ts); err != nil {
+			return xerrors.Errorf("applying tipset %s: %w", ts.Cids(), err)
+		}
+		if err := mi.writeHead(ts.Cids()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (mi *MsgIndex) loadHead() (*types.TipSet, error) {
+	data, err := mi.ds.Get(msgIndexHeadKey)
+	if err == dstore.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("loading message index head: %w", err)
+	}
+
+	var tscids []cid.Cid
+	if err := json.Unmarshal(data, &tscids); err != nil {
+		return nil, xerrors.Errorf("failed to unmarshal message index head: %w", err)
+	}
+
+	return mi.cs.LoadTipSet(tscids)
+}
+
+func (mi *MsgIndex) writeHead(tscids []cid.Cid) error {
+	data, err := json.Marshal(tscids)
+	if err != nil {
+		return xerrors.Errorf("failed to marshal tipset: %w", err)
+	}
+
+	if err := mi.ds.Put(msgIndexHeadKey, data); err != nil {
+		return xerrors.Errorf("failed to write message index head: %w", err)
+	}
+
+	return nil
+}
+
+// executedMessages returns the messages executed in ts, which are the
+// messages included in its parent, along with the parent tipset
+func (mi *MsgIndex) executedMessages(ts *types.TipSet) (*types.TipSet, []ChainMsg, error) {
+	pts, err := mi.cs.LoadTipSet(ts.Parents())
+	if err != nil {
+		return nil, nil, xerrors.Errorf("loading parent tipset: %w", err)
+	}
+
+	msgs, err := mi.cs.MessagesForTipset(pts)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("loading messages: %w", err)
+	}
+
+	return pts, msgs, nil
+}
+
+// executedState loads the state after the execution of the messages
+// executed in ts. Addresses are resolved to ID addresses in it, so that the
+// actors created by messages are found too
+func (mi *MsgIndex) executedState(ts *types.TipSet) (*state.StateTree, error) {
+	st, err := state.LoadStateTree(hamt.CSTFromBstore(mi.cs.Blockstore()), ts.ParentState())
+	if err != nil {
+		return nil, xerrors.Errorf("loading state tree: %w", err)
+	}
+	return st, nil
+}
+
+// addrKeys returns the index keys of a message included at the given height
+func addrKeys(st *state.StateTree, height uint64, m ChainMsg) []dstore.Key {
+	resolve := func(a address.Address) address.Address {
+		id, err := st.LookupID(a)
+		if err != nil {
+			// sends creating an actor may fail, in which case there is no
+			// ID address
+			return a
+		}
+		return id
+	}
+
+	vmm := m.VMMessage()
+	return []dstore.Key{
+		msgIndexAddrKey(msgIndexFromPrefix, resolve(vmm.From), height, m.Cid()),
+		msgIndexAddrKey(msgIndexToPrefix, resolve(vmm.To), height, m.Cid()),
+	}
+}
+
+func (mi *MsgIndex) applyTipSet(ts *types.TipSet) error {
+	// The genesis block did not execute any messages
+	if ts.Height() == 0 {
+		return nil
+	}
+
+	pts, msgs, err := mi.executedMessages(ts)
+	if err != nil {
+		return err
+	}
+
+	st, err := mi.executedState(ts)
+	if err != nil {
+		return err
+	}
+
+	for i, m := range msgs {
+		data, err := json.Marshal(&MsgInfo{
+			TipSet: ts.Key(),
+			Height: ts.Height(),
+			Index:  i,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := mi.ds.Put(msgIndexMsgKey(m.Cid()), data); err != nil {
+			return err
+		}
+
+		for _, k := range addrKeys(st, pts.Height(), m) {
+			if err := mi.ds.Put(k, []byte{}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (mi *MsgIndex) revertTipSet(ts *types.TipSet) error {
+	if ts.Height() == 0 {
+		return nil
+	}
+
+	pts, msgs, err := mi.executedMessages(ts)
+	if err != nil {
+		return err
+	}
+
+	st, err := mi.executedState(ts)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range msgs {
+		info, err := mi.GetMsgInfo(m.Cid())
+		if err != nil {
+			return err
+		}
+
+		// the message may have already been indexed in a different tipset
+		if info != nil && info.TipSet == ts.Key() {
+			if err := mi.delete(msgIndexMsgKey(m.Cid())); err != nil {
+				return err
+			}
+		}
+
+		for _, k := range addrKeys(st, pts.Height(), m) {
+			if err := mi.delete(k); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (mi *MsgIndex) delete(k dstore.Key) error {
+	if err := mi.ds.Delete(k); err != nil && err != dstore.ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+// GetMsgInfo returns where the message was executed on the current chain, or
+// nil if it wasn't found in the index
+func (mi *MsgIndex) GetMsgInfo(mcid cid.Cid) (*MsgInfo, error) {
+	data, err := mi.ds.Get(msgIndexMsgKey(mcid))
+	if err == dstore.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("loading message info: %w", err)
+	}
+
+	var info MsgInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, xerrors.Errorf("failed to unmarshal message info: %w", err)
+	}
+
+	return &info, nil
+}
+
+// ListMessagesFrom returns the messages sent by the actor with the given ID
+// address that were included in the current chain at heights between
+// minHeight and maxHeight, inclusive, ordered by height
+func (mi *MsgIndex) ListMessagesFrom(addr address.Address, minHeight, maxHeight uint64) ([]cid.Cid, error) {
+	return mi.listMessages(msgIndexFromPrefix, addr, minHeight, maxHeight)
+}
+
+// ListMessagesTo returns the messages received by the actor with the given
+// ID address that were included in the current chain at heights between
+// minHeight and maxHeight, inclusive, ordered by height
+func (mi *MsgIndex) ListMessagesTo(addr address.Address, minHeight, maxHeight uint64) ([]cid.Cid, error) {
+	return mi.listMessages(msgIndexToPrefix, addr, minHeight, maxHeight)
+}
+
+func (mi *MsgIndex) listMessages(prefix dstore.Key, addr address.Address, minHeight, maxHeight uint64) ([]cid.Cid, error) {
+	res, err := mi.ds.Query(query.Query{
+		Prefix:   prefix.ChildString(addr.String()).String(),
+		KeysOnly: true,
+		Orders:   []query.Order{query.OrderByKey{}},
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("querying message index: %w", err)
+	}
+	defer res.Close()
+
+	var out []cid.Cid
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, xerrors.Errorf("reading message index: %w", r.Error)
+		}
+
+		k := dstore.RawKey(r.Key)
+		h, err := strconv.ParseUint(k.Parent().Name(), 10, 64)
+		if err != nil {
+			return nil, xerrors.Errorf("parsing height of message index key %s: %w", k, err)
+		}
+		if h < minHeight || h > maxHeight {
+			continue
+		}
+
+		c, err := cid.Decode(k.Name())
+		if err != nil {
+			return nil, xerrors.Errorf("parsing cid of message index key %s: %w", k, err)
+		}
+
+		out = append(out, c)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,161 @@
+package store_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	hamt "github.com/ipfs/go-hamt-ipld"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/chain/address"
+	"github.com/filecoin-project/lotus/chain/gen"
+	"github.com/filecoin-project/lotus/chain/state"
+	"github.com/filecoin-project/lotus/chain/store"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// waitIndexed waits for the message index to process head changes, which
+// happens asynchronously
+func waitIndexed(t *testing.T, cs *store.ChainStore, mcid cid.Cid, indexed bool) {
+	t.Helper()
+
+	for i := 0; i < 500; i++ {
+		info, err := cs.MsgIndex().GetMsgInfo(mcid)
+		require.NoError(t, err)
+		if (info != nil) == indexed {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for the message index")
+}
+
+func TestMsgIndex(t *testing.T) {
+	cg, err := gen.NewGenerator()
+	require.NoError(t, err)
+
+	var tipsets []*types.TipSet
+	for i := 0; i < 6; i++ {
+		mts, err := cg.NextTipSet()
+		require.NoError(t, err)
+		tipsets = append(tipsets, mts.TipSet.TipSet())
+	}
+
+	cs := cg.ChainStore()
+	head := tipsets[len(tipsets)-1]
+
+	// messages included in the head aren't executed
+	executed := tipsets[len(tipsets)-2]
+	emsgs, err := cs.MessagesForTipset(executed)
+	require.NoError(t, err)
+	require.NotEmpty(t, emsgs)
+
+	require.NoError(t, cs.SetHead(head))
+	waitIndexed(t, cs, emsgs[0].Cid(), true)
+
+	hmsgs, err := cs.MessagesForTipset(head)
+	require.NoError(t, err)
+	require.NotEmpty(t, hmsgs)
+	info, err := cs.MsgIndex().GetMsgInfo(hmsgs[0].Cid())
+	require.NoError(t, err)
+	require.Nil(t, info, "messages included in the head were indexed")
+
+	info, err = cs.MsgIndex().GetMsgInfo(emsgs[3].Cid())
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	require.Equal(t, head.Key(), info.TipSet)
+	require.Equal(t, head.Height(), info.Height)
+	require.Equal(t, 3, info.Index)
+
+	// the generator sends the same number of messages from the banker in
+	// each tipset, one to each receiver
+	perTipSet := len(emsgs)
+	m := emsgs[0].VMMessage()
+
+	st, err := state.LoadStateTree(hamt.CSTFromBstore(cs.Blockstore()), head.ParentState())
+	require.NoError(t, err)
+	fromID, err := st.LookupID(m.From)
+	require.NoError(t, err)
+	toID, err := st.LookupID(m.To)
+	require.NoError(t, err)
+
+	list := func(addr address.Address, to bool, minHeight, maxHeight uint64) []cid.Cid {
+		t.Helper()
+
+		var out []cid.Cid
+		var err error
+		if to {
+			out, err = cs.MsgIndex().ListMessagesTo(addr, minHeight, maxHeight)
+		} else {
+			out, err = cs.MsgIndex().ListMessagesFrom(addr, minHeight, maxHeight)
+		}
+		require.NoError(t, err)
+		return out
+	}
+
+	sent := list(fromID, false, 0, head.Height())
+	require.Len(t, sent, perTipSet*(len(tipsets)-1))
+	require.Empty(t, list(fromID, true, 0, head.Height()), "sent messages listed as received")
+	require.Empty(t, list(m.From, false, 0, head.Height()), "messages must be indexed by ID address")
+
+	received := list(toID, true, 0, head.Height())
+	require.Len(t, received, len(tipsets)-1)
+	require.Empty(t, list(toID, false, 0, head.Height()), "received messages listed as sent")
+
+	// listed by inclusion height
+	for i, c := range received {
+		msgs, err := cs.MessagesForTipset(tipsets[i])
+		require.NoError(t, err)
+
+		var found bool
+		for _, m := range msgs {
+			found = found || m.Cid() == c
+		}
+		require.True(t, found, "message %d not included at the expected height", i)
+	}
+
+	require.Len(t, list(fromID, false, tipsets[1].Height(), tipsets[2].Height()), 2*perTipSet)
+
+	// reverting tipsets removes the messages they executed
+	rmsgs, err := cs.MessagesForTipset(tipsets[2])
+	require.NoError(t, err)
+
+	require.NoError(t, cs.SetHead(tipsets[2]))
+	waitIndexed(t, cs, rmsgs[0].Cid(), false)
+
+	require.Len(t, list(fromID, false, 0, head.Height()), 2*perTipSet)
+	require.Len(t, list(toID, true, 0, head.Height()), 2)
+}
+
+func TestMsgIndexLoad(t *testing.T) {
+	cg, err := gen.NewGenerator()
+	require.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		_, err := cg.NextTipSet()
+		require.NoError(t, err)
+	}
+
+	head := cg.CurTipset.TipSet()
+	pts, err := cg.ChainStore().LoadTipSet(head.Parents())
+	require.NoError(t, err)
+	msgs, err := cg.ChainStore().MessagesForTipset(pts)
+	require.NoError(t, err)
+	require.NotEmpty(t, msgs)
+
+	// a chain store which has a head, but no message index yet
+	ds := datastore.NewMapDatastore()
+	data, err := json.Marshal(head.Cids())
+	require.NoError(t, err)
+	require.NoError(t, ds.Put(datastore.NewKey("head"), data))
+
+	cs := store.NewChainStore(cg.ChainStore().Blockstore(), ds)
+	require.NoError(t, cs.Load())
+	require.Equal(t, head.Cids(), cs.GetHeaviestTipSet().Cids())
+
+	// the index is built in the background
+	waitIndexed(t, cs, msgs[0].Cid(), true)
+}
@@ -0,0 +1,263 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// PruneResult describes the objects removed (or, for a dry run, the objects
+// that would be removed) by a prune
+type PruneResult struct {
+	// Height below which state trees and receipts were pruned
+	Height uint64
+
+	Objects uint64
+	Bytes   uint64
+}
+
+// Prune removes state trees and message receipts of tipsets more than retain
+// epochs below the current head from the blockstore. Block headers, messages
+// and the genesis state are always kept, as are objects still referenced by
+// retained states. With dryRun set nothing is deleted, and the result
+// describes what would be reclaimed.
+//
+// Receipts are removed along with the states, so the results of messages
+// executed below the prune height can't be looked up anymore (e.g. with
+// StateSearchMsg). States keep being computed while the prune walks the
+// chain, it only stops state writes while deleting, see StateWriteLock.
+func (cs *ChainStore) Prune(ctx context.Context, retain uint64, dryRun bool) (*PruneResult, error) {
+	cs.pruneRunLk.Lock()
+	defer cs.pruneRunLk.Unlock()
+
+	head := cs.GetHeaviestTipSet()
+	if head.Height() <= retain {
+		return &PruneResult{}, nil
+	}
+	boundary := head.Height() - retain
+
+	if !dryRun {
+		cs.pruneWritesLk.Lock()
+		cs.pruneWrites = []cid.Cid{}
+		cs.pruneWritesLk.Unlock()
+
+		defer func() {
+			cs.pruneWritesLk.Lock()
+			cs.pruneWrites = nil
+			cs.pruneWritesLk.Unlock()
+		}()
+	}
+
+	keep := cid.NewSet()
+
+	// mark everything reachable from the retained states first
+	oldest, err := cs.markStates(ctx, head, nil, boundary, keep)
+	if err != nil {
+		return nil, xerrors.Errorf("marking retained states: %w", err)
+	}
+
+	gen, err := cs.GetGenesis()
+	if err != nil {
+		return nil, xerrors.Errorf("getting genesis: %w", err)
+	}
+	if err := cs.markDag(ctx, gen.ParentStateRoot, keep); err != nil {
+		return nil, xerrors.Errorf("marking genesis state: %w", err)
+	}
+	if err := cs.markDag(ctx, gen.ParentMessageReceipts, keep); err != nil {
+		return nil, xerrors.Errorf("marking genesis receipts: %w", err)
+	}
+
+	// collect the objects of old states that aren't referenced by any of the
+	// retained ones
+	remove := cid.NewSet()
+	seen := cid.NewSet()
+	for ts := oldest; ts.Height() > 0; {
+		for _, b := range ts.Blocks() {
+			if err := cs.sweepDag(ctx, b.ParentStateRoot, keep, seen, remove); err != nil {
+				return nil, xerrors.Errorf("collecting state of block %s: %w", b.Cid(), err)
+			}
+			if err := cs.sweepDag(ctx, b.ParentMessageReceipts, keep, seen, remove); err != nil {
+				return nil, xerrors.Errorf("collecting receipts of block %s: %w", b.Cid(), err)
+			}
+		}
+
+		ts, err = cs.LoadTipSet(ts.Parents())
+		if err != nil {
+			return nil, xerrors.Errorf("loading parent tipset: %w", err)
+		}
+	}
+
+	if !dryRun {
+		// states written from now on would reference the objects about to be
+		// removed if they already exist, so stop state writes until they are
+		// deleted, and keep everything written while walking the chain
+		cs.pruneLk.Lock()
+		defer cs.pruneLk.Unlock()
+
+		cs.pruneWritesLk.Lock()
+		written := cs.pruneWrites
+		cs.pruneWritesLk.Unlock()
+
+		for _, root := range written {
+			if err := cs.markDag(ctx, root, keep); err != nil {
+				return nil, xerrors.Errorf("marking state written while pruning: %w", err)
+			}
+		}
+	}
+
+	// the head may have changed in the meantime, to tipsets whose state was
+	// computed before the prune started
+	if _, err := cs.markStates(ctx, cs.GetHeaviestTipSet(), head, boundary, keep); err != nil {
+		return nil, xerrors.Errorf("marking new states: %w", err)
+	}
+
+	res := &PruneResult{Height: boundary}
+	err = remove.ForEach(func(c cid.Cid) error {
+		if keep.Has(c) {
+			return nil
+		}
+
+		size, err := cs.bs.GetSize(c)
+		if err != nil {
+			if err == bstore.ErrNotFound {
+				return nil
+			}
+			return xerrors.Errorf("getting size of %s: %w", c, err)
+		}
+
+		if !dryRun {
+			if err := cs.bs.DeleteBlock(c); err != nil {
+				return xerrors.Errorf("deleting %s: %w", c, err)
+			}
+		}
+
+		res.Objects++
+		res.Bytes += uint64(size)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// StateWriteLock returns the lock to hold while computing new states and
+// writing them to the blockstore. Writes skip objects which already exist,
+// so a state written while pruning could reference the objects the prune is
+// about to remove. Prune holds the lock exclusively while deleting, states
+// written before that must be reported with StateWritten.
+func (cs *ChainStore) StateWriteLock() sync.Locker {
+	return cs.pruneLk.RLocker()
+}
+
+// StateWritten records the roots of a state written under StateWriteLock, so
+// that a running prune keeps the objects they reference
+func (cs *ChainStore) StateWritten(roots ...cid.Cid) {
+	cs.pruneWritesLk.Lock()
+	defer cs.pruneWritesLk.Unlock()
+
+	if cs.pruneWrites != nil {
+		cs.pruneWrites = append(cs.pruneWrites, roots...)
+	}
+}
+
+// markStates marks the state trees and receipts referenced by tipsets from
+// ts down to the boundary height, stopping early at stop. The lowest tipset
+// walked is returned.
+func (cs *ChainStore) markStates(ctx context.Context, ts *types.TipSet, stop *types.TipSet, boundary uint64, keep *cid.Set) (*types.TipSet, error) {
+	for {
+		if stop != nil && ts.Equals(stop) {
+			return ts, nil
+		}
+
+		for _, b := range ts.Blocks() {
+			if err := cs.markDag(ctx, b.ParentStateRoot, keep); err != nil {
+				return nil, err
+			}
+			if err := cs.markDag(ctx, b.ParentMessageReceipts, keep); err != nil {
+				return nil, err
+			}
+		}
+
+		if ts.Height() <= boundary || ts.Height() == 0 {
+			return ts, nil
+		}
+
+		pts, err := cs.LoadTipSet(ts.Parents())
+		if err != nil {
+			return nil, xerrors.Errorf("loading parent tipset: %w", err)
+		}
+		ts = pts
+	}
+}
+
+func (cs *ChainStore) markDag(ctx context.Context, root cid.Cid, keep *cid.Set) error {
+	return cs.walkDag(ctx, root, func(c cid.Cid) bool {
+		return keep.Visit(c)
+	})
+}
+
+func (cs *ChainStore) sweepDag(ctx context.Context, root cid.Cid, keep, seen, remove *cid.Set) error {
+	return cs.walkDag(ctx, root, func(c cid.Cid) bool {
+		// objects referenced by retained states are kept along with
+		// everything they link to
+		if keep.Has(c) || !seen.Visit(c) {
+			return false
+		}
+		remove.Add(c)
+		return true
+	})
+}
+
+// walkDag walks the DAG under root, descending into the links of objects for
+// which visit returns true. Objects missing from the blockstore, for example
+// those already pruned, are skipped.
+func (cs *ChainStore) walkDag(ctx context.Context, root cid.Cid, visit func(cid.Cid) bool) error {
+	if root.Prefix().MhType == 0 {
+		// identity cid, data is inlined
+		return nil
+	}
+
+	if !visit(root) {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	if root.Prefix().Codec != cid.DagCBOR {
+		return nil
+	}
+
+	blk, err := cs.bs.Get(root)
+	if err != nil {
+		if err == bstore.ErrNotFound {
+			return nil
+		}
+		return xerrors.Errorf("getting object %s: %w", root, err)
+	}
+
+	links, err := cbg.ScanForLinks(bytes.NewReader(blk.RawData()))
+	if err != nil {
+		return xerrors.Errorf("scanning for links in %s: %w", root, err)
+	}
+
+	for _, l := range links {
+		if err := cs.walkDag(ctx, l, visit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
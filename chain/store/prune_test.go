@@ -0,0 +1,145 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/chain/gen"
+	"github.com/filecoin-project/lotus/chain/stmgr"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func TestPrune(t *testing.T) {
+	ctx := context.Background()
+
+	cg, err := gen.NewGenerator()
+	require.NoError(t, err)
+
+	var tipsets []*types.TipSet
+	for i := 0; i < 10; i++ {
+		mts, err := cg.NextTipSet()
+		require.NoError(t, err)
+		tipsets = append(tipsets, mts.TipSet.TipSet())
+	}
+
+	cs := cg.ChainStore()
+	head := tipsets[len(tipsets)-1]
+	require.NoError(t, cs.SetHead(head))
+
+	hasState := func(ts *types.TipSet) bool {
+		has, err := cs.Blockstore().Has(ts.ParentState())
+		require.NoError(t, err)
+		return has
+	}
+
+	boundary := tipsets[4]
+	retain := head.Height() - boundary.Height()
+
+	dry, err := cs.Prune(ctx, retain, true)
+	require.NoError(t, err)
+	require.Equal(t, boundary.Height(), dry.Height)
+	require.True(t, dry.Objects > 0)
+	require.True(t, hasState(tipsets[1]), "dry run removed state")
+
+	res, err := cs.Prune(ctx, retain, false)
+	require.NoError(t, err)
+	require.Equal(t, dry.Objects, res.Objects)
+	require.Equal(t, dry.Bytes, res.Bytes)
+
+	require.False(t, hasState(tipsets[1]))
+	require.False(t, hasState(tipsets[2]))
+
+	gents, err := types.NewTipSet([]*types.BlockHeader{cg.Genesis()})
+	require.NoError(t, err)
+	require.True(t, hasState(gents), "genesis state removed")
+
+	// retained states are complete, the head can still be executed on top of
+	// its parent state
+	for _, ts := range tipsets[4:] {
+		require.True(t, hasState(ts))
+	}
+	_, _, err = stmgr.NewStateManager(cs).TipSetState(ctx, head)
+	require.NoError(t, err)
+
+	// nothing left to remove
+	res, err = cs.Prune(ctx, retain, true)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), res.Objects)
+}
+
+func TestPruneDuringStateWrites(t *testing.T) {
+	ctx := context.Background()
+
+	cg, err := gen.NewGenerator()
+	require.NoError(t, err)
+
+	var tipsets []*types.TipSet
+	for i := 0; i < 10; i++ {
+		mts, err := cg.NextTipSet()
+		require.NoError(t, err)
+		tipsets = append(tipsets, mts.TipSet.TipSet())
+	}
+
+	cs := cg.ChainStore()
+	head := tipsets[len(tipsets)-1]
+	require.NoError(t, cs.SetHead(head))
+
+	hasState := func(ts *types.TipSet) bool {
+		has, err := cs.Blockstore().Has(ts.ParentState())
+		require.NoError(t, err)
+		return has
+	}
+
+	retain := head.Height() - tipsets[4].Height()
+
+	lk := cs.StateWriteLock()
+	lk.Lock()
+
+	// dry runs don't wait for state writes
+	dry := make(chan error, 1)
+	go func() {
+		_, err := cs.Prune(ctx, retain, true)
+		dry <- err
+	}()
+
+	select {
+	case err := <-dry:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("dry run waited for state writes")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := cs.Prune(ctx, retain, false)
+		done <- err
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("prune deleted objects while a state was being written")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// a state written while the prune runs, referencing old objects
+	cs.StateWritten(tipsets[1].ParentState())
+	lk.Unlock()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("prune didn't finish")
+	}
+
+	require.True(t, hasState(tipsets[1]), "state written while pruning removed")
+	require.False(t, hasState(tipsets[2]))
+
+	// it's only kept until the next prune
+	_, err = cs.Prune(ctx, retain, false)
+	require.NoError(t, err)
+	require.False(t, hasState(tipsets[1]))
+}
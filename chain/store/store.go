@@ -1,10 +1,12 @@
 package store
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"io"
 	"sync"
 
 	"github.com/filecoin-project/lotus/build"
@@ -20,6 +22,8 @@ import (
 
 	lru "github.com/hashicorp/golang-lru"
 	block "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-car"
+	carutil "github.com/ipfs/go-car/util"
 	"github.com/ipfs/go-cid"
 	dstore "github.com/ipfs/go-datastore"
 	hamt "github.com/ipfs/go-hamt-ipld"
@@ -51,6 +55,16 @@ type ChainStore struct {
 	headChangeNotifs []func(rev, app []*types.TipSet) error
 
 	mmCache *lru.ARCCache
+
+	msgIndex *MsgIndex
+
+	// pruneLk is held for reading while states are written, and exclusively
+	// while a prune deletes objects. pruneWrites collects the roots of states
+	// written while a prune runs, it's nil otherwise.
+	pruneLk       sync.RWMutex
+	pruneRunLk    sync.Mutex
+	pruneWritesLk sync.Mutex
+	pruneWrites   []cid.Cid
 }
 
 func NewChainStore(bs bstore.Blockstore, ds dstore.Batching) *ChainStore {
@@ -90,6 +104,9 @@ func NewChainStore(bs bstore.Blockstore, ds dstore.Batching) *ChainStore {
 
 	cs.headChangeNotifs = append(cs.headChangeNotifs, hcnf)
 
+	cs.msgIndex = newMsgIndex(cs, ds)
+	cs.headChangeNotifs = append(cs.headChangeNotifs, cs.msgIndex.headChange)
+
 	return cs
 }
 
@@ -115,6 +132,9 @@ func (cs *ChainStore) Load() error {
 
 	cs.heaviest = ts
 
+	// catching up with a long chain takes a while, don't block startup on it
+	cs.msgIndex.setHead(ts)
+
 	return nil
 }
 
@@ -404,6 +424,11 @@ func (cs *ChainStore) ReorgOps(a, b *types.TipSet) ([]*types.TipSet, []*types.Ti
 	return leftChain, rightChain, nil
 }
 
+// MsgIndex returns the index of messages executed on the current chain
+func (cs *ChainStore) MsgIndex() *MsgIndex {
+	return cs.msgIndex
+}
+
 func (cs *ChainStore) GetHeaviestTipSet() *types.TipSet {
 	cs.heaviestLk.Lock()
 	defer cs.heaviestLk.Unlock()
@@ -888,3 +913,130 @@ func (cr *chainRand) GetRandomness(ctx context.Context, h int64) ([]byte, error)
 	lb := (int64(cr.bh) + int64(len(cr.tickets))) - h
 	return cr.cs.GetRandomness(ctx, cr.blks, cr.tickets, lb)
 }
+
+// Export writes the chain ending at ts as a CAR file to w. Block headers and
+// messages are always included, state trees and receipts only if inclState
+// is set (the genesis state is always written, as it is needed to validate
+// the chain on import).
+func (cs *ChainStore) Export(ctx context.Context, ts *types.TipSet, inclState bool, w io.Writer) error {
+	if ts == nil {
+		ts = cs.GetHeaviestTipSet()
+	}
+
+	h := &car.CarHeader{
+		Roots:   ts.Cids(),
+		Version: 1,
+	}
+
+	if err := car.WriteHeader(h, w); err != nil {
+		return xerrors.Errorf("failed to write car header: %w", err)
+	}
+
+	seen := cid.NewSet()
+
+	writeObj := func(c cid.Cid) ([]byte, error) {
+		data, err := cs.bs.Get(c)
+		if err != nil {
+			return nil, xerrors.Errorf("getting object %s: %w", c, err)
+		}
+
+		if err := carutil.LdWrite(w, c.Bytes(), data.RawData()); err != nil {
+			return nil, xerrors.Errorf("writing object %s: %w", c, err)
+		}
+
+		return data.RawData(), nil
+	}
+
+	var writeDag func(root cid.Cid) error
+	writeDag = func(root cid.Cid) error {
+		if !seen.Visit(root) {
+			return nil
+		}
+		if root.Prefix().MhType == 0 {
+			// identity cid, data is inlined
+			return nil
+		}
+
+		data, err := writeObj(root)
+		if err != nil {
+			return err
+		}
+
+		if root.Prefix().Codec != cid.DagCBOR {
+			return nil
+		}
+
+		links, err := cbg.ScanForLinks(bytes.NewReader(data))
+		if err != nil {
+			return xerrors.Errorf("scanning for links in %s: %w", root, err)
+		}
+
+		for _, l := range links {
+			if err := writeDag(l); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	blocksToWalk := ts.Cids()
+
+	for len(blocksToWalk) > 0 {
+		next := blocksToWalk[0]
+		blocksToWalk = blocksToWalk[1:]
+		if !seen.Visit(next) {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		b, err := cs.GetBlock(next)
+		if err != nil {
+			return xerrors.Errorf("getting block %s: %w", next, err)
+		}
+
+		if _, err := writeObj(next); err != nil {
+			return xerrors.Errorf("writing block header: %w", err)
+		}
+
+		if err := writeDag(b.Messages); err != nil {
+			return xerrors.Errorf("writing messages of block %s: %w", next, err)
+		}
+
+		if b.Height == 0 || inclState {
+			if err := writeDag(b.ParentStateRoot); err != nil {
+				return xerrors.Errorf("writing state root of block %s: %w", next, err)
+			}
+			if err := writeDag(b.ParentMessageReceipts); err != nil {
+				return xerrors.Errorf("writing receipts of block %s: %w", next, err)
+			}
+		}
+
+		if b.Height > 0 {
+			blocksToWalk = append(blocksToWalk, b.Parents...)
+		}
+	}
+
+	return nil
+}
+
+// Import loads a CAR file written by Export into the blockstore and returns
+// the tipset it is rooted at. The caller is responsible for validating the
+// imported chain before using it.
+func (cs *ChainStore) Import(r io.Reader) (*types.TipSet, error) {
+	header, err := car.LoadCar(cs.Blockstore(), r)
+	if err != nil {
+		return nil, xerrors.Errorf("loadcar failed: %w", err)
+	}
+
+	root, err := cs.LoadTipSet(header.Roots)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load root tipset from chainfile: %w", err)
+	}
+
+	return root, nil
+}
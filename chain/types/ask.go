@@ -8,6 +8,8 @@ import (
 func init() {
 	cbor.RegisterCborType(SignedStorageAsk{})
 	cbor.RegisterCborType(StorageAsk{})
+	cbor.RegisterCborType(SignedRetrievalAsk{})
+	cbor.RegisterCborType(RetrievalAsk{})
 }
 
 type SignedStorageAsk struct {
@@ -25,3 +27,25 @@ type StorageAsk struct {
 	Expiry       uint64
 	SeqNo        uint64
 }
+
+type SignedRetrievalAsk struct {
+	Ask       *RetrievalAsk
+	Signature *Signature
+}
+
+type RetrievalAsk struct {
+	// Prices in attoFIL. UnsealPrice is paid with the first proposal of a
+	// retrieval deal
+	PricePerByte BigInt
+	UnsealPrice  BigInt
+
+	// PaymentInterval is the number of bytes sent for each payment, it grows
+	// by PaymentIntervalIncrease after every payment
+	PaymentInterval         uint64
+	PaymentIntervalIncrease uint64
+
+	Miner     address.Address
+	Timestamp uint64
+	Expiry    uint64
+	SeqNo     uint64
+}
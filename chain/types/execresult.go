@@ -0,0 +1,11 @@
+package types
+
+// ExecutionTrace records a single send performed while executing a message,
+// along with all the sends it made in turn
+type ExecutionTrace struct {
+	Msg    *Message
+	MsgRct *MessageReceipt
+	Error  string
+
+	Subcalls []*ExecutionTrace
+}
@@ -10,6 +10,7 @@ import (
 	cbg "github.com/whyrusleeping/cbor-gen"
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/actors"
 	"github.com/filecoin-project/lotus/chain/actors/aerrors"
 	"github.com/filecoin-project/lotus/chain/types"
@@ -20,6 +21,23 @@ type invoker struct {
 	builtInState map[cid.Cid]reflect.Type
 }
 
+// methodUpgrades maps methods added to actors after genesis to the height from
+// which they can be called. Before that, calls fail as if the method didn't
+// exist.
+var methodUpgrades = map[cid.Cid]map[uint64]uint64{
+	actors.StorageMinerCodeCid: {
+		actors.MAMethods.ChangeWorker: build.UpgradeMinerKeyChangeHeight,
+		actors.MAMethods.ChangeOwner:  build.UpgradeMinerKeyChangeHeight,
+
+		actors.MAMethods.ExtendSectorExpiration: build.UpgradeSectorExpirationHeight,
+
+		actors.MAMethods.DeclareFaults: build.UpgradeDeclareFaultsHeight,
+	},
+	actors.StorageMarketCodeCid: {
+		actors.SMAMethods.GetLastExpirationFromDealIDs: build.UpgradeSectorExpirationHeight,
+	},
+}
+
 type invokeFunc func(act *types.Actor, vmctx types.VMContext, params []byte) ([]byte, aerrors.ActorError)
 type nativeCode []invokeFunc
 
@@ -50,6 +68,9 @@ func (inv *invoker) Invoke(act *types.Actor, vmctx types.VMContext, method uint6
 	if method >= uint64(len(code)) || code[method] == nil {
 		return nil, aerrors.Newf(255, "no method %d on actor", method)
 	}
+	if h, ok := methodUpgrades[act.Code][method]; ok && vmctx.BlockHeight() < h {
+		return nil, aerrors.Newf(255, "no method %d on actor", method)
+	}
 	return code[method](act, vmctx, params)
 
 }
@@ -59,6 +59,10 @@ type VMContext struct {
 
 	// address that started invoke chain
 	origin address.Address
+
+	// traces of sends made from this context, only recorded when tracing
+	// is enabled on the VM
+	subcalls []*types.ExecutionTrace
 }
 
 // Message is the message that kicked off the current invocation
@@ -151,10 +155,32 @@ func (vmc *VMContext) Send(to address.Address, method uint64, value types.BigInt
 		GasLimit: vmc.gasAvailable,
 	}
 
-	ret, err, _ := vmc.vm.send(ctx, msg, vmc, 0)
+	gasBefore := vmc.gasUsed
+	ret, err, sub := vmc.vm.send(ctx, msg, vmc, 0)
+	if vmc.vm.tracing {
+		vmc.subcalls = append(vmc.subcalls, makeTrace(msg, ret, err, types.BigSub(vmc.gasUsed, gasBefore), sub))
+	}
 	return ret, err
 }
 
+func makeTrace(msg *types.Message, ret []byte, err aerrors.ActorError, gasUsed types.BigInt, vmctx *VMContext) *types.ExecutionTrace {
+	t := &types.ExecutionTrace{
+		Msg: msg,
+		MsgRct: &types.MessageReceipt{
+			ExitCode: aerrors.RetCode(err),
+			Return:   ret,
+			GasUsed:  gasUsed,
+		},
+	}
+	if err != nil {
+		t.Error = err.Error()
+	}
+	if vmctx != nil {
+		t.Subcalls = vmctx.subcalls
+	}
+	return t
+}
+
 // BlockHeight returns the height of the block this message was added to the chain in
 func (vmc *VMContext) BlockHeight() uint64 {
 	return vmc.height
@@ -304,6 +330,7 @@ type VM struct {
 	blockMiner  address.Address
 	inv         *invoker
 	rand        Rand
+	tracing     bool
 }
 
 func NewVM(base cid.Cid, height uint64, r Rand, maddr address.Address, cbs blockstore.Blockstore) (*VM, error) {
@@ -326,6 +353,12 @@ func NewVM(base cid.Cid, height uint64, r Rand, maddr address.Address, cbs block
 	}, nil
 }
 
+// SetTracing enables recording of execution traces, which are then returned
+// in the ApplyRet of applied messages
+func (vm *VM) SetTracing(enabled bool) {
+	vm.tracing = enabled
+}
+
 type Rand interface {
 	GetRandomness(ctx context.Context, h int64) ([]byte, error)
 }
@@ -333,6 +366,9 @@ type Rand interface {
 type ApplyRet struct {
 	types.MessageReceipt
 	ActorErr aerrors.ActorError
+
+	// ExecutionTrace is only set when tracing is enabled on the VM
+	ExecutionTrace *types.ExecutionTrace
 }
 
 func (vm *VM) send(ctx context.Context, msg *types.Message, parent *VMContext,
@@ -497,13 +533,19 @@ func (vm *VM) ApplyMessage(ctx context.Context, msg *types.Message) (*ApplyRet,
 		return nil, xerrors.Errorf("gas handling math is wrong")
 	}
 
+	var et *types.ExecutionTrace
+	if vm.tracing {
+		et = makeTrace(msg, ret, actorErr, gasUsed, vmctx)
+	}
+
 	return &ApplyRet{
 		MessageReceipt: types.MessageReceipt{
 			ExitCode: errcode,
 			Return:   ret,
 			GasUsed:  gasUsed,
 		},
-		ActorErr: actorErr,
+		ActorErr:       actorErr,
+		ExecutionTrace: et,
 	}, nil
 }
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
@@ -25,6 +26,8 @@ var chainCmd = &cli.Command{
 		chainGetMsgCmd,
 		chainSetHeadCmd,
 		chainListCmd,
+		chainExportCmd,
+		chainPruneCmd,
 	},
 }
 
@@ -347,3 +350,105 @@ func printTipSet(format string, ts *types.TipSet) {
 
 	fmt.Println(format)
 }
+
+var chainExportCmd = &cli.Command{
+	Name:      "export",
+	Usage:     "export chain to a car file",
+	ArgsUsage: "[outputPath]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "tipset",
+			Usage: "comma separated block cids of the tipset to export from (defaults to chain head)",
+		},
+		&cli.BoolFlag{
+			Name:  "include-state-roots",
+			Usage: "also export the state trees and receipts of every tipset",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		if !cctx.Args().Present() {
+			return fmt.Errorf("must specify filename to export chain to")
+		}
+
+		var tsk types.TipSetKey
+		if cctx.IsSet("tipset") {
+			ts, err := parseTipSet(api, ctx, strings.Split(cctx.String("tipset"), ","))
+			if err != nil {
+				return err
+			}
+			tsk = ts.Key()
+		}
+
+		fi, err := os.Create(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+		defer fi.Close()
+
+		stream, err := api.ChainExport(ctx, tsk, cctx.Bool("include-state-roots"))
+		if err != nil {
+			return err
+		}
+
+		for chunk := range stream {
+			if chunk.Err != "" {
+				return xerrors.Errorf("chain export failed: %s", chunk.Err)
+			}
+
+			if _, err := fi.Write(chunk.Data); err != nil {
+				return err
+			}
+
+			if chunk.Done {
+				return nil
+			}
+		}
+
+		return xerrors.Errorf("chain export stream ended before the export finished")
+	},
+}
+
+var chainPruneCmd = &cli.Command{
+	Name:        "prune",
+	Usage:       "remove old state trees and receipts from the chain blockstore",
+	Description: "receipts of pruned tipsets are removed too, so the results of old messages can't be looked up anymore",
+	Flags: []cli.Flag{
+		&cli.Uint64Flag{
+			Name:  "retain-finalities",
+			Usage: "number of finality windows below the chain head to keep state for",
+			Value: 2,
+		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "only report how much space would be reclaimed",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		res, err := api.ChainPrune(ctx, cctx.Uint64("retain-finalities"), cctx.Bool("dry-run"))
+		if err != nil {
+			return err
+		}
+
+		verb := "Removed"
+		if cctx.Bool("dry-run") {
+			verb = "Would remove"
+		}
+		fmt.Printf("%s %d objects (%d bytes) of state below height %d\n", verb, res.Objects, res.Bytes, res.Height)
+
+		return nil
+	},
+}
@@ -5,9 +5,12 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime/encoding/dagjson"
+	ipldfree "github.com/ipld/go-ipld-prime/impl/free"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"golang.org/x/xerrors"
 	"gopkg.in/urfave/cli.v2"
@@ -16,6 +19,7 @@ import (
 	actors "github.com/filecoin-project/lotus/chain/actors"
 	"github.com/filecoin-project/lotus/chain/address"
 	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/ipldutil"
 )
 
 var clientCmd = &cli.Command{
@@ -29,6 +33,7 @@ var clientCmd = &cli.Command{
 		clientRetrieveCmd,
 		clientQueryAskCmd,
 		clientListDeals,
+		clientListRetrievals,
 	},
 }
 
@@ -182,6 +187,18 @@ var clientRetrieveCmd = &cli.Command{
 			Name:  "address",
 			Usage: "address to use for transactions",
 		},
+		&cli.Uint64Flag{
+			Name:  "offset",
+			Usage: "offset of the first byte to retrieve",
+		},
+		&cli.Uint64Flag{
+			Name:  "length",
+			Usage: "number of bytes to retrieve, everything after the offset if not set",
+		},
+		&cli.StringFlag{
+			Name:  "selector",
+			Usage: "dag-json IPLD selector, the selected blocks are written to outfile as a CAR",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		if cctx.NArg() != 2 {
@@ -236,6 +253,23 @@ var clientRetrieveCmd = &cli.Command{
 		}
 		order := offers[0].Order()
 		order.Client = payer
+		order.Offset = cctx.Uint64("offset")
+		order.Length = cctx.Uint64("length")
+
+		if sel := cctx.String("selector"); sel != "" {
+			if order.Offset != 0 || order.Length != 0 {
+				return xerrors.New("--selector can't be used with --offset or --length")
+			}
+
+			nd, err := dagjson.Decoder(ipldfree.NodeBuilder(), strings.NewReader(sel))
+			if err != nil {
+				return xerrors.Errorf("parsing selector: %w", err)
+			}
+			order.Selector, err = ipldutil.EncodeNode(nd)
+			if err != nil {
+				return err
+			}
+		}
 
 		if err := api.ClientRetrieve(ctx, order, cctx.Args().Get(1)); err != nil {
 			return err
@@ -298,11 +332,11 @@ var clientQueryAskCmd = &cli.Command{
 				return xerrors.Errorf("failed to get peerID for miner: %w", err)
 			}
 
-			if ret.ExitCode != 0 {
-				return fmt.Errorf("call to GetPeerID was unsuccesful (exit code %d)", ret.ExitCode)
+			if ret.Receipt.ExitCode != 0 {
+				return fmt.Errorf("call to GetPeerID was unsuccesful (exit code %d)", ret.Receipt.ExitCode)
 			}
 
-			p, err := peer.IDFromBytes(ret.Return)
+			p, err := peer.IDFromBytes(ret.Receipt.Return)
 			if err != nil {
 				return err
 			}
@@ -359,3 +393,28 @@ var clientListDeals = &cli.Command{
 		return w.Flush()
 	},
 }
+
+var clientListRetrievals = &cli.Command{
+	Name:  "list-retrievals",
+	Usage: "List retrievals made by the client",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		retrievals, err := api.ClientListRetrievals(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "ID\tRoot\tMiner\tState\tRange\tReceived\tSpent\tMessage\n")
+		for _, r := range retrievals {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d+%d\t%d\t%s\t%s\n", r.ID, r.Root, r.Miner, lapi.RetrievalStates[r.State], r.Offset, r.Length, r.BytesReceived, types.FIL(r.FundsSpent), r.Message)
+		}
+		return w.Flush()
+	},
+}
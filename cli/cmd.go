@@ -53,6 +53,12 @@ func getAPI(ctx *cli.Context, repoFlag string) (string, http.Header, error) {
 	return "ws://" + addr + "/rpc/v0", headers, nil
 }
 
+// GetRawAPI returns the API endpoint and auth headers of the node in the repo
+// pointed to by repoFlag
+func GetRawAPI(ctx *cli.Context, repoFlag string) (string, http.Header, error) {
+	return getAPI(ctx, repoFlag)
+}
+
 func GetAPI(ctx *cli.Context) (api.Common, jsonrpc.ClientCloser, error) {
 	f := "repo"
 	if ctx.String("storagerepo") != "" {
@@ -117,6 +123,7 @@ var Commands = []*cli.Command{
 	createMinerCmd,
 	fetchParamCmd,
 	mpoolCmd,
+	msigCmd,
 	netCmd,
 	paychCmd,
 	sendCmd,
@@ -0,0 +1,298 @@
+package cli
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+	"gopkg.in/urfave/cli.v2"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/address"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+var msigCmd = &cli.Command{
+	Name:  "msig",
+	Usage: "Interact with a multisig wallet",
+	Subcommands: []*cli.Command{
+		msigCreateCmd,
+		msigInspectCmd,
+		msigProposeCmd,
+		msigApproveCmd,
+		msigCancelCmd,
+	},
+}
+
+var msigSourceFlag = &cli.StringFlag{
+	Name:  "source",
+	Usage: "account to send the message from (defaults to the default wallet address)",
+}
+
+var msigCreateCmd = &cli.Command{
+	Name:      "create",
+	Usage:     "Create a new multisig wallet",
+	ArgsUsage: "[address1 address2 ...]",
+	Flags: []cli.Flag{
+		&cli.Uint64Flag{
+			Name:  "required",
+			Usage: "number of approvals required to execute a transaction (defaults to all signers)",
+		},
+		&cli.StringFlag{
+			Name:  "value",
+			Usage: "initial funds to give to the multisig, in FIL",
+			Value: "0",
+		},
+		&cli.Uint64Flag{
+			Name:  "duration",
+			Usage: "number of blocks over which the initial funds unlock",
+		},
+		msigSourceFlag,
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() < 1 {
+			return fmt.Errorf("multisigs must have at least one signer")
+		}
+
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		var signers []address.Address
+		for _, a := range cctx.Args().Slice() {
+			addr, err := address.NewFromString(a)
+			if err != nil {
+				return fmt.Errorf("failed to parse signer address %q: %w", a, err)
+			}
+			signers = append(signers, addr)
+		}
+
+		required := cctx.Uint64("required")
+		if required == 0 {
+			required = uint64(len(signers))
+		}
+
+		val, err := types.ParseFIL(cctx.String("value"))
+		if err != nil {
+			return err
+		}
+
+		src, err := msigSource(ctx, api, cctx)
+		if err != nil {
+			return err
+		}
+
+		mcid, err := api.MsigCreate(ctx, required, signers, cctx.Uint64("duration"), types.BigInt(val), src)
+		if err != nil {
+			return err
+		}
+
+		wait, err := api.StateWaitMsg(ctx, mcid)
+		if err != nil {
+			return err
+		}
+
+		if wait.Receipt.ExitCode != 0 {
+			return xerrors.Errorf("multisig creation failed (exit code %d)", wait.Receipt.ExitCode)
+		}
+
+		addr, err := address.NewFromBytes(wait.Receipt.Return)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Created new multisig: ", addr)
+		return nil
+	},
+}
+
+var msigInspectCmd = &cli.Command{
+	Name:      "inspect",
+	Usage:     "Inspect a multisig wallet",
+	ArgsUsage: "[address]",
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Args().Present() {
+			return fmt.Errorf("must specify address of multisig to inspect")
+		}
+
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		maddr, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		act, err := api.StateGetActor(ctx, maddr, nil)
+		if err != nil {
+			return err
+		}
+
+		avail, err := api.MsigGetAvailableBalance(ctx, maddr, nil)
+		if err != nil {
+			return err
+		}
+
+		pending, err := api.MsigGetPending(ctx, maddr, nil)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Balance: %s\n", types.FIL(act.Balance))
+		fmt.Printf("Spendable: %s\n", types.FIL(avail))
+
+		fmt.Println("Pending Transactions:")
+		w := tabwriter.NewWriter(os.Stdout, 8, 4, 0, ' ', 0)
+		fmt.Fprintf(w, "ID\tTo\tValue\tMethod\tParams\tApprovals\n")
+		for _, tx := range pending {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%x\t%d\n", tx.TxID, tx.To, types.FIL(tx.Value), tx.Method, tx.Params, len(tx.Approved))
+		}
+		return w.Flush()
+	},
+}
+
+var msigProposeCmd = &cli.Command{
+	Name:      "propose",
+	Usage:     "Propose a multisig transaction",
+	ArgsUsage: "[multisigAddress destinationAddress value]",
+	Flags: []cli.Flag{
+		&cli.Uint64Flag{
+			Name:  "method",
+			Usage: "method to call on the destination",
+		},
+		&cli.StringFlag{
+			Name:  "params",
+			Usage: "hex encoded parameters of the method call",
+		},
+		msigSourceFlag,
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 3 {
+			return fmt.Errorf("must pass multisig address, destination and value")
+		}
+
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		msig, err := address.NewFromString(cctx.Args().Get(0))
+		if err != nil {
+			return err
+		}
+
+		dest, err := address.NewFromString(cctx.Args().Get(1))
+		if err != nil {
+			return err
+		}
+
+		val, err := types.ParseFIL(cctx.Args().Get(2))
+		if err != nil {
+			return err
+		}
+
+		params, err := hex.DecodeString(cctx.String("params"))
+		if err != nil {
+			return xerrors.Errorf("failed to decode params: %w", err)
+		}
+
+		src, err := msigSource(ctx, api, cctx)
+		if err != nil {
+			return err
+		}
+
+		mcid, err := api.MsigPropose(ctx, msig, dest, types.BigInt(val), src, cctx.Uint64("method"), params)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("sent proposal in message: ", mcid)
+		return nil
+	},
+}
+
+var msigApproveCmd = &cli.Command{
+	Name:      "approve",
+	Usage:     "Approve a multisig transaction",
+	ArgsUsage: "[multisigAddress txId]",
+	Flags: []cli.Flag{
+		msigSourceFlag,
+	},
+	Action: func(cctx *cli.Context) error {
+		return msigTxCmd(cctx, "approval", func(ctx context.Context, api api.FullNode, msig address.Address, txid uint64, src address.Address) (cid.Cid, error) {
+			return api.MsigApprove(ctx, msig, txid, src)
+		})
+	},
+}
+
+var msigCancelCmd = &cli.Command{
+	Name:      "cancel",
+	Usage:     "Cancel a multisig transaction",
+	ArgsUsage: "[multisigAddress txId]",
+	Flags: []cli.Flag{
+		msigSourceFlag,
+	},
+	Action: func(cctx *cli.Context) error {
+		return msigTxCmd(cctx, "cancellation", func(ctx context.Context, api api.FullNode, msig address.Address, txid uint64, src address.Address) (cid.Cid, error) {
+			return api.MsigCancel(ctx, msig, txid, src)
+		})
+	},
+}
+
+func msigTxCmd(cctx *cli.Context, what string, send func(context.Context, api.FullNode, address.Address, uint64, address.Address) (cid.Cid, error)) error {
+	if cctx.Args().Len() != 2 {
+		return fmt.Errorf("must pass multisig address and transaction id")
+	}
+
+	api, closer, err := GetFullNodeAPI(cctx)
+	if err != nil {
+		return err
+	}
+	defer closer()
+	ctx := ReqContext(cctx)
+
+	msig, err := address.NewFromString(cctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+
+	txid, err := strconv.ParseUint(cctx.Args().Get(1), 10, 64)
+	if err != nil {
+		return xerrors.Errorf("failed to parse transaction id: %w", err)
+	}
+
+	src, err := msigSource(ctx, api, cctx)
+	if err != nil {
+		return err
+	}
+
+	mcid, err := send(ctx, api, msig, txid, src)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("sent %s in message: %s\n", what, mcid)
+	return nil
+}
+
+func msigSource(ctx context.Context, api api.FullNode, cctx *cli.Context) (address.Address, error) {
+	if src := cctx.String("source"); src != "" {
+		return address.NewFromString(src)
+	}
+
+	return api.WalletDefaultAddress(ctx)
+}
@@ -2,11 +2,13 @@ package cli
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/filecoin-project/lotus/chain/address"
 	"github.com/filecoin-project/lotus/chain/types"
 
 	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
 	"gopkg.in/urfave/cli.v2"
 )
 
@@ -22,6 +24,7 @@ var stateCmd = &cli.Command{
 		stateListMinersCmd,
 		stateGetActorCmd,
 		stateLookupIDCmd,
+		stateReplaySetCmd,
 	},
 }
 
@@ -130,6 +133,12 @@ var stateProvingSetCmd = &cli.Command{
 var stateReplaySetCmd = &cli.Command{
 	Name:  "replay",
 	Usage: "Replay a particular message within a tipset",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "trace",
+			Usage: "print the execution trace of the message",
+		},
+	},
 	Action: func(cctx *cli.Context) error {
 		if cctx.Args().Len() < 2 {
 			fmt.Println("usage: <tipset> <message cid>")
@@ -176,12 +185,46 @@ var stateReplaySetCmd = &cli.Command{
 			return err
 		}
 
-		api.StateReplay(ctx, ts, mcid)
+		res, err := api.StateReplay(ctx, ts, mcid)
+		if err != nil {
+			return xerrors.Errorf("replay call failed: %w", err)
+		}
+
+		fmt.Println("Replay receipt:")
+		fmt.Printf("Exit code: %d\n", res.Receipt.ExitCode)
+		fmt.Printf("Return: %x\n", res.Receipt.Return)
+		fmt.Printf("Gas Used: %s\n", res.Receipt.GasUsed)
+		if res.Receipt.ExitCode != 0 {
+			fmt.Printf("Error message: %q\n", res.Error)
+		}
+
+		if cctx.Bool("trace") && res.ExecutionTrace != nil {
+			fmt.Println("Execution trace:")
+			printExecutionTrace(res.ExecutionTrace, 1)
+		}
 
 		return nil
 	},
 }
 
+func printExecutionTrace(t *types.ExecutionTrace, depth int) {
+	indent := strings.Repeat("  ", depth)
+	fmt.Printf("%s%s -> %s (method %d, value %s): exit %d, gas %s\n", indent, t.Msg.From, t.Msg.To, t.Msg.Method, t.Msg.Value, t.MsgRct.ExitCode, t.MsgRct.GasUsed)
+	if len(t.Msg.Params) > 0 {
+		fmt.Printf("%s  params: %x\n", indent, t.Msg.Params)
+	}
+	if len(t.MsgRct.Return) > 0 {
+		fmt.Printf("%s  return: %x\n", indent, t.MsgRct.Return)
+	}
+	if t.Error != "" {
+		fmt.Printf("%s  error: %s\n", indent, t.Error)
+	}
+
+	for _, sub := range t.Subcalls {
+		printExecutionTrace(sub, depth+1)
+	}
+}
+
 var statePledgeCollateralCmd = &cli.Command{
 	Name:  "pledge-collateral",
 	Usage: "Get minimum miner pledge collateral",
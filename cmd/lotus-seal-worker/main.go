@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	logging "github.com/ipfs/go-log"
+	"github.com/mitchellh/go-homedir"
+	"golang.org/x/xerrors"
+	"gopkg.in/urfave/cli.v2"
+
+	"github.com/filecoin-project/lotus/build"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var log = logging.Logger("main")
+
+func main() {
+	logging.SetLogLevel("*", "INFO")
+
+	log.Info("Starting lotus worker")
+
+	local := []*cli.Command{
+		runCmd,
+	}
+
+	app := &cli.App{
+		Name:    "lotus-seal-worker",
+		Usage:   "Remote storage miner worker",
+		Version: build.Version,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "repo",
+				EnvVars: []string{"WORKER_PATH"},
+				Value:   "~/.lotusworker", // TODO: Consider XDG_DATA_HOME
+			},
+			&cli.StringFlag{
+				Name:    "storagerepo",
+				EnvVars: []string{"LOTUS_STORAGE_PATH"},
+				Value:   "~/.lotusstorage", // TODO: Consider XDG_DATA_HOME
+			},
+		},
+
+		Commands: local,
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		log.Warnf("%+v", err)
+		os.Exit(1)
+	}
+}
+
+var runCmd = &cli.Command{
+	Name:  "run",
+	Usage: "Start lotus worker",
+	Flags: []cli.Flag{
+		&cli.UintFlag{
+			Name:  "threads",
+			Usage: "number of sealing tasks to run in parallel",
+			Value: 1,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return xerrors.Errorf("getting miner api: %w", err)
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		ainfo, headers, err := lcli.GetRawAPI(cctx, "storagerepo")
+		if err != nil {
+			return xerrors.Errorf("could not get api info: %w", err)
+		}
+		// the rpc endpoint is ws://<addr>/rpc/v0, sector data is served over http
+		minerEndpoint := "http://" + strings.TrimSuffix(strings.TrimPrefix(ainfo, "ws://"), "/rpc/v0")
+
+		v, err := nodeApi.Version(ctx)
+		if err != nil {
+			return err
+		}
+		if v.APIVersion != build.APIVersion {
+			return xerrors.Errorf("lotus-storage-miner API version doesn't match: local: %d, remote: %d", build.APIVersion, v.APIVersion)
+		}
+
+		repo, err := homedir.Expand(cctx.String("repo"))
+		if err != nil {
+			return err
+		}
+
+		if err := build.GetParams(true, false); err != nil {
+			return xerrors.Errorf("fetching proof parameters: %w", err)
+		}
+
+		go func() {
+			<-ctx.Done()
+			log.Warn("Shutting down..")
+		}()
+
+		return acceptJobs(ctx, nodeApi, minerEndpoint, headers, repo, cctx.Uint("threads"))
+	},
+}
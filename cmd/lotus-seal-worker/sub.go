@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/lib/sectorbuilder"
+)
+
+type worker struct {
+	api           api.StorageMiner
+	minerEndpoint string
+	auth          http.Header
+
+	sb *sectorbuilder.SectorBuilder
+}
+
+func acceptJobs(ctx context.Context, api api.StorageMiner, endpoint string, auth http.Header, repo string, threads uint) error {
+	if threads == 0 || threads > math.MaxUint8 {
+		return xerrors.Errorf("invalid number of threads: %d", threads)
+	}
+
+	act, err := api.ActorAddress(ctx)
+	if err != nil {
+		return err
+	}
+	ssize, err := api.ActorSectorSize(ctx, act)
+	if err != nil {
+		return err
+	}
+
+	sb, err := sectorbuilder.NewStandalone(&sectorbuilder.Config{
+		SectorSize:    ssize,
+		Miner:         act,
+		WorkerThreads: uint8(threads),
+
+		CacheDir:  filepath.Join(repo, "cache"),
+		SealedDir: filepath.Join(repo, "sealed"),
+		StagedDir: filepath.Join(repo, "staging"),
+	})
+	if err != nil {
+		return err
+	}
+	defer sb.Destroy()
+
+	w := &worker{
+		api:           api,
+		minerEndpoint: endpoint,
+		auth:          auth,
+		sb:            sb,
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, threads)
+	for i := uint(0); i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- w.processQueue(ctx)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// processQueue registers with the miner as a single worker, and processes
+// tasks sent to it one at a time
+func (w *worker) processQueue(ctx context.Context) error {
+	tasks, err := w.api.WorkerQueue(ctx)
+	if err != nil {
+		return xerrors.Errorf("registering worker: %w", err)
+	}
+
+	log.Info("Waiting for new tasks")
+
+	for {
+		select {
+		case task, ok := <-tasks:
+			if !ok {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return xerrors.New("task queue closed by the miner")
+			}
+
+			log.Infof("New task: %d, sector %d, action: %s", task.TaskID, task.SectorID, task.Type)
+
+			res := w.processTask(task)
+
+			log.Infof("Task %d done, err: %+v", task.TaskID, res.GoErr)
+
+			if err := w.api.WorkerDone(ctx, task.TaskID, res); err != nil {
+				log.Errorf("reporting result of task %d: %+v", task.TaskID, err)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (w *worker) processTask(task sectorbuilder.WorkerTask) sectorbuilder.SealRes {
+	var res sectorbuilder.SealRes
+
+	switch task.Type {
+	case sectorbuilder.WorkerAddPiece:
+		// the piece is written to an empty staged sector, the miner adds it
+		// to its staged sector after the existing pieces
+		if err := w.remove("staged", task.SectorID); err != nil {
+			return errRes(xerrors.Errorf("cleaning up staged sector: %w", err))
+		}
+
+		piece, err := w.fetchPiece(task.TaskID)
+		if err != nil {
+			return errRes(xerrors.Errorf("fetching piece: %w", err))
+		}
+		defer piece.Close()
+
+		res.Piece, err = w.sb.AddPiece(task.PieceSize, task.SectorID, piece, nil)
+		if err != nil {
+			return errRes(xerrors.Errorf("adding piece: %w", err))
+		}
+
+		if err := w.pushPiece(task.TaskID, task.SectorID); err != nil {
+			return errRes(xerrors.Errorf("pushing piece: %w", err))
+		}
+	case sectorbuilder.WorkerPreCommit:
+		if err := w.fetchSector(task.SectorID, "staged"); err != nil {
+			return errRes(xerrors.Errorf("fetching staged sector: %w", err))
+		}
+
+		rspco, err := w.sb.SealPreCommit(task.SectorID, task.SealTicket, task.Pieces)
+		if err != nil {
+			return errRes(xerrors.Errorf("precommitting: %w", err))
+		}
+		res.Rspco = rspco
+
+		if err := w.push("sealed", task.SectorID); err != nil {
+			return errRes(xerrors.Errorf("pushing sealed sector: %w", err))
+		}
+		if err := w.push("cache", task.SectorID); err != nil {
+			return errRes(xerrors.Errorf("pushing sector cache: %w", err))
+		}
+	case sectorbuilder.WorkerCommit:
+		if err := w.fetchSector(task.SectorID, "sealed"); err != nil {
+			return errRes(xerrors.Errorf("fetching sealed sector: %w", err))
+		}
+		if err := w.fetchSector(task.SectorID, "cache"); err != nil {
+			return errRes(xerrors.Errorf("fetching sector cache: %w", err))
+		}
+
+		proof, err := w.sb.SealCommit(task.SectorID, task.SealTicket, task.SealSeed, task.Pieces, nil, task.Rspco)
+		if err != nil {
+			return errRes(xerrors.Errorf("committing: %w", err))
+		}
+		res.Proof = proof
+	default:
+		return errRes(xerrors.Errorf("unknown task type %d", task.Type))
+	}
+
+	// the miner has everything it needs now
+	for _, typ := range []string{"staged", "sealed", "cache"} {
+		if err := w.remove(typ, task.SectorID); err != nil {
+			log.Warnf("cleaning up %s files of sector %d: %+v", typ, task.SectorID, err)
+		}
+	}
+
+	return res
+}
+
+func errRes(err error) sectorbuilder.SealRes {
+	return sectorbuilder.SealRes{Err: err.Error(), GoErr: err}
+}
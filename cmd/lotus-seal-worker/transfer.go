@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/lib/sectorbuilder"
+	"github.com/filecoin-project/lotus/lib/tarutil"
+)
+
+func (w *worker) sectorURL(typ string, sectorID uint64) string {
+	return w.minerEndpoint + "/remote/" + typ + "/" + w.sb.SectorName(sectorID)
+}
+
+func (w *worker) request(method string, url string, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, xerrors.Errorf("new request: %w", err)
+	}
+
+	for k, v := range w.auth {
+		req.Header[k] = v
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, xerrors.Errorf("do request: %w", err)
+	}
+
+	if resp.StatusCode != 200 {
+		_ = resp.Body.Close()
+		return nil, xerrors.Errorf("%s %s: non-200 code: %d", method, url, resp.StatusCode)
+	}
+
+	return resp, nil
+}
+
+func (w *worker) fetchSector(sectorID uint64, typ string) error {
+	outname, err := w.sb.AllocPath(typ, w.sb.SectorName(sectorID))
+	if err != nil {
+		return err
+	}
+
+	url := w.sectorURL(typ, sectorID)
+	log.Infof("Fetch %s %s", typ, url)
+
+	resp, err := w.request("GET", url, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := os.RemoveAll(outname); err != nil {
+		return xerrors.Errorf("removing dest: %w", err)
+	}
+
+	switch resp.Header.Get("Content-Type") {
+	case "application/x-tar":
+		return tarutil.ExtractTar(resp.Body, outname)
+	case "application/octet-stream":
+		f, err := os.Create(outname)
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(f, resp.Body); err != nil {
+			_ = f.Close()
+			return err
+		}
+
+		return f.Close()
+	default:
+		return xerrors.Errorf("unknown content type: '%s'", resp.Header.Get("Content-Type"))
+	}
+}
+
+func (w *worker) fetchPiece(taskID uint64) (io.ReadCloser, error) {
+	url := fmt.Sprintf("%s/remote/piece/%d", w.minerEndpoint, taskID)
+	log.Infof("Fetch piece %s", url)
+
+	resp, err := w.request("GET", url, nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+func (w *worker) push(typ string, sectorID uint64) error {
+	filename, err := w.sb.GetPath(typ, w.sb.SectorName(sectorID))
+	if err != nil {
+		return err
+	}
+
+	url := w.sectorURL(typ, sectorID)
+	log.Infof("Push %s %s", typ, url)
+
+	stat, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+
+	var r io.ReadCloser
+	var contentType string
+	if stat.IsDir() {
+		r, err = tarutil.TarDirectory(filename)
+		contentType = "application/x-tar"
+	} else {
+		r, err = os.Open(filename)
+		contentType = "application/octet-stream"
+	}
+	if err != nil {
+		return xerrors.Errorf("opening push reader: %w", err)
+	}
+	defer r.Close()
+
+	resp, err := w.request("PUT", url, r, contentType)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// pushPiece uploads the padded piece of an add piece task, written alone to the
+// staged sector
+func (w *worker) pushPiece(taskID uint64, sectorID uint64) error {
+	filename, err := w.sb.GetPath("staged", w.sb.SectorName(sectorID))
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/remote/piece/%d", w.minerEndpoint, taskID)
+	log.Infof("Push piece %s", url)
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return xerrors.Errorf("opening push reader: %w", err)
+	}
+	defer f.Close()
+
+	resp, err := w.request("PUT", url, f, "application/octet-stream")
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+func (w *worker) remove(typ string, sectorID uint64) error {
+	filename, err := w.sb.GetPath(typ, w.sb.SectorName(sectorID))
+	if err != nil {
+		if xerrors.Is(err, sectorbuilder.ErrSectorNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	return os.RemoveAll(filename)
+}
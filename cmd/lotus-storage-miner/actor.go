@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+	"gopkg.in/urfave/cli.v2"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/address"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var actorCmd = &cli.Command{
+	Name:  "actor",
+	Usage: "manipulate the miner actor",
+	Subcommands: []*cli.Command{
+		actorSetWorkerCmd,
+		actorSetOwnerCmd,
+		actorAcceptOwnerCmd,
+	},
+}
+
+var actorSetWorkerCmd = &cli.Command{
+	Name:      "set-worker",
+	Usage:     "change the worker key of the miner, waiting for the change to take effect",
+	ArgsUsage: "[newWorkerAddress]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "no-wait",
+			Usage: "don't wait for the new worker key to take effect",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Args().Present() {
+			return fmt.Errorf("must pass the address of the new worker")
+		}
+
+		newWorker, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		api, maddr, closer, err := actorAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		mi, err := api.StateMinerInfo(ctx, maddr, nil)
+		if err != nil {
+			return err
+		}
+
+		if mi.Worker == newWorker {
+			return fmt.Errorf("%s is already the worker of %s", newWorker, maddr)
+		}
+
+		has, err := api.WalletHas(ctx, newWorker)
+		if err != nil {
+			return err
+		}
+		if !has {
+			fmt.Printf("WARNING: key for new worker %s not found in the local wallet\n", newWorker)
+		}
+
+		if err := sendMinerMessage(ctx, api, maddr, mi.Owner, actors.MAMethods.ChangeWorker, &actors.ChangeWorkerParams{
+			NewWorker: newWorker,
+		}); err != nil {
+			return err
+		}
+
+		mi, err = api.StateMinerInfo(ctx, maddr, nil)
+		if err != nil {
+			return err
+		}
+		if mi.WorkerChange == nil {
+			return xerrors.Errorf("worker change not found in miner state")
+		}
+
+		fmt.Printf("Worker change to %s takes effect at height %d\n", mi.WorkerChange.NewWorker, mi.WorkerChange.EffectiveAt)
+		if cctx.Bool("no-wait") {
+			return nil
+		}
+
+		effectiveAt := mi.WorkerChange.EffectiveAt
+		for {
+			head, err := api.ChainHead(ctx)
+			if err != nil {
+				return err
+			}
+
+			if head.Height() >= effectiveAt {
+				break
+			}
+
+			fmt.Printf("\r\x1b[2KHeight: %d, %d blocks left", head.Height(), effectiveAt-head.Height())
+
+			select {
+			case <-ctx.Done():
+				fmt.Println()
+				return ctx.Err()
+			case <-time.After(build.BlockDelay * time.Second):
+			}
+		}
+		fmt.Println()
+
+		worker, err := api.StateMinerWorker(ctx, maddr, nil)
+		if err != nil {
+			return err
+		}
+		if worker != newWorker {
+			return xerrors.Errorf("worker is %s after the change took effect, expected %s", worker, newWorker)
+		}
+
+		fmt.Printf("Worker changed to %s, restart the storage miner to use the new key\n", worker)
+		return nil
+	},
+}
+
+var actorSetOwnerCmd = &cli.Command{
+	Name:      "set-owner",
+	Usage:     "nominate a new owner of the miner, which has to accept it with accept-owner",
+	ArgsUsage: "[newOwnerAddress]",
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Args().Present() {
+			return fmt.Errorf("must pass the address of the new owner")
+		}
+
+		newOwner, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		api, maddr, closer, err := actorAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		mi, err := api.StateMinerInfo(ctx, maddr, nil)
+		if err != nil {
+			return err
+		}
+
+		if err := sendMinerMessage(ctx, api, maddr, mi.Owner, actors.MAMethods.ChangeOwner, &actors.ChangeOwnerParams{
+			NewOwner: newOwner,
+		}); err != nil {
+			return err
+		}
+
+		if newOwner == mi.Owner {
+			fmt.Println("Canceled pending owner change")
+			return nil
+		}
+
+		fmt.Printf("Nominated %s as the new owner of %s\n", newOwner, maddr)
+		return nil
+	},
+}
+
+var actorAcceptOwnerCmd = &cli.Command{
+	Name:  "accept-owner",
+	Usage: "accept a pending nomination as the owner of the miner",
+	Action: func(cctx *cli.Context) error {
+		api, maddr, closer, err := actorAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		mi, err := api.StateMinerInfo(ctx, maddr, nil)
+		if err != nil {
+			return err
+		}
+
+		if mi.OwnerChange == nil {
+			return fmt.Errorf("no pending owner change for %s", maddr)
+		}
+		newOwner := mi.OwnerChange.NewOwner
+
+		if err := sendMinerMessage(ctx, api, maddr, newOwner, actors.MAMethods.ChangeOwner, &actors.ChangeOwnerParams{
+			NewOwner: newOwner,
+		}); err != nil {
+			return err
+		}
+
+		fmt.Printf("Owner of %s changed to %s\n", maddr, newOwner)
+		return nil
+	},
+}
+
+func actorAPI(cctx *cli.Context) (api.FullNode, address.Address, func(), error) {
+	nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+	if err != nil {
+		return nil, address.Undef, nil, err
+	}
+	defer closer()
+
+	maddr, err := nodeApi.ActorAddress(lcli.ReqContext(cctx))
+	if err != nil {
+		return nil, address.Undef, nil, xerrors.Errorf("getting miner address: %w", err)
+	}
+
+	api, acloser, err := lcli.GetFullNodeAPI(cctx)
+	if err != nil {
+		return nil, address.Undef, nil, err
+	}
+
+	return api, maddr, acloser, nil
+}
+
+// sendMinerMessage sends a message calling method on the miner actor from
+// the given address, and waits for it to be executed successfully
+func sendMinerMessage(ctx context.Context, api api.FullNode, maddr address.Address, from address.Address, method uint64, params cbg.CBORMarshaler) error {
+	has, err := api.WalletHas(ctx, from)
+	if err != nil {
+		return err
+	}
+	if !has {
+		return xerrors.Errorf("key for %s not found in the local wallet", from)
+	}
+
+	enc, aerr := actors.SerializeParams(params)
+	if aerr != nil {
+		return xerrors.Errorf("serializing params: %w", aerr)
+	}
+
+	smsg, err := api.MpoolPushMessage(ctx, &types.Message{
+		To:       maddr,
+		From:     from,
+		Value:    types.NewInt(0),
+		GasPrice: types.NewInt(0),
+		GasLimit: types.NewInt(1000000),
+		Method:   method,
+		Params:   enc,
+	})
+	if err != nil {
+		return xerrors.Errorf("pushing message: %w", err)
+	}
+
+	fmt.Printf("Sent message %s, waiting for it to be executed\n", smsg.Cid())
+
+	wait, err := api.StateWaitMsg(ctx, smsg.Cid())
+	if err != nil {
+		return xerrors.Errorf("waiting for message: %w", err)
+	}
+
+	if wait.Receipt.ExitCode != 0 {
+		return xerrors.Errorf("message execution failed (exit code %d)", wait.Receipt.ExitCode)
+	}
+
+	return nil
+}
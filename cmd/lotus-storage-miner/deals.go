@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+	"gopkg.in/urfave/cli.v2"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var dealsCmd = &cli.Command{
+	Name:  "deals",
+	Usage: "interact with storage deals",
+	Subcommands: []*cli.Command{
+		dealsListCmd,
+		dealsInspectCmd,
+		dealsSetAskCmd,
+		dealsGetAskCmd,
+		dealsSetRetrievalAskCmd,
+		dealsGetRetrievalAskCmd,
+		dealsListRetrievalsCmd,
+	},
+}
+
+var dealsListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "List storage deals tracked by the miner",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		deals, err := nodeApi.MarketListDeals(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "ProposalCid\tDealID\tState\tClient\tSize\tPrice\tDuration\tSector\n")
+		for _, d := range deals {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%d\t%d\n", d.ProposalCid, d.DealID, dealStateStr(d.State), d.Proposal.Client, sizeStr(types.NewInt(d.Proposal.PieceSize)), d.Proposal.StoragePricePerEpoch, d.Proposal.Duration, d.SectorID)
+		}
+		return w.Flush()
+	},
+}
+
+var dealsInspectCmd = &cli.Command{
+	Name:      "inspect",
+	Usage:     "Show the details of a storage deal",
+	ArgsUsage: "[proposalCid]",
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Args().Present() {
+			return xerrors.New("must specify the proposal CID of the deal")
+		}
+
+		proposal, err := cid.Decode(cctx.Args().First())
+		if err != nil {
+			return xerrors.Errorf("parsing proposal CID: %w", err)
+		}
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		d, err := nodeApi.MarketGetDeal(ctx, proposal)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("ProposalCid:\t%s\n", d.ProposalCid)
+		fmt.Printf("State:\t\t%s\n", dealStateStr(d.State))
+		fmt.Printf("DealID:\t\t%d\n", d.DealID)
+		fmt.Printf("Client:\t\t%s (peer %s)\n", d.Proposal.Client, d.Client)
+		fmt.Printf("Ref:\t\t%s\n", d.Ref)
+		fmt.Printf("PieceSize:\t%s\n", sizeStr(types.NewInt(d.Proposal.PieceSize)))
+		fmt.Printf("Price:\t\t%s per epoch\n", types.FIL(d.Proposal.StoragePricePerEpoch))
+		fmt.Printf("Collateral:\t%s\n", types.FIL(d.Proposal.StorageCollateral))
+		fmt.Printf("Duration:\t%d\n", d.Proposal.Duration)
+		fmt.Printf("ProposalExpiration:\t%d\n", d.Proposal.ProposalExpiration)
+		if d.SectorID != 0 {
+			fmt.Printf("Sector:\t\t%d\n", d.SectorID)
+		}
+		if d.State == api.DealAccepted {
+			fmt.Printf("Transferred:\t%s / %s\n", sizeStr(types.NewInt(d.Transferred)), sizeStr(types.NewInt(d.Proposal.PieceSize)))
+		}
+		return nil
+	},
+}
+
+var dealsSetAskCmd = &cli.Command{
+	Name:      "set-ask",
+	Usage:     "Set the storage price",
+	ArgsUsage: "[price in attoFIL per GiB per epoch]",
+	Flags: []cli.Flag{
+		&cli.DurationFlag{
+			Name:  "ttl",
+			Usage: "how long the ask is valid for",
+			Value: 24 * time.Hour,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Args().Present() {
+			return xerrors.New("must specify the price")
+		}
+
+		price, err := types.BigFromString(cctx.Args().First())
+		if err != nil {
+			return xerrors.Errorf("parsing price: %w", err)
+		}
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		return nodeApi.MarketSetAsk(ctx, price, int64(cctx.Duration("ttl")/time.Second))
+	},
+}
+
+var dealsGetAskCmd = &cli.Command{
+	Name:  "get-ask",
+	Usage: "Print the current storage ask",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		ask, err := nodeApi.MarketGetAsk(ctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Price per GiB per epoch:\t%s attoFIL\n", ask.Ask.Price)
+		fmt.Printf("Min piece size:\t\t%s\n", sizeStr(types.NewInt(ask.Ask.MinPieceSize)))
+		fmt.Printf("Expiry:\t\t\t%s\n", time.Unix(int64(ask.Ask.Expiry), 0))
+		fmt.Printf("SeqNo:\t\t\t%d\n", ask.Ask.SeqNo)
+		return nil
+	},
+}
+
+var dealsSetRetrievalAskCmd = &cli.Command{
+	Name:      "set-retrieval-ask",
+	Usage:     "Set the retrieval price and payment interval",
+	ArgsUsage: "[price in attoFIL per byte]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "unseal-price",
+			Usage: "price in attoFIL paid once per retrieval deal",
+			Value: "0",
+		},
+		&cli.Uint64Flag{
+			Name:  "payment-interval",
+			Usage: "number of bytes sent for each payment",
+			Value: build.UnixfsChunkSize,
+		},
+		&cli.Uint64Flag{
+			Name:  "payment-interval-increase",
+			Usage: "how much the payment interval grows after each payment",
+			Value: build.UnixfsChunkSize,
+		},
+		&cli.DurationFlag{
+			Name:  "ttl",
+			Usage: "how long the ask is valid for",
+			Value: 24 * time.Hour,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Args().Present() {
+			return xerrors.New("must specify the price")
+		}
+
+		price, err := types.BigFromString(cctx.Args().First())
+		if err != nil {
+			return xerrors.Errorf("parsing price: %w", err)
+		}
+
+		unsealPrice, err := types.BigFromString(cctx.String("unseal-price"))
+		if err != nil {
+			return xerrors.Errorf("parsing unseal price: %w", err)
+		}
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		return nodeApi.MarketSetRetrievalAsk(ctx, price, unsealPrice, cctx.Uint64("payment-interval"), cctx.Uint64("payment-interval-increase"), int64(cctx.Duration("ttl")/time.Second))
+	},
+}
+
+var dealsGetRetrievalAskCmd = &cli.Command{
+	Name:  "get-retrieval-ask",
+	Usage: "Print the current retrieval ask",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		ask, err := nodeApi.MarketGetRetrievalAsk(ctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Price per byte:			%s attoFIL\n", ask.Ask.PricePerByte)
+		fmt.Printf("Unseal price:			%s attoFIL\n", ask.Ask.UnsealPrice)
+		fmt.Printf("Payment interval:		%s\n", sizeStr(types.NewInt(ask.Ask.PaymentInterval)))
+		fmt.Printf("Payment interval increase:	%s\n", sizeStr(types.NewInt(ask.Ask.PaymentIntervalIncrease)))
+		fmt.Printf("Expiry:				%s\n", time.Unix(int64(ask.Ask.Expiry), 0))
+		fmt.Printf("SeqNo:				%d\n", ask.Ask.SeqNo)
+		return nil
+	},
+}
+
+var dealsListRetrievalsCmd = &cli.Command{
+	Name:  "list-retrievals",
+	Usage: "List retrieval deals served by the miner",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		deals, err := nodeApi.MarketListRetrievalDeals(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "ID\tRoot\tClient\tState\tSent\tReceived\tMessage\n")
+		for _, d := range deals {
+			state := fmt.Sprintf("<Unknown %d>", d.State)
+			if d.State < api.RetrievalState(len(api.RetrievalStates)) {
+				state = api.RetrievalStates[d.State]
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", d.ID, d.Root, d.Client, state, sizeStr(types.NewInt(d.BytesSent)), types.FIL(d.FundsReceived), d.Message)
+		}
+		return w.Flush()
+	},
+}
+
+func dealStateStr(s api.DealState) string {
+	if s < api.DealState(len(api.DealStates)) {
+		return api.DealStates[s]
+	}
+	return fmt.Sprintf("<Unknown %d>", s)
+}
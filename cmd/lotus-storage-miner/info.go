@@ -58,7 +58,21 @@ var infoCmd = &cli.Command{
 		if err != nil {
 			return err
 		}
-		fmt.Printf("Worker use: %d / %d (+%d)\n", wstat.Total-wstat.Reserved-wstat.Free, wstat.Total, wstat.Reserved)
+		fmt.Printf("Worker use:\n")
+		fmt.Printf("\tLocal: %d / %d (+%d reserved)\n", wstat.LocalTotal-wstat.LocalReserved-wstat.LocalFree, wstat.LocalTotal-wstat.LocalReserved, wstat.LocalReserved)
+		fmt.Printf("\tRemote: %d / %d\n", wstat.RemotesTotal-wstat.RemotesFree, wstat.RemotesTotal)
+		for _, r := range wstat.Remotes {
+			if r.Busy {
+				fmt.Printf("\t\t#%d: %s task %d\n", r.ID, r.TaskType, r.TaskID)
+			} else {
+				fmt.Printf("\t\t#%d: idle\n", r.ID)
+			}
+		}
+
+		fmt.Printf("Queues:\n")
+		fmt.Printf("\tAddPiece: %d\n", wstat.AddPieceWait)
+		fmt.Printf("\tPreCommit: %d\n", wstat.PreCommitWait)
+		fmt.Printf("\tCommit: %d\n", wstat.CommitWait)
 
 		ppe, err := api.StateMinerProvingPeriodEnd(ctx, maddr, nil)
 		if err != nil {
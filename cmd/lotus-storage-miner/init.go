@@ -229,11 +229,11 @@ func configureStorageMiner(ctx context.Context, api api.FullNode, addr address.A
 		return xerrors.Errorf("failed to get worker address: %w", err)
 	}
 
-	if recp.ExitCode != 0 {
-		return xerrors.Errorf("getWorkerAddr returned exit code %d", recp.ExitCode)
+	if recp.Receipt.ExitCode != 0 {
+		return xerrors.Errorf("getWorkerAddr returned exit code %d", recp.Receipt.ExitCode)
 	}
 
-	waddr, err := address.NewFromBytes(recp.Return)
+	waddr, err := address.NewFromBytes(recp.Receipt.Return)
 	if err != nil {
 		return xerrors.Errorf("getWorkerAddr returned bad address: %w", err)
 	}
@@ -26,6 +26,9 @@ func main() {
 		infoCmd,
 		storeGarbageCmd,
 		sectorsCmd,
+		actorCmd,
+		storageCmd,
+		dealsCmd,
 	}
 	jaeger := tracing.SetupJaegerTracing("lotus")
 	defer func() {
@@ -19,6 +19,7 @@ import (
 	"github.com/filecoin-project/lotus/lib/auth"
 	"github.com/filecoin-project/lotus/lib/jsonrpc"
 	"github.com/filecoin-project/lotus/node"
+	"github.com/filecoin-project/lotus/node/impl"
 	"github.com/filecoin-project/lotus/node/repo"
 )
 
@@ -128,6 +129,11 @@ var runCmd = &cli.Command{
 
 		http.Handle("/rpc/v0", ah)
 
+		http.Handle("/remote/", &auth.Handler{
+			Verify: minerapi.AuthVerify,
+			Next:   minerapi.(*impl.StorageMinerAPI).ServeRemote,
+		})
+
 		srv := &http.Server{Handler: http.DefaultServeMux}
 
 		sigChan := make(chan os.Signal, 2)
@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"strconv"
 
+	"golang.org/x/xerrors"
 	"gopkg.in/urfave/cli.v2"
 
 	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors"
 	lcli "github.com/filecoin-project/lotus/cli"
 )
 
@@ -32,6 +34,9 @@ var sectorsCmd = &cli.Command{
 		sectorsStatusCmd,
 		sectorsListCmd,
 		sectorsRefsCmd,
+		sectorsUpdateCmd,
+		sectorsFaultsCmd,
+		sectorsExtendCmd,
 	},
 }
 
@@ -70,6 +75,13 @@ var sectorsStatusCmd = &cli.Command{
 		fmt.Printf("SeedH:\t\t%d\n", status.Seed.BlockHeight)
 		fmt.Printf("Proof:\t\t%x\n", status.Proof)
 		fmt.Printf("Deals:\t\t%v\n", status.Deals)
+		for _, piece := range status.Pieces {
+			fmt.Printf("\tDeal %d: %s (%d bytes)\n", piece.DealID, piece.Ref, piece.Size)
+		}
+		if status.LastErr != "" {
+			fmt.Printf("Retries:\t%d\n", status.Retries)
+			fmt.Printf("Last Error:\t%s\n", status.LastErr)
+		}
 		return nil
 	},
 }
@@ -170,6 +182,128 @@ var sectorsRefsCmd = &cli.Command{
 	},
 }
 
+var sectorsUpdateCmd = &cli.Command{
+	Name:      "update-state",
+	Usage:     "ADVANCED: manually update the state of a sector, this may aid in error recovery",
+	ArgsUsage: "[sectorId] [newState]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "really-do-it",
+			Usage: "pass this flag if you know what you are doing",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Bool("really-do-it") {
+			return xerrors.Errorf("this is a command for advanced users, only use it if you are sure of what you are doing")
+		}
+
+		if cctx.Args().Len() != 2 {
+			return xerrors.Errorf("must pass sector ID and new state")
+		}
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		id, err := strconv.ParseUint(cctx.Args().Get(0), 10, 64)
+		if err != nil {
+			return xerrors.Errorf("could not parse sector ID: %w", err)
+		}
+
+		st, ok := api.SectorStateFromStr(cctx.Args().Get(1))
+		if !ok {
+			return xerrors.Errorf("unknown sector state %q, expected one of %v", cctx.Args().Get(1), api.SectorStates[1:])
+		}
+
+		return nodeApi.SectorsUpdate(ctx, id, st)
+	},
+}
+
+var sectorsFaultsCmd = &cli.Command{
+	Name:  "faults",
+	Usage: "List faulty sectors in the proving set",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		faults, err := nodeApi.SectorsFaults(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(faults) == 0 {
+			fmt.Println("No faulty sectors")
+			return nil
+		}
+
+		for _, f := range faults {
+			fmt.Printf("%d:	declared: %s	%s\n", f.SectorID, yesno(f.Declared), f.Err)
+		}
+		return nil
+	},
+}
+
+var sectorsExtendCmd = &cli.Command{
+	Name:      "extend",
+	Usage:     "Extend the on-chain expiration of a sector",
+	ArgsUsage: "[sectorId] [expirationHeight]",
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 2 {
+			return xerrors.Errorf("must pass sector ID and new expiration height")
+		}
+
+		id, err := strconv.ParseUint(cctx.Args().Get(0), 10, 64)
+		if err != nil {
+			return xerrors.Errorf("could not parse sector ID: %w", err)
+		}
+
+		expiration, err := strconv.ParseUint(cctx.Args().Get(1), 10, 64)
+		if err != nil {
+			return xerrors.Errorf("could not parse expiration height: %w", err)
+		}
+
+		api, maddr, closer, err := actorAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		current, err := api.StateMinerSectorExpiration(ctx, maddr, id, nil)
+		if err != nil {
+			return err
+		}
+		if current == 0 {
+			return xerrors.Errorf("sector %d doesn't expire", id)
+		}
+		if expiration <= current {
+			return xerrors.Errorf("sector %d already expires at %d", id, current)
+		}
+
+		worker, err := api.StateMinerWorker(ctx, maddr, nil)
+		if err != nil {
+			return err
+		}
+
+		if err := sendMinerMessage(ctx, api, maddr, worker, actors.MAMethods.ExtendSectorExpiration, &actors.ExtendSectorExpirationParams{
+			SectorID:   id,
+			Expiration: expiration,
+		}); err != nil {
+			return err
+		}
+
+		fmt.Printf("Sector %d now expires at %d (was %d)\n", id, expiration, current)
+		return nil
+	},
+}
+
 func yesno(b bool) string {
 	if b {
 		return "YES"
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"golang.org/x/xerrors"
+	"gopkg.in/urfave/cli.v2"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var storageCmd = &cli.Command{
+	Name:  "storage",
+	Usage: "manage sector storage paths",
+	Subcommands: []*cli.Command{
+		storageAttachCmd,
+		storageListCmd,
+	},
+}
+
+var storageAttachCmd = &cli.Command{
+	Name:      "attach",
+	Usage:     "attach a path for storing sealed sectors",
+	ArgsUsage: "[path]",
+	Flags: []cli.Flag{
+		&cli.Uint64Flag{
+			Name:  "weight",
+			Usage: "scales the free space of the path when placing new sectors",
+			Value: 1,
+		},
+		&cli.BoolFlag{
+			Name:  "read-only",
+			Usage: "only use the path for existing sectors",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		if !cctx.Args().Present() {
+			return xerrors.New("must specify a path to attach")
+		}
+
+		p, err := filepath.Abs(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		return nodeApi.StorageAttach(ctx, api.StoragePath{
+			Path:     p,
+			Weight:   cctx.Uint64("weight"),
+			ReadOnly: cctx.Bool("read-only"),
+		})
+	},
+}
+
+var storageListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "list sector storage paths",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		paths, err := nodeApi.StorageList(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Path\tWeight\tReadOnly\tSectors\tAvailable\tCapacity\n")
+		for _, p := range paths {
+			fmt.Fprintf(w, "%s\t%d\t%t\t%d\t%s\t%s\n", p.Path, p.Weight, p.ReadOnly, p.Sectors, sizeStr(types.NewInt(p.Available)), sizeStr(types.NewInt(p.Capacity)))
+		}
+		return w.Flush()
+	},
+}
@@ -5,8 +5,10 @@ package main
 import (
 	"context"
 	"io/ioutil"
+	"os"
 
 	"github.com/filecoin-project/lotus/peermgr"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	"github.com/multiformats/go-multiaddr"
 
 	"golang.org/x/xerrors"
@@ -14,6 +16,8 @@ import (
 
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/stmgr"
+	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/node"
 	"github.com/filecoin-project/lotus/node/modules"
 	"github.com/filecoin-project/lotus/node/modules/testing"
@@ -46,6 +50,10 @@ var DaemonCmd = &cli.Command{
 			Name:  "bootstrap",
 			Value: true,
 		},
+		&cli.StringFlag{
+			Name:  "import-chain",
+			Usage: "import chain from given file (as exported by 'lotus chain export') before starting the node",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		ctx := context.Background()
@@ -62,6 +70,13 @@ var DaemonCmd = &cli.Command{
 			return xerrors.Errorf("fetching proof parameters: %w", err)
 		}
 
+		chainfile := cctx.String("import-chain")
+		if chainfile != "" {
+			if err := ImportChain(r, chainfile); err != nil {
+				return err
+			}
+		}
+
 		genBytes := build.MaybeGenesis()
 
 		if cctx.String("genesis") != "" {
@@ -117,3 +132,60 @@ var DaemonCmd = &cli.Command{
 		return serveRPC(api, stop, endpoint)
 	},
 }
+
+// ImportChain loads a chain exported with `lotus chain export` into the repo,
+// validates it and sets its root tipset as the chain head.
+func ImportChain(r repo.Repo, fname string) error {
+	fi, err := os.Open(fname)
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+
+	lr, err := r.Lock(repo.FullNode)
+	if err != nil {
+		return err
+	}
+	defer lr.Close()
+
+	ds, err := lr.Datastore("/blocks")
+	if err != nil {
+		return err
+	}
+
+	mds, err := lr.Datastore("/metadata")
+	if err != nil {
+		return err
+	}
+
+	bs := blockstore.NewBlockstore(ds)
+
+	cst := store.NewChainStore(bs, mds)
+	log.Info("importing chain from file...")
+	ts, err := cst.Import(fi)
+	if err != nil {
+		return xerrors.Errorf("importing chain failed: %w", err)
+	}
+
+	gb, err := cst.GetTipsetByHeight(context.TODO(), 0, ts)
+	if err != nil {
+		return xerrors.Errorf("getting genesis of imported chain: %w", err)
+	}
+
+	if err := cst.SetGenesis(gb.Blocks()[0]); err != nil {
+		return xerrors.Errorf("setting genesis: %w", err)
+	}
+
+	stm := stmgr.NewStateManager(cst)
+	log.Info("validating imported chain...")
+	if err := stm.ValidateChain(context.TODO(), ts); err != nil {
+		return xerrors.Errorf("chain validation failed: %w", err)
+	}
+
+	log.Infof("accepting %s as new head", ts.Cids())
+	if err := cst.SetHead(ts); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,567 @@
+package datatransfer
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	xerrors "golang.org/x/xerrors"
+)
+
+/* This file was generated by github.com/whyrusleeping/cbor-gen */
+
+var _ = xerrors.Errorf
+
+func (t *TransferRequest) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{137}); err != nil {
+		return err
+	}
+
+	// t.t.Initiator (peer.ID) (string)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.Initiator)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.Initiator)); err != nil {
+		return err
+	}
+
+	// t.t.TransferID (uint64) (uint64)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.TransferID))); err != nil {
+		return err
+	}
+
+	// t.t.Pull (bool) (bool)
+	if err := cbg.WriteBool(w, t.Pull); err != nil {
+		return err
+	}
+
+	// t.t.Cancel (bool) (bool)
+	if err := cbg.WriteBool(w, t.Cancel); err != nil {
+		return err
+	}
+
+	// t.t.VoucherType (string) (string)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.VoucherType)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.VoucherType)); err != nil {
+		return err
+	}
+
+	// t.t.Voucher ([]uint8) (slice)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajByteString, uint64(len(t.Voucher)))); err != nil {
+		return err
+	}
+	if _, err := w.Write(t.Voucher); err != nil {
+		return err
+	}
+
+	// t.t.BaseCid (cid.Cid) (struct)
+
+	if err := cbg.WriteCid(w, t.BaseCid); err != nil {
+		return xerrors.Errorf("failed to write cid field t.BaseCid: %w", err)
+	}
+
+	// t.t.Selector ([]uint8) (slice)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajByteString, uint64(len(t.Selector)))); err != nil {
+		return err
+	}
+	if _, err := w.Write(t.Selector); err != nil {
+		return err
+	}
+
+	// t.t.Skip (uint64) (uint64)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Skip))); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *TransferRequest) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 9 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.t.Initiator (peer.ID) (string)
+
+	{
+		sval, err := cbg.ReadString(br)
+		if err != nil {
+			return err
+		}
+
+		t.Initiator = peer.ID(sval)
+	}
+	// t.t.TransferID (uint64) (uint64)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajUnsignedInt {
+		return fmt.Errorf("wrong type for uint64 field")
+	}
+	t.TransferID = uint64(extra)
+	// t.t.Pull (bool) (bool)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajOther {
+		return fmt.Errorf("booleans must be major type 7")
+	}
+	switch extra {
+	case 20:
+		t.Pull = false
+	case 21:
+		t.Pull = true
+	default:
+		return fmt.Errorf("booleans are either major type 7, value 20 or 21 (got %d)", extra)
+	}
+	// t.t.Cancel (bool) (bool)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajOther {
+		return fmt.Errorf("booleans must be major type 7")
+	}
+	switch extra {
+	case 20:
+		t.Cancel = false
+	case 21:
+		t.Cancel = true
+	default:
+		return fmt.Errorf("booleans are either major type 7, value 20 or 21 (got %d)", extra)
+	}
+	// t.t.VoucherType (string) (string)
+
+	{
+		sval, err := cbg.ReadString(br)
+		if err != nil {
+			return err
+		}
+
+		t.VoucherType = string(sval)
+	}
+	// t.t.Voucher ([]uint8) (slice)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if extra > 8192 {
+		return fmt.Errorf("t.Voucher: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajByteString {
+		return fmt.Errorf("expected byte array")
+	}
+	t.Voucher = make([]byte, extra)
+	if _, err := io.ReadFull(br, t.Voucher); err != nil {
+		return err
+	}
+	// t.t.BaseCid (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.BaseCid: %w", err)
+		}
+
+		t.BaseCid = c
+
+	}
+	// t.t.Selector ([]uint8) (slice)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if extra > 8192 {
+		return fmt.Errorf("t.Selector: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajByteString {
+		return fmt.Errorf("expected byte array")
+	}
+	t.Selector = make([]byte, extra)
+	if _, err := io.ReadFull(br, t.Selector); err != nil {
+		return err
+	}
+	// t.t.Skip (uint64) (uint64)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajUnsignedInt {
+		return fmt.Errorf("wrong type for uint64 field")
+	}
+	t.Skip = uint64(extra)
+	return nil
+}
+
+func (t *TransferResponse) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{131}); err != nil {
+		return err
+	}
+
+	// t.t.Accepted (bool) (bool)
+	if err := cbg.WriteBool(w, t.Accepted); err != nil {
+		return err
+	}
+
+	// t.t.Message (string) (string)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.Message)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.Message)); err != nil {
+		return err
+	}
+
+	// t.t.Skip (uint64) (uint64)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Skip))); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *TransferResponse) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 3 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.t.Accepted (bool) (bool)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajOther {
+		return fmt.Errorf("booleans must be major type 7")
+	}
+	switch extra {
+	case 20:
+		t.Accepted = false
+	case 21:
+		t.Accepted = true
+	default:
+		return fmt.Errorf("booleans are either major type 7, value 20 or 21 (got %d)", extra)
+	}
+	// t.t.Message (string) (string)
+
+	{
+		sval, err := cbg.ReadString(br)
+		if err != nil {
+			return err
+		}
+
+		t.Message = string(sval)
+	}
+	// t.t.Skip (uint64) (uint64)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajUnsignedInt {
+		return fmt.Errorf("wrong type for uint64 field")
+	}
+	t.Skip = uint64(extra)
+	return nil
+}
+
+func (t *ChannelRecord) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{141}); err != nil {
+		return err
+	}
+
+	// t.t.Initiator (peer.ID) (string)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.Initiator)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.Initiator)); err != nil {
+		return err
+	}
+
+	// t.t.TransferID (uint64) (uint64)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.TransferID))); err != nil {
+		return err
+	}
+
+	// t.t.Sender (peer.ID) (string)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.Sender)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.Sender)); err != nil {
+		return err
+	}
+
+	// t.t.Recipient (peer.ID) (string)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.Recipient)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.Recipient)); err != nil {
+		return err
+	}
+
+	// t.t.VoucherType (string) (string)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.VoucherType)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.VoucherType)); err != nil {
+		return err
+	}
+
+	// t.t.Voucher ([]uint8) (slice)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajByteString, uint64(len(t.Voucher)))); err != nil {
+		return err
+	}
+	if _, err := w.Write(t.Voucher); err != nil {
+		return err
+	}
+
+	// t.t.BaseCid (cid.Cid) (struct)
+
+	if err := cbg.WriteCid(w, t.BaseCid); err != nil {
+		return xerrors.Errorf("failed to write cid field t.BaseCid: %w", err)
+	}
+
+	// t.t.Selector ([]uint8) (slice)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajByteString, uint64(len(t.Selector)))); err != nil {
+		return err
+	}
+	if _, err := w.Write(t.Selector); err != nil {
+		return err
+	}
+
+	// t.t.Status (uint64) (uint64)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Status))); err != nil {
+		return err
+	}
+
+	// t.t.Blocks (uint64) (uint64)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Blocks))); err != nil {
+		return err
+	}
+
+	// t.t.Sent (uint64) (uint64)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Sent))); err != nil {
+		return err
+	}
+
+	// t.t.Received (uint64) (uint64)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Received))); err != nil {
+		return err
+	}
+
+	// t.t.Message (string) (string)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.Message)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.Message)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *ChannelRecord) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 13 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.t.Initiator (peer.ID) (string)
+
+	{
+		sval, err := cbg.ReadString(br)
+		if err != nil {
+			return err
+		}
+
+		t.Initiator = peer.ID(sval)
+	}
+	// t.t.TransferID (uint64) (uint64)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajUnsignedInt {
+		return fmt.Errorf("wrong type for uint64 field")
+	}
+	t.TransferID = uint64(extra)
+	// t.t.Sender (peer.ID) (string)
+
+	{
+		sval, err := cbg.ReadString(br)
+		if err != nil {
+			return err
+		}
+
+		t.Sender = peer.ID(sval)
+	}
+	// t.t.Recipient (peer.ID) (string)
+
+	{
+		sval, err := cbg.ReadString(br)
+		if err != nil {
+			return err
+		}
+
+		t.Recipient = peer.ID(sval)
+	}
+	// t.t.VoucherType (string) (string)
+
+	{
+		sval, err := cbg.ReadString(br)
+		if err != nil {
+			return err
+		}
+
+		t.VoucherType = string(sval)
+	}
+	// t.t.Voucher ([]uint8) (slice)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if extra > 8192 {
+		return fmt.Errorf("t.Voucher: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajByteString {
+		return fmt.Errorf("expected byte array")
+	}
+	t.Voucher = make([]byte, extra)
+	if _, err := io.ReadFull(br, t.Voucher); err != nil {
+		return err
+	}
+	// t.t.BaseCid (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.BaseCid: %w", err)
+		}
+
+		t.BaseCid = c
+
+	}
+	// t.t.Selector ([]uint8) (slice)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if extra > 8192 {
+		return fmt.Errorf("t.Selector: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajByteString {
+		return fmt.Errorf("expected byte array")
+	}
+	t.Selector = make([]byte, extra)
+	if _, err := io.ReadFull(br, t.Selector); err != nil {
+		return err
+	}
+	// t.t.Status (uint64) (uint64)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajUnsignedInt {
+		return fmt.Errorf("wrong type for uint64 field")
+	}
+	t.Status = uint64(extra)
+	// t.t.Blocks (uint64) (uint64)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajUnsignedInt {
+		return fmt.Errorf("wrong type for uint64 field")
+	}
+	t.Blocks = uint64(extra)
+	// t.t.Sent (uint64) (uint64)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajUnsignedInt {
+		return fmt.Errorf("wrong type for uint64 field")
+	}
+	t.Sent = uint64(extra)
+	// t.t.Received (uint64) (uint64)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajUnsignedInt {
+		return fmt.Errorf("wrong type for uint64 field")
+	}
+	t.Received = uint64(extra)
+	// t.t.Message (string) (string)
+
+	{
+		sval, err := cbg.ReadString(br)
+		if err != nil {
+			return err
+		}
+
+		t.Message = string(sval)
+	}
+	return nil
+}
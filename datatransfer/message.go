@@ -0,0 +1,66 @@
+package datatransfer
+
+import (
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+const ProtocolID = "/fil/datatransfer/-1.0.0" // TODO: spec
+
+// C > R
+//
+// > TransferRequest{Initiator, TransferID, Pull, Voucher, BaseCid, Selector}
+// < TransferResponse{Accepted}
+// .. Blocks, from the sender, in selector traversal order
+// .. TransferResponse{Accepted}, from the recipient, once it has all blocks
+//
+// Blocks are written as varint length prefixed (cid, data) pairs, like in
+// CAR files.
+//
+// A channel is restarted by sending the same request again, the recipient
+// tells how many blocks it already has with Skip. Cancel requests close the
+// channel on the other side.
+
+// TransferRequest is the first message on a data transfer stream
+type TransferRequest struct {
+	Initiator  peer.ID
+	TransferID uint64
+	Pull       bool
+	Cancel     bool
+
+	VoucherType string
+	Voucher     []byte
+	BaseCid     cid.Cid
+	Selector    []byte // dag-cbor encoded
+
+	// Skip is the number of blocks a restarting puller already received
+	Skip uint64
+}
+
+type TransferResponse struct {
+	Accepted bool
+	Message  string
+
+	// Skip is the number of blocks the recipient of a restarted push already
+	// received
+	Skip uint64
+}
+
+// ChannelRecord is the persisted state of a channel
+type ChannelRecord struct {
+	Initiator  peer.ID
+	TransferID uint64
+	Sender     peer.ID
+	Recipient  peer.ID
+
+	VoucherType string
+	Voucher     []byte
+	BaseCid     cid.Cid
+	Selector    []byte
+
+	Status   uint64
+	Blocks   uint64 // blocks sent or received, in traversal order
+	Sent     uint64
+	Received uint64
+	Message  string
+}
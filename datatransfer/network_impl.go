@@ -0,0 +1,864 @@
+package datatransfer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+
+	blocks "github.com/ipfs/go-block-format"
+	carutil "github.com/ipfs/go-car/util"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	ipldformat "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
+	ipld "github.com/ipld/go-ipld-prime"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/lib/cborutil"
+	"github.com/filecoin-project/lotus/lib/ipldutil"
+	"github.com/filecoin-project/lotus/lib/statestore"
+)
+
+var log = logging.Logger("datatransfer")
+
+// The initiator restarts failed transfers with an exponential backoff between
+// minRestartDelay and maxRestartDelay, so the other party can be offline for a
+// while, e.g. to reboot. It gives up when a transfer hasn't made any progress
+// for restartTimeout.
+var (
+	minRestartDelay = 5 * time.Second
+	maxRestartDelay = 5 * time.Minute
+	restartTimeout  = 2 * time.Hour
+)
+
+const cancelTimeout = 30 * time.Second
+
+var errRejected = xerrors.New("data transfer rejected")
+
+type voucherEntry struct {
+	typ       reflect.Type // vouchers are decoded into a new *typ
+	validator RequestValidator
+}
+
+type activeChannel struct {
+	cancel context.CancelFunc
+}
+
+// NetworkManager is a data transfer manager which moves DAGs between peers
+// over a libp2p protocol. Blocks are read from and written to dag, and
+// channel state is kept in channels, so transfers restart after either side
+// reboots.
+type NetworkManager struct {
+	h        host.Host
+	dag      ipldformat.DAGService
+	channels *statestore.StateStore
+
+	vouchersLk sync.Mutex
+	vouchers   map[string]voucherEntry
+
+	subscribersLk sync.Mutex
+	subscribers   []Subscriber
+
+	// recordsLk serializes channel record updates
+	recordsLk sync.Mutex
+
+	lk     sync.Mutex
+	nextID TransferID
+	active map[ChannelID]*activeChannel
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+var _ Manager = &NetworkManager{}
+
+// NewNetworkManager returns a data transfer manager for the given host.
+// Start must be called before transfers can be made.
+func NewNetworkManager(h host.Host, dag ipldformat.DAGService, channels *statestore.StateStore) (*NetworkManager, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m := &NetworkManager{
+		h:        h,
+		dag:      dag,
+		channels: channels,
+
+		vouchers: map[string]voucherEntry{},
+
+		nextID: 1,
+		active: map[ChannelID]*activeChannel{},
+
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	recs, err := m.list()
+	if err != nil {
+		return nil, xerrors.Errorf("listing data transfer channels: %w", err)
+	}
+
+	for _, r := range recs {
+		if r.Initiator == h.ID() && TransferID(r.TransferID) >= m.nextID {
+			m.nextID = TransferID(r.TransferID) + 1
+		}
+	}
+
+	return m, nil
+}
+
+// Start handles incoming transfers, and restarts the ongoing transfers this
+// node initiated
+func (m *NetworkManager) Start(context.Context) error {
+	m.h.SetStreamHandler(ProtocolID, m.handleStream)
+
+	recs, err := m.list()
+	if err != nil {
+		return xerrors.Errorf("listing data transfer channels: %w", err)
+	}
+
+	for _, r := range recs {
+		if Status(r.Status) != Ongoing || r.Initiator != m.h.ID() {
+			continue
+		}
+
+		log.Infof("restarting data transfer %d with %s", r.TransferID, r.other(m.h.ID()))
+		go m.runInitiator(r.channelID())
+	}
+
+	return nil
+}
+
+// Stop interrupts all transfers. They are restarted on the next Start.
+func (m *NetworkManager) Stop(context.Context) error {
+	m.h.RemoveStreamHandler(ProtocolID)
+	m.cancel()
+	return nil
+}
+
+// RegisterVoucherType registers a validator for the given voucher type
+// will error if voucher type does not implement voucher
+// or if there is a voucher type registered with an identical identifier
+func (m *NetworkManager) RegisterVoucherType(voucherType reflect.Type, validator RequestValidator) error {
+	if voucherType.Kind() == reflect.Ptr {
+		voucherType = voucherType.Elem()
+	}
+
+	v, ok := reflect.New(voucherType).Interface().(Voucher)
+	if !ok {
+		return xerrors.Errorf("%s doesn't implement Voucher", voucherType)
+	}
+
+	m.vouchersLk.Lock()
+	defer m.vouchersLk.Unlock()
+
+	if _, ok := m.vouchers[v.Identifier()]; ok {
+		return xerrors.Errorf("voucher type %s already registered", v.Identifier())
+	}
+
+	m.vouchers[v.Identifier()] = voucherEntry{
+		typ:       voucherType,
+		validator: validator,
+	}
+	return nil
+}
+
+// open a data transfer that will send data to the recipient peer and
+// transfer parts of the piece that match the selector
+func (m *NetworkManager) OpenPushDataChannel(ctx context.Context, to peer.ID, voucher Voucher, baseCid cid.Cid, selector ipld.Node) (ChannelID, error) {
+	return m.openChannel(to, voucher, baseCid, selector, false)
+}
+
+// open a data transfer that will request data from the sending peer and
+// transfer parts of the piece that match the selector
+func (m *NetworkManager) OpenPullDataChannel(ctx context.Context, to peer.ID, voucher Voucher, baseCid cid.Cid, selector ipld.Node) (ChannelID, error) {
+	return m.openChannel(to, voucher, baseCid, selector, true)
+}
+
+// openChannel records a new channel, and starts the transfer in the
+// background. The transfer outlives the context of the caller.
+func (m *NetworkManager) openChannel(to peer.ID, voucher Voucher, baseCid cid.Cid, selector ipld.Node, pull bool) (ChannelID, error) {
+	vb, err := voucher.ToBytes()
+	if err != nil {
+		return ChannelID{}, xerrors.Errorf("encoding voucher: %w", err)
+	}
+
+	sb, err := ipldutil.EncodeNode(selector)
+	if err != nil {
+		return ChannelID{}, xerrors.Errorf("encoding selector: %w", err)
+	}
+
+	m.lk.Lock()
+	id := m.nextID
+	m.nextID++
+	m.lk.Unlock()
+
+	rec := &ChannelRecord{
+		Initiator:  m.h.ID(),
+		TransferID: uint64(id),
+		Sender:     m.h.ID(),
+		Recipient:  to,
+
+		VoucherType: voucher.Identifier(),
+		Voucher:     vb,
+		BaseCid:     baseCid,
+		Selector:    sb,
+
+		Status: uint64(Ongoing),
+	}
+	if pull {
+		rec.Sender, rec.Recipient = to, m.h.ID()
+	}
+
+	chid := rec.channelID()
+	if err := m.channels.Begin(chid, rec); err != nil {
+		return ChannelID{}, xerrors.Errorf("tracking data transfer channel: %w", err)
+	}
+
+	m.notify(Open, rec)
+
+	go m.runInitiator(chid)
+
+	return chid, nil
+}
+
+// close an open channel (effectively a cancel)
+func (m *NetworkManager) CloseDataTransferChannel(chid ChannelID) {
+	var rec ChannelRecord
+	if err := m.channels.Get(chid, &rec); err != nil {
+		log.Warnf("closing data transfer channel %s: %s", chid, err)
+		return
+	}
+
+	if m.closeChannel(chid, xerrors.New("data transfer cancelled")) {
+		go m.sendCancel(rec)
+	}
+}
+
+// get status of a transfer
+func (m *NetworkManager) TransferChannelStatus(chid ChannelID) Status {
+	var rec ChannelRecord
+	if err := m.channels.Get(chid, &rec); err != nil {
+		return ChannelNotFoundError
+	}
+	return Status(rec.Status)
+}
+
+// get notified when certain types of events happen
+func (m *NetworkManager) SubscribeToEvents(subscriber Subscriber) {
+	m.subscribersLk.Lock()
+	defer m.subscribersLk.Unlock()
+
+	m.subscribers = append(m.subscribers, subscriber)
+}
+
+// get all in progress transfers
+func (m *NetworkManager) InProgressChannels() map[ChannelID]ChannelState {
+	recs, err := m.list()
+	if err != nil {
+		log.Errorf("listing data transfer channels: %s", err)
+		return nil
+	}
+
+	out := map[ChannelID]ChannelState{}
+	for i := range recs {
+		if Status(recs[i].Status) == Ongoing {
+			out[recs[i].channelID()] = m.channelState(&recs[i])
+		}
+	}
+	return out
+}
+
+// runInitiator runs the transfer on a channel this node opened, restarting
+// it when the connection fails, until it stops making progress
+func (m *NetworkManager) runInitiator(chid ChannelID) {
+	ctx, done := m.track(chid)
+	defer done()
+
+	delay := minRestartDelay
+	lastProgress := time.Now()
+	for {
+		var rec ChannelRecord
+		if err := m.channels.Get(chid, &rec); err != nil {
+			log.Errorf("getting data transfer channel %s: %s", chid, err)
+			return
+		}
+		before := rec.Blocks
+
+		err := m.initiate(ctx, &rec)
+		if ctx.Err() != nil {
+			// cancelled, or the node is stopping, in which case the
+			// transfer is restarted by the next Start
+			return
+		}
+		if err == nil || xerrors.Is(err, errRejected) {
+			m.finish(chid, err)
+			return
+		}
+
+		if err := m.channels.Get(chid, &rec); err != nil {
+			log.Errorf("getting data transfer channel %s: %s", chid, err)
+			return
+		}
+		if rec.Blocks > before {
+			lastProgress = time.Now()
+			delay = minRestartDelay
+		}
+		if time.Since(lastProgress) >= restartTimeout {
+			if m.finish(chid, xerrors.Errorf("no progress for %s: %w", restartTimeout, err)) {
+				m.sendCancel(rec)
+			}
+			return
+		}
+
+		log.Warnf("data transfer %s interrupted, restarting in %s: %s", chid, delay, err)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+
+		delay *= 2
+		if delay > maxRestartDelay {
+			delay = maxRestartDelay
+		}
+	}
+}
+
+// initiate opens a stream to the other party, and runs the transfer
+func (m *NetworkManager) initiate(ctx context.Context, rec *ChannelRecord) error {
+	pull := rec.Recipient == m.h.ID()
+
+	s, err := m.h.NewStream(ctx, rec.other(m.h.ID()), ProtocolID)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	defer resetOnCancel(ctx, s)()
+
+	req := &TransferRequest{
+		Initiator:  rec.Initiator,
+		TransferID: rec.TransferID,
+		Pull:       pull,
+
+		VoucherType: rec.VoucherType,
+		Voucher:     rec.Voucher,
+		BaseCid:     rec.BaseCid,
+		Selector:    rec.Selector,
+	}
+	if pull {
+		req.Skip = rec.Blocks
+	}
+
+	if err := cborutil.WriteCborRPC(s, req); err != nil {
+		return xerrors.Errorf("sending data transfer request: %w", err)
+	}
+
+	var resp TransferResponse
+	if err := cborutil.ReadCborRPC(s, &resp); err != nil {
+		return xerrors.Errorf("reading data transfer response: %w", err)
+	}
+	if !resp.Accepted {
+		return xerrors.Errorf("%s: %w", resp.Message, errRejected)
+	}
+
+	if pull {
+		return m.receive(ctx, s, rec, rec.Blocks)
+	}
+	return m.send(ctx, s, rec, resp.Skip)
+}
+
+func (m *NetworkManager) handleStream(s network.Stream) {
+	defer s.Close()
+
+	var req TransferRequest
+	if err := cborutil.ReadCborRPC(s, &req); err != nil {
+		log.Warnf("reading data transfer request: %s", err)
+		return
+	}
+
+	remote := s.Conn().RemotePeer()
+	chid := ChannelID{initiator: req.Initiator, id: TransferID(req.TransferID)}
+
+	if req.Cancel {
+		m.handleCancel(chid, remote)
+		return
+	}
+
+	rec, err := m.accept(remote, &req)
+	if err != nil {
+		log.Warnf("rejecting data transfer %s: %s", chid, err)
+		_ = cborutil.WriteCborRPC(s, &TransferResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx, done := m.track(chid)
+	defer done()
+	defer resetOnCancel(ctx, s)()
+
+	resp := &TransferResponse{
+		Accepted: true,
+	}
+	if !req.Pull {
+		resp.Skip = rec.Blocks
+	}
+	if err := cborutil.WriteCborRPC(s, resp); err != nil {
+		log.Warnf("writing data transfer response: %s", err)
+		return
+	}
+
+	if req.Pull {
+		err = m.send(ctx, s, rec, req.Skip)
+	} else {
+		err = m.receive(ctx, s, rec, rec.Blocks)
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		// the initiator restarts the transfer, or cancels it when giving up
+		log.Warnf("data transfer %s interrupted: %s", chid, err)
+		return
+	}
+
+	m.finish(chid, nil)
+}
+
+// accept validates a transfer request, and returns the record of the
+// channel, which is created for new transfers
+func (m *NetworkManager) accept(remote peer.ID, req *TransferRequest) (*ChannelRecord, error) {
+	if req.Initiator != remote {
+		return nil, xerrors.New("data transfer requests must come from the channel initiator")
+	}
+
+	voucher, validator, err := m.decodeVoucher(req.VoucherType, req.Voucher)
+	if err != nil {
+		return nil, err
+	}
+
+	selector, err := ipldutil.DecodeNode(req.Selector)
+	if err != nil {
+		return nil, xerrors.Errorf("decoding selector: %w", err)
+	}
+
+	if req.Pull {
+		err = validator.ValidatePull(remote, voucher, req.BaseCid, selector)
+	} else {
+		err = validator.ValidatePush(remote, voucher, req.BaseCid, selector)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	chid := ChannelID{initiator: req.Initiator, id: TransferID(req.TransferID)}
+
+	var rec ChannelRecord
+	err = m.channels.Get(chid, &rec)
+	switch {
+	case err == nil:
+		// restarted transfer
+		if Status(rec.Status) == Failed {
+			return nil, xerrors.Errorf("data transfer channel closed: %s", rec.Message)
+		}
+		if !rec.BaseCid.Equals(req.BaseCid) || !bytes.Equal(rec.Selector, req.Selector) || (rec.Sender == m.h.ID()) != req.Pull {
+			return nil, xerrors.New("request doesn't match the existing data transfer channel")
+		}
+	case xerrors.Is(err, datastore.ErrNotFound):
+		rec = ChannelRecord{
+			Initiator:  req.Initiator,
+			TransferID: req.TransferID,
+			Sender:     remote,
+			Recipient:  m.h.ID(),
+
+			VoucherType: req.VoucherType,
+			Voucher:     req.Voucher,
+			BaseCid:     req.BaseCid,
+			Selector:    req.Selector,
+
+			Status: uint64(Ongoing),
+		}
+		if req.Pull {
+			rec.Sender, rec.Recipient = m.h.ID(), remote
+		}
+
+		if err := m.channels.Begin(chid, &rec); err != nil {
+			return nil, xerrors.Errorf("tracking data transfer channel: %w", err)
+		}
+
+		m.notify(Open, &rec)
+	default:
+		return nil, xerrors.Errorf("getting data transfer channel: %w", err)
+	}
+
+	return &rec, nil
+}
+
+// send writes the blocks visited by the selector to the stream, after
+// skipping the blocks the recipient already has, and waits for the
+// recipient to confirm it received everything
+func (m *NetworkManager) send(ctx context.Context, s network.Stream, rec *ChannelRecord, skip uint64) error {
+	sel, err := ipldutil.ParseSelector(rec.Selector)
+	if err != nil {
+		return err
+	}
+
+	chid := rec.channelID()
+
+	var n uint64
+	loader := func(lnk ipld.Link, _ ipld.LinkContext) (io.Reader, error) {
+		c, err := ipldutil.LinkCid(lnk)
+		if err != nil {
+			return nil, err
+		}
+
+		nd, err := m.dag.Get(ctx, c)
+		if err != nil {
+			return nil, xerrors.Errorf("getting block %s: %w", c, err)
+		}
+		data := nd.RawData()
+
+		n++
+		if n > skip {
+			if err := carutil.LdWrite(s, c.Bytes(), data); err != nil {
+				return nil, xerrors.Errorf("sending block %s: %w", c, err)
+			}
+
+			err := m.progress(chid, func(r *ChannelRecord) {
+				r.Blocks = n
+				r.Sent += uint64(len(data))
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return bytes.NewReader(data), nil
+	}
+
+	if err := ipldutil.WalkSelector(ctx, rec.BaseCid, sel, loader); err != nil {
+		return err
+	}
+
+	var ack TransferResponse
+	if err := cborutil.ReadCborRPC(s, &ack); err != nil {
+		return xerrors.Errorf("reading data transfer confirmation: %w", err)
+	}
+	if !ack.Accepted {
+		return xerrors.Errorf("%s: %w", ack.Message, errRejected)
+	}
+
+	return nil
+}
+
+// receive runs the selector traversal, reading blocks from the stream. The
+// first skip blocks were received before the transfer was restarted.
+func (m *NetworkManager) receive(ctx context.Context, s network.Stream, rec *ChannelRecord, skip uint64) error {
+	sel, err := ipldutil.ParseSelector(rec.Selector)
+	if err != nil {
+		return err
+	}
+
+	chid := rec.channelID()
+	br := bufio.NewReader(s)
+
+	var n uint64
+	loader := func(lnk ipld.Link, _ ipld.LinkContext) (io.Reader, error) {
+		expect, err := ipldutil.LinkCid(lnk)
+		if err != nil {
+			return nil, err
+		}
+
+		n++
+		if n <= skip {
+			nd, err := m.dag.Get(ctx, expect)
+			if err != nil {
+				return nil, xerrors.Errorf("getting block %s: %w", expect, err)
+			}
+			return bytes.NewReader(nd.RawData()), nil
+		}
+
+		c, data, err := carutil.ReadNode(br)
+		if err != nil {
+			return nil, xerrors.Errorf("reading block: %w", err)
+		}
+		if !c.Equals(expect) {
+			return nil, xerrors.Errorf("unexpected block: want %s, got %s", expect, c)
+		}
+
+		chk, err := expect.Prefix().Sum(data)
+		if err != nil {
+			return nil, err
+		}
+		if !chk.Equals(expect) {
+			return nil, xerrors.Errorf("block data doesn't match %s", expect)
+		}
+
+		blk, err := blocks.NewBlockWithCid(data, expect)
+		if err != nil {
+			return nil, err
+		}
+		nd, err := ipldformat.Decode(blk)
+		if err != nil {
+			return nil, xerrors.Errorf("decoding block %s: %w", expect, err)
+		}
+		if err := m.dag.Add(ctx, nd); err != nil {
+			return nil, xerrors.Errorf("storing block %s: %w", expect, err)
+		}
+
+		err = m.progress(chid, func(r *ChannelRecord) {
+			r.Blocks = n
+			r.Received += uint64(len(data))
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return bytes.NewReader(data), nil
+	}
+
+	if err := ipldutil.WalkSelector(ctx, rec.BaseCid, sel, loader); err != nil {
+		return err
+	}
+
+	return cborutil.WriteCborRPC(s, &TransferResponse{
+		Accepted: true,
+	})
+}
+
+func (m *NetworkManager) handleCancel(chid ChannelID, remote peer.ID) {
+	var rec ChannelRecord
+	if err := m.channels.Get(chid, &rec); err != nil {
+		log.Warnf("cancelling data transfer %s: %s", chid, err)
+		return
+	}
+	if rec.Sender != remote && rec.Recipient != remote {
+		log.Warnf("peer %s tried to cancel data transfer %s it isn't part of", remote, chid)
+		return
+	}
+
+	m.closeChannel(chid, xerrors.Errorf("data transfer cancelled by %s", remote))
+}
+
+// sendCancel tells the other party the channel was closed
+func (m *NetworkManager) sendCancel(rec ChannelRecord) {
+	ctx, cancel := context.WithTimeout(m.ctx, cancelTimeout)
+	defer cancel()
+
+	s, err := m.h.NewStream(ctx, rec.other(m.h.ID()), ProtocolID)
+	if err != nil {
+		log.Warnf("sending data transfer cancel: %s", err)
+		return
+	}
+	defer s.Close()
+
+	err = cborutil.WriteCborRPC(s, &TransferRequest{
+		Initiator:  rec.Initiator,
+		TransferID: rec.TransferID,
+		Cancel:     true,
+		BaseCid:    rec.BaseCid,
+	})
+	if err != nil {
+		log.Warnf("sending data transfer cancel: %s", err)
+	}
+}
+
+// closeChannel stops the transfer on the channel, and marks it failed. It
+// returns false if the channel was already closed.
+func (m *NetworkManager) closeChannel(chid ChannelID, reason error) bool {
+	m.lk.Lock()
+	if ac, ok := m.active[chid]; ok {
+		ac.cancel()
+	}
+	m.lk.Unlock()
+
+	return m.finish(chid, reason)
+}
+
+// finish records the outcome of the transfer, unless the channel was already
+// closed, in which case it returns false
+func (m *NetworkManager) finish(chid ChannelID, ferr error) bool {
+	var rec ChannelRecord
+	closed := false
+
+	err := m.update(chid, func(r *ChannelRecord) error {
+		if Status(r.Status) != Ongoing {
+			return nil
+		}
+
+		r.Status = uint64(Completed)
+		if ferr != nil {
+			r.Status = uint64(Failed)
+			r.Message = ferr.Error()
+		}
+
+		closed = true
+		rec = *r
+		return nil
+	})
+	if err != nil {
+		log.Errorf("recording data transfer %s outcome: %s", chid, err)
+		return false
+	}
+	if !closed {
+		return false
+	}
+
+	event := Complete
+	if ferr != nil {
+		log.Warnf("data transfer %s failed: %s", chid, ferr)
+		event = Error
+	}
+	m.notify(event, &rec)
+
+	return true
+}
+
+func (m *NetworkManager) progress(chid ChannelID, mut func(*ChannelRecord)) error {
+	var rec ChannelRecord
+	err := m.update(chid, func(r *ChannelRecord) error {
+		if Status(r.Status) != Ongoing {
+			return xerrors.Errorf("data transfer channel closed: %s", r.Message)
+		}
+
+		mut(r)
+		rec = *r
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("recording data transfer progress: %w", err)
+	}
+
+	m.notify(Progress, &rec)
+	return nil
+}
+
+func (m *NetworkManager) update(chid ChannelID, mutator func(*ChannelRecord) error) error {
+	m.recordsLk.Lock()
+	defer m.recordsLk.Unlock()
+
+	return m.channels.Mutate(chid, mutator)
+}
+
+func (m *NetworkManager) list() ([]ChannelRecord, error) {
+	var out []ChannelRecord
+	if err := m.channels.List(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// track registers a running transfer, so it can be cancelled. A restarted
+// transfer replaces the previous one.
+func (m *NetworkManager) track(chid ChannelID) (context.Context, func()) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	if prev, ok := m.active[chid]; ok {
+		prev.cancel()
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	ac := &activeChannel{cancel: cancel}
+	m.active[chid] = ac
+
+	return ctx, func() {
+		m.lk.Lock()
+		if m.active[chid] == ac {
+			delete(m.active, chid)
+		}
+		m.lk.Unlock()
+
+		cancel()
+	}
+}
+
+func (m *NetworkManager) notify(event Event, rec *ChannelRecord) {
+	state := m.channelState(rec)
+
+	m.subscribersLk.Lock()
+	subscribers := append([]Subscriber(nil), m.subscribers...)
+	m.subscribersLk.Unlock()
+
+	for _, subscriber := range subscribers {
+		subscriber(event, state)
+	}
+}
+
+func (m *NetworkManager) channelState(rec *ChannelRecord) ChannelState {
+	state := ChannelState{
+		Channel: Channel{
+			transferID: TransferID(rec.TransferID),
+			baseCid:    rec.BaseCid,
+			sender:     rec.Sender,
+			recipient:  rec.Recipient,
+		},
+		sent:     rec.Sent,
+		received: rec.Received,
+	}
+
+	voucher, _, err := m.decodeVoucher(rec.VoucherType, rec.Voucher)
+	if err != nil {
+		log.Warnf("data transfer %d: %s", rec.TransferID, err)
+	}
+	state.voucher = voucher
+
+	selector, err := ipldutil.DecodeNode(rec.Selector)
+	if err != nil {
+		log.Warnf("data transfer %d: decoding selector: %s", rec.TransferID, err)
+	}
+	state.selector = selector
+
+	return state
+}
+
+func (m *NetworkManager) decodeVoucher(identifier string, b []byte) (Voucher, RequestValidator, error) {
+	m.vouchersLk.Lock()
+	entry, ok := m.vouchers[identifier]
+	m.vouchersLk.Unlock()
+	if !ok {
+		return nil, nil, xerrors.Errorf("unknown voucher type %s", identifier)
+	}
+
+	v := reflect.New(entry.typ).Interface().(Voucher)
+	if err := v.FromBytes(b); err != nil {
+		return nil, nil, xerrors.Errorf("decoding voucher: %w", err)
+	}
+	return v, entry.validator, nil
+}
+
+func (r *ChannelRecord) channelID() ChannelID {
+	return ChannelID{initiator: r.Initiator, id: TransferID(r.TransferID)}
+}
+
+// other returns the other party of the channel
+func (r *ChannelRecord) other(self peer.ID) peer.ID {
+	if r.Sender == self {
+		return r.Recipient
+	}
+	return r.Sender
+}
+
+// resetOnCancel resets the stream if ctx is cancelled before the returned
+// function is called, which unblocks reads and writes on it
+func resetOnCancel(ctx context.Context, s network.Stream) func() {
+	finished := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = s.Reset()
+		case <-finished:
+		}
+	}()
+
+	return func() {
+		close(finished)
+	}
+}
@@ -0,0 +1,371 @@
+package datatransfer
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	chunker "github.com/ipfs/go-ipfs-chunker"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	ipldformat "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/go-merkledag"
+	"github.com/ipfs/go-unixfs/importer/balanced"
+	ihelper "github.com/ipfs/go-unixfs/importer/helpers"
+	ipld "github.com/ipld/go-ipld-prime"
+	ipldfree "github.com/ipld/go-ipld-prime/impl/free"
+	"github.com/ipld/go-ipld-prime/traversal/selector"
+	"github.com/ipld/go-ipld-prime/traversal/selector/builder"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/lib/statestore"
+)
+
+type testVoucher struct {
+	Data string
+}
+
+func (v *testVoucher) ToBytes() ([]byte, error) { return []byte(v.Data), nil }
+
+func (v *testVoucher) FromBytes(b []byte) error {
+	v.Data = string(b)
+	return nil
+}
+
+func (v *testVoucher) Identifier() string { return "testVoucher" }
+
+// testValidator accepts all requests, unless err is set
+type testValidator struct {
+	err error
+}
+
+func (tv *testValidator) ValidatePush(peer.ID, Voucher, cid.Cid, ipld.Node) error {
+	return tv.err
+}
+
+func (tv *testValidator) ValidatePull(peer.ID, Voucher, cid.Cid, ipld.Node) error {
+	return tv.err
+}
+
+type testNode struct {
+	h         host.Host
+	dtm       *NetworkManager
+	bs        blockstore.Blockstore
+	dag       ipldformat.DAGService
+	channels  *statestore.StateStore
+	validator RequestValidator
+	events    chan Event
+}
+
+func newTestNode(t *testing.T, ctx context.Context, mn mocknet.Mocknet, validator RequestValidator) *testNode {
+	h, err := mn.GenPeer()
+	require.NoError(t, err)
+
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+
+	tn := &testNode{
+		h:         h,
+		bs:        bs,
+		dag:       merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs))),
+		channels:  statestore.New(datastore.NewMapDatastore()),
+		validator: validator,
+		events:    make(chan Event, 1000),
+	}
+	tn.start(t, ctx)
+	return tn
+}
+
+// start creates the data transfer manager, from the channel records kept by
+// the previous one when restarting
+func (tn *testNode) start(t *testing.T, ctx context.Context) {
+	dtm, err := NewNetworkManager(tn.h, tn.dag, tn.channels)
+	require.NoError(t, err)
+	require.NoError(t, dtm.RegisterVoucherType(reflect.TypeOf(testVoucher{}), tn.validator))
+	dtm.SubscribeToEvents(func(event Event, _ ChannelState) {
+		tn.events <- event
+	})
+	require.NoError(t, dtm.Start(ctx))
+
+	tn.dtm = dtm
+}
+
+// reboot stops the data transfer manager, and starts a new one
+func (tn *testNode) reboot(t *testing.T, ctx context.Context) {
+	require.NoError(t, tn.dtm.Stop(ctx))
+	tn.start(t, ctx)
+}
+
+// waitForEvent waits until the given event is received
+func (tn *testNode) waitForEvent(t *testing.T, want Event) {
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case event := <-tn.events:
+			if event == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for data transfer event %d", want)
+		}
+	}
+}
+
+func (tn *testNode) record(t *testing.T, chid ChannelID) ChannelRecord {
+	var rec ChannelRecord
+	require.NoError(t, tn.channels.Get(chid, &rec))
+	return rec
+}
+
+// waitFor waits for the transfer to complete or fail
+func (tn *testNode) waitFor(t *testing.T) Event {
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case event := <-tn.events:
+			if event == Complete || event == Error {
+				return event
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for data transfer")
+		}
+	}
+}
+
+func testFile(t *testing.T, dag ipldformat.DAGService, size int) cid.Cid {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(5)).Read(data)
+
+	params := ihelper.DagBuilderParams{
+		Maxlinks:  4, // make sure there are a few levels
+		RawLeaves: true,
+		Dagserv:   dag,
+	}
+
+	db, err := params.New(chunker.NewSizeSplitter(bytes.NewReader(data), 1024))
+	require.NoError(t, err)
+	nd, err := balanced.Layout(db)
+	require.NoError(t, err)
+
+	return nd.Cid()
+}
+
+func allSelector() ipld.Node {
+	ssb := builder.NewSelectorSpecBuilder(ipldfree.NodeBuilder())
+	return ssb.ExploreRecursive(selector.RecursionLimitNone(),
+		ssb.ExploreAll(ssb.ExploreRecursiveEdge())).Node()
+}
+
+func setup(t *testing.T, ctx context.Context, validator RequestValidator) (*testNode, *testNode) {
+	mn := mocknet.New(ctx)
+
+	a := newTestNode(t, ctx, mn, validator)
+	b := newTestNode(t, ctx, mn, validator)
+
+	require.NoError(t, mn.LinkAll())
+	require.NoError(t, mn.ConnectAllButSelf())
+
+	return a, b
+}
+
+// requireAllBlocks checks all blocks were transferred, and returns their
+// total size
+func requireAllBlocks(t *testing.T, from, to *testNode) uint64 {
+	keys, err := from.bs.AllKeysChan(context.Background())
+	require.NoError(t, err)
+
+	n := 0
+	var size uint64
+	for k := range keys {
+		has, err := to.bs.Has(k)
+		require.NoError(t, err)
+		require.True(t, has, "missing block %s", k)
+
+		blk, err := from.bs.Get(k)
+		require.NoError(t, err)
+		size += uint64(len(blk.RawData()))
+		n++
+	}
+	require.True(t, n > 1)
+	return size
+}
+
+// shortRestarts makes the initiator restart transfers quickly, until the
+// returned function is called
+func shortRestarts(timeout time.Duration) func() {
+	minDelay, maxDelay, prevTimeout := minRestartDelay, maxRestartDelay, restartTimeout
+	minRestartDelay, maxRestartDelay, restartTimeout = 10*time.Millisecond, 50*time.Millisecond, timeout
+
+	return func() {
+		minRestartDelay, maxRestartDelay, restartTimeout = minDelay, maxDelay, prevTimeout
+	}
+}
+
+func TestPull(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sender, recipient := setup(t, ctx, &testValidator{})
+	root := testFile(t, sender.dag, 20*1024+100)
+
+	chid, err := recipient.dtm.OpenPullDataChannel(ctx, sender.dtm.h.ID(), &testVoucher{"pull"}, root, allSelector())
+	require.NoError(t, err)
+
+	assert.Equal(t, Complete, recipient.waitFor(t))
+	assert.Equal(t, Complete, sender.waitFor(t))
+
+	assert.Equal(t, Completed, recipient.dtm.TransferChannelStatus(chid))
+	assert.Equal(t, Completed, sender.dtm.TransferChannelStatus(chid))
+	assert.Empty(t, recipient.dtm.InProgressChannels())
+
+	size := requireAllBlocks(t, sender, recipient)
+	assert.Equal(t, size, recipient.record(t, chid).Received)
+	assert.Equal(t, size, sender.record(t, chid).Sent)
+}
+
+func TestPush(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sender, recipient := setup(t, ctx, &testValidator{})
+	root := testFile(t, sender.dag, 20*1024+100)
+
+	chid, err := sender.dtm.OpenPushDataChannel(ctx, recipient.dtm.h.ID(), &testVoucher{"push"}, root, allSelector())
+	require.NoError(t, err)
+
+	assert.Equal(t, Complete, sender.waitFor(t))
+	assert.Equal(t, Complete, recipient.waitFor(t))
+
+	assert.Equal(t, Completed, sender.dtm.TransferChannelStatus(chid))
+	assert.Equal(t, Completed, recipient.dtm.TransferChannelStatus(chid))
+
+	size := requireAllBlocks(t, sender, recipient)
+	assert.Equal(t, size, sender.record(t, chid).Sent)
+	assert.Equal(t, size, recipient.record(t, chid).Received)
+}
+
+func TestRejected(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sender, recipient := setup(t, ctx, &testValidator{err: xerrors.New("no")})
+	root := testFile(t, sender.dag, 1024)
+
+	chid, err := recipient.dtm.OpenPullDataChannel(ctx, sender.dtm.h.ID(), &testVoucher{"pull"}, root, allSelector())
+	require.NoError(t, err)
+
+	assert.Equal(t, Error, recipient.waitFor(t))
+	assert.Equal(t, Failed, recipient.dtm.TransferChannelStatus(chid))
+	assert.Equal(t, ChannelNotFoundError, sender.dtm.TransferChannelStatus(chid))
+}
+
+func TestRestartAfterReboot(t *testing.T) {
+	defer shortRestarts(time.Minute)()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sender, recipient := setup(t, ctx, &testValidator{})
+	root := testFile(t, sender.dag, 20*1024+100)
+
+	// the sender is down for much longer than a few restarts
+	require.NoError(t, sender.dtm.Stop(ctx))
+
+	chid, err := recipient.dtm.OpenPullDataChannel(ctx, sender.h.ID(), &testVoucher{"pull"}, root, allSelector())
+	require.NoError(t, err)
+
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, Ongoing, recipient.dtm.TransferChannelStatus(chid))
+
+	// the recipient reboots, and picks up the transfer when the sender is
+	// back
+	recipient.reboot(t, ctx)
+	sender.start(t, ctx)
+
+	assert.Equal(t, Complete, recipient.waitFor(t))
+	assert.Equal(t, Complete, sender.waitFor(t))
+
+	size := requireAllBlocks(t, sender, recipient)
+	assert.Equal(t, size, recipient.record(t, chid).Received)
+	assert.Equal(t, size, sender.record(t, chid).Sent)
+
+	// new channels don't reuse the IDs from before the reboot
+	next, err := recipient.dtm.OpenPullDataChannel(ctx, sender.h.ID(), &testVoucher{"pull"}, root, allSelector())
+	require.NoError(t, err)
+	assert.NotEqual(t, chid, next)
+}
+
+func TestRestartTimeout(t *testing.T) {
+	defer shortRestarts(200 * time.Millisecond)()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sender, recipient := setup(t, ctx, &testValidator{})
+	root := testFile(t, sender.dag, 1024)
+
+	require.NoError(t, sender.dtm.Stop(ctx))
+
+	chid, err := recipient.dtm.OpenPullDataChannel(ctx, sender.h.ID(), &testVoucher{"pull"}, root, allSelector())
+	require.NoError(t, err)
+
+	assert.Equal(t, Error, recipient.waitFor(t))
+	assert.Equal(t, Failed, recipient.dtm.TransferChannelStatus(chid))
+}
+
+// blockingDag blocks reads until release is closed
+type blockingDag struct {
+	ipldformat.DAGService
+	release chan struct{}
+}
+
+func (bd *blockingDag) Get(ctx context.Context, c cid.Cid) (ipldformat.Node, error) {
+	select {
+	case <-bd.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return bd.DAGService.Get(ctx, c)
+}
+
+func TestCloseDataTransferChannel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sender, recipient := setup(t, ctx, &testValidator{})
+	root := testFile(t, sender.dag, 20*1024+100)
+
+	// the transfer stalls on the sender until the channel is closed
+	bd := &blockingDag{DAGService: sender.dag, release: make(chan struct{})}
+	defer close(bd.release)
+	sender.dag = bd
+	sender.reboot(t, ctx)
+
+	chid, err := recipient.dtm.OpenPullDataChannel(ctx, sender.h.ID(), &testVoucher{"pull"}, root, allSelector())
+	require.NoError(t, err)
+	sender.waitForEvent(t, Open)
+
+	recipient.dtm.CloseDataTransferChannel(chid)
+
+	assert.Equal(t, Error, recipient.waitFor(t))
+	assert.Equal(t, Failed, recipient.dtm.TransferChannelStatus(chid))
+
+	// the sender is told to stop too
+	assert.Equal(t, Error, sender.waitFor(t))
+	assert.Equal(t, Failed, sender.dtm.TransferChannelStatus(chid))
+	assert.Equal(t, uint64(0), sender.record(t, chid).Sent)
+
+	// closing again does nothing
+	recipient.dtm.CloseDataTransferChannel(chid)
+	assert.Equal(t, Failed, recipient.dtm.TransferChannelStatus(chid))
+}
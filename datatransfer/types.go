@@ -2,6 +2,7 @@ package datatransfer
 
 import (
 	"context"
+	"fmt"
 	"reflect"
 
 	"github.com/ipfs/go-cid"
@@ -43,11 +44,15 @@ const (
 // request/responder and unique to the requester
 type TransferID uint64
 
-// ChannelID is a unique identifier for a channel, distinct by both the other
-// party's peer ID + the transfer ID
+// ChannelID is a unique identifier for a channel, distinct by both the
+// initiator's peer ID + the transfer ID
 type ChannelID struct {
-	to peer.ID
-	id TransferID
+	initiator peer.ID
+	id        TransferID
+}
+
+func (c ChannelID) String() string {
+	return fmt.Sprintf("%s-%d", c.initiator.Pretty(), c.id)
 }
 
 // Channel represents all the parameters for a single data transfer
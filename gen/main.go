@@ -11,6 +11,7 @@ import (
 	"github.com/filecoin-project/lotus/chain/blocksync"
 	"github.com/filecoin-project/lotus/chain/deals"
 	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/datatransfer"
 	"github.com/filecoin-project/lotus/paych"
 	"github.com/filecoin-project/lotus/retrieval"
 	"github.com/filecoin-project/lotus/storage"
@@ -31,6 +32,8 @@ func main() {
 		types.BlockMsg{},
 		types.SignedStorageAsk{},
 		types.StorageAsk{},
+		types.SignedRetrievalAsk{},
+		types.RetrievalAsk{},
 		types.ExpTipSet{},
 	)
 	if err != nil {
@@ -63,9 +66,12 @@ func main() {
 		retrieval.Query{},
 		retrieval.QueryResponse{},
 		retrieval.Unixfs0Offer{},
+		retrieval.SelectorOffer{},
 		retrieval.DealProposal{},
 		retrieval.DealResponse{},
 		retrieval.Block{},
+		retrieval.ClientRetrieval{},
+		retrieval.ProviderRetrieval{},
 	)
 	if err != nil {
 		fmt.Println(err)
@@ -86,14 +92,21 @@ func main() {
 		actors.InitActorState{},
 		actors.ExecParams{},
 		actors.AccountActorState{},
-		actors.StorageMinerActorState{},
+		// actors.StorageMinerActorState is encoded by hand, see actor_miner_cbor.go
 		actors.StorageMinerConstructorParams{},
 		actors.SectorPreCommitInfo{},
 		actors.PreCommittedSector{},
 		actors.MinerInfo{},
+		actors.MinerExtState{},
+		actors.WorkerKeyChange{},
+		actors.OwnerChange{},
 		actors.SubmitPoStParams{},
+		actors.DeclareFaultsParams{},
 		actors.PaymentVerifyParams{},
 		actors.UpdatePeerIDParams{},
+		actors.ChangeWorkerParams{},
+		actors.ChangeOwnerParams{},
+		actors.ExtendSectorExpirationParams{},
 		actors.MultiSigActorState{},
 		actors.MultiSigConstructorParams{},
 		actors.MultiSigProposeParams{},
@@ -127,6 +140,7 @@ func main() {
 		actors.ProcessStorageDealsPaymentParams{},
 		actors.OnChainDeal{},
 		actors.ComputeDataCommitmentParams{},
+		actors.GetLastExpirationFromDealIDsParams{},
 		actors.SectorProveCommitInfo{},
 		actors.CheckMinerParams{},
 	)
@@ -151,11 +165,21 @@ func main() {
 		os.Exit(1)
 	}
 
+	err = gen.WriteTupleEncodersToFile("./datatransfer/cbor_gen.go", "datatransfer",
+		datatransfer.TransferRequest{},
+		datatransfer.TransferResponse{},
+		datatransfer.ChannelRecord{},
+	)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
 	err = gen.WriteTupleEncodersToFile("./storage/cbor_gen.go", "storage",
 		storage.SealTicket{},
 		storage.SealSeed{},
 		storage.Piece{},
-		storage.SectorInfo{},
+		// storage.SectorInfo is encoded by hand, see sealing_cbor.go
 	)
 	if err != nil {
 		fmt.Println(err)
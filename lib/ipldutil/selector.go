@@ -0,0 +1,166 @@
+package ipldutil
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-merkledag"
+	ipld "github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/encoding/dagcbor"
+	ipldfree "github.com/ipld/go-ipld-prime/impl/free"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/traversal"
+	"github.com/ipld/go-ipld-prime/traversal/selector"
+	"golang.org/x/xerrors"
+)
+
+func init() {
+	// Most data moved around is unixfs, register decoders for its blocks so
+	// selectors can traverse them
+	cidlink.RegisterMulticodecDecoder(cid.DagProtobuf, dagpbDecoder)
+	cidlink.RegisterMulticodecDecoder(cid.Raw, rawDecoder)
+}
+
+// EncodeNode serializes a node, e.g. a selector, as dag-cbor
+func EncodeNode(nd ipld.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := dagcbor.Encoder(nd, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeNode reads a node serialized with EncodeNode
+func DecodeNode(b []byte) (ipld.Node, error) {
+	return dagcbor.Decoder(ipldfree.NodeBuilder(), bytes.NewReader(b))
+}
+
+// ParseSelector decodes and parses a dag-cbor encoded selector
+func ParseSelector(b []byte) (selector.Selector, error) {
+	nd, err := DecodeNode(b)
+	if err != nil {
+		return nil, xerrors.Errorf("decoding selector: %w", err)
+	}
+
+	sel, err := selector.ParseSelector(nd)
+	if err != nil {
+		return nil, xerrors.Errorf("parsing selector: %w", err)
+	}
+	return sel, nil
+}
+
+// WalkSelector traverses the DAG under root with the selector. The traversal
+// is deterministic, so when the sending and receiving sides both run it,
+// blocks are loaded in the same order on both sides.
+func WalkSelector(ctx context.Context, root cid.Cid, sel selector.Selector, loader ipld.Loader) error {
+	nd, err := cidlink.Link{Cid: root}.Load(ctx, ipld.LinkContext{}, ipldfree.NodeBuilder(), loader)
+	if err != nil {
+		return xerrors.Errorf("loading root: %w", err)
+	}
+
+	return traversal.Progress{
+		Cfg: &traversal.Config{
+			Ctx:        ctx,
+			LinkLoader: loader,
+			LinkNodeBuilderChooser: func(ipld.Link, ipld.LinkContext) ipld.NodeBuilder {
+				return ipldfree.NodeBuilder()
+			},
+		},
+	}.WalkAdv(nd, sel, func(traversal.Progress, ipld.Node, traversal.VisitReason) error {
+		return nil
+	})
+}
+
+// LinkCid returns the CID a link points to
+func LinkCid(lnk ipld.Link) (cid.Cid, error) {
+	cl, ok := lnk.(cidlink.Link)
+	if !ok {
+		return cid.Undef, xerrors.Errorf("unsupported link type %T", lnk)
+	}
+	return cl.Cid, nil
+}
+
+func rawDecoder(nb ipld.NodeBuilder, r io.Reader) (ipld.Node, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return nb.CreateBytes(data)
+}
+
+// dagpbDecoder exposes dag-pb nodes in the data model as
+// {"Data": bytes, "Links": [{"Hash": link, "Name": string, "Tsize": int}]}
+func dagpbDecoder(nb ipld.NodeBuilder, r io.Reader) (ipld.Node, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	pn, err := merkledag.DecodeProtobuf(data)
+	if err != nil {
+		return nil, err
+	}
+
+	lb, err := nb.CreateList()
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range pn.Links() {
+		ln, err := buildMap(nb, "Hash", cidlink.Link{Cid: l.Cid}, "Name", l.Name, "Tsize", int(l.Size))
+		if err != nil {
+			return nil, err
+		}
+		if err := lb.Append(ln); err != nil {
+			return nil, err
+		}
+	}
+	links, err := lb.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	return buildMap(nb, "Data", pn.Data(), "Links", links)
+}
+
+// buildMap builds a map node from key, value pairs
+func buildMap(nb ipld.NodeBuilder, kv ...interface{}) (ipld.Node, error) {
+	mb, err := nb.CreateMap()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i+1 < len(kv); i += 2 {
+		k, err := nb.CreateString(kv[i].(string))
+		if err != nil {
+			return nil, err
+		}
+
+		var v ipld.Node
+		switch val := kv[i+1].(type) {
+		case ipld.Node:
+			v = val
+		case ipld.Link:
+			v, err = nb.CreateLink(val)
+		case string:
+			v, err = nb.CreateString(val)
+		case int:
+			v, err = nb.CreateInt(val)
+		case []byte:
+			v, err = nb.CreateBytes(val)
+		default:
+			err = xerrors.Errorf("unsupported value type %T", val)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if err := mb.Insert(k, v); err != nil {
+			return nil, err
+		}
+	}
+
+	return mb.Build()
+}
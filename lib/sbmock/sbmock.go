@@ -0,0 +1,342 @@
+// +build mockproofs
+
+package sbmock
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/address"
+	"github.com/filecoin-project/lotus/lib/sectorbuilder"
+)
+
+const mockWorkers = 4
+
+// SBMock is a sectorbuilder.Interface which keeps sector data in memory and
+// produces deterministic fake commitments and proofs. It's only available in
+// mockproofs builds, where the proof verification functions in
+// lib/sectorbuilder accept its proofs.
+//
+// Piece and data commitments are the mock ones from lib/sectorbuilder, hashes
+// of the piece data computed in Go. They match the commitments deal clients
+// and the storage market actor compute in the same build
+type SBMock struct {
+	sectorSize uint64
+	miner      address.Address
+
+	rateLimit chan struct{}
+
+	lk           sync.Mutex
+	nextSectorID uint64
+	sectors      map[uint64]*sectorState
+	pieces       map[string][]byte
+}
+
+const (
+	statePacking = iota
+	statePreCommit
+	stateCommit
+)
+
+type sectorState struct {
+	state  int
+	failed bool
+
+	pieces [][]byte
+	infos  []sectorbuilder.PublicPieceInfo
+}
+
+func NewMockSectorBuilder(cfg *sectorbuilder.Config) *SBMock {
+	return &SBMock{
+		sectorSize: cfg.SectorSize,
+		miner:      cfg.Miner,
+
+		rateLimit: make(chan struct{}, mockWorkers),
+
+		sectors: map[uint64]*sectorState{},
+		pieces:  map[string][]byte{},
+	}
+}
+
+var _ sectorbuilder.Interface = &SBMock{}
+
+func (sb *SBMock) SectorSize() uint64 {
+	return sb.sectorSize
+}
+
+func (sb *SBMock) AcquireSectorId() (uint64, error) {
+	sb.lk.Lock()
+	defer sb.lk.Unlock()
+
+	sb.nextSectorID++
+	sb.sectors[sb.nextSectorID] = &sectorState{}
+	return sb.nextSectorID, nil
+}
+
+func (sb *SBMock) RateLimit() func() {
+	sb.rateLimit <- struct{}{}
+
+	return func() {
+		<-sb.rateLimit
+	}
+}
+
+func (sb *SBMock) AddPiece(pieceSize uint64, sectorId uint64, file io.Reader, existingPieceSizes []uint64) (sectorbuilder.PublicPieceInfo, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(file, int64(pieceSize)))
+	if err != nil {
+		return sectorbuilder.PublicPieceInfo{}, xerrors.Errorf("reading piece: %w", err)
+	}
+	if uint64(len(data)) != pieceSize {
+		return sectorbuilder.PublicPieceInfo{}, xerrors.Errorf("piece reader returned %d bytes, expected %d", len(data), pieceSize)
+	}
+
+	commP, err := sectorbuilder.GeneratePieceCommitment(bytes.NewReader(data), pieceSize)
+	if err != nil {
+		return sectorbuilder.PublicPieceInfo{}, xerrors.Errorf("generating piece commitment: %w", err)
+	}
+
+	sb.lk.Lock()
+	defer sb.lk.Unlock()
+
+	ss, ok := sb.sectors[sectorId]
+	if !ok {
+		return sectorbuilder.PublicPieceInfo{}, xerrors.Errorf("sector %d not found", sectorId)
+	}
+	if ss.state != statePacking {
+		return sectorbuilder.PublicPieceInfo{}, xerrors.Errorf("sector %d isn't accepting pieces", sectorId)
+	}
+	if len(existingPieceSizes) != len(ss.infos) {
+		return sectorbuilder.PublicPieceInfo{}, xerrors.Errorf("sector %d has %d pieces, caller expected %d", sectorId, len(ss.infos), len(existingPieceSizes))
+	}
+
+	var sum uint64
+	for _, piece := range ss.infos {
+		sum += piece.Size
+	}
+	if sum+pieceSize > sectorbuilder.UserBytesForSectorSize(sb.sectorSize) {
+		return sectorbuilder.PublicPieceInfo{}, xerrors.Errorf("piece doesn't fit in sector %d", sectorId)
+	}
+
+	info := sectorbuilder.PublicPieceInfo{
+		Size:  pieceSize,
+		CommP: commP,
+	}
+
+	ss.pieces = append(ss.pieces, data)
+	ss.infos = append(ss.infos, info)
+
+	return info, nil
+}
+
+func (sb *SBMock) TrimStagedSector(sectorID uint64, pieceSizes []uint64) error {
+	ss, err := sb.sector(sectorID, statePacking)
+	if err != nil {
+		return err
+	}
+
+	sb.lk.Lock()
+	defer sb.lk.Unlock()
+
+	if len(pieceSizes) > len(ss.infos) {
+		return xerrors.Errorf("sector %d has %d pieces, caller expected %d", sectorID, len(ss.infos), len(pieceSizes))
+	}
+	for i, size := range pieceSizes {
+		if ss.infos[i].Size != size {
+			return xerrors.Errorf("piece %d of sector %d has size %d, caller expected %d", i, sectorID, ss.infos[i].Size, size)
+		}
+	}
+
+	ss.pieces = ss.pieces[:len(pieceSizes)]
+	ss.infos = ss.infos[:len(pieceSizes)]
+	return nil
+}
+
+func (sb *SBMock) SealPreCommit(sectorID uint64, ticket sectorbuilder.SealTicket, pieces []sectorbuilder.PublicPieceInfo) (sectorbuilder.RawSealPreCommitOutput, error) {
+	ss, err := sb.sector(sectorID, statePacking)
+	if err != nil {
+		return sectorbuilder.RawSealPreCommitOutput{}, err
+	}
+
+	var sum uint64
+	for _, piece := range pieces {
+		sum += piece.Size
+	}
+	ussize := sectorbuilder.UserBytesForSectorSize(sb.sectorSize)
+	if sum != ussize {
+		return sectorbuilder.RawSealPreCommitOutput{}, xerrors.Errorf("aggregated piece sizes don't match sector size: %d != %d (%d)", sum, ussize, int64(ussize-sum))
+	}
+
+	commD, err := sectorbuilder.GenerateDataCommitment(sb.sectorSize, pieces)
+	if err != nil {
+		return sectorbuilder.RawSealPreCommitOutput{}, xerrors.Errorf("generating data commitment: %w", err)
+	}
+
+	commR := commitment("commR", commD[:], ticket.TicketBytes[:], sb.miner.Bytes(), u64(sectorID))
+
+	sb.lk.Lock()
+	ss.state = statePreCommit
+	sb.lk.Unlock()
+
+	return sectorbuilder.RawSealPreCommitOutput{
+		CommD:     commD,
+		CommR:     commR,
+		CommC:     commitment("commC", commR[:]),
+		CommRLast: commitment("commRLast", commR[:]),
+	}, nil
+}
+
+func (sb *SBMock) SealCommit(sectorID uint64, ticket sectorbuilder.SealTicket, seed sectorbuilder.SealSeed, pieces []sectorbuilder.PublicPieceInfo, pieceKeys []string, rspco sectorbuilder.RawSealPreCommitOutput) ([]byte, error) {
+	ss, err := sb.sector(sectorID, statePreCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pieceKeys) != len(ss.pieces) {
+		return nil, xerrors.Errorf("got %d piece keys for sector %d with %d pieces", len(pieceKeys), sectorID, len(ss.pieces))
+	}
+
+	sb.lk.Lock()
+	for i, key := range pieceKeys {
+		sb.pieces[key] = ss.pieces[i]
+	}
+	ss.state = stateCommit
+	sb.lk.Unlock()
+
+	return sectorbuilder.MockSealProof(rspco.CommR[:], rspco.CommD[:], sb.miner, ticket.TicketBytes[:], seed.TicketBytes[:], sectorID), nil
+}
+
+func (sb *SBMock) GeneratePoSt(sectorInfo sectorbuilder.SortedSectorInfo, challengeSeed [sectorbuilder.CommLen]byte, faults []uint64) ([]byte, error) {
+	sb.lk.Lock()
+	defer sb.lk.Unlock()
+
+	faulty := map[uint64]bool{}
+	for _, id := range faults {
+		faulty[id] = true
+	}
+
+	for _, si := range sectorInfo.Values() {
+		if faulty[si.SectorID] {
+			continue
+		}
+
+		ss, ok := sb.sectors[si.SectorID]
+		if !ok || ss.state != stateCommit {
+			return nil, xerrors.Errorf("sector %d isn't sealed", si.SectorID)
+		}
+		if ss.failed {
+			return nil, xerrors.Errorf("sector %d failed", si.SectorID)
+		}
+	}
+
+	return sectorbuilder.MockPoStProof(sectorInfo, challengeSeed, faults), nil
+}
+
+func (sb *SBMock) ReadPieceFromSealedSector(pieceKey string) ([]byte, error) {
+	sb.lk.Lock()
+	defer sb.lk.Unlock()
+
+	data, ok := sb.pieces[pieceKey]
+	if !ok {
+		return nil, xerrors.Errorf("piece %s not found", pieceKey)
+	}
+
+	return data, nil
+}
+
+func (sb *SBMock) CheckSector(sectorID uint64) error {
+	sb.lk.Lock()
+	defer sb.lk.Unlock()
+
+	ss, ok := sb.sectors[sectorID]
+	if !ok || ss.state != stateCommit {
+		return xerrors.Errorf("sector %d isn't sealed", sectorID)
+	}
+	if ss.failed {
+		return xerrors.Errorf("sector %d failed", sectorID)
+	}
+
+	return nil
+}
+
+func (sb *SBMock) RemoveSector(sectorID uint64) error {
+	sb.lk.Lock()
+	defer sb.lk.Unlock()
+
+	delete(sb.sectors, sectorID)
+	return nil
+}
+
+// FailSector makes the sector fail CheckSector and GeneratePoSt, simulating
+// lost sector data
+func (sb *SBMock) FailSector(sectorID uint64) error {
+	sb.lk.Lock()
+	defer sb.lk.Unlock()
+
+	ss, ok := sb.sectors[sectorID]
+	if !ok {
+		return xerrors.Errorf("sector %d not found", sectorID)
+	}
+
+	ss.failed = true
+	return nil
+}
+
+func (sb *SBMock) WorkerStats() sectorbuilder.WorkerStats {
+	free := mockWorkers - len(sb.rateLimit)
+
+	return sectorbuilder.WorkerStats{
+		LocalFree:  free,
+		LocalTotal: mockWorkers,
+	}
+}
+
+func (sb *SBMock) AddWorker(ctx context.Context) (<-chan sectorbuilder.WorkerTask, error) {
+	return nil, xerrors.New("remote workers not supported by the mock sectorbuilder")
+}
+
+func (sb *SBMock) TaskDone(ctx context.Context, task uint64, res sectorbuilder.SealRes) error {
+	return xerrors.New("remote workers not supported by the mock sectorbuilder")
+}
+
+func (sb *SBMock) sector(sectorID uint64, state int) (*sectorState, error) {
+	sb.lk.Lock()
+	defer sb.lk.Unlock()
+
+	ss, ok := sb.sectors[sectorID]
+	if !ok {
+		return nil, xerrors.Errorf("sector %d not found", sectorID)
+	}
+	if ss.state != state {
+		return nil, xerrors.Errorf("sector %d in unexpected state %d, expected %d", sectorID, ss.state, state)
+	}
+
+	return ss, nil
+}
+
+// commitment hashes the inputs into a commitment, which is a valid field
+// element like real commitments are
+func commitment(domain string, inputs ...[]byte) [sectorbuilder.CommLen]byte {
+	h := sha256.New()
+	h.Write([]byte(domain))
+	for _, in := range inputs {
+		h.Write(in)
+	}
+
+	var out [sectorbuilder.CommLen]byte
+	copy(out[:], h.Sum(nil))
+	out[sectorbuilder.CommLen-1] &= 0x3f
+	return out
+}
+
+func u64(v uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	return b[:]
+}
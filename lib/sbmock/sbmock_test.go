@@ -0,0 +1,78 @@
+// +build mockproofs
+
+package sbmock
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/chain/address"
+	"github.com/filecoin-project/lotus/lib/sectorbuilder"
+)
+
+func TestSealAndVerify(t *testing.T) {
+	maddr, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+
+	sb := NewMockSectorBuilder(&sectorbuilder.Config{
+		SectorSize: 1024,
+		Miner:      maddr,
+	})
+
+	sid, err := sb.AcquireSectorId()
+	require.NoError(t, err)
+
+	size := sectorbuilder.UserBytesForSectorSize(sb.SectorSize())
+	ppi, err := sb.AddPiece(size, sid, io.LimitReader(rand.New(rand.NewSource(42)), int64(size)), nil)
+	require.NoError(t, err)
+
+	ticket := sectorbuilder.SealTicket{BlockHeight: 5, TicketBytes: [32]byte{1, 2, 3}}
+	seed := sectorbuilder.SealSeed{BlockHeight: 15, TicketBytes: [32]byte{4, 5, 6}}
+
+	pieces := []sectorbuilder.PublicPieceInfo{ppi}
+	rspco, err := sb.SealPreCommit(sid, ticket, pieces)
+	require.NoError(t, err)
+
+	proof, err := sb.SealCommit(sid, ticket, seed, pieces, []string{"piece"}, rspco)
+	require.NoError(t, err)
+
+	ok, err := sectorbuilder.VerifySeal(sb.SectorSize(), rspco.CommR[:], rspco.CommD[:], maddr, ticket.TicketBytes[:], seed.TicketBytes[:], sid, proof)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = sectorbuilder.VerifySeal(sb.SectorSize(), rspco.CommR[:], rspco.CommD[:], maddr, ticket.TicketBytes[:], seed.TicketBytes[:], sid+1, proof)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	data, err := sb.ReadPieceFromSealedSector("piece")
+	require.NoError(t, err)
+	require.Len(t, data, int(size))
+
+	require.NoError(t, sb.CheckSector(sid))
+
+	ssi := sectorbuilder.NewSortedSectorInfo([]sectorbuilder.SectorInfo{{SectorID: sid, CommR: rspco.CommR}})
+	var cseed [sectorbuilder.CommLen]byte
+	postProof, err := sb.GeneratePoSt(ssi, cseed, nil)
+	require.NoError(t, err)
+
+	ok, err = sectorbuilder.VerifyPost(context.TODO(), sb.SectorSize(), ssi, cseed, postProof, nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	require.NoError(t, sb.FailSector(sid))
+	require.Error(t, sb.CheckSector(sid))
+
+	_, err = sb.GeneratePoSt(ssi, cseed, nil)
+	require.Error(t, err)
+
+	postProof, err = sb.GeneratePoSt(ssi, cseed, []uint64{sid})
+	require.NoError(t, err)
+
+	ok, err = sectorbuilder.VerifyPost(context.TODO(), sb.SectorSize(), ssi, cseed, postProof, []uint64{sid})
+	require.NoError(t, err)
+	require.True(t, ok)
+}
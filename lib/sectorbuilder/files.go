@@ -1,8 +1,10 @@
 package sectorbuilder
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sync"
@@ -10,33 +12,87 @@ import (
 	"golang.org/x/xerrors"
 )
 
-func (sb *SectorBuilder) sectorName(sectorID uint64) string {
+func (sb *SectorBuilder) SectorName(sectorID uint64) string {
 	return fmt.Sprintf("s-%s-%d", sb.Miner, sectorID)
 }
 
+// GetPath returns the path of a sector file of the given type (staged, sealed
+// or cache) for remote workers. Sealed sectors which aren't in any storage path
+// aren't found, see AllocPath
+func (sb *SectorBuilder) GetPath(typ string, sectorName string) (string, error) {
+	return sb.sectorFilePath(typ, sectorName, false)
+}
+
+// AllocPath is like GetPath, but puts sealed sectors which aren't stored yet
+// in a storage path. It's used when receiving sealed sectors from remote
+// workers
+func (sb *SectorBuilder) AllocPath(typ string, sectorName string) (string, error) {
+	return sb.sectorFilePath(typ, sectorName, true)
+}
+
+func (sb *SectorBuilder) sectorFilePath(typ string, sectorName string, alloc bool) (string, error) {
+	if sectorName != filepath.Base(sectorName) || sectorName == "." || sectorName == ".." {
+		return "", xerrors.Errorf("invalid sector name: %q", sectorName)
+	}
+
+	switch typ {
+	case "staged":
+		return filepath.Join(sb.stagedDir, sectorName), nil
+	case "sealed", "cache":
+		p, err := sb.sectorStoragePath(sectorName, alloc)
+		if err != nil {
+			return "", err
+		}
+
+		if typ == "sealed" {
+			return filepath.Join(p.sealedDir, sectorName), nil
+		}
+		return filepath.Join(p.cacheDir, sectorName), nil
+	default:
+		return "", xerrors.Errorf("unknown sector file type: %q", typ)
+	}
+}
+
+func (sb *SectorBuilder) sectorStoragePath(sectorName string, alloc bool) (*storagePath, error) {
+	if alloc {
+		return sb.allocSectorPath(sectorName)
+	}
+	return sb.lookupSectorPath(sectorName)
+}
+
 func (sb *SectorBuilder) stagedSectorPath(sectorID uint64) string {
-	return filepath.Join(sb.stagedDir, sb.sectorName(sectorID))
+	return filepath.Join(sb.stagedDir, sb.SectorName(sectorID))
 }
 
 func (sb *SectorBuilder) stagedSectorFile(sectorID uint64) (*os.File, error) {
 	return os.OpenFile(sb.stagedSectorPath(sectorID), os.O_RDWR|os.O_CREATE, 0644)
 }
 
-func (sb *SectorBuilder) sealedSectorPath(sectorID uint64) (string, error) {
-	path := filepath.Join(sb.sealedDir, sb.sectorName(sectorID))
-
-	e, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+// sealedSectorPath returns the path of the sealed file of a sector. With alloc
+// set, storage is allocated for new sectors
+func (sb *SectorBuilder) sealedSectorPath(sectorID uint64, alloc bool) (string, error) {
+	p, err := sb.sectorStoragePath(sb.SectorName(sectorID), alloc)
 	if err != nil {
 		return "", err
 	}
 
-	return path, e.Close()
+	return filepath.Join(p.sealedDir, sb.SectorName(sectorID)), nil
 }
 
-func (sb *SectorBuilder) sectorCacheDir(sectorID uint64) (string, error) {
-	dir := filepath.Join(sb.cacheDir, sb.sectorName(sectorID))
+// sectorCacheDir returns the cache directory of a sector, creating it if
+// alloc is set
+func (sb *SectorBuilder) sectorCacheDir(sectorID uint64, alloc bool) (string, error) {
+	p, err := sb.sectorStoragePath(sb.SectorName(sectorID), alloc)
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(p.cacheDir, sb.SectorName(sectorID))
+	if !alloc {
+		return dir, nil
+	}
 
-	err := os.Mkdir(dir, 0755)
+	err = os.Mkdir(dir, 0755)
 	if os.IsExist(err) {
 		err = nil
 	}
@@ -44,6 +100,169 @@ func (sb *SectorBuilder) sectorCacheDir(sectorID uint64) (string, error) {
 	return dir, err
 }
 
+// pieceLocation records where a piece was sealed, to unseal it
+type pieceLocation struct {
+	SectorID uint64
+	// Offset and Size of the piece in the unsealed sector, in user bytes
+	Offset uint64
+	Size   uint64
+
+	Ticket [CommLen]byte
+	CommD  [CommLen]byte
+}
+
+// alignPieces returns the in-sector offsets of pieces in the staged sector,
+// where each piece is aligned to its in-sector size, and where the last piece
+// ends
+func alignPieces(sizes []uint64) (offsets []uint64, end uint64) {
+	offsets = make([]uint64, len(sizes))
+
+	for i, size := range sizes {
+		padded := size + size/127
+		if end%padded != 0 {
+			end += padded - end%padded
+		}
+
+		offsets[i] = end
+		end += padded
+	}
+
+	return offsets, end
+}
+
+// pieceOffsets returns the offsets of pieces in the unsealed sector, in user
+// bytes
+func pieceOffsets(sizes []uint64) []uint64 {
+	offsets, _ := alignPieces(sizes)
+	for i, offset := range offsets {
+		offsets[i] = offset - offset/128
+	}
+
+	return offsets
+}
+
+// TrimStagedSector drops data after the given pieces from the staged sector.
+// It's left behind by piece writes which failed, and has to be removed before
+// more pieces are added
+func (sb *SectorBuilder) TrimStagedSector(sectorID uint64, pieceSizes []uint64) error {
+	_, end := alignPieces(pieceSizes)
+
+	path := sb.stagedSectorPath(sectorID)
+	st, err := os.Stat(path)
+	switch {
+	case os.IsNotExist(err) && end == 0:
+		return nil
+	case err != nil:
+		return xerrors.Errorf("stat staged sector %d: %w", sectorID, err)
+	case uint64(st.Size()) < end:
+		return xerrors.Errorf("staged sector %d is smaller than its pieces (%d < %d)", sectorID, st.Size(), end)
+	case uint64(st.Size()) == end:
+		return nil
+	}
+
+	log.Warnf("dropping %d bytes of partially written pieces from staged sector %d", uint64(st.Size())-end, sectorID)
+	return os.Truncate(path, int64(end))
+}
+
+func (sb *SectorBuilder) putPieceLocations(sectorID uint64, ticket SealTicket, commD [CommLen]byte, pieces []PublicPieceInfo, pieceKeys []string) error {
+	sizes := make([]uint64, len(pieces))
+	for i, piece := range pieces {
+		sizes[i] = piece.Size
+	}
+
+	for i, offset := range pieceOffsets(sizes) {
+		b, err := json.Marshal(&pieceLocation{
+			SectorID: sectorID,
+			Offset:   offset,
+			Size:     sizes[i],
+			Ticket:   ticket.TicketBytes,
+			CommD:    commD,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := sb.ds.Put(pieceLocationsKey.ChildString(pieceKeys[i]), b); err != nil {
+			return xerrors.Errorf("storing location of piece %s: %w", pieceKeys[i], err)
+		}
+	}
+
+	return nil
+}
+
+func (sb *SectorBuilder) pieceLocation(pieceKey string) (*pieceLocation, error) {
+	b, err := sb.ds.Get(pieceLocationsKey.ChildString(pieceKey))
+	if err != nil {
+		return nil, err
+	}
+
+	var loc pieceLocation
+	if err := json.Unmarshal(b, &loc); err != nil {
+		return nil, xerrors.Errorf("unmarshaling piece location: %w", err)
+	}
+	return &loc, nil
+}
+
+// CheckSector verifies that the sealed replica and cache of a sector are
+// present and readable, as required to generate PoSts for it
+func (sb *SectorBuilder) CheckSector(sectorID uint64) error {
+	sb.pathsLk.Lock()
+	p := sb.findSector(sb.SectorName(sectorID))
+	sb.pathsLk.Unlock()
+	if p == nil {
+		return xerrors.Errorf("sector not found in any storage path")
+	}
+
+	sealedPath := filepath.Join(p.sealedDir, sb.SectorName(sectorID))
+
+	f, err := os.Open(sealedPath)
+	if err != nil {
+		return xerrors.Errorf("opening sealed sector: %w", err)
+	}
+	_, err = f.ReadAt(make([]byte, 1), 0)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return xerrors.Errorf("reading sealed sector: %w", err)
+	}
+
+	cacheDir := filepath.Join(p.cacheDir, sb.SectorName(sectorID))
+	files, err := ioutil.ReadDir(cacheDir)
+	if err != nil {
+		return xerrors.Errorf("reading sector cache: %w", err)
+	}
+	if len(files) == 0 {
+		return xerrors.Errorf("sector cache %s is empty", cacheDir)
+	}
+
+	return nil
+}
+
+// RemoveSector deletes the staged and sealed files, and the cache of a sector
+func (sb *SectorBuilder) RemoveSector(sectorID uint64) error {
+	name := sb.SectorName(sectorID)
+
+	sb.pathsLk.Lock()
+	p := sb.findSector(name)
+	sb.pathsLk.Unlock()
+
+	if p != nil {
+		if err := os.Remove(filepath.Join(p.sealedDir, name)); err != nil && !os.IsNotExist(err) {
+			return xerrors.Errorf("removing sealed sector: %w", err)
+		}
+		if err := os.RemoveAll(filepath.Join(p.cacheDir, name)); err != nil {
+			return xerrors.Errorf("removing sector cache: %w", err)
+		}
+	}
+
+	if err := os.Remove(sb.stagedSectorPath(sectorID)); err != nil && !os.IsNotExist(err) {
+		return xerrors.Errorf("removing staged sector: %w", err)
+	}
+
+	return nil
+}
+
 func toReadableFile(r io.Reader, n int64) (*os.File, func() error, error) {
 	f, ok := r.(*os.File)
 	if ok {
@@ -0,0 +1,50 @@
+package sectorbuilder
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/chain/address"
+)
+
+func TestPieceOffsets(t *testing.T) {
+	// user sizes of 128, 256 and 512 byte in-sector pieces
+	p128 := uint64(127)
+	p256 := uint64(254)
+	p512 := uint64(508)
+
+	offsets, end := alignPieces([]uint64{p128, p256, p512})
+	assert.Equal(t, []uint64{0, 256, 512}, offsets)
+	assert.Equal(t, uint64(1024), end)
+
+	assert.Equal(t, []uint64{0, p128, p256}, pieceOffsets([]uint64{p128, p128, p256}))
+}
+
+func TestTrimStagedSector(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sbstaged")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	maddr, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+
+	sb := &SectorBuilder{ssize: 1024, Miner: maddr, stagedDir: dir}
+
+	// nothing was written to the sector yet
+	require.NoError(t, sb.TrimStagedSector(1, nil))
+
+	// a 256 byte in-sector piece, followed by part of a failed write
+	require.NoError(t, ioutil.WriteFile(sb.stagedSectorPath(1), make([]byte, 256+100), 0644))
+
+	require.NoError(t, sb.TrimStagedSector(1, []uint64{254}))
+	st, err := os.Stat(sb.stagedSectorPath(1))
+	require.NoError(t, err)
+	assert.Equal(t, int64(256), st.Size())
+
+	require.NoError(t, sb.TrimStagedSector(1, []uint64{254}))
+	require.Error(t, sb.TrimStagedSector(1, []uint64{254, 254}))
+}
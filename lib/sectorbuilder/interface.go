@@ -0,0 +1,33 @@
+package sectorbuilder
+
+import (
+	"context"
+	"io"
+)
+
+// Interface is the set of sector operations the storage miner depends on.
+// It's implemented by SectorBuilder, and by a mock in lib/sbmock which allows
+// running miner flows without real proofs in mockproofs builds
+type Interface interface {
+	SectorSize() uint64
+
+	AcquireSectorId() (uint64, error)
+	RateLimit() func()
+
+	AddPiece(pieceSize uint64, sectorId uint64, file io.Reader, existingPieceSizes []uint64) (PublicPieceInfo, error)
+	TrimStagedSector(sectorID uint64, pieceSizes []uint64) error
+	SealPreCommit(sectorID uint64, ticket SealTicket, pieces []PublicPieceInfo) (RawSealPreCommitOutput, error)
+	SealCommit(sectorID uint64, ticket SealTicket, seed SealSeed, pieces []PublicPieceInfo, pieceKeys []string, rspco RawSealPreCommitOutput) ([]byte, error)
+
+	GeneratePoSt(sectorInfo SortedSectorInfo, challengeSeed [CommLen]byte, faults []uint64) ([]byte, error)
+
+	ReadPieceFromSealedSector(pieceKey string) ([]byte, error)
+	CheckSector(sectorID uint64) error
+	RemoveSector(sectorID uint64) error
+
+	WorkerStats() WorkerStats
+	AddWorker(ctx context.Context) (<-chan WorkerTask, error)
+	TaskDone(ctx context.Context, task uint64, res SealRes) error
+}
+
+var _ Interface = &SectorBuilder{}
@@ -0,0 +1,108 @@
+// +build mockproofs
+
+package sectorbuilder
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"io"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/address"
+)
+
+// In mockproofs builds, commitments and proofs are hashes of their inputs,
+// computed in Go. Proofs generated by the mock sectorbuilder in lib/sbmock are
+// accepted without running any proof verification.
+
+func VerifySeal(sectorSize uint64, commR, commD []byte, proverID address.Address, ticket []byte, seed []byte, sectorID uint64, proof []byte) (bool, error) {
+	return bytes.Equal(proof, MockSealProof(commR, commD, proverID, ticket, seed, sectorID)), nil
+}
+
+func VerifyPost(ctx context.Context, sectorSize uint64, sectorInfo SortedSectorInfo, challengeSeed [CommLen]byte, proof []byte, faults []uint64) (bool, error) {
+	return bytes.Equal(proof, MockPoStProof(sectorInfo, challengeSeed, faults)), nil
+}
+
+func GeneratePieceCommitment(piece io.Reader, pieceSize uint64) (commP [CommLen]byte, err error) {
+	h := sha256.New()
+	h.Write([]byte("commP"))
+	h.Write(u64(pieceSize))
+	if _, err := io.CopyN(h, piece, int64(pieceSize)); err != nil {
+		return [CommLen]byte{}, xerrors.Errorf("reading piece: %w", err)
+	}
+
+	return mockSum(h), nil
+}
+
+func GenerateDataCommitment(ssize uint64, pieces []PublicPieceInfo) ([CommLen]byte, error) {
+	inputs := [][]byte{u64(ssize)}
+	for _, piece := range pieces {
+		commP := piece.CommP
+		inputs = append(inputs, u64(piece.Size), commP[:])
+	}
+
+	return mockCommitment("commD", inputs...), nil
+}
+
+// MockSealProof returns the seal proof VerifySeal accepts for the inputs
+func MockSealProof(commR, commD []byte, proverID address.Address, ticket []byte, seed []byte, sectorID uint64) []byte {
+	proof := mockCommitment("seal", comm(commR), comm(commD), proverID.Bytes(), comm(ticket), comm(seed), u64(sectorID))
+	return proof[:]
+}
+
+// MockPoStProof returns the PoSt VerifyPost accepts for the inputs
+func MockPoStProof(sectorInfo SortedSectorInfo, challengeSeed [CommLen]byte, faults []uint64) []byte {
+	faulty := map[uint64]bool{}
+	for _, id := range faults {
+		faulty[id] = true
+	}
+
+	inputs := [][]byte{challengeSeed[:]}
+	for _, si := range sectorInfo.Values() {
+		if faulty[si.SectorID] {
+			continue
+		}
+		commR := si.CommR
+		inputs = append(inputs, u64(si.SectorID), commR[:])
+	}
+
+	proof := mockCommitment("post", inputs...)
+	return proof[:]
+}
+
+// mockCommitment hashes the inputs into a commitment
+func mockCommitment(domain string, inputs ...[]byte) [CommLen]byte {
+	h := sha256.New()
+	h.Write([]byte(domain))
+	for _, in := range inputs {
+		h.Write(in)
+	}
+
+	return mockSum(h)
+}
+
+// mockSum returns the hash as a commitment, which is a valid field element
+// like real commitments are
+func mockSum(h hash.Hash) [CommLen]byte {
+	var out [CommLen]byte
+	copy(out[:], h.Sum(nil))
+	out[CommLen-1] &= 0x3f
+	return out
+}
+
+// comm pads or truncates b to commitment length, like the real verifier
+func comm(b []byte) []byte {
+	var out [CommLen]byte
+	copy(out[:], b)
+	return out[:]
+}
+
+func u64(v uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	return b[:]
+}
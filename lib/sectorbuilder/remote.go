@@ -0,0 +1,327 @@
+package sectorbuilder
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+type WorkerTaskType int
+
+const (
+	WorkerAddPiece WorkerTaskType = iota
+	WorkerPreCommit
+	WorkerCommit
+)
+
+func (t WorkerTaskType) String() string {
+	switch t {
+	case WorkerAddPiece:
+		return "AddPiece"
+	case WorkerPreCommit:
+		return "PreCommit"
+	case WorkerCommit:
+		return "Commit"
+	default:
+		return "Unknown"
+	}
+}
+
+// WorkerTask is a unit of sealing work handed out to a remote seal worker
+type WorkerTask struct {
+	Type   WorkerTaskType
+	TaskID uint64
+
+	SectorID uint64
+
+	// add piece
+	PieceSize uint64
+
+	// preCommit
+	SealTicket SealTicket
+	Pieces     []PublicPieceInfo
+
+	// commit
+	SealSeed SealSeed
+	Rspco    RawSealPreCommitOutput
+}
+
+// SealRes is the result of a WorkerTask, reported back by the worker
+type SealRes struct {
+	Err   string
+	GoErr error `json:"-"`
+
+	Piece PublicPieceInfo
+	Rspco RawSealPreCommitOutput
+	Proof []byte
+}
+
+func (r *SealRes) err() error {
+	if r.GoErr != nil {
+		return r.GoErr
+	}
+	if r.Err != "" {
+		return xerrors.New(r.Err)
+	}
+	return nil
+}
+
+// remotePiece is the piece of an add piece task handed out to a remote worker.
+// The worker fetches the data, and uploads it back padded, to be written to the
+// staged sector after the existing pieces
+type remotePiece struct {
+	data io.Reader
+
+	sectorID uint64
+	size     uint64
+	existing []uint64
+
+	written bool
+}
+
+type workerCall struct {
+	task WorkerTask
+	ret  chan SealRes
+}
+
+type remote struct {
+	lk sync.Mutex
+
+	sealTasks chan<- WorkerTask
+	busy      *WorkerTask // nil when idle
+}
+
+type RemoteWorkerStatus struct {
+	ID       int
+	Busy     bool
+	TaskID   uint64
+	TaskType WorkerTaskType
+}
+
+// AddWorker registers a remote seal worker. Tasks are sent to the returned
+// channel until ctx is cancelled, results are reported with TaskDone
+func (sb *SectorBuilder) AddWorker(ctx context.Context) (<-chan WorkerTask, error) {
+	sb.remoteLk.Lock()
+	defer sb.remoteLk.Unlock()
+
+	taskCh := make(chan WorkerTask)
+	r := &remote{
+		sealTasks: taskCh,
+	}
+
+	sb.remoteCtr++
+	sb.remotes[sb.remoteCtr] = r
+
+	go sb.remoteWorker(ctx, r, sb.remoteCtr, taskCh)
+
+	return taskCh, nil
+}
+
+func (sb *SectorBuilder) remoteWorker(ctx context.Context, r *remote, id int, taskCh chan WorkerTask) {
+	defer log.Warnf("remote worker %d closed", id)
+	defer close(taskCh)
+
+	defer func() {
+		sb.remoteLk.Lock()
+		delete(sb.remotes, id)
+		sb.remoteLk.Unlock()
+	}()
+
+	for {
+		var task workerCall
+		select {
+		case task = <-sb.addPieceTasks:
+		case task = <-sb.precommitTasks:
+		case task = <-sb.commitTasks:
+		case <-ctx.Done():
+			return
+		case <-sb.stopping:
+			return
+		}
+
+		if !sb.remoteTask(ctx, r, id, task) {
+			return
+		}
+	}
+}
+
+// remoteTask sends the task to the worker and waits for the result, it
+// returns false when the worker went away
+func (sb *SectorBuilder) remoteTask(ctx context.Context, r *remote, id int, task workerCall) bool {
+	resCh := make(chan SealRes, 1)
+
+	sb.remoteLk.Lock()
+	sb.remoteResults[task.task.TaskID] = resCh
+	sb.remoteLk.Unlock()
+
+	defer func() {
+		sb.remoteLk.Lock()
+		delete(sb.remoteResults, task.task.TaskID)
+		sb.remoteLk.Unlock()
+	}()
+
+	r.lk.Lock()
+	r.busy = &task.task
+	r.lk.Unlock()
+
+	defer func() {
+		r.lk.Lock()
+		r.busy = nil
+		r.lk.Unlock()
+	}()
+
+	select {
+	case r.sealTasks <- task.task:
+	case <-ctx.Done():
+		task.ret <- SealRes{GoErr: xerrors.Errorf("remote worker %d disconnected before starting task %d", id, task.task.TaskID)}
+		return false
+	}
+
+	log.Infof("remote worker %d started %s task %d (sector %d)", id, task.task.Type, task.task.TaskID, task.task.SectorID)
+
+	select {
+	case res := <-resCh:
+		task.ret <- res
+		return true
+	case <-ctx.Done():
+		task.ret <- SealRes{GoErr: xerrors.Errorf("remote worker %d disconnected while processing task %d", id, task.task.TaskID)}
+		return false
+	case <-sb.stopping:
+		task.ret <- SealRes{GoErr: xerrors.New("sectorbuilder stopped")}
+		return false
+	}
+}
+
+// TaskDone reports the result of a task handed out to a remote worker
+func (sb *SectorBuilder) TaskDone(ctx context.Context, task uint64, res SealRes) error {
+	sb.remoteLk.Lock()
+	rres, ok := sb.remoteResults[task]
+	if ok {
+		delete(sb.remoteResults, task)
+	}
+	sb.remoteLk.Unlock()
+
+	if !ok {
+		return xerrors.Errorf("task %d not found", task)
+	}
+
+	select {
+	case rres <- res:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TaskPiece returns the data of the piece being added by an add piece task.
+// The data can only be read once
+func (sb *SectorBuilder) TaskPiece(task uint64) (io.Reader, error) {
+	sb.remoteLk.Lock()
+	defer sb.remoteLk.Unlock()
+
+	rp, ok := sb.remotePieces[task]
+	if !ok || rp.data == nil {
+		return nil, xerrors.Errorf("no piece data for task %d", task)
+	}
+
+	r := rp.data
+	rp.data = nil
+	return r, nil
+}
+
+// WriteTaskPiece writes the padded piece written by the remote worker of an add
+// piece task to the staged sector, aligned after the existing pieces
+func (sb *SectorBuilder) WriteTaskPiece(task uint64, r io.Reader) error {
+	sb.remoteLk.Lock()
+	rp, ok := sb.remotePieces[task]
+	sb.remoteLk.Unlock()
+	if !ok {
+		return xerrors.Errorf("no piece for task %d", task)
+	}
+
+	offsets, end := alignPieces(append(append([]uint64{}, rp.existing...), rp.size))
+	offset := offsets[len(offsets)-1]
+
+	if err := sb.TrimStagedSector(rp.sectorID, rp.existing); err != nil {
+		return err
+	}
+
+	f, err := sb.stagedSectorFile(rp.sectorID)
+	if err != nil {
+		return err
+	}
+
+	if err := writePieceAt(f, r, offset, end-offset); err != nil {
+		_ = f.Close()
+		return xerrors.Errorf("writing piece of task %d: %w", task, err)
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	sb.remoteLk.Lock()
+	rp.written = true
+	sb.remoteLk.Unlock()
+
+	return nil
+}
+
+// writePieceAt writes size bytes of padded piece data at offset, the alignment
+// padding before the piece is zeroed
+func writePieceAt(f *os.File, r io.Reader, offset uint64, size uint64) error {
+	if err := f.Truncate(int64(offset)); err != nil {
+		return err
+	}
+	if _, err := f.Seek(int64(offset), io.SeekStart); err != nil {
+		return err
+	}
+
+	if _, err := io.CopyN(f, r, int64(size)); err != nil {
+		return xerrors.Errorf("expected %d bytes: %w", size, err)
+	}
+
+	n, err := io.Copy(ioutil.Discard, r)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return xerrors.Errorf("got %d bytes more than the padded piece size %d", n, size)
+	}
+
+	return nil
+}
+
+func (sb *SectorBuilder) remoteStats() (total int, free int, remotes []RemoteWorkerStatus) {
+	sb.remoteLk.Lock()
+	defer sb.remoteLk.Unlock()
+
+	for id, r := range sb.remotes {
+		st := RemoteWorkerStatus{ID: id}
+
+		r.lk.Lock()
+		if r.busy != nil {
+			st.Busy = true
+			st.TaskID = r.busy.TaskID
+			st.TaskType = r.busy.Type
+		}
+		r.lk.Unlock()
+
+		total++
+		if !st.Busy {
+			free++
+		}
+		remotes = append(remotes, st)
+	}
+
+	sort.Slice(remotes, func(i, j int) bool {
+		return remotes[i].ID < remotes[j].ID
+	})
+
+	return total, free, remotes
+}
@@ -0,0 +1,241 @@
+package sectorbuilder
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/chain/address"
+)
+
+func newRemoteTestSB(t *testing.T, localThreads int) (*SectorBuilder, func()) {
+	dir, err := ioutil.TempDir("", "sbremote")
+	require.NoError(t, err)
+
+	maddr, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+
+	sb := &SectorBuilder{
+		ssize:     1024,
+		Miner:     maddr,
+		stagedDir: dir,
+
+		rateLimit: make(chan struct{}, localThreads),
+
+		addPieceTasks:  make(chan workerCall),
+		precommitTasks: make(chan workerCall),
+		commitTasks:    make(chan workerCall),
+
+		remotes:       map[int]*remote{},
+		remoteResults: map[uint64]chan<- SealRes{},
+		remotePieces:  map[uint64]*remotePiece{},
+
+		stopping: make(chan struct{}),
+	}
+
+	return sb, func() {
+		sb.Destroy()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+// nextTask waits for a task to be sent to a fake remote worker
+func nextTask(t *testing.T, tasks <-chan WorkerTask) WorkerTask {
+	select {
+	case task, ok := <-tasks:
+		require.True(t, ok, "task channel closed")
+		return task
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a task")
+	}
+	return WorkerTask{}
+}
+
+type scheduleRes struct {
+	ret     <-chan SealRes
+	release func()
+	err     error
+}
+
+func scheduleAsync(sb *SectorBuilder, noLocal bool, task WorkerTask) <-chan scheduleRes {
+	out := make(chan scheduleRes, 1)
+	go func() {
+		ret, release, err := sb.schedule(sb.precommitTasks, &sb.preCommitWait, noLocal, task)
+		out <- scheduleRes{ret, release, err}
+	}()
+	return out
+}
+
+func TestRemoteAddPiece(t *testing.T) {
+	// no local slots, pieces can only be added by remote workers
+	sb, done := newRemoteTestSB(t, 0)
+	defer done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tasks, err := sb.AddWorker(ctx)
+	require.NoError(t, err)
+
+	st := sb.WorkerStats()
+	assert.Equal(t, 1, st.RemotesTotal)
+	assert.Equal(t, 1, st.RemotesFree)
+
+	// a 128 byte in-sector piece was already added
+	existing := bytes.Repeat([]byte{1}, 128)
+	require.NoError(t, ioutil.WriteFile(sb.stagedSectorPath(1), existing, 0644))
+
+	data := bytes.Repeat([]byte{2}, 254)
+	type addRes struct {
+		piece PublicPieceInfo
+		err   error
+	}
+	added := make(chan addRes, 1)
+	go func() {
+		piece, err := sb.AddPiece(254, 1, bytes.NewReader(data), []uint64{127})
+		added <- addRes{piece, err}
+	}()
+
+	task := nextTask(t, tasks)
+	assert.Equal(t, WorkerAddPiece, task.Type)
+	assert.Equal(t, uint64(1), task.SectorID)
+	assert.Equal(t, uint64(254), task.PieceSize)
+
+	st = sb.WorkerStats()
+	assert.Equal(t, 0, st.RemotesFree)
+	require.Len(t, st.Remotes, 1)
+	assert.True(t, st.Remotes[0].Busy)
+	assert.Equal(t, task.TaskID, st.Remotes[0].TaskID)
+
+	rd, err := sb.TaskPiece(task.TaskID)
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(rd)
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	_, err = sb.TaskPiece(task.TaskID)
+	require.Error(t, err, "piece data can only be read once")
+
+	// the worker sends back just the padded piece
+	padded := bytes.Repeat([]byte{3}, 256)
+	require.Error(t, sb.WriteTaskPiece(task.TaskID, bytes.NewReader(padded[:100])))
+	require.NoError(t, sb.WriteTaskPiece(task.TaskID, bytes.NewReader(padded)))
+
+	require.NoError(t, sb.TaskDone(ctx, task.TaskID, SealRes{Piece: PublicPieceInfo{Size: 254}}))
+
+	res := <-added
+	require.NoError(t, res.err)
+	assert.Equal(t, uint64(254), res.piece.Size)
+
+	// the piece is aligned to its in-sector size after the existing piece
+	staged, err := ioutil.ReadFile(sb.stagedSectorPath(1))
+	require.NoError(t, err)
+	expect := append(append(existing, make([]byte, 128)...), padded...)
+	assert.Equal(t, expect, staged)
+
+	// the piece has to be sent before the task is done
+	go func() {
+		piece, err := sb.AddPiece(254, 1, bytes.NewReader(data), []uint64{127, 254})
+		added <- addRes{piece, err}
+	}()
+
+	task = nextTask(t, tasks)
+	require.NoError(t, sb.TaskDone(ctx, task.TaskID, SealRes{Piece: PublicPieceInfo{Size: 254}}))
+	require.Error(t, (<-added).err)
+}
+
+func TestScheduleLocalSlot(t *testing.T) {
+	sb, done := newRemoteTestSB(t, 1)
+	defer done()
+
+	// without remote workers tasks run locally
+	ret, release, err := sb.schedule(sb.precommitTasks, &sb.preCommitWait, false, WorkerTask{Type: WorkerPreCommit, TaskID: 1})
+	require.NoError(t, err)
+	require.Nil(t, ret)
+	require.NotNil(t, release)
+	assert.Equal(t, 0, sb.WorkerStats().LocalFree)
+
+	// the only slot is taken, the next task waits
+	waiting := scheduleAsync(sb, false, WorkerTask{Type: WorkerPreCommit, TaskID: 2})
+	select {
+	case <-waiting:
+		t.Fatal("task scheduled without a free slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, 1, sb.WorkerStats().PreCommitWait)
+
+	release()
+
+	res := <-waiting
+	require.NoError(t, res.err)
+	require.Nil(t, res.ret)
+	res.release()
+	assert.Equal(t, 1, sb.WorkerStats().LocalFree)
+	assert.Equal(t, 0, sb.WorkerStats().PreCommitWait)
+
+	// with local processing disabled, tasks wait for a remote worker even
+	// when a local slot is free
+	waiting = scheduleAsync(sb, true, WorkerTask{Type: WorkerPreCommit, TaskID: 3})
+	select {
+	case <-waiting:
+		t.Fatal("task scheduled locally with local processing disabled")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tasks, err := sb.AddWorker(ctx)
+	require.NoError(t, err)
+
+	task := nextTask(t, tasks)
+	assert.Equal(t, uint64(3), task.TaskID)
+
+	res = <-waiting
+	require.NoError(t, res.err)
+	require.NotNil(t, res.ret)
+
+	require.NoError(t, sb.TaskDone(ctx, task.TaskID, SealRes{Proof: []byte("proof")}))
+
+	sres, err := sb.remoteResult(res.ret)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("proof"), sres.Proof)
+
+	require.Error(t, sb.TaskDone(ctx, task.TaskID, SealRes{}), "results are only accepted once")
+}
+
+func TestRemoteWorkerDisconnect(t *testing.T) {
+	sb, done := newRemoteTestSB(t, 0)
+	defer done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	tasks, err := sb.AddWorker(ctx)
+	require.NoError(t, err)
+
+	waiting := scheduleAsync(sb, true, WorkerTask{Type: WorkerPreCommit, TaskID: 1})
+	task := nextTask(t, tasks)
+	res := <-waiting
+	require.NoError(t, res.err)
+
+	// the worker goes away while processing the task
+	cancel()
+
+	_, err = sb.remoteResult(res.ret)
+	require.Error(t, err)
+
+	_, ok := <-tasks
+	assert.False(t, ok, "task channel should be closed")
+
+	for sb.WorkerStats().RemotesTotal != 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	require.Error(t, sb.TaskDone(context.Background(), task.TaskID, SealRes{}))
+}
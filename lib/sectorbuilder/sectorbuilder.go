@@ -1,18 +1,19 @@
 package sectorbuilder
 
 import (
-	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"unsafe"
 
 	sectorbuilder "github.com/filecoin-project/go-sectorbuilder"
 	"github.com/ipfs/go-datastore"
 	logging "github.com/ipfs/go-log"
-	"go.opencensus.io/trace"
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/lotus/chain/address"
@@ -23,6 +24,7 @@ const PoStReservedWorkers = 1
 const PoRepProofPartitions = 2
 
 var lastSectorIdKey = datastore.NewKey("/sectorbuilder/last")
+var pieceLocationsKey = datastore.NewKey("/sectorbuilder/pieces")
 
 var log = logging.Logger("sectorbuilder")
 
@@ -58,10 +60,35 @@ type SectorBuilder struct {
 	Miner address.Address
 
 	stagedDir string
-	sealedDir string
-	cacheDir  string
+
+	// sealed sectors and caches are spread across storage paths, the first
+	// one is the default path from Config
+	pathsLk sync.Mutex
+	paths   []*storagePath
 
 	rateLimit chan struct{}
+
+	noPreCommit bool
+	noCommit    bool
+
+	// tasks waiting for a worker, remote workers pick them up, local calls
+	// use rateLimit slots
+	addPieceTasks  chan workerCall
+	precommitTasks chan workerCall
+	commitTasks    chan workerCall
+
+	taskCtr       uint64
+	addPieceWait  int32
+	preCommitWait int32
+	commitWait    int32
+
+	remoteLk      sync.Mutex
+	remoteCtr     int
+	remotes       map[int]*remote
+	remoteResults map[uint64]chan<- SealRes
+	remotePieces  map[uint64]*remotePiece
+
+	stopping chan struct{}
 }
 
 type Config struct {
@@ -70,10 +97,18 @@ type Config struct {
 
 	WorkerThreads uint8
 
+	// NoPreCommit and NoCommit disable local sealing, leaving the work to
+	// remote seal workers
+	NoPreCommit bool
+	NoCommit    bool
+
 	CacheDir    string
 	SealedDir   string
 	StagedDir   string
 	MetadataDir string
+
+	// Storage lists additional paths for sealed sectors
+	Storage []StoragePath
 }
 
 func New(cfg *Config, ds dtypes.MetadataDS) (*SectorBuilder, error) {
@@ -118,16 +153,75 @@ func New(cfg *Config, ds dtypes.MetadataDS) (*SectorBuilder, error) {
 		ssize: cfg.SectorSize,
 
 		stagedDir: cfg.StagedDir,
-		sealedDir: cfg.SealedDir,
-		cacheDir:  cfg.CacheDir,
+		paths:     []*storagePath{defaultPath(cfg)},
 
 		Miner:     cfg.Miner,
 		rateLimit: make(chan struct{}, cfg.WorkerThreads-PoStReservedWorkers),
+
+		noPreCommit: cfg.NoPreCommit,
+		noCommit:    cfg.NoCommit,
+
+		addPieceTasks:  make(chan workerCall),
+		precommitTasks: make(chan workerCall),
+		commitTasks:    make(chan workerCall),
+
+		remotes:       map[int]*remote{},
+		remoteResults: map[uint64]chan<- SealRes{},
+		remotePieces:  map[uint64]*remotePiece{},
+
+		stopping: make(chan struct{}),
+	}
+
+	for _, p := range cfg.Storage {
+		if _, err := sb.addPath(p); err != nil {
+			return nil, xerrors.Errorf("adding storage path %s: %w", p.Path, err)
+		}
+	}
+
+	if err := sb.loadStoragePaths(); err != nil {
+		return nil, err
 	}
 
 	return sb, nil
 }
 
+func defaultPath(cfg *Config) *storagePath {
+	return &storagePath{
+		StoragePath: StoragePath{
+			Path:   filepath.Dir(cfg.SealedDir),
+			Weight: 1,
+		},
+		sealedDir: cfg.SealedDir,
+		cacheDir:  cfg.CacheDir,
+	}
+}
+
+// NewStandalone creates a sectorbuilder which can only seal sectors, without
+// tracking them. Used by remote seal workers
+func NewStandalone(cfg *Config) (*SectorBuilder, error) {
+	for _, dir := range []string{cfg.StagedDir, cfg.SealedDir, cfg.CacheDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	return &SectorBuilder{
+		ssize: cfg.SectorSize,
+
+		stagedDir: cfg.StagedDir,
+		paths:     []*storagePath{defaultPath(cfg)},
+
+		Miner:     cfg.Miner,
+		rateLimit: make(chan struct{}, cfg.WorkerThreads),
+
+		remotes:       map[int]*remote{},
+		remoteResults: map[uint64]chan<- SealRes{},
+		remotePieces:  map[uint64]*remotePiece{},
+
+		stopping: make(chan struct{}),
+	}, nil
+}
+
 func (sb *SectorBuilder) RateLimit() func() {
 	if cap(sb.rateLimit) == len(sb.rateLimit) {
 		log.Warn("rate-limiting sectorbuilder call")
@@ -139,8 +233,79 @@ func (sb *SectorBuilder) RateLimit() func() {
 	}
 }
 
-func (sb *SectorBuilder) WorkerStats() (free, reserved, total int) {
-	return cap(sb.rateLimit) - len(sb.rateLimit), PoStReservedWorkers, cap(sb.rateLimit) + PoStReservedWorkers
+type WorkerStats struct {
+	LocalFree     int
+	LocalReserved int // for PoSt
+	LocalTotal    int
+
+	RemotesTotal int
+	RemotesFree  int
+	Remotes      []RemoteWorkerStatus
+
+	AddPieceWait  int
+	PreCommitWait int
+	CommitWait    int
+}
+
+func (sb *SectorBuilder) WorkerStats() WorkerStats {
+	rtotal, rfree, remotes := sb.remoteStats()
+
+	return WorkerStats{
+		LocalFree:     cap(sb.rateLimit) - len(sb.rateLimit),
+		LocalReserved: PoStReservedWorkers,
+		LocalTotal:    cap(sb.rateLimit) + PoStReservedWorkers,
+
+		RemotesTotal: rtotal,
+		RemotesFree:  rfree,
+		Remotes:      remotes,
+
+		AddPieceWait:  int(atomic.LoadInt32(&sb.addPieceWait)),
+		PreCommitWait: int(atomic.LoadInt32(&sb.preCommitWait)),
+		CommitWait:    int(atomic.LoadInt32(&sb.commitWait)),
+	}
+}
+
+// localSlot returns the channel used to acquire a local worker slot, or nil
+// (which blocks forever in select) if local processing is disabled
+func (sb *SectorBuilder) localSlot(disabled bool) chan struct{} {
+	if disabled {
+		return nil
+	}
+	return sb.rateLimit
+}
+
+// schedule waits for either a remote worker to accept the task, or a free
+// local slot. If the task was accepted by a remote worker, the returned
+// channel delivers its result, otherwise the caller must run the task locally
+// and release the slot with the returned function
+func (sb *SectorBuilder) schedule(tasks chan workerCall, wait *int32, noLocal bool, task WorkerTask) (<-chan SealRes, func(), error) {
+	call := workerCall{
+		task: task,
+		ret:  make(chan SealRes, 1),
+	}
+
+	atomic.AddInt32(wait, 1)
+	defer atomic.AddInt32(wait, -1)
+
+	select {
+	case tasks <- call:
+		return call.ret, nil, nil
+	case sb.localSlot(noLocal) <- struct{}{}:
+		return nil, func() {
+			<-sb.rateLimit
+		}, nil
+	case <-sb.stopping:
+		return nil, nil, xerrors.New("sectorbuilder stopped")
+	}
+}
+
+func (sb *SectorBuilder) remoteResult(ret <-chan SealRes) (SealRes, error) {
+	select {
+	case res := <-ret:
+		return res, res.err()
+	case <-sb.stopping:
+		return SealRes{}, xerrors.New("sectorbuilder stopped")
+	}
 }
 
 func addressToProverID(a address.Address) [32]byte {
@@ -150,7 +315,11 @@ func addressToProverID(a address.Address) [32]byte {
 }
 
 func (sb *SectorBuilder) Destroy() {
-	sectorbuilder.DestroySectorBuilder(sb.handle)
+	close(sb.stopping)
+
+	if sb.handle != nil {
+		sectorbuilder.DestroySectorBuilder(sb.handle)
+	}
 }
 
 func (sb *SectorBuilder) AcquireSectorId() (uint64, error) {
@@ -169,9 +338,57 @@ func (sb *SectorBuilder) AcquireSectorId() (uint64, error) {
 }
 
 func (sb *SectorBuilder) AddPiece(pieceSize uint64, sectorId uint64, file io.Reader, existingPieceSizes []uint64) (PublicPieceInfo, error) {
-	ret := sb.RateLimit()
-	defer ret()
+	task := WorkerTask{
+		Type:      WorkerAddPiece,
+		TaskID:    atomic.AddUint64(&sb.taskCtr, 1),
+		SectorID:  sectorId,
+		PieceSize: pieceSize,
+	}
+
+	// remote workers fetch the piece data from the miner, and send back just
+	// the padded piece
+	rp := &remotePiece{
+		data:     file,
+		sectorID: sectorId,
+		size:     pieceSize,
+		existing: existingPieceSizes,
+	}
+
+	sb.remoteLk.Lock()
+	sb.remotePieces[task.TaskID] = rp
+	sb.remoteLk.Unlock()
+
+	defer func() {
+		sb.remoteLk.Lock()
+		delete(sb.remotePieces, task.TaskID)
+		sb.remoteLk.Unlock()
+	}()
+
+	ret, release, err := sb.schedule(sb.addPieceTasks, &sb.addPieceWait, false, task)
+	if err != nil {
+		return PublicPieceInfo{}, err
+	}
+	if ret != nil {
+		res, err := sb.remoteResult(ret)
+		if err != nil {
+			return PublicPieceInfo{}, xerrors.Errorf("remote AddPiece: %w", err)
+		}
+
+		sb.remoteLk.Lock()
+		written := rp.written
+		sb.remoteLk.Unlock()
+		if !written {
+			return PublicPieceInfo{}, xerrors.Errorf("remote AddPiece: worker didn't send the piece of task %d", task.TaskID)
+		}
+
+		return res.Piece, nil
+	}
+	defer release()
 
+	return sb.addPiece(pieceSize, sectorId, file, existingPieceSizes)
+}
+
+func (sb *SectorBuilder) addPiece(pieceSize uint64, sectorId uint64, file io.Reader, existingPieceSizes []uint64) (PublicPieceInfo, error) {
 	f, werr, err := toReadableFile(file, int64(pieceSize))
 	if err != nil {
 		return PublicPieceInfo{}, err
@@ -206,23 +423,59 @@ func (sb *SectorBuilder) ReadPieceFromSealedSector(pieceKey string) ([]byte, err
 	ret := sb.RateLimit()
 	defer ret()
 
-	return sectorbuilder.ReadPieceFromSealedSector(sb.handle, pieceKey)
-}
+	loc, err := sb.pieceLocation(pieceKey)
+	switch err {
+	case nil:
+	case datastore.ErrNotFound:
+		// sealed before piece locations were recorded, only found in the
+		// default storage path
+		return sectorbuilder.ReadPieceFromSealedSector(sb.handle, pieceKey)
+	default:
+		return nil, xerrors.Errorf("getting piece location: %w", err)
+	}
 
-func (sb *SectorBuilder) SealPreCommit(sectorID uint64, ticket SealTicket, pieces []PublicPieceInfo) (RawSealPreCommitOutput, error) {
-	ret := sb.RateLimit()
-	defer ret()
+	sealedPath, err := sb.sealedSectorPath(loc.SectorID, false)
+	if err != nil {
+		return nil, err
+	}
 
-	cacheDir, err := sb.sectorCacheDir(sectorID)
+	unsealed, err := ioutil.TempFile(sb.stagedDir, "unseal-")
 	if err != nil {
-		return RawSealPreCommitOutput{}, err
+		return nil, err
+	}
+	defer os.Remove(unsealed.Name())
+	if err := unsealed.Close(); err != nil {
+		return nil, err
 	}
 
-	sealedPath, err := sb.sealedSectorPath(sectorID)
+	err = sectorbuilder.StandaloneUnseal(sb.ssize,
+		PoRepProofPartitions,
+		sealedPath,
+		unsealed.Name(),
+		loc.SectorID,
+		addressToProverID(sb.Miner),
+		loc.Ticket,
+		loc.CommD,
+	)
 	if err != nil {
-		return RawSealPreCommitOutput{}, err
+		return nil, xerrors.Errorf("unsealing sector %d: %w", loc.SectorID, err)
 	}
 
+	f, err := os.Open(unsealed.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	out := make([]byte, loc.Size)
+	if _, err := f.ReadAt(out, int64(loc.Offset)); err != nil {
+		return nil, xerrors.Errorf("reading piece from unsealed sector %d: %w", loc.SectorID, err)
+	}
+
+	return out, nil
+}
+
+func (sb *SectorBuilder) SealPreCommit(sectorID uint64, ticket SealTicket, pieces []PublicPieceInfo) (RawSealPreCommitOutput, error) {
 	var sum uint64
 	for _, piece := range pieces {
 		sum += piece.Size
@@ -232,6 +485,40 @@ func (sb *SectorBuilder) SealPreCommit(sectorID uint64, ticket SealTicket, piece
 		return RawSealPreCommitOutput{}, xerrors.Errorf("aggregated piece sizes don't match sector size: %d != %d (%d)", sum, ussize, int64(ussize-sum))
 	}
 
+	ret, release, err := sb.schedule(sb.precommitTasks, &sb.preCommitWait, sb.noPreCommit, WorkerTask{
+		Type:       WorkerPreCommit,
+		TaskID:     atomic.AddUint64(&sb.taskCtr, 1),
+		SectorID:   sectorID,
+		SealTicket: ticket,
+		Pieces:     pieces,
+	})
+	if err != nil {
+		return RawSealPreCommitOutput{}, err
+	}
+	if ret != nil {
+		res, err := sb.remoteResult(ret)
+		if err != nil {
+			return RawSealPreCommitOutput{}, xerrors.Errorf("remote SealPreCommit: %w", err)
+		}
+		return res.Rspco, nil
+	}
+	defer release()
+
+	return sb.sealPreCommit(sectorID, ticket, pieces)
+}
+
+func (sb *SectorBuilder) sealPreCommit(sectorID uint64, ticket SealTicket, pieces []PublicPieceInfo) (RawSealPreCommitOutput, error) {
+	// sealing starts here, put the sector in a storage path
+	cacheDir, err := sb.sectorCacheDir(sectorID, true)
+	if err != nil {
+		return RawSealPreCommitOutput{}, err
+	}
+
+	sealedPath, err := sb.sealedSectorPath(sectorID, true)
+	if err != nil {
+		return RawSealPreCommitOutput{}, err
+	}
+
 	stagedPath := sb.stagedSectorPath(sectorID)
 
 	rspco, err := sectorbuilder.StandaloneSealPreCommit(
@@ -253,27 +540,40 @@ func (sb *SectorBuilder) SealPreCommit(sectorID uint64, ticket SealTicket, piece
 }
 
 func (sb *SectorBuilder) SealCommit(sectorID uint64, ticket SealTicket, seed SealSeed, pieces []PublicPieceInfo, pieceKeys []string, rspco RawSealPreCommitOutput) (proof []byte, err error) {
-	ret := sb.RateLimit()
-	defer ret()
-
-	cacheDir, err := sb.sectorCacheDir(sectorID)
+	ret, release, err := sb.schedule(sb.commitTasks, &sb.commitWait, sb.noCommit, WorkerTask{
+		Type:       WorkerCommit,
+		TaskID:     atomic.AddUint64(&sb.taskCtr, 1),
+		SectorID:   sectorID,
+		SealTicket: ticket,
+		Pieces:     pieces,
+		SealSeed:   seed,
+		Rspco:      rspco,
+	})
 	if err != nil {
 		return nil, err
 	}
+	if ret != nil {
+		res, err := sb.remoteResult(ret)
+		if err != nil {
+			return nil, xerrors.Errorf("remote SealCommit: %w", err)
+		}
+		proof = res.Proof
+	} else {
+		proof, err = sb.sealCommit(sectorID, ticket, seed, pieces, rspco)
+		release()
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	proof, err = sectorbuilder.StandaloneSealCommit(
-		sb.ssize,
-		PoRepProofPartitions,
-		cacheDir,
-		sectorID,
-		addressToProverID(sb.Miner),
-		ticket.TicketBytes,
-		seed.TicketBytes,
-		pieces,
-		rspco,
-	)
+	if sb.handle == nil {
+		// standalone sectorbuilders don't keep track of sealed sectors
+		return proof, nil
+	}
+
+	cacheDir, err := sb.sectorCacheDir(sectorID, false)
 	if err != nil {
-		return nil, xerrors.Errorf("StandaloneSealCommit: %w", err)
+		return nil, err
 	}
 
 	pmeta := make([]sectorbuilder.PieceMetadata, len(pieces))
@@ -285,7 +585,7 @@ func (sb *SectorBuilder) SealCommit(sectorID uint64, ticket SealTicket, seed Sea
 		}
 	}
 
-	sealedPath, err := sb.sealedSectorPath(sectorID)
+	sealedPath, err := sb.sealedSectorPath(sectorID, false)
 	if err != nil {
 		return nil, err
 	}
@@ -307,56 +607,89 @@ func (sb *SectorBuilder) SealCommit(sectorID uint64, ticket SealTicket, seed Sea
 	if err != nil {
 		return nil, xerrors.Errorf("ImportSealedSector: %w", err)
 	}
-	return proof, nil
-}
 
-func (sb *SectorBuilder) GeneratePoSt(sectorInfo SortedSectorInfo, challengeSeed [CommLen]byte, faults []uint64) ([]byte, error) {
-	// Wait, this is a blocking method with no way of interrupting it?
-	// does it checkpoint itself?
-	return sectorbuilder.GeneratePoSt(sb.handle, sectorInfo, challengeSeed, faults)
-}
+	if err := sb.putPieceLocations(sectorID, ticket, rspco.CommD, pieces, pieceKeys); err != nil {
+		return nil, err
+	}
 
-func (sb *SectorBuilder) SectorSize() uint64 {
-	return sb.ssize
+	return proof, nil
 }
 
-var UserBytesForSectorSize = sectorbuilder.GetMaxUserBytesPerStagedSector
+func (sb *SectorBuilder) sealCommit(sectorID uint64, ticket SealTicket, seed SealSeed, pieces []PublicPieceInfo, rspco RawSealPreCommitOutput) ([]byte, error) {
+	cacheDir, err := sb.sectorCacheDir(sectorID, false)
+	if err != nil {
+		return nil, err
+	}
 
-func VerifySeal(sectorSize uint64, commR, commD []byte, proverID address.Address, ticket []byte, seed []byte, sectorID uint64, proof []byte) (bool, error) {
-	var commRa, commDa, ticketa, seeda [32]byte
-	copy(commRa[:], commR)
-	copy(commDa[:], commD)
-	copy(ticketa[:], ticket)
-	copy(seeda[:], seed)
-	proverIDa := addressToProverID(proverID)
+	proof, err := sectorbuilder.StandaloneSealCommit(
+		sb.ssize,
+		PoRepProofPartitions,
+		cacheDir,
+		sectorID,
+		addressToProverID(sb.Miner),
+		ticket.TicketBytes,
+		seed.TicketBytes,
+		pieces,
+		rspco,
+	)
+	if err != nil {
+		return nil, xerrors.Errorf("StandaloneSealCommit: %w", err)
+	}
 
-	return sectorbuilder.VerifySeal(sectorSize, commRa, commDa, proverIDa, ticketa, seeda, sectorID, proof)
+	return proof, nil
 }
 
-func NewSortedSectorInfo(sectors []SectorInfo) SortedSectorInfo {
-	return sectorbuilder.NewSortedSectorInfo(sectors...)
-}
+func (sb *SectorBuilder) GeneratePoSt(sectorInfo SortedSectorInfo, challengeSeed [CommLen]byte, faults []uint64) ([]byte, error) {
+	privInfo, err := sb.privateSectorInfo(sectorInfo, faults)
+	if err != nil {
+		return nil, err
+	}
 
-func VerifyPost(ctx context.Context, sectorSize uint64, sectorInfo SortedSectorInfo, challengeSeed [CommLen]byte, proof []byte, faults []uint64) (bool, error) {
-	_, span := trace.StartSpan(ctx, "VerifyPoSt")
-	defer span.End()
-	return sectorbuilder.VerifyPoSt(sectorSize, sectorInfo, challengeSeed, proof, faults)
+	// Wait, this is a blocking method with no way of interrupting it?
+	// does it checkpoint itself?
+	return sectorbuilder.StandaloneGeneratePoSt(sb.ssize, addressToProverID(sb.Miner), privInfo, challengeSeed, faults)
 }
 
-func GeneratePieceCommitment(piece io.Reader, pieceSize uint64) (commP [CommLen]byte, err error) {
-	f, werr, err := toReadableFile(piece, int64(pieceSize))
-	if err != nil {
-		return [32]byte{}, err
+// privateSectorInfo adds the paths of the sealed files and caches to the
+// sectors to prove. Faulty sectors don't need to be found, they aren't read
+func (sb *SectorBuilder) privateSectorInfo(sectorInfo SortedSectorInfo, faults []uint64) (sectorbuilder.SortedPrivateSectorInfo, error) {
+	faulty := map[uint64]bool{}
+	for _, id := range faults {
+		faulty[id] = true
 	}
 
-	commP, err = sectorbuilder.GeneratePieceCommitmentFromFile(f, pieceSize)
-	if err != nil {
-		return [32]byte{}, err
+	sb.pathsLk.Lock()
+	defer sb.pathsLk.Unlock()
+
+	var out []sectorbuilder.PrivateSectorInfo
+	for _, si := range sectorInfo.Values() {
+		name := sb.SectorName(si.SectorID)
+
+		p := sb.findSector(name)
+		if p == nil {
+			if !faulty[si.SectorID] {
+				return sectorbuilder.SortedPrivateSectorInfo{}, xerrors.Errorf("sector %d: %w", si.SectorID, ErrSectorNotFound)
+			}
+			p = sb.paths[0]
+		}
+
+		out = append(out, sectorbuilder.PrivateSectorInfo{
+			SectorID:         si.SectorID,
+			CommR:            si.CommR,
+			CacheDirPath:     filepath.Join(p.cacheDir, name),
+			SealedSectorPath: filepath.Join(p.sealedDir, name),
+		})
 	}
 
-	return commP, werr()
+	return sectorbuilder.NewSortedPrivateSectorInfo(out...), nil
+}
+
+func (sb *SectorBuilder) SectorSize() uint64 {
+	return sb.ssize
 }
 
-func GenerateDataCommitment(ssize uint64, pieces []PublicPieceInfo) ([CommLen]byte, error) {
-	return sectorbuilder.GenerateDataCommitment(ssize, pieces)
+var UserBytesForSectorSize = sectorbuilder.GetMaxUserBytesPerStagedSector
+
+func NewSortedSectorInfo(sectors []SectorInfo) SortedSectorInfo {
+	return sectorbuilder.NewSortedSectorInfo(sectors...)
 }
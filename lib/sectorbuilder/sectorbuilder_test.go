@@ -1,3 +1,5 @@
+// +build !mockproofs
+
 package sectorbuilder_test
 
 import (
@@ -6,6 +8,7 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"testing"
@@ -212,6 +215,82 @@ func TestSealAndVerify2(t *testing.T) {
 	wg.Wait()
 }
 
+func TestSealPoStAndUnsealAttachedStorage(t *testing.T) {
+	if runtime.NumCPU() < 10 && os.Getenv("CI") == "" { // don't bother on slow hardware
+		t.Skip("this is slow")
+	}
+	os.Setenv("BELLMAN_NO_GPU", "1")
+	os.Setenv("RUST_LOG", "info")
+
+	build.SectorSizes = []uint64{sectorSize}
+
+	if err := build.GetParams(true, true); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	ds := datastore.NewMapDatastore()
+
+	dir, err := ioutil.TempDir("", "sbtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sb, err := sectorbuilder.TempSectorbuilderDir(dir, sectorSize, ds)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	cleanup := func() {
+		sb.Destroy()
+		if err := os.RemoveAll(dir); err != nil {
+			t.Error(err)
+		}
+	}
+	defer cleanup()
+
+	// the attached path outweighs the default one, new sectors go there
+	attached := filepath.Join(dir, "attached")
+	require.NoError(t, sb.AttachStorage(sectorbuilder.StoragePath{Path: attached, Weight: 1000}))
+
+	si, err := sb.AcquireSectorId()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	s := seal{sid: si}
+
+	s.precommit(t, sb, 1, func() {})
+	s.commit(t, sb, func() {})
+
+	_, err = os.Stat(filepath.Join(attached, "sealed", sb.SectorName(si)))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "sealed", sb.SectorName(si)))
+	require.True(t, os.IsNotExist(err))
+
+	checkUnseal := func() {
+		expect, err := ioutil.ReadAll(io.LimitReader(rand.New(rand.NewSource(42+1)), int64(s.ppi.Size)))
+		require.NoError(t, err)
+
+		data, err := sb.ReadPieceFromSealedSector("foo")
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		require.Equal(t, expect, data)
+	}
+
+	s.post(t, sb)
+	checkUnseal()
+
+	// Restart sectorbuilder, the attached path is remembered
+	sb.Destroy()
+	sb, err = sectorbuilder.TempSectorbuilderDir(dir, sectorSize, ds)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	s.post(t, sb)
+	checkUnseal()
+}
+
 func TestAcquireID(t *testing.T) {
 	ds := datastore.NewMapDatastore()
 
@@ -0,0 +1,284 @@
+package sectorbuilder
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/ipfs/go-datastore"
+	"golang.org/x/xerrors"
+)
+
+var storagePathsKey = datastore.NewKey("/sectorbuilder/storage")
+
+// StoragePath is a directory holding sealed sectors, and their caches, in
+// `sealed` and `cache` subdirectories
+type StoragePath struct {
+	Path string
+
+	// Weight scales the free space of the path when choosing where to put new
+	// sectors. Zero is treated as 1
+	Weight uint64
+
+	// ReadOnly paths are searched for existing sectors, but don't get new ones
+	ReadOnly bool
+}
+
+type StoragePathStat struct {
+	StoragePath
+
+	Capacity  uint64
+	Available uint64
+
+	// Sectors is the number of sectors stored in the path
+	Sectors int
+}
+
+type storagePath struct {
+	StoragePath
+
+	sealedDir string
+	cacheDir  string
+}
+
+func newStoragePath(p StoragePath) (*storagePath, error) {
+	abs, err := filepath.Abs(p.Path)
+	if err != nil {
+		return nil, xerrors.Errorf("resolving storage path %s: %w", p.Path, err)
+	}
+	p.Path = abs
+
+	sp := &storagePath{
+		StoragePath: p,
+		sealedDir:   filepath.Join(abs, "sealed"),
+		cacheDir:    filepath.Join(abs, "cache"),
+	}
+
+	if p.ReadOnly {
+		st, err := os.Stat(abs)
+		if err != nil {
+			return nil, xerrors.Errorf("read-only storage path: %w", err)
+		}
+		if !st.IsDir() {
+			return nil, xerrors.Errorf("storage path %s isn't a directory", abs)
+		}
+		return sp, nil
+	}
+
+	for _, dir := range []string{sp.sealedDir, sp.cacheDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, xerrors.Errorf("creating storage path directory: %w", err)
+		}
+	}
+
+	return sp, nil
+}
+
+// addPath adds a storage path, unless it's already known. Must be called with
+// pathsLk held
+func (sb *SectorBuilder) addPath(p StoragePath) (bool, error) {
+	sp, err := newStoragePath(p)
+	if err != nil {
+		return false, err
+	}
+
+	for _, existing := range sb.paths {
+		if existing.Path == sp.Path {
+			return false, nil
+		}
+	}
+
+	sb.paths = append(sb.paths, sp)
+	return true, nil
+}
+
+// loadStoragePaths adds storage paths attached with AttachStorage
+func (sb *SectorBuilder) loadStoragePaths() error {
+	b, err := sb.ds.Get(storagePathsKey)
+	switch err {
+	case nil:
+	case datastore.ErrNotFound:
+		return nil
+	default:
+		return err
+	}
+
+	var attached []StoragePath
+	if err := json.Unmarshal(b, &attached); err != nil {
+		return xerrors.Errorf("unmarshaling attached storage paths: %w", err)
+	}
+
+	sb.pathsLk.Lock()
+	defer sb.pathsLk.Unlock()
+
+	for _, p := range attached {
+		if _, err := sb.addPath(p); err != nil {
+			return xerrors.Errorf("adding storage path %s: %w", p.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// AttachStorage adds a storage path for sealed sectors. The path is
+// remembered across restarts
+func (sb *SectorBuilder) AttachStorage(p StoragePath) error {
+	if sb.ds == nil {
+		return xerrors.New("standalone sectorbuilders don't support attaching storage")
+	}
+
+	sb.pathsLk.Lock()
+	defer sb.pathsLk.Unlock()
+
+	added, err := sb.addPath(p)
+	if err != nil {
+		return err
+	}
+	if !added {
+		return xerrors.Errorf("storage path %s is already attached", p.Path)
+	}
+
+	var attached []StoragePath
+	b, err := sb.ds.Get(storagePathsKey)
+	switch err {
+	case nil:
+		if err := json.Unmarshal(b, &attached); err != nil {
+			return xerrors.Errorf("unmarshaling attached storage paths: %w", err)
+		}
+	case datastore.ErrNotFound:
+	default:
+		return err
+	}
+
+	attached = append(attached, sb.paths[len(sb.paths)-1].StoragePath)
+
+	b, err = json.Marshal(attached)
+	if err != nil {
+		return err
+	}
+
+	return sb.ds.Put(storagePathsKey, b)
+}
+
+// StorageStats returns the storage paths with their usage
+func (sb *SectorBuilder) StorageStats() ([]StoragePathStat, error) {
+	sb.pathsLk.Lock()
+	defer sb.pathsLk.Unlock()
+
+	out := make([]StoragePathStat, len(sb.paths))
+	for i, p := range sb.paths {
+		capacity, available, err := fsStat(p.Path)
+		if err != nil {
+			return nil, xerrors.Errorf("getting stats for %s: %w", p.Path, err)
+		}
+
+		sectors, err := ioutil.ReadDir(p.sealedDir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, xerrors.Errorf("listing sealed sectors in %s: %w", p.Path, err)
+		}
+
+		out[i] = StoragePathStat{
+			StoragePath: p.StoragePath,
+			Capacity:    capacity,
+			Available:   available,
+			Sectors:     len(sectors),
+		}
+	}
+
+	return out, nil
+}
+
+// findSector returns the storage path holding the named sector, or nil if
+// the sector isn't in any of them. Must be called with pathsLk held
+func (sb *SectorBuilder) findSector(sectorName string) *storagePath {
+	for _, p := range sb.paths {
+		if _, err := os.Stat(filepath.Join(p.sealedDir, sectorName)); err == nil {
+			return p
+		}
+		if _, err := os.Stat(filepath.Join(p.cacheDir, sectorName)); err == nil {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// ErrSectorNotFound is returned when looking up a sector which isn't in any
+// storage path
+var ErrSectorNotFound = xerrors.New("sector not found in any storage path")
+
+// lookupSectorPath returns the storage path holding the named sector
+func (sb *SectorBuilder) lookupSectorPath(sectorName string) (*storagePath, error) {
+	sb.pathsLk.Lock()
+	defer sb.pathsLk.Unlock()
+
+	if p := sb.findSector(sectorName); p != nil {
+		return p, nil
+	}
+
+	return nil, xerrors.Errorf("sector %s: %w", sectorName, ErrSectorNotFound)
+}
+
+// allocSectorPath returns the storage path holding the named sector, putting
+// new sectors in the writable path with the most weighted free space. It must
+// only be called when sealing a sector, as it creates the sealed file
+func (sb *SectorBuilder) allocSectorPath(sectorName string) (*storagePath, error) {
+	sb.pathsLk.Lock()
+	defer sb.pathsLk.Unlock()
+
+	if p := sb.findSector(sectorName); p != nil {
+		return p, nil
+	}
+
+	var best *storagePath
+	var bestScore uint64
+	for _, p := range sb.paths {
+		if p.ReadOnly {
+			continue
+		}
+
+		_, available, err := fsStat(p.Path)
+		if err != nil {
+			log.Warnf("getting free space of %s: %+v", p.Path, err)
+			continue
+		}
+		if available < sb.ssize {
+			continue
+		}
+
+		weight := p.Weight
+		if weight == 0 {
+			weight = 1
+		}
+
+		// in sectors to keep this from overflowing
+		score := available / sb.ssize * weight
+		if best == nil || score > bestScore {
+			best, bestScore = p, score
+		}
+	}
+
+	if best == nil {
+		return nil, xerrors.Errorf("no storage path with enough space for sector %s", sectorName)
+	}
+
+	// create the sealed file right away, so that the sector is found in the
+	// chosen path from now on
+	f, err := os.OpenFile(filepath.Join(best.sealedDir, sectorName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return best, f.Close()
+}
+
+func fsStat(path string) (capacity uint64, available uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+
+	return st.Blocks * uint64(st.Bsize), st.Bavail * uint64(st.Bsize), nil
+}
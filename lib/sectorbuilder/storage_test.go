@@ -0,0 +1,70 @@
+package sectorbuilder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+func TestStoragePaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sbstorage")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	rw := filepath.Join(dir, "rw")
+	ro := filepath.Join(dir, "ro")
+	require.NoError(t, os.Mkdir(ro, 0755))
+
+	ds := datastore.NewMapDatastore()
+	sb := &SectorBuilder{ssize: 1024, ds: ds}
+
+	require.NoError(t, sb.AttachStorage(StoragePath{Path: ro, ReadOnly: true}))
+	require.NoError(t, sb.AttachStorage(StoragePath{Path: rw, Weight: 10}))
+	require.Error(t, sb.AttachStorage(StoragePath{Path: rw}))
+
+	// looking up unknown sectors doesn't allocate storage for them
+	_, err = sb.lookupSectorPath("s-1")
+	assert.True(t, xerrors.Is(err, ErrSectorNotFound), "unexpected error: %v", err)
+	_, err = sb.GetPath("sealed", "s-1")
+	assert.True(t, xerrors.Is(err, ErrSectorNotFound), "unexpected error: %v", err)
+	_, err = os.Stat(filepath.Join(rw, "sealed", "s-1"))
+	assert.True(t, os.IsNotExist(err))
+
+	// new sectors only go to writable paths
+	p, err := sb.allocSectorPath("s-1")
+	require.NoError(t, err)
+	assert.Equal(t, rw, p.Path)
+	assert.FileExists(t, filepath.Join(rw, "sealed", "s-1"))
+
+	p, err = sb.lookupSectorPath("s-1")
+	require.NoError(t, err)
+	assert.Equal(t, rw, p.Path)
+
+	// existing sectors are found in read-only paths
+	require.NoError(t, os.MkdirAll(filepath.Join(ro, "sealed"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(ro, "sealed", "s-2"), []byte("sector"), 0644))
+
+	p, err = sb.lookupSectorPath("s-2")
+	require.NoError(t, err)
+	assert.Equal(t, ro, p.Path)
+
+	// attached paths are remembered
+	sb2 := &SectorBuilder{ssize: 1024, ds: ds}
+	require.NoError(t, sb2.loadStoragePaths())
+
+	stats, err := sb2.StorageStats()
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+	assert.Equal(t, ro, stats[0].Path)
+	assert.Equal(t, 1, stats[0].Sectors)
+	assert.True(t, stats[0].ReadOnly)
+	assert.Equal(t, rw, stats[1].Path)
+	assert.Equal(t, uint64(10), stats[1].Weight)
+	assert.Equal(t, 1, stats[1].Sectors)
+}
@@ -0,0 +1,105 @@
+package tarutil
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	logging "github.com/ipfs/go-log"
+	"golang.org/x/xerrors"
+)
+
+var log = logging.Logger("tarutil")
+
+// ExtractTar extracts a flat tar archive (as created by TarDirectory) into dir
+func ExtractTar(body io.Reader, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return xerrors.Errorf("mkdir: %w", err)
+	}
+
+	tr := tar.NewReader(body)
+	for {
+		header, err := tr.Next()
+		switch err {
+		default:
+			return err
+		case io.EOF:
+			return nil
+		case nil:
+		}
+
+		name := filepath.Base(header.Name)
+		if name != header.Name || name == "." || name == ".." {
+			return xerrors.Errorf("unexpected file in archive: %q", header.Name)
+		}
+
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			return xerrors.Errorf("creating file %s: %w", name, err)
+		}
+
+		if _, err := io.Copy(f, tr); err != nil {
+			_ = f.Close()
+			return xerrors.Errorf("writing file %s: %w", name, err)
+		}
+
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+}
+
+// TarDirectory returns a tar archive of the files in dir. Subdirectories
+// are not included
+func TarDirectory(dir string) (io.ReadCloser, error) {
+	r, w := io.Pipe()
+
+	go func() {
+		_ = w.CloseWithError(writeTarDirectory(dir, w))
+	}()
+
+	return r, nil
+}
+
+func writeTarDirectory(dir string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if !file.Mode().IsRegular() {
+			log.Warnf("skipping %s in %s, not a regular file", file.Name(), dir)
+			continue
+		}
+
+		h, err := tar.FileInfoHeader(file, "")
+		if err != nil {
+			return xerrors.Errorf("getting header for file %s: %w", file.Name(), err)
+		}
+
+		if err := tw.WriteHeader(h); err != nil {
+			return xerrors.Errorf("writing header for file %s: %w", file.Name(), err)
+		}
+
+		f, err := os.Open(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return xerrors.Errorf("opening %s for reading: %w", file.Name(), err)
+		}
+
+		if _, err := io.Copy(tw, f); err != nil {
+			_ = f.Close()
+			return xerrors.Errorf("copy data for file %s: %w", file.Name(), err)
+		}
+
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	return tw.Close()
+}
@@ -0,0 +1,35 @@
+package tarutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTarRoundtrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tarutil")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src")
+	require.NoError(t, os.Mkdir(src, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(src, "a"), []byte("foo"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(src, "b"), []byte("barbaz"), 0644))
+
+	r, err := TarDirectory(src)
+	require.NoError(t, err)
+
+	dst := filepath.Join(dir, "dst")
+	require.NoError(t, ExtractTar(r, dst))
+	require.NoError(t, r.Close())
+
+	for name, content := range map[string]string{"a": "foo", "b": "barbaz"} {
+		b, err := ioutil.ReadFile(filepath.Join(dst, name))
+		require.NoError(t, err)
+		assert.Equal(t, content, string(b))
+	}
+}
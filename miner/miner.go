@@ -1,12 +1,13 @@
 package miner
 
 import (
+	"bytes"
+	"container/heap"
 	"context"
 	"sync"
 	"time"
 
 	"github.com/filecoin-project/lotus/build"
-	"github.com/filecoin-project/lotus/chain/actors"
 	"github.com/filecoin-project/lotus/chain/address"
 	"github.com/filecoin-project/lotus/chain/gen"
 	"github.com/filecoin-project/lotus/chain/types"
@@ -276,8 +277,8 @@ func (m *Miner) submitNullTicket(base *MiningBase, ticket *types.Ticket) {
 	m.lastWork = base
 }
 
-func (m *Miner) computeVRF(ctx context.Context, addr address.Address, input []byte) ([]byte, error) {
-	w, err := m.getMinerWorker(ctx, addr, nil)
+func (m *Miner) computeVRF(ctx context.Context, addr address.Address, base *MiningBase, input []byte) ([]byte, error) {
+	w, err := m.getMinerWorker(ctx, addr, base)
 	if err != nil {
 		return nil, err
 	}
@@ -285,26 +286,15 @@ func (m *Miner) computeVRF(ctx context.Context, addr address.Address, input []by
 	return gen.ComputeVRF(ctx, m.api.WalletSign, w, input)
 }
 
-func (m *Miner) getMinerWorker(ctx context.Context, addr address.Address, ts *types.TipSet) (address.Address, error) {
-	ret, err := m.api.StateCall(ctx, &types.Message{
-		From:   addr,
-		To:     addr,
-		Method: actors.MAMethods.GetWorkerAddr,
-	}, ts)
+// getMinerWorker returns the worker in effect at the height of the block
+// mined on top of base
+func (m *Miner) getMinerWorker(ctx context.Context, addr address.Address, base *MiningBase) (address.Address, error) {
+	mi, err := m.api.StateMinerInfo(ctx, addr, base.ts)
 	if err != nil {
-		return address.Undef, xerrors.Errorf("failed to get miner worker addr: %w", err)
+		return address.Undef, xerrors.Errorf("failed to get miner info: %w", err)
 	}
 
-	if ret.ExitCode != 0 {
-		return address.Undef, xerrors.Errorf("failed to get miner worker addr (exit code %d)", ret.ExitCode)
-	}
-
-	w, err := address.NewFromBytes(ret.Return)
-	if err != nil {
-		return address.Undef, xerrors.Errorf("GetWorkerAddr returned malformed address: %w", err)
-	}
-
-	return w, nil
+	return mi.WorkerAt(base.ts.Height() + uint64(len(base.tickets)) + 1), nil
 }
 
 func (m *Miner) scratchTicket(ctx context.Context, addr address.Address, base *MiningBase) (*types.Ticket, error) {
@@ -315,7 +305,7 @@ func (m *Miner) scratchTicket(ctx context.Context, addr address.Address, base *M
 		lastTicket = base.ts.MinTicket()
 	}
 
-	vrfOut, err := m.computeVRF(ctx, addr, lastTicket.VRFProof)
+	vrfOut, err := m.computeVRF(ctx, addr, base, lastTicket.VRFProof)
 	if err != nil {
 		return nil, err
 	}
@@ -332,7 +322,7 @@ func (m *Miner) createBlock(base *MiningBase, addr address.Address, ticket *type
 		return nil, xerrors.Errorf("failed to get pending messages: %w", err)
 	}
 
-	msgs, err := selectMessages(context.TODO(), m.api.StateGetActor, base, pending)
+	msgs, err := selectMessages(context.TODO(), m.api.StateGetActor, base, pending, defaultBlockLimits)
 	if err != nil {
 		return nil, xerrors.Errorf("message filtering failed: %w", err)
 	}
@@ -345,10 +335,78 @@ func (m *Miner) createBlock(base *MiningBase, addr address.Address, ticket *type
 
 type actorLookup func(context.Context, address.Address, *types.TipSet) (*types.Actor, error)
 
-func selectMessages(ctx context.Context, al actorLookup, base *MiningBase, msgs []*types.SignedMessage) ([]*types.SignedMessage, error) {
-	out := make([]*types.SignedMessage, 0, len(msgs))
-	inclNonces := make(map[address.Address]uint64)
-	inclBalances := make(map[address.Address]types.BigInt)
+type blockLimits struct {
+	gasLimit types.BigInt
+	size     int
+}
+
+var defaultBlockLimits = blockLimits{
+	gasLimit: types.NewInt(build.BlockGasLimit),
+	size:     build.BlockMessagesSizeLimit,
+}
+
+// msgChain is a sequence of messages from a single sender, with consecutive
+// nonces starting at the sender's current state nonce
+type msgChain struct {
+	from address.Address
+	msgs []*types.SignedMessage
+
+	// best prefix of msgs to include next, and its effective gas price
+	prefix   int
+	effPrice types.BigInt
+}
+
+// update finds the prefix of the chain which yields the highest effective
+// gas price (total fees of the prefix divided by its total gas limit). This
+// lets high-fee messages pull in cheaper messages they depend on.
+func (mc *msgChain) update() {
+	mc.prefix = 0
+	mc.effPrice = types.NewInt(0)
+
+	fees := types.NewInt(0)
+	gas := types.NewInt(0)
+	for i, m := range mc.msgs {
+		fees = types.BigAdd(fees, types.BigMul(m.Message.GasPrice, m.Message.GasLimit))
+		gas = types.BigAdd(gas, m.Message.GasLimit)
+
+		price := m.Message.GasPrice
+		if gas.GreaterThan(types.NewInt(0)) {
+			price = types.BigDiv(fees, gas)
+		}
+
+		if i == 0 || !price.LessThan(mc.effPrice) {
+			mc.prefix = i + 1
+			mc.effPrice = price
+		}
+	}
+}
+
+type chainHeap []*msgChain
+
+func (h chainHeap) Len() int { return len(h) }
+func (h chainHeap) Less(i, j int) bool {
+	if !h[i].effPrice.Equals(h[j].effPrice) {
+		return h[i].effPrice.GreaterThan(h[j].effPrice)
+	}
+	// break ties deterministically
+	return bytes.Compare(h[i].from.Bytes(), h[j].from.Bytes()) < 0
+}
+func (h chainHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *chainHeap) Push(x interface{}) { *h = append(*h, x.(*msgChain)) }
+func (h *chainHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// buildChains groups messages by sender and returns, for each sender, the
+// longest run of messages with consecutive nonces starting at the sender's
+// state nonce that the sender can afford
+func buildChains(ctx context.Context, al actorLookup, base *MiningBase, msgs []*types.SignedMessage) ([]*msgChain, error) {
+	bySender := make(map[address.Address]map[uint64]*types.SignedMessage)
+	var senders []address.Address
 	for _, msg := range msgs {
 		if msg.Message.To == address.Undef {
 			log.Warnf("message in mempool had bad 'To' address")
@@ -356,35 +414,100 @@ func selectMessages(ctx context.Context, al actorLookup, base *MiningBase, msgs
 		}
 
 		from := msg.Message.From
+		if _, ok := bySender[from]; !ok {
+			bySender[from] = make(map[uint64]*types.SignedMessage)
+			senders = append(senders, from)
+		}
+
+		if _, ok := bySender[from][msg.Message.Nonce]; ok {
+			log.Warnf("mempool returned multiple messages from %s with nonce %d", from, msg.Message.Nonce)
+			continue
+		}
+		bySender[from][msg.Message.Nonce] = msg
+	}
+
+	out := make([]*msgChain, 0, len(senders))
+	for _, from := range senders {
 		act, err := al(ctx, from, base.ts)
 		if err != nil {
 			return nil, xerrors.Errorf("failed to check message sender balance: %w", err)
 		}
 
-		if _, ok := inclNonces[from]; !ok {
-			inclNonces[from] = act.Nonce
-			inclBalances[from] = act.Balance
+		mc := &msgChain{from: from}
+
+		balance := act.Balance
+		for nonce := act.Nonce; ; nonce++ {
+			msg, ok := bySender[from][nonce]
+			if !ok {
+				break
+			}
+
+			if balance.LessThan(msg.Message.RequiredFunds()) {
+				log.Warnf("message in mempool does not have enough funds: %s", msg.Cid())
+				break
+			}
+			balance = types.BigSub(balance, msg.Message.RequiredFunds())
+
+			mc.msgs = append(mc.msgs, msg)
 		}
 
-		if inclBalances[from].LessThan(msg.Message.RequiredFunds()) {
-			log.Warnf("message in mempool does not have enough funds: %s", msg.Cid())
-			continue
+		if skipped := len(bySender[from]) - len(mc.msgs); skipped > 0 {
+			log.Debugf("skipping %d messages from %s with already used or too high nonces, or insufficient funds", skipped, from)
 		}
 
-		if msg.Message.Nonce > inclNonces[from] {
-			log.Warnf("message in mempool has too high of a nonce (%d > %d) %s", msg.Message.Nonce, inclNonces[from], msg.Cid())
-			continue
+		if len(mc.msgs) > 0 {
+			mc.update()
+			out = append(out, mc)
 		}
+	}
 
-		if msg.Message.Nonce < inclNonces[from] {
-			log.Warnf("message in mempool has already used nonce (%d < %d), from %s, to %s, %s", msg.Message.Nonce, inclNonces[from], msg.Message.From, msg.Message.To, msg.Cid())
-			continue
+	return out, nil
+}
+
+// selectMessages picks messages to include in a block. Messages are grouped
+// into per-sender nonce chains, which are packed greedily by effective gas
+// price until the block gas or size limit is reached.
+func selectMessages(ctx context.Context, al actorLookup, base *MiningBase, msgs []*types.SignedMessage, limits blockLimits) ([]*types.SignedMessage, error) {
+	chains, err := buildChains(ctx, al, base, msgs)
+	if err != nil {
+		return nil, err
+	}
+
+	h := chainHeap(chains)
+	heap.Init(&h)
+
+	out := make([]*types.SignedMessage, 0, len(msgs))
+	gasLeft := limits.gasLimit
+	sizeLeft := limits.size
+
+	for h.Len() > 0 {
+		mc := heap.Pop(&h).(*msgChain)
+
+		var i int
+		for ; i < mc.prefix; i++ {
+			m := mc.msgs[i]
+			size := m.Size()
+			if gasLeft.LessThan(m.Message.GasLimit) || sizeLeft < size {
+				break
+			}
+
+			gasLeft = types.BigSub(gasLeft, m.Message.GasLimit)
+			sizeLeft -= size
+			out = append(out, m)
 		}
 
-		inclNonces[from] = msg.Message.Nonce + 1
-		inclBalances[from] = types.BigSub(inclBalances[from], msg.Message.RequiredFunds())
+		if i < mc.prefix {
+			// the next message in this chain doesn't fit, so none of the
+			// following messages from this sender can be included either
+			continue
+		}
 
-		out = append(out, msg)
+		mc.msgs = mc.msgs[i:]
+		if len(mc.msgs) > 0 {
+			mc.update()
+			heap.Push(&h, mc)
+		}
 	}
+
 	return out, nil
 }
@@ -80,7 +80,7 @@ func TestMessageFiltering(t *testing.T) {
 		},
 	}
 
-	outmsgs, err := selectMessages(ctx, af, &MiningBase{}, wrapMsgs(msgs))
+	outmsgs, err := selectMessages(ctx, af, &MiningBase{}, wrapMsgs(msgs), defaultBlockLimits)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -95,6 +95,75 @@ func TestMessageFiltering(t *testing.T) {
 	}
 }
 
+func TestMessageSelectionByGasPrice(t *testing.T) {
+	ctx := context.TODO()
+	cheap := mustIDAddr(1)
+	pricey := mustIDAddr(2)
+	cpfp := mustIDAddr(3)
+
+	actors := map[address.Address]*types.Actor{
+		cheap:  &types.Actor{Balance: types.NewInt(1000000)},
+		pricey: &types.Actor{Balance: types.NewInt(1000000)},
+		cpfp:   &types.Actor{Balance: types.NewInt(1000000)},
+	}
+
+	af := func(ctx context.Context, addr address.Address, ts *types.TipSet) (*types.Actor, error) {
+		return actors[addr], nil
+	}
+
+	mkMsg := func(from address.Address, nonce uint64, price uint64) types.Message {
+		return types.Message{
+			From:     from,
+			To:       from,
+			Nonce:    nonce,
+			Value:    types.NewInt(0),
+			GasLimit: types.NewInt(100),
+			GasPrice: types.NewInt(price),
+		}
+	}
+
+	msgs := []types.Message{
+		mkMsg(cheap, 0, 1),
+		mkMsg(cheap, 1, 1),
+		mkMsg(cheap, 2, 1),
+		mkMsg(pricey, 0, 10),
+		mkMsg(cpfp, 0, 2),
+		mkMsg(cpfp, 1, 20),
+	}
+
+	// room for four messages
+	limits := blockLimits{
+		gasLimit: types.NewInt(400),
+		size:     defaultBlockLimits.size,
+	}
+
+	outmsgs, err := selectMessages(ctx, af, &MiningBase{}, wrapMsgs(msgs), limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(outmsgs) != 4 {
+		t.Fatalf("expected 4 messages to be selected, got %d", len(outmsgs))
+	}
+
+	expect := []struct {
+		from  address.Address
+		nonce uint64
+	}{
+		{cpfp, 0},
+		{cpfp, 1},
+		{pricey, 0},
+		{cheap, 0},
+	}
+
+	for i, e := range expect {
+		m := outmsgs[i].Message
+		if m.From != e.from || m.Nonce != e.nonce {
+			t.Fatalf("message %d: expected %s/%d, got %s/%d", i, e.from, e.nonce, m.From, m.Nonce)
+		}
+	}
+}
+
 func wrapMsgs(msgs []types.Message) []*types.SignedMessage {
 	var out []*types.SignedMessage
 	for _, m := range msgs {
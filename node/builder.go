@@ -85,6 +85,8 @@ const (
 	HandleIncomingBlocksKey
 	HandleIncomingMessagesKey
 
+	RunChainPruningKey
+
 	RunDealClientKey
 	RegisterClientValidatorKey
 
@@ -202,6 +204,7 @@ func Online() Option {
 			// Filecoin services
 			Override(new(*chain.Syncer), modules.NewSyncer),
 			Override(new(*blocksync.BlockSync), blocksync.NewBlockSyncClient),
+			Override(new(*chain.MpoolConfig), modules.MpoolConfig(config.DefaultFullNode().Mpool)),
 			Override(new(*chain.MessagePool), modules.MessagePool),
 
 			Override(new(modules.Genesis), modules.ErrorGenesis),
@@ -221,8 +224,9 @@ func Online() Option {
 			Override(new(discovery.PeerResolver), modules.RetrievalResolver),
 
 			Override(new(*retrieval.Client), retrieval.NewClient),
+			Override(new(dtypes.ClientRetrievalStore), modules.NewClientRetrievalStore),
 			Override(new(dtypes.ClientDealStore), modules.NewClientDealStore),
-			Override(new(dtypes.ClientDataTransfer), modules.NewClientDAGServiceDataTransfer),
+			Override(new(dtypes.ClientDataTransfer), modules.NewClientDataTransfer),
 			Override(new(*deals.ClientRequestValidator), deals.NewClientRequestValidator),
 			Override(new(*deals.Client), deals.NewClient),
 			Override(RegisterClientValidatorKey, modules.RegisterClientValidator),
@@ -237,7 +241,7 @@ func Online() Option {
 
 		// Storage miner
 		ApplyIf(func(s *Settings) bool { return s.nodeType == repo.StorageMiner },
-			Override(new(*sectorbuilder.SectorBuilder), modules.SectorBuilder),
+			Override(new(sectorbuilder.Interface), modules.SectorBuilder),
 			Override(new(*sectorblocks.SectorBlocks), sectorblocks.NewSectorBlocks),
 			Override(new(storage.TicketFn), modules.SealTicketGen),
 			Override(new(*storage.Miner), modules.StorageMiner),
@@ -245,8 +249,9 @@ func Online() Option {
 			Override(new(dtypes.StagingDAG), modules.StagingDAG),
 
 			Override(new(*retrieval.Miner), retrieval.NewMiner),
+			Override(new(dtypes.ProviderRetrievalStore), modules.NewProviderRetrievalStore),
 			Override(new(dtypes.ProviderDealStore), modules.NewProviderDealStore),
-			Override(new(dtypes.ProviderDataTransfer), modules.NewProviderDAGServiceDataTransfer),
+			Override(new(dtypes.ProviderDataTransfer), modules.NewProviderDataTransfer),
 			Override(new(*deals.ProviderRequestValidator), deals.NewProviderRequestValidator),
 			Override(new(*deals.Provider), deals.NewProvider),
 			Override(RegisterProviderValidatorKey, modules.RegisterProviderValidator),
@@ -312,6 +317,10 @@ func ConfigFullNode(c interface{}) Option {
 	return Options(
 		ConfigCommon(&cfg.Common),
 		Override(HeadMetricsKey, metrics.SendHeadNotifs(cfg.Metrics.Nickname)),
+		Override(new(*chain.MpoolConfig), modules.MpoolConfig(cfg.Mpool)),
+		If(cfg.Pruning.Enabled,
+			Override(RunChainPruningKey, modules.RunChainPruning(cfg.Pruning)),
+		),
 		If(cfg.Metrics.PubsubTracing,
 			Override(new(*pubsub.PubSub), lp2p.GossipSub(lp2p.PubsubTracer())),
 		),
@@ -332,7 +341,11 @@ func ConfigStorageMiner(c interface{}, lr repo.LockedRepo) Option {
 	return Options(
 		ConfigCommon(&cfg.Common),
 
-		Override(new(*sectorbuilder.Config), modules.SectorBuilderConfig(path, cfg.SectorBuilder.WorkerCount)),
+		Override(new(*sectorbuilder.Config), modules.SectorBuilderConfig(path, cfg.SectorBuilder)),
+		Override(new(storage.SealingConfig), storage.SealingConfig{
+			WaitDealsDelay: time.Duration(cfg.Sealing.WaitDealsDelay),
+		}),
+		Override(new(deals.DealPolicy), modules.DealPolicy(cfg.Dealmaking)),
 	)
 }
 
@@ -15,6 +15,8 @@ type Common struct {
 type FullNode struct {
 	Common
 	Metrics Metrics
+	Mpool   Mpool
+	Pruning Pruning
 }
 
 // // Common
@@ -24,6 +26,8 @@ type StorageMiner struct {
 	Common
 
 	SectorBuilder SectorBuilder
+	Sealing       Sealing
+	Dealmaking    Dealmaking
 }
 
 // API contains configs for API endpoint
@@ -45,11 +49,73 @@ type Metrics struct {
 	PubsubTracing bool
 }
 
+type Mpool struct {
+	// ReplaceByFeePercent is the minimal GasPrice increase (in percent) needed
+	// to replace a pending message with the same nonce
+	ReplaceByFeePercent uint64
+	MaxPendingPerSender int
+	MaxPending          int
+}
+
+type Pruning struct {
+	// Enabled turns on periodic removal of old state trees and receipts from
+	// the chain blockstore. Results of messages executed in pruned tipsets
+	// can't be looked up anymore
+	Enabled bool
+	// RetainFinalities is the number of finality windows below the chain head
+	// for which state is kept
+	RetainFinalities uint64
+	Interval         Duration
+}
+
 // // Storage Miner
 
 type SectorBuilder struct {
 	Path        string
 	WorkerCount uint
+
+	// Leave sealing to remote seal workers
+	DisableLocalPreCommit bool
+	DisableLocalCommit    bool
+
+	// Storage lists additional directories for sealed sectors, new sectors
+	// are placed based on free space
+	Storage []StoragePath
+}
+
+type Sealing struct {
+	// WaitDealsDelay is how long a sector is kept open for more deals before
+	// it's sealed
+	WaitDealsDelay Duration
+}
+
+// Dealmaking configures which storage deals are accepted, on top of the
+// storage ask. Zero values disable the corresponding checks
+type Dealmaking struct {
+	// MinPricePerGiBEpoch is the minimum price per GiB per epoch, in attoFIL
+	MinPricePerGiBEpoch string
+	// MinDuration and MaxDuration bound the deal duration, in epochs
+	MinDuration  uint64
+	MaxDuration  uint64
+	MaxPieceSize uint64
+
+	// AllowedClients, if not empty, are the only client addresses deals are
+	// accepted from
+	AllowedClients []string
+	BlockedClients []string
+
+	// FilterCommand is run through the shell for each deal proposal, with the
+	// proposal as JSON on stdin. Exiting with a non-zero status rejects the
+	// deal, the output is sent to the client as the reason.
+	FilterCommand string
+}
+
+type StoragePath struct {
+	Path string
+	// Weight scales the free space of the path when placing new sectors
+	Weight uint64
+	// ReadOnly paths don't get new sectors
+	ReadOnly bool
 }
 
 func defCommon() Common {
@@ -72,6 +138,15 @@ func defCommon() Common {
 func DefaultFullNode() *FullNode {
 	return &FullNode{
 		Common: defCommon(),
+		Mpool: Mpool{
+			ReplaceByFeePercent: 25,
+			MaxPendingPerSender: 1000,
+			MaxPending:          5000,
+		},
+		Pruning: Pruning{
+			RetainFinalities: 2,
+			Interval:         Duration(time.Hour),
+		},
 	}
 }
 
@@ -82,6 +157,10 @@ func DefaultStorageMiner() *StorageMiner {
 		SectorBuilder: SectorBuilder{
 			WorkerCount: 5,
 		},
+
+		Sealing: Sealing{
+			WaitDealsDelay: Duration(time.Hour),
+		},
 	}
 	cfg.Common.API.ListenAddress = "/ip4/127.0.0.1/tcp/2345/http"
 	return cfg
@@ -266,7 +266,24 @@ func (a *API) ClientRetrieve(ctx context.Context, order api.RetrievalOrder, path
 		return err
 	}
 
-	err = a.Retrieval.RetrieveUnixfs(ctx, order.Root, order.Size, order.Total, order.MinerPeerID, order.Client, order.Miner, outFile)
+	terms := retrieval.PaymentTerms{
+		PricePerByte:            order.PricePerByte,
+		UnsealPrice:             order.UnsealPrice,
+		PaymentInterval:         order.PaymentInterval,
+		PaymentIntervalIncrease: order.PaymentIntervalIncrease,
+	}
+
+	if order.Selector != nil {
+		err = a.Retrieval.RetrieveSelector(ctx, order.Root, order.Selector, order.Size, order.Total, terms, order.MinerPeerID, order.Client, order.Miner, outFile)
+		if err != nil {
+			_ = outFile.Close()
+			return xerrors.Errorf("RetrieveSelector: %w", err)
+		}
+
+		return outFile.Close()
+	}
+
+	err = a.Retrieval.RetrieveUnixfs(ctx, order.Root, order.Size, order.Offset, order.Length, order.Total, terms, order.MinerPeerID, order.Client, order.Miner, outFile)
 	if err != nil {
 		_ = outFile.Close()
 		return xerrors.Errorf("RetrieveUnixfs: %w", err)
@@ -275,6 +292,36 @@ func (a *API) ClientRetrieve(ctx context.Context, order api.RetrievalOrder, path
 	return outFile.Close()
 }
 
+func (a *API) ClientListRetrievals(ctx context.Context) ([]api.RetrievalInfo, error) {
+	retrievals, err := a.Retrieval.List()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]api.RetrievalInfo, len(retrievals))
+	for k, v := range retrievals {
+		out[k] = api.RetrievalInfo{
+			ID:    v.ID,
+			State: v.State,
+
+			Root:   v.Root,
+			Offset: v.Offset,
+			Length: v.Length,
+
+			Client:      v.Client,
+			Miner:       v.MinerAddr,
+			MinerPeerID: v.Miner,
+
+			FundsSpent:    v.FundsSpent,
+			BytesReceived: v.BytesReceived,
+
+			Message: v.Message,
+		}
+	}
+
+	return out, nil
+}
+
 func (a *API) ClientQueryAsk(ctx context.Context, p peer.ID, miner address.Address) (*types.SignedStorageAsk, error) {
 	return a.DealClient.QueryAsk(ctx, p, miner)
 }
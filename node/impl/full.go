@@ -20,8 +20,10 @@ var log = logging.Logger("node")
 type FullNodeAPI struct {
 	CommonAPI
 	full.ChainAPI
+	full.GasAPI
 	client.API
 	full.MpoolAPI
+	full.MsigAPI
 	market.MarketAPI
 	paych.PaychAPI
 	full.StateAPI
@@ -2,16 +2,21 @@ package full
 
 import (
 	"context"
+	"io"
 
 	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/types"
 	"golang.org/x/xerrors"
 
 	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
 	"go.uber.org/fx"
 )
 
+var log = logging.Logger("fullnode")
+
 type ChainAPI struct {
 	fx.In
 
@@ -164,3 +169,65 @@ func (a *ChainAPI) ChainGetGenesis(ctx context.Context) (*types.TipSet, error) {
 func (a *ChainAPI) ChainTipSetWeight(ctx context.Context, ts *types.TipSet) (types.BigInt, error) {
 	return a.Chain.Weight(ctx, ts)
 }
+
+func (a *ChainAPI) ChainExport(ctx context.Context, tsk types.TipSetKey, inclState bool) (<-chan api.ExportChunk, error) {
+	ts := a.Chain.GetHeaviestTipSet()
+	if len(tsk.Cids()) > 0 {
+		var err error
+		ts, err = a.Chain.LoadTipSet(tsk.Cids())
+		if err != nil {
+			return nil, xerrors.Errorf("loading tipset %s: %w", tsk, err)
+		}
+	}
+
+	r, w := io.Pipe()
+	out := make(chan api.ExportChunk)
+	go func() {
+		err := a.Chain.Export(ctx, ts, inclState, w)
+		if err != nil {
+			log.Errorf("chain export call failed: %s", err)
+		}
+		// readers get io.EOF when err is nil
+		w.CloseWithError(err)
+	}()
+
+	go func() {
+		defer close(out)
+		for {
+			buf := make([]byte, 4096)
+			n, err := r.Read(buf)
+
+			chunk := api.ExportChunk{Data: buf[:n]}
+			switch {
+			case err == io.EOF:
+				chunk.Done = true
+			case err != nil:
+				chunk.Err = err.Error()
+			case n == 0:
+				continue
+			}
+
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				log.Warnf("export writer failed: %s", ctx.Err())
+				r.CloseWithError(ctx.Err())
+				return
+			}
+
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (a *ChainAPI) ChainPrune(ctx context.Context, retainFinalities uint64, dryRun bool) (*store.PruneResult, error) {
+	if retainFinalities == 0 {
+		return nil, xerrors.Errorf("must retain at least one finality window of state")
+	}
+
+	return a.Chain.Prune(ctx, retainFinalities*build.Finality, dryRun)
+}
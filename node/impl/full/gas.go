@@ -0,0 +1,188 @@
+package full
+
+import (
+	"context"
+	"sort"
+
+	"github.com/ipfs/go-hamt-ipld"
+	"go.uber.org/fx"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain"
+	"github.com/filecoin-project/lotus/chain/address"
+	"github.com/filecoin-project/lotus/chain/state"
+	"github.com/filecoin-project/lotus/chain/stmgr"
+	"github.com/filecoin-project/lotus/chain/store"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// extra gas added on top of the estimated gas usage, in percent, to account
+// for state changes between estimation and inclusion
+const gasEstimateMarginPercent = 25
+
+// gas limit the message is executed with during estimation
+const gasEstimateCallLimit = 10000000000
+
+type GasAPI struct {
+	fx.In
+
+	StateManager *stmgr.StateManager
+	Chain        *store.ChainStore
+	Mpool        *chain.MessagePool
+}
+
+func (a *GasAPI) loadTipSet(tsk types.TipSetKey) (*types.TipSet, error) {
+	if len(tsk.Cids()) == 0 {
+		return a.Chain.GetHeaviestTipSet(), nil
+	}
+
+	ts, err := a.Chain.LoadTipSet(tsk.Cids())
+	if err != nil {
+		return nil, xerrors.Errorf("loading tipset %s: %w", tsk, err)
+	}
+	return ts, nil
+}
+
+func (a *GasAPI) StateEstimateGas(ctx context.Context, msg *types.Message, tsk types.TipSetKey) (types.BigInt, error) {
+	ts, err := a.loadTipSet(tsk)
+	if err != nil {
+		return types.EmptyInt, err
+	}
+
+	msgCopy := *msg
+	msgCopy.GasLimit = types.NewInt(gasEstimateCallLimit)
+	msgCopy.GasPrice = types.NewInt(0)
+	if msgCopy.Value.Nil() {
+		msgCopy.Value = types.NewInt(0)
+	}
+
+	priorMsgs, err := a.priorMessages(ctx, msg.From, ts)
+	if err != nil {
+		return types.EmptyInt, err
+	}
+
+	rct, err := a.StateManager.CallWithGas(ctx, &msgCopy, priorMsgs, ts)
+	if err != nil && len(priorMsgs) > 0 {
+		log.Warnf("estimating gas after the pending messages from %s failed, estimating against chain state: %s", msg.From, err)
+		rct, err = a.StateManager.CallWithGas(ctx, &msgCopy, nil, ts)
+	}
+	if err != nil {
+		return types.EmptyInt, xerrors.Errorf("executing message: %w", err)
+	}
+
+	if rct.ExitCode != 0 {
+		return types.EmptyInt, xerrors.Errorf("message execution failed: exit %d", rct.ExitCode)
+	}
+
+	margin := types.BigDiv(types.BigMul(rct.GasUsed, types.NewInt(gasEstimateMarginPercent)), types.NewInt(100))
+	return types.BigAdd(rct.GasUsed, margin), nil
+}
+
+// priorMessages returns the pending messages from the sender which directly
+// follow its nonce in the state computed for ts. Messages after a nonce gap
+// can't be executed, so they are left out.
+func (a *GasAPI) priorMessages(ctx context.Context, from address.Address, ts *types.TipSet) ([]store.ChainMsg, error) {
+	pending := a.Mpool.PendingFor(from)
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	st, _, err := a.StateManager.TipSetState(ctx, ts)
+	if err != nil {
+		return nil, xerrors.Errorf("computing tipset state: %w", err)
+	}
+
+	stree, err := state.LoadStateTree(hamt.CSTFromBstore(a.Chain.Blockstore()), st)
+	if err != nil {
+		return nil, xerrors.Errorf("loading state tree: %w", err)
+	}
+
+	act, err := stree.GetActor(from)
+	if err != nil {
+		// the sender doesn't exist yet, none of its messages can be executed
+		return nil, nil
+	}
+
+	nonce := act.Nonce
+	var out []store.ChainMsg
+	for _, m := range pending {
+		if m.Message.Nonce < nonce {
+			continue
+		}
+		if m.Message.Nonce > nonce {
+			break
+		}
+
+		out = append(out, m)
+		nonce++
+	}
+
+	return out, nil
+}
+
+type gasSample struct {
+	price types.BigInt
+	limit types.BigInt
+}
+
+// medianGasPrice returns the median gas price of the samples, weighted by gas
+// limit
+func medianGasPrice(samples []gasSample) types.BigInt {
+	if len(samples) == 0 {
+		return types.NewInt(0)
+	}
+
+	totalGas := types.NewInt(0)
+	for _, s := range samples {
+		totalGas = types.BigAdd(totalGas, s.limit)
+	}
+
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].price.LessThan(samples[j].price)
+	})
+
+	half := types.BigDiv(totalGas, types.NewInt(2))
+	acc := types.NewInt(0)
+	for _, s := range samples {
+		acc = types.BigAdd(acc, s.limit)
+		if acc.GreaterThan(half) {
+			return s.price
+		}
+	}
+
+	return samples[len(samples)-1].price
+}
+
+// MpoolEstimateGasPrice suggests a gas price based on the messages included in
+// the last nblocks tipsets, returning the median gas price weighted by gas
+// limit. If no messages were included, zero is returned.
+func (a *GasAPI) MpoolEstimateGasPrice(ctx context.Context, nblocks uint64, tsk types.TipSetKey) (types.BigInt, error) {
+	ts, err := a.loadTipSet(tsk)
+	if err != nil {
+		return types.EmptyInt, err
+	}
+
+	var samples []gasSample
+
+	for i := uint64(0); i < nblocks && ts.Height() > 0; i++ {
+		msgs, err := a.Chain.MessagesForTipset(ts)
+		if err != nil {
+			return types.EmptyInt, xerrors.Errorf("loading messages for tipset %s: %w", ts.Cids(), err)
+		}
+
+		for _, m := range msgs {
+			vmm := m.VMMessage()
+			samples = append(samples, gasSample{
+				price: vmm.GasPrice,
+				limit: vmm.GasLimit,
+			})
+		}
+
+		ts, err = a.Chain.LoadTipSet(ts.Parents())
+		if err != nil {
+			return types.EmptyInt, xerrors.Errorf("loading parent tipset: %w", err)
+		}
+	}
+
+	return medianGasPrice(samples), nil
+}
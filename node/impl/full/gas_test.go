@@ -0,0 +1,204 @@
+package full
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-hamt-ipld"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/chain"
+	"github.com/filecoin-project/lotus/chain/address"
+	"github.com/filecoin-project/lotus/chain/gen"
+	"github.com/filecoin-project/lotus/chain/state"
+	"github.com/filecoin-project/lotus/chain/stmgr"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+type gasTestNode struct {
+	api    *MpoolAPI
+	sm     *stmgr.StateManager
+	head   *types.TipSet
+	banker address.Address
+}
+
+func newGasTestNode(t *testing.T, ctx context.Context) *gasTestNode {
+	cg, err := gen.NewGenerator()
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err := cg.NextTipSet()
+		require.NoError(t, err)
+	}
+
+	cs := cg.ChainStore()
+	head := cg.CurTipset.TipSet()
+	require.NoError(t, cs.SetHead(head))
+
+	sm := stmgr.NewStateManager(cs)
+
+	h, err := mocknet.New(ctx).GenPeer()
+	require.NoError(t, err)
+	ps, err := pubsub.NewFloodSub(ctx, h)
+	require.NoError(t, err)
+
+	mp, err := chain.NewMessagePool(sm, ps, datastore.NewMapDatastore(), &chain.MpoolConfig{ReplaceByFeePercent: 25})
+	require.NoError(t, err)
+
+	return &gasTestNode{
+		api: &MpoolAPI{
+			WalletAPI: WalletAPI{StateManager: sm, Wallet: cg.Wallet()},
+			GasAPI:    GasAPI{StateManager: sm, Chain: cs, Mpool: mp},
+			Mpool:     mp,
+		},
+		sm:     sm,
+		head:   head,
+		banker: cg.Banker(),
+	}
+}
+
+// nonce returns the banker nonce after executing the head
+func (tn *gasTestNode) nonce(t *testing.T, ctx context.Context) uint64 {
+	st, _, err := tn.sm.TipSetState(ctx, tn.head)
+	require.NoError(t, err)
+	stree, err := state.LoadStateTree(hamt.CSTFromBstore(tn.sm.ChainStore().Blockstore()), st)
+	require.NoError(t, err)
+	act, err := stree.GetActor(tn.banker)
+	require.NoError(t, err)
+	return act.Nonce
+}
+
+func (tn *gasTestNode) sendMsg(t *testing.T) *types.Message {
+	to, err := address.NewSecp256k1Address([]byte("gas estimate test"))
+	require.NoError(t, err)
+
+	return &types.Message{
+		From:  tn.banker,
+		To:    to,
+		Value: types.NewInt(1000),
+	}
+}
+
+func TestStateEstimateGas(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tn := newGasTestNode(t, ctx)
+	defer tn.api.Mpool.Close()
+
+	msg := tn.sendMsg(t)
+
+	gas, err := tn.api.StateEstimateGas(ctx, msg, types.TipSetKey{})
+	require.NoError(t, err)
+
+	// the estimate covers the gas used, with some margin
+	rct, err := tn.sm.CallWithGas(ctx, &types.Message{
+		From:     msg.From,
+		To:       msg.To,
+		Value:    msg.Value,
+		GasPrice: types.NewInt(0),
+		GasLimit: gas,
+	}, nil, tn.head)
+	require.NoError(t, err)
+	require.Equal(t, uint8(0), rct.ExitCode)
+	assert.True(t, rct.GasUsed.GreaterThan(types.NewInt(0)))
+	assert.Equal(t, types.BigAdd(rct.GasUsed, types.BigDiv(rct.GasUsed, types.NewInt(4))).String(), gas.String())
+
+	nonce := tn.nonce(t, ctx)
+	add := func(nonce uint64) {
+		smsg, err := tn.api.WalletSignMessage(ctx, tn.banker, &types.Message{
+			From:     tn.banker,
+			To:       msg.To,
+			Nonce:    nonce,
+			Value:    types.NewInt(1),
+			GasPrice: types.NewInt(0),
+			GasLimit: types.NewInt(10000),
+		})
+		require.NoError(t, err)
+		require.NoError(t, tn.api.Mpool.Add(smsg))
+	}
+
+	// a nonce gap in the pending messages doesn't break estimation
+	add(nonce + 5)
+	gapped, err := tn.api.StateEstimateGas(ctx, msg, types.TipSetKey{})
+	require.NoError(t, err)
+	assert.Equal(t, gas.String(), gapped.String())
+
+	// messages right after the state nonce are executed first
+	add(nonce)
+	prior, err := tn.api.StateEstimateGas(ctx, msg, types.TipSetKey{})
+	require.NoError(t, err)
+	assert.True(t, prior.GreaterThan(types.NewInt(0)))
+
+	// messages which fail can't be estimated
+	failing := tn.sendMsg(t)
+	failing.Value = types.TotalFilecoinInt
+	_, err = tn.api.StateEstimateGas(ctx, failing, types.TipSetKey{})
+	assert.Error(t, err)
+}
+
+func TestMedianGasPrice(t *testing.T) {
+	sample := func(price, limit uint64) gasSample {
+		return gasSample{price: types.NewInt(price), limit: types.NewInt(limit)}
+	}
+
+	assert.Equal(t, "0", medianGasPrice(nil).String())
+	assert.Equal(t, "5", medianGasPrice([]gasSample{sample(5, 100)}).String())
+
+	// weighted by gas limit
+	assert.Equal(t, "1", medianGasPrice([]gasSample{sample(10, 100), sample(1, 1000), sample(5, 100)}).String())
+	assert.Equal(t, "10", medianGasPrice([]gasSample{sample(10, 1000), sample(1, 100), sample(5, 100)}).String())
+	assert.Equal(t, "5", medianGasPrice([]gasSample{sample(10, 100), sample(1, 100), sample(5, 100)}).String())
+}
+
+func TestMpoolEstimateGasPrice(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tn := newGasTestNode(t, ctx)
+	defer tn.api.Mpool.Close()
+
+	// the generated chain only has free messages
+	price, err := tn.api.MpoolEstimateGasPrice(ctx, 10, types.TipSetKey{})
+	require.NoError(t, err)
+	assert.Equal(t, "0", price.String())
+}
+
+func TestMpoolPushMessageFillsGas(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tn := newGasTestNode(t, ctx)
+	defer tn.api.Mpool.Close()
+
+	msg := tn.sendMsg(t)
+	gas, err := tn.api.StateEstimateGas(ctx, msg, types.TipSetKey{})
+	require.NoError(t, err)
+
+	nonce, err := tn.api.MpoolGetNonce(ctx, tn.banker)
+	require.NoError(t, err)
+
+	smsg, err := tn.api.MpoolPushMessage(ctx, msg)
+	require.NoError(t, err)
+	assert.Equal(t, nonce, smsg.Message.Nonce)
+	assert.Equal(t, gas.String(), smsg.Message.GasLimit.String())
+	assert.Equal(t, "0", smsg.Message.GasPrice.String())
+	require.NoError(t, smsg.Signature.Verify(tn.banker, smsg.Message.Cid().Bytes()))
+
+	// gas set by the caller is kept
+	msg = tn.sendMsg(t)
+	msg.GasLimit = types.NewInt(5000)
+	msg.GasPrice = types.NewInt(7)
+
+	smsg, err = tn.api.MpoolPushMessage(ctx, msg)
+	require.NoError(t, err)
+	assert.Equal(t, nonce+1, smsg.Message.Nonce)
+	assert.Equal(t, "5000", smsg.Message.GasLimit.String())
+	assert.Equal(t, "7", smsg.Message.GasPrice.String())
+
+	assert.Len(t, tn.api.Mpool.PendingFor(tn.banker), 2)
+}
@@ -12,10 +12,15 @@ import (
 	"github.com/filecoin-project/lotus/chain/types"
 )
 
+// number of recent tipsets considered when filling in the gas price of
+// messages pushed without one
+const gasPriceEstimateBlocks = 10
+
 type MpoolAPI struct {
 	fx.In
 
 	WalletAPI
+	GasAPI
 
 	Mpool *chain.MessagePool
 }
@@ -35,6 +40,22 @@ func (a *MpoolAPI) MpoolPushMessage(ctx context.Context, msg *types.Message) (*t
 		return nil, xerrors.Errorf("MpoolPushMessage expects message nonce to be 0, was %d", msg.Nonce)
 	}
 
+	if msg.GasLimit.Nil() || msg.GasLimit.Equals(types.NewInt(0)) {
+		gasLimit, err := a.StateEstimateGas(ctx, msg, types.TipSetKey{})
+		if err != nil {
+			return nil, xerrors.Errorf("estimating gas limit: %w", err)
+		}
+		msg.GasLimit = gasLimit
+	}
+
+	if msg.GasPrice.Nil() {
+		gasPrice, err := a.MpoolEstimateGasPrice(ctx, gasPriceEstimateBlocks, types.TipSetKey{})
+		if err != nil {
+			return nil, xerrors.Errorf("estimating gas price: %w", err)
+		}
+		msg.GasPrice = gasPrice
+	}
+
 	return a.Mpool.PushWithNonce(msg.From, func(nonce uint64) (*types.SignedMessage, error) {
 		msg.Nonce = nonce
 
@@ -0,0 +1,143 @@
+package full
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	"go.uber.org/fx"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/address"
+	"github.com/filecoin-project/lotus/chain/stmgr"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+type MsigAPI struct {
+	fx.In
+
+	MpoolAPI
+
+	StateManager *stmgr.StateManager
+}
+
+func (a *MsigAPI) loadMsigState(ctx context.Context, addr address.Address, ts *types.TipSet) (*types.Actor, *actors.MultiSigActorState, error) {
+	var st actors.MultiSigActorState
+	act, err := a.StateManager.LoadActorState(ctx, addr, &st, ts)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to load multisig actor state: %w", err)
+	}
+
+	if act.Code != actors.MultisigCodeCid {
+		return nil, nil, xerrors.Errorf("given actor was not a multisig")
+	}
+
+	return act, &st, nil
+}
+
+func (a *MsigAPI) MsigGetAvailableBalance(ctx context.Context, addr address.Address, ts *types.TipSet) (types.BigInt, error) {
+	if ts == nil {
+		ts = a.StateManager.ChainStore().GetHeaviestTipSet()
+	}
+
+	act, st, err := a.loadMsigState(ctx, addr, ts)
+	if err != nil {
+		return types.EmptyInt, err
+	}
+
+	// the state is the parent state of ts, which messages included in ts are
+	// executed against at its height
+	return st.AvailableBalance(act.Balance, ts.Height()), nil
+}
+
+func (a *MsigAPI) MsigGetPending(ctx context.Context, addr address.Address, ts *types.TipSet) ([]*actors.MTransaction, error) {
+	_, st, err := a.loadMsigState(ctx, addr, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*actors.MTransaction
+	for i := range st.Transactions {
+		if st.Transactions[i].Active() != nil {
+			continue
+		}
+		out = append(out, &st.Transactions[i])
+	}
+
+	return out, nil
+}
+
+func (a *MsigAPI) MsigCreate(ctx context.Context, req uint64, signers []address.Address, unlockDuration uint64, val types.BigInt, src address.Address) (cid.Cid, error) {
+	if req == 0 || req > uint64(len(signers)) {
+		return cid.Undef, xerrors.Errorf("required number of approvals must be between 1 and the number of signers (%d), was %d", len(signers), req)
+	}
+
+	enc, err := actors.CreateExecParams(actors.MultisigCodeCid, &actors.MultiSigConstructorParams{
+		Signers:        signers,
+		Required:       req,
+		UnlockDuration: unlockDuration,
+	})
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	return a.pushMsigMessage(ctx, &types.Message{
+		To:     actors.InitAddress,
+		From:   src,
+		Value:  val,
+		Method: actors.IAMethods.Exec,
+		Params: enc,
+	})
+}
+
+func (a *MsigAPI) MsigPropose(ctx context.Context, msig address.Address, to address.Address, amt types.BigInt, src address.Address, method uint64, params []byte) (cid.Cid, error) {
+	enc, err := actors.SerializeParams(&actors.MultiSigProposeParams{
+		To:     to,
+		Value:  amt,
+		Method: method,
+		Params: params,
+	})
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	return a.pushMsigMessage(ctx, &types.Message{
+		To:     msig,
+		From:   src,
+		Value:  types.NewInt(0),
+		Method: actors.MultiSigMethods.Propose,
+		Params: enc,
+	})
+}
+
+func (a *MsigAPI) MsigApprove(ctx context.Context, msig address.Address, txID uint64, src address.Address) (cid.Cid, error) {
+	return a.msigTxMessage(ctx, msig, actors.MultiSigMethods.Approve, txID, src)
+}
+
+func (a *MsigAPI) MsigCancel(ctx context.Context, msig address.Address, txID uint64, src address.Address) (cid.Cid, error) {
+	return a.msigTxMessage(ctx, msig, actors.MultiSigMethods.Cancel, txID, src)
+}
+
+func (a *MsigAPI) msigTxMessage(ctx context.Context, msig address.Address, method uint64, txID uint64, src address.Address) (cid.Cid, error) {
+	enc, err := actors.SerializeParams(&actors.MultiSigTxID{TxID: txID})
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	return a.pushMsigMessage(ctx, &types.Message{
+		To:     msig,
+		From:   src,
+		Value:  types.NewInt(0),
+		Method: method,
+		Params: enc,
+	})
+}
+
+func (a *MsigAPI) pushMsigMessage(ctx context.Context, msg *types.Message) (cid.Cid, error) {
+	smsg, err := a.MpoolPushMessage(ctx, msg)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to push message: %w", err)
+	}
+
+	return smsg.Cid(), nil
+}
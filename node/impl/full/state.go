@@ -72,6 +72,19 @@ func (a *StateAPI) StateMinerWorker(ctx context.Context, m address.Address, ts *
 	return stmgr.GetMinerWorker(ctx, a.StateManager, ts, m)
 }
 
+func (a *StateAPI) StateMinerInfo(ctx context.Context, m address.Address, ts *types.TipSet) (*api.MinerInfo, error) {
+	mi, ext, err := stmgr.GetMinerInfo(ctx, a.StateManager, ts, m)
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.MinerInfo{
+		MinerInfo:    *mi,
+		WorkerChange: ext.WorkerChange,
+		OwnerChange:  ext.OwnerChange,
+	}, nil
+}
+
 func (a *StateAPI) StateMinerPeerID(ctx context.Context, m address.Address, ts *types.TipSet) (peer.ID, error) {
 	return stmgr.GetMinerPeerID(ctx, a.StateManager, ts, m)
 }
@@ -84,6 +97,14 @@ func (a *StateAPI) StateMinerSectorSize(ctx context.Context, actor address.Addre
 	return stmgr.GetMinerSectorSize(ctx, a.StateManager, ts, actor)
 }
 
+func (a *StateAPI) StateMinerFaults(ctx context.Context, actor address.Address, ts *types.TipSet) (*api.MinerFaults, error) {
+	return stmgr.GetMinerFaults(ctx, a.StateManager, ts, actor)
+}
+
+func (a *StateAPI) StateMinerSectorExpiration(ctx context.Context, actor address.Address, sectorID uint64, ts *types.TipSet) (uint64, error) {
+	return stmgr.GetMinerSectorExpiration(ctx, a.StateManager, ts, actor, sectorID)
+}
+
 func (a *StateAPI) StatePledgeCollateral(ctx context.Context, ts *types.TipSet) (types.BigInt, error) {
 	param, err := actors.SerializeParams(&actors.PledgeCollateralParams{Size: types.NewInt(0)})
 	if err != nil {
@@ -108,26 +129,55 @@ func (a *StateAPI) StatePledgeCollateral(ctx context.Context, ts *types.TipSet)
 	return types.BigFromBytes(ret.Return), nil
 }
 
-func (a *StateAPI) StateCall(ctx context.Context, msg *types.Message, ts *types.TipSet) (*types.MessageReceipt, error) {
-	return a.StateManager.Call(ctx, msg, ts)
+func (a *StateAPI) StateCall(ctx context.Context, msg *types.Message, ts *types.TipSet) (*api.InvocResult, error) {
+	r, err := a.StateManager.CallWithTrace(ctx, msg, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	return invocResult(msg, r), nil
 }
 
-func (a *StateAPI) StateReplay(ctx context.Context, ts *types.TipSet, mc cid.Cid) (*api.ReplayResults, error) {
+func (a *StateAPI) StateReplay(ctx context.Context, ts *types.TipSet, mc cid.Cid) (*api.InvocResult, error) {
 	m, r, err := a.StateManager.Replay(ctx, ts, mc)
 	if err != nil {
 		return nil, err
 	}
+	if r == nil {
+		return nil, xerrors.Errorf("message %s not found in tipset %s", mc, ts.Cids())
+	}
+
+	return invocResult(m, r), nil
+}
+
+func (a *StateAPI) StateCompute(ctx context.Context, msgs []*types.Message, ts *types.TipSet) (*api.ComputeStateOutput, error) {
+	rets, err := a.StateManager.ComputeState(ctx, msgs, ts)
+	if err != nil {
+		return nil, err
+	}
 
+	out := &api.ComputeStateOutput{
+		Trace: make([]*api.InvocResult, len(rets)),
+	}
+	for i, r := range rets {
+		out.Trace[i] = invocResult(msgs[i], r)
+	}
+
+	return out, nil
+}
+
+func invocResult(m *types.Message, r *vm.ApplyRet) *api.InvocResult {
 	var errstr string
 	if r.ActorErr != nil {
 		errstr = r.ActorErr.Error()
 	}
 
-	return &api.ReplayResults{
-		Msg:     m,
-		Receipt: &r.MessageReceipt,
-		Error:   errstr,
-	}, nil
+	return &api.InvocResult{
+		Msg:            m,
+		Receipt:        &r.MessageReceipt,
+		ExecutionTrace: r.ExecutionTrace,
+		Error:          errstr,
+	}
 }
 
 func (a *StateAPI) stateForTs(ctx context.Context, ts *types.TipSet) (*state.StateTree, error) {
@@ -218,6 +268,134 @@ func (a *StateAPI) StateWaitMsg(ctx context.Context, msg cid.Cid) (*api.MsgWait,
 	}, nil
 }
 
+func (a *StateAPI) StateSearchMsg(ctx context.Context, msg cid.Cid) (*api.MsgWait, error) {
+	ts, recpt, err := a.StateManager.SearchForMessage(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	if recpt == nil {
+		return nil, nil
+	}
+
+	return &api.MsgWait{
+		Receipt: *recpt,
+		TipSet:  ts,
+	}, nil
+}
+
+func (a *StateAPI) StateListMessages(ctx context.Context, match *api.MessageMatch, tsk types.TipSetKey, fromHeight uint64) ([]cid.Cid, error) {
+	if match == nil || (match.To == address.Undef && match.From == address.Undef) {
+		return nil, xerrors.Errorf("must specify at least To or From in message filter")
+	}
+
+	head := a.Chain.GetHeaviestTipSet()
+	ts := head
+	if len(tsk.Cids()) > 0 {
+		var err error
+		ts, err = a.Chain.LoadTipSet(tsk.Cids())
+		if err != nil {
+			return nil, xerrors.Errorf("loading tipset %s: %w", tsk, err)
+		}
+	}
+
+	// the genesis tipset didn't execute any messages
+	if ts.Height() == 0 {
+		return nil, nil
+	}
+
+	st, err := a.stateForTs(ctx, ts)
+	if err != nil {
+		return nil, xerrors.Errorf("loading state: %w", err)
+	}
+
+	// messages are indexed by the ID addresses of the actors
+	resolve := func(addr address.Address) address.Address {
+		if addr == address.Undef {
+			return addr
+		}
+		id, err := st.LookupID(addr)
+		if err != nil {
+			// no such actor, but failed sends to the address may exist
+			return addr
+		}
+		return id
+	}
+
+	from, to := resolve(match.From), resolve(match.To)
+	matches := func(m *types.Message) bool {
+		if from != address.Undef && resolve(m.From) != from {
+			return false
+		}
+		if to != address.Undef && resolve(m.To) != to {
+			return false
+		}
+		return true
+	}
+
+	// The index only covers the heaviest chain. Messages executed in tipsets
+	// which aren't on it are searched for directly, up to where the chain of
+	// ts joins the heaviest chain. Messages included in ts itself aren't
+	// executed yet.
+	var forked []*types.TipSet
+	cur, err := a.Chain.LoadTipSet(ts.Parents())
+	if err != nil {
+		return nil, xerrors.Errorf("loading parent tipset: %w", err)
+	}
+	for cur.Height() > 0 && cur.Height() >= fromHeight {
+		hts, err := a.Chain.GetTipsetByHeight(ctx, cur.Height(), head)
+		if err != nil {
+			return nil, xerrors.Errorf("loading tipset at height %d: %w", cur.Height(), err)
+		}
+		if hts.Equals(cur) {
+			break
+		}
+
+		forked = append(forked, cur)
+		cur, err = a.Chain.LoadTipSet(cur.Parents())
+		if err != nil {
+			return nil, xerrors.Errorf("loading parent tipset: %w", err)
+		}
+	}
+
+	var cids []cid.Cid
+	if from != address.Undef {
+		cids, err = a.Chain.MsgIndex().ListMessagesFrom(from, fromHeight, cur.Height())
+	} else {
+		cids, err = a.Chain.MsgIndex().ListMessagesTo(to, fromHeight, cur.Height())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]cid.Cid, 0, len(cids))
+	for _, c := range cids {
+		m, err := a.Chain.GetCMessage(c)
+		if err != nil {
+			return nil, xerrors.Errorf("loading message %s: %w", c, err)
+		}
+
+		if matches(m.VMMessage()) {
+			out = append(out, c)
+		}
+	}
+
+	for i := len(forked) - 1; i >= 0; i-- {
+		msgs, err := a.Chain.MessagesForTipset(forked[i])
+		if err != nil {
+			return nil, xerrors.Errorf("loading messages of tipset %s: %w", forked[i].Cids(), err)
+		}
+
+		for _, m := range msgs {
+			if matches(m.VMMessage()) {
+				out = append(out, m.Cid())
+			}
+		}
+	}
+
+	return out, nil
+}
+
 func (a *StateAPI) StateGetReceipt(ctx context.Context, msg cid.Cid, ts *types.TipSet) (*types.MessageReceipt, error) {
 	return a.StateManager.GetReceipt(ctx, msg, ts)
 }
@@ -2,10 +2,22 @@ package impl
 
 import (
 	"context"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/chain/address"
+	"github.com/filecoin-project/lotus/chain/deals"
+	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/lib/sectorbuilder"
+	"github.com/filecoin-project/lotus/lib/tarutil"
+	"github.com/filecoin-project/lotus/retrieval"
 	"github.com/filecoin-project/lotus/storage"
 	"github.com/filecoin-project/lotus/storage/sectorblocks"
 )
@@ -14,26 +26,188 @@ type StorageMinerAPI struct {
 	CommonAPI
 
 	SectorBuilderConfig *sectorbuilder.Config
-	SectorBuilder       *sectorbuilder.SectorBuilder
+	SectorBuilder       sectorbuilder.Interface
 	SectorBlocks        *sectorblocks.SectorBlocks
 
-	Miner *storage.Miner
-	Full  api.FullNode
+	Miner           *storage.Miner
+	StorageProvider *deals.Provider
+	Retrieval       *retrieval.Miner
+	Full            api.FullNode
+}
+
+// ServeRemote serves sector data to, and accepts sealing outputs from remote
+// seal workers at /remote/{staged,sealed,cache}/<sector name>. Piece data for
+// add piece tasks is served, and the padded piece accepted back at
+// /remote/piece/<task id>
+func (sm *StorageMinerAPI) ServeRemote(w http.ResponseWriter, r *http.Request) {
+	if !api.HasPerm(r.Context(), api.PermAdmin) {
+		w.WriteHeader(401)
+		return
+	}
+
+	// remote workers need sector files on disk
+	sb, ok := sm.SectorBuilder.(*sectorbuilder.SectorBuilder)
+	if !ok {
+		w.WriteHeader(501)
+		return
+	}
+
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/remote/"), "/")
+	if len(path) != 2 {
+		w.WriteHeader(404)
+		return
+	}
+
+	var err error
+	switch {
+	case r.Method == "GET" && path[0] == "piece":
+		err = remoteGetPiece(sb, w, path[1])
+	case r.Method == "GET":
+		err = remoteGetSector(sb, w, path[0], path[1])
+	case r.Method == "PUT" && path[0] == "piece":
+		err = remotePutPiece(sb, r, path[1])
+	case r.Method == "PUT":
+		err = remotePutSector(sb, r, path[0], path[1])
+	default:
+		w.WriteHeader(405)
+		return
+	}
+
+	if xerrors.Is(err, sectorbuilder.ErrSectorNotFound) || os.IsNotExist(err) {
+		w.WriteHeader(404)
+		return
+	}
+	if err != nil {
+		log.Errorf("serving remote %s %s: %+v", r.Method, r.URL.Path, err)
+		w.WriteHeader(500)
+		return
+	}
+}
+
+func remoteGetPiece(sb *sectorbuilder.SectorBuilder, w http.ResponseWriter, task string) error {
+	id, err := strconv.ParseUint(task, 10, 64)
+	if err != nil {
+		return xerrors.Errorf("parsing task id: %w", err)
+	}
+
+	rd, err := sb.TaskPiece(id)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(200)
+	if _, err := io.Copy(w, rd); err != nil {
+		log.Errorf("sending piece for task %d: %+v", id, err)
+	}
+	return nil
+}
+
+func remotePutPiece(sb *sectorbuilder.SectorBuilder, r *http.Request, task string) error {
+	id, err := strconv.ParseUint(task, 10, 64)
+	if err != nil {
+		return xerrors.Errorf("parsing task id: %w", err)
+	}
+
+	return sb.WriteTaskPiece(id, r.Body)
+}
+
+func remoteGetSector(sb *sectorbuilder.SectorBuilder, w http.ResponseWriter, typ string, name string) error {
+	path, err := sb.GetPath(typ, name)
+	if err != nil {
+		return err
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	var rd io.ReadCloser
+	if stat.IsDir() {
+		rd, err = tarutil.TarDirectory(path)
+		w.Header().Set("Content-Type", "application/x-tar")
+	} else {
+		rd, err = os.Open(path)
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+
+	w.WriteHeader(200)
+	if _, err := io.Copy(w, rd); err != nil {
+		log.Errorf("sending %s: %+v", path, err)
+	}
+	return nil
+}
+
+func remotePutSector(sb *sectorbuilder.SectorBuilder, r *http.Request, typ string, name string) error {
+	path, err := sb.AllocPath(typ, name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+
+	if r.Header.Get("Content-Type") == "application/x-tar" {
+		return tarutil.ExtractTar(r.Body, path)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r.Body); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
 }
 
 func (sm *StorageMinerAPI) WorkerStats(context.Context) (api.WorkerStats, error) {
-	free, reserved, total := sm.SectorBuilder.WorkerStats()
-	return api.WorkerStats{
-		Free:     free,
-		Reserved: reserved,
-		Total:    total,
-	}, nil
+	return sm.SectorBuilder.WorkerStats(), nil
+}
+
+func (sm *StorageMinerAPI) StorageList(ctx context.Context) ([]api.StoragePathStat, error) {
+	sb, ok := sm.SectorBuilder.(*sectorbuilder.SectorBuilder)
+	if !ok {
+		return nil, xerrors.New("sectorbuilder doesn't support storage paths")
+	}
+
+	return sb.StorageStats()
+}
+
+func (sm *StorageMinerAPI) StorageAttach(ctx context.Context, p api.StoragePath) error {
+	sb, ok := sm.SectorBuilder.(*sectorbuilder.SectorBuilder)
+	if !ok {
+		return xerrors.New("sectorbuilder doesn't support storage paths")
+	}
+
+	return sb.AttachStorage(p)
+}
+
+func (sm *StorageMinerAPI) WorkerQueue(ctx context.Context) (<-chan sectorbuilder.WorkerTask, error) {
+	return sm.SectorBuilder.AddWorker(ctx)
+}
+
+func (sm *StorageMinerAPI) WorkerDone(ctx context.Context, task uint64, res sectorbuilder.SealRes) error {
+	return sm.SectorBuilder.TaskDone(ctx, task, res)
 }
 
 func (sm *StorageMinerAPI) ActorAddress(context.Context) (address.Address, error) {
 	return sm.SectorBuilderConfig.Miner, nil
 }
 
+func (sm *StorageMinerAPI) ActorSectorSize(ctx context.Context, addr address.Address) (uint64, error) {
+	return sm.Full.StateMinerSectorSize(ctx, addr, nil)
+}
+
 func (sm *StorageMinerAPI) StoreGarbageData(ctx context.Context) error {
 	return sm.Miner.StoreGarbageData()
 }
@@ -45,8 +219,15 @@ func (sm *StorageMinerAPI) SectorsStatus(ctx context.Context, sid uint64) (api.S
 	}
 
 	deals := make([]uint64, len(info.Pieces))
+	pieces := make([]api.SectorPiece, len(info.Pieces))
 	for i, piece := range info.Pieces {
 		deals[i] = piece.DealID
+		pieces[i] = api.SectorPiece{
+			DealID: piece.DealID,
+			Ref:    piece.Ref,
+			Size:   piece.Size,
+			CommP:  piece.CommP,
+		}
 	}
 
 	return api.SectorInfo{
@@ -56,8 +237,11 @@ func (sm *StorageMinerAPI) SectorsStatus(ctx context.Context, sid uint64) (api.S
 		CommR:    info.CommR,
 		Proof:    info.Proof,
 		Deals:    deals,
+		Pieces:   pieces,
 		Ticket:   info.Ticket.SB(),
 		Seed:     info.Seed.SB(),
+		Retries:  info.Retries,
+		LastErr:  info.LastErr,
 	}, nil
 }
 
@@ -91,4 +275,108 @@ func (sm *StorageMinerAPI) SectorsRefs(context.Context) (map[string][]api.Sealed
 	return out, nil
 }
 
+func (sm *StorageMinerAPI) SectorsUpdate(ctx context.Context, id uint64, state api.SectorState) error {
+	return sm.Miner.ForceSectorState(ctx, id, state)
+}
+
+func (sm *StorageMinerAPI) SectorsFaults(context.Context) ([]api.SectorFault, error) {
+	return sm.Miner.Faults(), nil
+}
+
+func (sm *StorageMinerAPI) PoStHistory(context.Context) ([]api.PoStRecord, error) {
+	return sm.Miner.PoStHistory()
+}
+
+func (sm *StorageMinerAPI) MarketListDeals(context.Context) ([]api.MinerDeal, error) {
+	list, err := sm.StorageProvider.ListDeals()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]api.MinerDeal, len(list))
+	for i, deal := range list {
+		out[i] = sm.minerDeal(deal)
+	}
+	return out, nil
+}
+
+func (sm *StorageMinerAPI) MarketGetDeal(ctx context.Context, proposal cid.Cid) (*api.MinerDeal, error) {
+	deal, err := sm.StorageProvider.GetDeal(proposal)
+	if err != nil {
+		return nil, err
+	}
+
+	out := sm.minerDeal(*deal)
+	return &out, nil
+}
+
+func (sm *StorageMinerAPI) minerDeal(deal deals.MinerDeal) api.MinerDeal {
+	return api.MinerDeal{
+		ProposalCid: deal.ProposalCid,
+		State:       deal.State,
+		Client:      deal.Client,
+		Proposal:    deal.Proposal,
+		Ref:         deal.Ref,
+		DealID:      deal.DealID,
+		SectorID:    deal.SectorID,
+		Transferred: sm.StorageProvider.Transferred(deal.ProposalCid),
+	}
+}
+
+func (sm *StorageMinerAPI) MarketSetAsk(ctx context.Context, price types.BigInt, ttlSecs int64) error {
+	if ttlSecs <= 0 {
+		return xerrors.Errorf("ask ttl must be positive, got %d", ttlSecs)
+	}
+
+	return sm.StorageProvider.SetPrice(price, ttlSecs)
+}
+
+func (sm *StorageMinerAPI) MarketGetAsk(context.Context) (*types.SignedStorageAsk, error) {
+	ask := sm.StorageProvider.GetAsk()
+	if ask == nil {
+		return nil, xerrors.New("no storage ask set")
+	}
+	return ask, nil
+}
+
+func (sm *StorageMinerAPI) MarketSetRetrievalAsk(ctx context.Context, pricePerByte, unsealPrice types.BigInt, paymentInterval, paymentIntervalIncrease uint64, ttlSecs int64) error {
+	if ttlSecs <= 0 {
+		return xerrors.Errorf("ask ttl must be positive, got %d", ttlSecs)
+	}
+
+	return sm.Retrieval.SetAsk(pricePerByte, unsealPrice, paymentInterval, paymentIntervalIncrease, ttlSecs)
+}
+
+func (sm *StorageMinerAPI) MarketGetRetrievalAsk(context.Context) (*types.SignedRetrievalAsk, error) {
+	ask := sm.Retrieval.GetAsk()
+	if ask == nil {
+		return nil, xerrors.New("no retrieval ask set")
+	}
+	return ask, nil
+}
+
+func (sm *StorageMinerAPI) MarketListRetrievalDeals(context.Context) ([]api.RetrievalDeal, error) {
+	deals, err := sm.Retrieval.List()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]api.RetrievalDeal, len(deals))
+	for i, d := range deals {
+		out[i] = api.RetrievalDeal{
+			ID:     d.ID,
+			State:  d.State,
+			Root:   d.Root,
+			Client: d.Client,
+
+			Paych:         d.Paych,
+			FundsReceived: d.FundsReceived,
+			BytesSent:     d.BytesSent,
+
+			Message: d.Message,
+		}
+	}
+	return out, nil
+}
+
 var _ api.StorageMiner = &StorageMinerAPI{}
@@ -3,6 +3,7 @@ package modules
 import (
 	"bytes"
 	"context"
+	"time"
 
 	"github.com/ipfs/go-bitswap"
 	"github.com/ipfs/go-bitswap/network"
@@ -17,11 +18,13 @@ import (
 	"go.uber.org/fx"
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain"
 	"github.com/filecoin-project/lotus/chain/blocksync"
 	"github.com/filecoin-project/lotus/chain/stmgr"
 	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/node/config"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/node/modules/helpers"
 	"github.com/filecoin-project/lotus/node/repo"
@@ -41,8 +44,18 @@ func ChainExchange(mctx helpers.MetricsCtx, lc fx.Lifecycle, host host.Host, rt
 	return exch
 }
 
-func MessagePool(lc fx.Lifecycle, sm *stmgr.StateManager, ps *pubsub.PubSub, ds dtypes.MetadataDS) (*chain.MessagePool, error) {
-	mp, err := chain.NewMessagePool(sm, ps, ds)
+func MpoolConfig(cfg config.Mpool) func() *chain.MpoolConfig {
+	return func() *chain.MpoolConfig {
+		return &chain.MpoolConfig{
+			ReplaceByFeePercent: cfg.ReplaceByFeePercent,
+			MaxPendingPerSender: cfg.MaxPendingPerSender,
+			MaxPending:          cfg.MaxPending,
+		}
+	}
+}
+
+func MessagePool(lc fx.Lifecycle, sm *stmgr.StateManager, ps *pubsub.PubSub, ds dtypes.MetadataDS, cfg *chain.MpoolConfig) (*chain.MessagePool, error) {
+	mp, err := chain.NewMessagePool(sm, ps, ds, cfg)
 	if err != nil {
 		return nil, xerrors.Errorf("constructing mpool: %w", err)
 	}
@@ -82,6 +95,31 @@ func ChainStore(lc fx.Lifecycle, bs dtypes.ChainBlockstore, ds dtypes.MetadataDS
 	return chain
 }
 
+func RunChainPruning(cfg config.Pruning) func(mctx helpers.MetricsCtx, lc fx.Lifecycle, cs *store.ChainStore) {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, cs *store.ChainStore) {
+		ctx := helpers.LifecycleCtx(mctx, lc)
+
+		go func() {
+			tick := time.NewTicker(time.Duration(cfg.Interval))
+			defer tick.Stop()
+
+			for {
+				select {
+				case <-tick.C:
+					res, err := cs.Prune(ctx, cfg.RetainFinalities*build.Finality, false)
+					if err != nil {
+						log.Errorf("chain pruning failed: %s", err)
+						continue
+					}
+					log.Infow("pruned chain state", "height", res.Height, "objects", res.Objects, "bytes", res.Bytes)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+}
+
 func ErrorGenesis() Genesis {
 	return func() (header *types.BlockHeader, e error) {
 		return nil, xerrors.New("No genesis block provided, provide the file with 'lotus daemon --genesis=[genesis file]'")
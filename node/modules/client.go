@@ -52,10 +52,21 @@ func RegisterClientValidator(crv *deals.ClientRequestValidator, dtm dtypes.Clien
 	dtm.RegisterVoucherType(reflect.TypeOf(deals.StorageDataTransferVoucher{}), crv)
 }
 
-// NewClientDAGServiceDataTransfer returns a data transfer manager that just
-// uses the clients's Client DAG service for transfers
-func NewClientDAGServiceDataTransfer(dag dtypes.ClientDAG) dtypes.ClientDataTransfer {
-	return datatransfer.NewDAGServiceDataTransfer(dag)
+// NewClientDataTransfer returns a data transfer manager that transfers data
+// to and from the client's Client DAG service
+func NewClientDataTransfer(lc fx.Lifecycle, h host.Host, dag dtypes.ClientDAG, ds dtypes.MetadataDS) (dtypes.ClientDataTransfer, error) {
+	channels := statestore.New(namespace.Wrap(ds, datastore.NewKey("/datatransfer/client/channels")))
+	dtm, err := datatransfer.NewNetworkManager(h, dag, channels)
+	if err != nil {
+		return nil, err
+	}
+
+	lc.Append(fx.Hook{
+		OnStart: dtm.Start,
+		OnStop:  dtm.Stop,
+	})
+
+	return dtm, nil
 }
 
 // NewClientDealStore creates a statestore for the client to store its deals
@@ -63,6 +74,12 @@ func NewClientDealStore(ds dtypes.MetadataDS) dtypes.ClientDealStore {
 	return statestore.New(namespace.Wrap(ds, datastore.NewKey("/deals/client")))
 }
 
+// NewClientRetrievalStore creates a statestore for the client to store its
+// retrievals
+func NewClientRetrievalStore(ds dtypes.MetadataDS) dtypes.ClientRetrievalStore {
+	return statestore.New(namespace.Wrap(ds, datastore.NewKey("/retrievals/client")))
+}
+
 func ClientDAG(mctx helpers.MetricsCtx, lc fx.Lifecycle, ibs dtypes.ClientBlockstore, rt routing.Routing, h host.Host) dtypes.ClientDAG {
 	bitswapNetwork := network.NewFromIpfsHost(h, rt)
 	exch := bitswap.New(helpers.LifecycleCtx(mctx, lc), bitswapNetwork, ibs)
@@ -27,11 +27,13 @@ type ClientFilestore *filestore.Filestore
 type ClientBlockstore blockstore.Blockstore
 type ClientDAG ipld.DAGService
 type ClientDealStore *statestore.StateStore
+type ClientRetrievalStore *statestore.StateStore
 
 // ClientDataTransfer is a data transfer manager for the client
 type ClientDataTransfer datatransfer.Manager
 
 type ProviderDealStore *statestore.StateStore
+type ProviderRetrievalStore *statestore.StateStore
 
 // ProviderDataTransfer is a data transfer manager for the provider
 type ProviderDataTransfer datatransfer.Manager
@@ -24,9 +24,11 @@ import (
 	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/address"
 	"github.com/filecoin-project/lotus/chain/deals"
+	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/datatransfer"
 	"github.com/filecoin-project/lotus/lib/sectorbuilder"
 	"github.com/filecoin-project/lotus/lib/statestore"
+	"github.com/filecoin-project/lotus/node/config"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/node/modules/helpers"
 	"github.com/filecoin-project/lotus/node/repo"
@@ -43,7 +45,7 @@ func minerAddrFromDS(ds dtypes.MetadataDS) (address.Address, error) {
 	return address.NewFromBytes(maddrb)
 }
 
-func SectorBuilderConfig(storagePath string, threads uint) func(dtypes.MetadataDS, api.FullNode) (*sectorbuilder.Config, error) {
+func SectorBuilderConfig(storagePath string, sbcfg config.SectorBuilder) func(dtypes.MetadataDS, api.FullNode) (*sectorbuilder.Config, error) {
 	return func(ds dtypes.MetadataDS, api api.FullNode) (*sectorbuilder.Config, error) {
 		minerAddr, err := minerAddrFromDS(ds)
 		if err != nil {
@@ -60,8 +62,22 @@ func SectorBuilderConfig(storagePath string, threads uint) func(dtypes.MetadataD
 			return nil, err
 		}
 
-		if threads > math.MaxUint8 {
-			return nil, xerrors.Errorf("too many sectorbuilder threads specified: %d, max allowed: %d", threads, math.MaxUint8)
+		if sbcfg.WorkerCount > math.MaxUint8 {
+			return nil, xerrors.Errorf("too many sectorbuilder threads specified: %d, max allowed: %d", sbcfg.WorkerCount, math.MaxUint8)
+		}
+
+		storage := make([]sectorbuilder.StoragePath, len(sbcfg.Storage))
+		for i, p := range sbcfg.Storage {
+			path, err := homedir.Expand(p.Path)
+			if err != nil {
+				return nil, err
+			}
+
+			storage[i] = sectorbuilder.StoragePath{
+				Path:     path,
+				Weight:   p.Weight,
+				ReadOnly: p.ReadOnly,
+			}
 		}
 
 		cache := filepath.Join(sp, "cache")
@@ -72,25 +88,30 @@ func SectorBuilderConfig(storagePath string, threads uint) func(dtypes.MetadataD
 		sb := &sectorbuilder.Config{
 			Miner:         minerAddr,
 			SectorSize:    ssize,
-			WorkerThreads: uint8(threads),
+			WorkerThreads: uint8(sbcfg.WorkerCount),
+
+			NoPreCommit: sbcfg.DisableLocalPreCommit,
+			NoCommit:    sbcfg.DisableLocalCommit,
 
 			CacheDir:    cache,
 			MetadataDir: metadata,
 			SealedDir:   sealed,
 			StagedDir:   staging,
+
+			Storage: storage,
 		}
 
 		return sb, nil
 	}
 }
 
-func StorageMiner(mctx helpers.MetricsCtx, lc fx.Lifecycle, api api.FullNode, h host.Host, ds dtypes.MetadataDS, sb *sectorbuilder.SectorBuilder, tktFn storage.TicketFn) (*storage.Miner, error) {
+func StorageMiner(mctx helpers.MetricsCtx, lc fx.Lifecycle, api api.FullNode, h host.Host, ds dtypes.MetadataDS, sb sectorbuilder.Interface, tktFn storage.TicketFn, sealing storage.SealingConfig) (*storage.Miner, error) {
 	maddr, err := minerAddrFromDS(ds)
 	if err != nil {
 		return nil, err
 	}
 
-	sm, err := storage.NewMiner(api, maddr, h, ds, sb, tktFn)
+	sm, err := storage.NewMiner(api, maddr, h, ds, sb, tktFn, sealing)
 	if err != nil {
 		return nil, err
 	}
@@ -117,6 +138,49 @@ func HandleRetrieval(host host.Host, lc fx.Lifecycle, m *retrieval.Miner) {
 	})
 }
 
+// DealPolicy parses the dealmaking config into the policy used by the deal
+// provider
+func DealPolicy(cfg config.Dealmaking) func() (deals.DealPolicy, error) {
+	return func() (deals.DealPolicy, error) {
+		policy := deals.DealPolicy{
+			MinDuration:   cfg.MinDuration,
+			MaxDuration:   cfg.MaxDuration,
+			MaxPieceSize:  cfg.MaxPieceSize,
+			FilterCommand: cfg.FilterCommand,
+		}
+
+		if cfg.MinPricePerGiBEpoch != "" {
+			price, err := types.BigFromString(cfg.MinPricePerGiBEpoch)
+			if err != nil {
+				return deals.DealPolicy{}, xerrors.Errorf("parsing MinPricePerGiBEpoch: %w", err)
+			}
+			policy.MinPricePerGiBEpoch = price
+		}
+
+		var err error
+		if policy.AllowedClients, err = parseAddrs(cfg.AllowedClients); err != nil {
+			return deals.DealPolicy{}, xerrors.Errorf("parsing AllowedClients: %w", err)
+		}
+		if policy.BlockedClients, err = parseAddrs(cfg.BlockedClients); err != nil {
+			return deals.DealPolicy{}, xerrors.Errorf("parsing BlockedClients: %w", err)
+		}
+
+		return policy, nil
+	}
+}
+
+func parseAddrs(strs []string) ([]address.Address, error) {
+	out := make([]address.Address, len(strs))
+	for i, s := range strs {
+		a, err := address.NewFromString(s)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = a
+	}
+	return out, nil
+}
+
 func HandleDeals(mctx helpers.MetricsCtx, lc fx.Lifecycle, host host.Host, h *deals.Provider) {
 	ctx := helpers.LifecycleCtx(mctx, lc)
 
@@ -141,10 +205,21 @@ func RegisterProviderValidator(mrv *deals.ProviderRequestValidator, dtm dtypes.P
 	dtm.RegisterVoucherType(reflect.TypeOf(deals.StorageDataTransferVoucher{}), mrv)
 }
 
-// NewProviderDAGServiceDataTransfer returns a data transfer manager that just
-// uses the provider's Staging DAG service for transfers
-func NewProviderDAGServiceDataTransfer(dag dtypes.StagingDAG) dtypes.ProviderDataTransfer {
-	return datatransfer.NewDAGServiceDataTransfer(dag)
+// NewProviderDataTransfer returns a data transfer manager that transfers data
+// to and from the provider's Staging DAG service
+func NewProviderDataTransfer(lc fx.Lifecycle, h host.Host, dag dtypes.StagingDAG, ds dtypes.MetadataDS) (dtypes.ProviderDataTransfer, error) {
+	channels := statestore.New(namespace.Wrap(ds, datastore.NewKey("/datatransfer/provider/channels")))
+	dtm, err := datatransfer.NewNetworkManager(h, dag, channels)
+	if err != nil {
+		return nil, err
+	}
+
+	lc.Append(fx.Hook{
+		OnStart: dtm.Start,
+		OnStop:  dtm.Stop,
+	})
+
+	return dtm, nil
 }
 
 // NewProviderDealStore creates a statestore for the client to store its deals
@@ -152,6 +227,12 @@ func NewProviderDealStore(ds dtypes.MetadataDS) dtypes.ProviderDealStore {
 	return statestore.New(namespace.Wrap(ds, datastore.NewKey("/deals/client")))
 }
 
+// NewProviderRetrievalStore creates a statestore for the miner to store the
+// retrieval deals it serves
+func NewProviderRetrievalStore(ds dtypes.MetadataDS) dtypes.ProviderRetrievalStore {
+	return statestore.New(namespace.Wrap(ds, datastore.NewKey("/retrievals/provider")))
+}
+
 func StagingDAG(mctx helpers.MetricsCtx, lc fx.Lifecycle, r repo.LockedRepo, rt routing.Routing, h host.Host) (dtypes.StagingDAG, error) {
 	stagingds, err := r.Datastore("/staging")
 	if err != nil {
@@ -0,0 +1,25 @@
+// +build mockproofs
+
+package node_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/lotus/api/test"
+	"github.com/filecoin-project/lotus/lib/sbmock"
+	"github.com/filecoin-project/lotus/lib/sectorbuilder"
+	"github.com/filecoin-project/lotus/node"
+)
+
+// mockSbBuilder builds nodes with storage miners using the mock
+// sectorbuilder, which the chain accepts proofs from in mockproofs builds
+func mockSbBuilder(t *testing.T, nFull int, storage []int) ([]test.TestNode, []test.TestStorageNode) {
+	return buildNodes(t, nFull, storage,
+		node.Override(new(sectorbuilder.Interface), sbmock.NewMockSectorBuilder),
+	)
+}
+
+func TestAPIDealFlowMock(t *testing.T) {
+	test.TestDealFlowPoStRetrieval(t, mockSbBuilder, 50*time.Millisecond)
+}
@@ -0,0 +1,13 @@
+// +build !mockproofs
+
+package node_test
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/lotus/api/test"
+)
+
+func TestAPIDealFlow(t *testing.T) {
+	test.TestDealFlow(t, builder)
+}
@@ -6,6 +6,7 @@ import (
 	"crypto/rand"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/libp2p/go-libp2p-core/crypto"
 
@@ -26,9 +27,10 @@ import (
 	"github.com/filecoin-project/lotus/node/modules"
 	modtest "github.com/filecoin-project/lotus/node/modules/testing"
 	"github.com/filecoin-project/lotus/node/repo"
+	"github.com/filecoin-project/lotus/storage"
 )
 
-func testStorageNode(ctx context.Context, t *testing.T, waddr address.Address, act address.Address, pk crypto.PrivKey, tnd test.TestNode, mn mocknet.Mocknet) test.TestStorageNode {
+func testStorageNode(ctx context.Context, t *testing.T, waddr address.Address, act address.Address, pk crypto.PrivKey, tnd test.TestNode, mn mocknet.Mocknet, opts ...node.Option) test.TestStorageNode {
 	r := repo.NewMemory(nil)
 
 	lr, err := r.Lock(repo.StorageMiner)
@@ -86,6 +88,11 @@ func testStorageNode(ctx context.Context, t *testing.T, waddr address.Address, a
 		node.MockHost(mn),
 
 		node.Override(new(api.FullNode), tnd),
+		node.Override(new(storage.SealingConfig), storage.SealingConfig{
+			WaitDealsDelay: time.Second,
+		}),
+
+		node.Options(opts...),
 	)
 	require.NoError(t, err)
 
@@ -100,6 +107,10 @@ func testStorageNode(ctx context.Context, t *testing.T, waddr address.Address, a
 }
 
 func builder(t *testing.T, nFull int, storage []int) ([]test.TestNode, []test.TestStorageNode) {
+	return buildNodes(t, nFull, storage)
+}
+
+func buildNodes(t *testing.T, nFull int, storage []int, storageOpts ...node.Option) ([]test.TestNode, []test.TestStorageNode) {
 	ctx := context.Background()
 	mn := mocknet.New(ctx)
 
@@ -168,7 +179,7 @@ func builder(t *testing.T, nFull int, storage []int) ([]test.TestNode, []test.Te
 		genMiner, err := address.NewFromString("t0101")
 		require.NoError(t, err)
 
-		storers[i] = testStorageNode(ctx, t, wa, genMiner, pk, f, mn)
+		storers[i] = testStorageNode(ctx, t, wa, genMiner, pk, f, mn, storageOpts...)
 	}
 
 	if err := mn.LinkAll(); err != nil {
@@ -218,7 +229,3 @@ func rpcBuilder(t *testing.T, nFull int, storage []int) ([]test.TestNode, []test
 func TestAPIRPC(t *testing.T) {
 	test.TestApis(t, rpcBuilder)
 }
-
-func TestAPIDealFlow(t *testing.T) {
-	test.TestDealFlow(t, builder)
-}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/libp2p/go-libp2p-core/peer"
 	cbg "github.com/whyrusleeping/cbor-gen"
 	xerrors "golang.org/x/xerrors"
 )
@@ -17,7 +19,7 @@ func (t *RetParams) MarshalCBOR(w io.Writer) error {
 		_, err := w.Write(cbg.CborNull)
 		return err
 	}
-	if _, err := w.Write([]byte{129}); err != nil {
+	if _, err := w.Write([]byte{130}); err != nil {
 		return err
 	}
 
@@ -25,6 +27,11 @@ func (t *RetParams) MarshalCBOR(w io.Writer) error {
 	if err := t.Unixfs0.MarshalCBOR(w); err != nil {
 		return err
 	}
+
+	// t.t.Selector (retrieval.SelectorOffer) (struct)
+	if err := t.Selector.MarshalCBOR(w); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -39,7 +46,7 @@ func (t *RetParams) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("cbor input should be of type array")
 	}
 
-	if extra != 1 {
+	if extra != 2 {
 		return fmt.Errorf("cbor input had wrong number of fields")
 	}
 
@@ -63,6 +70,27 @@ func (t *RetParams) UnmarshalCBOR(r io.Reader) error {
 			}
 		}
 
+	}
+	// t.t.Selector (retrieval.SelectorOffer) (struct)
+
+	{
+
+		pb, err := br.PeekByte()
+		if err != nil {
+			return err
+		}
+		if pb == cbg.CborNull[0] {
+			var nbuf [1]byte
+			if _, err := br.Read(nbuf[:]); err != nil {
+				return err
+			}
+		} else {
+			t.Selector = new(SelectorOffer)
+			if err := t.Selector.UnmarshalCBOR(br); err != nil {
+				return err
+			}
+		}
+
 	}
 	return nil
 }
@@ -120,7 +148,7 @@ func (t *QueryResponse) MarshalCBOR(w io.Writer) error {
 		_, err := w.Write(cbg.CborNull)
 		return err
 	}
-	if _, err := w.Write([]byte{131}); err != nil {
+	if _, err := w.Write([]byte{132}); err != nil {
 		return err
 	}
 
@@ -134,6 +162,11 @@ func (t *QueryResponse) MarshalCBOR(w io.Writer) error {
 		return err
 	}
 
+	// t.t.Ask (types.SignedRetrievalAsk) (struct)
+	if err := t.Ask.MarshalCBOR(w); err != nil {
+		return err
+	}
+
 	// t.t.MinPrice (types.BigInt) (struct)
 	if err := t.MinPrice.MarshalCBOR(w); err != nil {
 		return err
@@ -152,7 +185,7 @@ func (t *QueryResponse) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("cbor input should be of type array")
 	}
 
-	if extra != 3 {
+	if extra != 4 {
 		return fmt.Errorf("cbor input had wrong number of fields")
 	}
 
@@ -176,6 +209,27 @@ func (t *QueryResponse) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("wrong type for uint64 field")
 	}
 	t.Size = uint64(extra)
+	// t.t.Ask (types.SignedRetrievalAsk) (struct)
+
+	{
+
+		pb, err := br.PeekByte()
+		if err != nil {
+			return err
+		}
+		if pb == cbg.CborNull[0] {
+			var nbuf [1]byte
+			if _, err := br.Read(nbuf[:]); err != nil {
+				return err
+			}
+		} else {
+			t.Ask = new(types.SignedRetrievalAsk)
+			if err := t.Ask.UnmarshalCBOR(br); err != nil {
+				return err
+			}
+		}
+
+	}
 	// t.t.MinPrice (types.BigInt) (struct)
 
 	{
@@ -247,6 +301,75 @@ func (t *Unixfs0Offer) UnmarshalCBOR(r io.Reader) error {
 	return nil
 }
 
+func (t *SelectorOffer) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{130}); err != nil {
+		return err
+	}
+
+	// t.t.Selector ([]uint8) (slice)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajByteString, uint64(len(t.Selector)))); err != nil {
+		return err
+	}
+	if _, err := w.Write(t.Selector); err != nil {
+		return err
+	}
+
+	// t.t.Size (uint64) (uint64)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Size))); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *SelectorOffer) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 2 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.t.Selector ([]uint8) (slice)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if extra > 8192 {
+		return fmt.Errorf("t.Selector: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajByteString {
+		return fmt.Errorf("expected byte array")
+	}
+	t.Selector = make([]byte, extra)
+	if _, err := io.ReadFull(br, t.Selector); err != nil {
+		return err
+	}
+	// t.t.Size (uint64) (uint64)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajUnsignedInt {
+		return fmt.Errorf("wrong type for uint64 field")
+	}
+	t.Size = uint64(extra)
+	return nil
+}
+
 func (t *DealProposal) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
@@ -462,3 +585,373 @@ func (t *Block) UnmarshalCBOR(r io.Reader) error {
 	}
 	return nil
 }
+
+func (t *ClientRetrieval) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{139}); err != nil {
+		return err
+	}
+
+	// t.t.ID (uint64) (uint64)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.ID))); err != nil {
+		return err
+	}
+
+	// t.t.State (uint64) (uint64)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.State))); err != nil {
+		return err
+	}
+
+	// t.t.Root (cid.Cid) (struct)
+
+	if err := cbg.WriteCid(w, t.Root); err != nil {
+		return xerrors.Errorf("failed to write cid field t.Root: %w", err)
+	}
+
+	// t.t.Offset (uint64) (uint64)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Offset))); err != nil {
+		return err
+	}
+
+	// t.t.Length (uint64) (uint64)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Length))); err != nil {
+		return err
+	}
+
+	// t.t.Client (address.Address) (struct)
+	if err := t.Client.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.t.Miner (peer.ID) (string)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.Miner)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.Miner)); err != nil {
+		return err
+	}
+
+	// t.t.MinerAddr (address.Address) (struct)
+	if err := t.MinerAddr.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.t.FundsSpent (types.BigInt) (struct)
+	if err := t.FundsSpent.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.t.BytesReceived (uint64) (uint64)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.BytesReceived))); err != nil {
+		return err
+	}
+
+	// t.t.Message (string) (string)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.Message)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.Message)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *ClientRetrieval) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 11 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.t.ID (uint64) (uint64)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajUnsignedInt {
+		return fmt.Errorf("wrong type for uint64 field")
+	}
+	t.ID = uint64(extra)
+	// t.t.State (uint64) (uint64)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajUnsignedInt {
+		return fmt.Errorf("wrong type for uint64 field")
+	}
+	t.State = uint64(extra)
+	// t.t.Root (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.Root: %w", err)
+		}
+
+		t.Root = c
+
+	}
+	// t.t.Offset (uint64) (uint64)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajUnsignedInt {
+		return fmt.Errorf("wrong type for uint64 field")
+	}
+	t.Offset = uint64(extra)
+	// t.t.Length (uint64) (uint64)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajUnsignedInt {
+		return fmt.Errorf("wrong type for uint64 field")
+	}
+	t.Length = uint64(extra)
+	// t.t.Client (address.Address) (struct)
+
+	{
+
+		if err := t.Client.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+	}
+	// t.t.Miner (peer.ID) (string)
+
+	{
+		sval, err := cbg.ReadString(br)
+		if err != nil {
+			return err
+		}
+
+		t.Miner = peer.ID(sval)
+	}
+	// t.t.MinerAddr (address.Address) (struct)
+
+	{
+
+		if err := t.MinerAddr.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+	}
+	// t.t.FundsSpent (types.BigInt) (struct)
+
+	{
+
+		if err := t.FundsSpent.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+	}
+	// t.t.BytesReceived (uint64) (uint64)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajUnsignedInt {
+		return fmt.Errorf("wrong type for uint64 field")
+	}
+	t.BytesReceived = uint64(extra)
+	// t.t.Message (string) (string)
+
+	{
+		sval, err := cbg.ReadString(br)
+		if err != nil {
+			return err
+		}
+
+		t.Message = string(sval)
+	}
+	return nil
+}
+
+func (t *ProviderRetrieval) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{137}); err != nil {
+		return err
+	}
+
+	// t.t.ID (uint64) (uint64)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.ID))); err != nil {
+		return err
+	}
+
+	// t.t.State (uint64) (uint64)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.State))); err != nil {
+		return err
+	}
+
+	// t.t.Root (cid.Cid) (struct)
+
+	if err := cbg.WriteCid(w, t.Root); err != nil {
+		return xerrors.Errorf("failed to write cid field t.Root: %w", err)
+	}
+
+	// t.t.Client (peer.ID) (string)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.Client)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.Client)); err != nil {
+		return err
+	}
+
+	// t.t.Paych (address.Address) (struct)
+	if err := t.Paych.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.t.FundsReceived (types.BigInt) (struct)
+	if err := t.FundsReceived.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.t.UnsealPaid (types.BigInt) (struct)
+	if err := t.UnsealPaid.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.t.BytesSent (uint64) (uint64)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.BytesSent))); err != nil {
+		return err
+	}
+
+	// t.t.Message (string) (string)
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.Message)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.Message)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *ProviderRetrieval) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 9 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.t.ID (uint64) (uint64)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajUnsignedInt {
+		return fmt.Errorf("wrong type for uint64 field")
+	}
+	t.ID = uint64(extra)
+	// t.t.State (uint64) (uint64)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajUnsignedInt {
+		return fmt.Errorf("wrong type for uint64 field")
+	}
+	t.State = uint64(extra)
+	// t.t.Root (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.Root: %w", err)
+		}
+
+		t.Root = c
+
+	}
+	// t.t.Client (peer.ID) (string)
+
+	{
+		sval, err := cbg.ReadString(br)
+		if err != nil {
+			return err
+		}
+
+		t.Client = peer.ID(sval)
+	}
+	// t.t.Paych (address.Address) (struct)
+
+	{
+
+		if err := t.Paych.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+	}
+	// t.t.FundsReceived (types.BigInt) (struct)
+
+	{
+
+		if err := t.FundsReceived.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+	}
+	// t.t.UnsealPaid (types.BigInt) (struct)
+
+	{
+
+		if err := t.UnsealPaid.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+	}
+	// t.t.BytesSent (uint64) (uint64)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajUnsignedInt {
+		return fmt.Errorf("wrong type for uint64 field")
+	}
+	t.BytesSent = uint64(extra)
+	// t.t.Message (string) (string)
+
+	{
+		sval, err := cbg.ReadString(br)
+		if err != nil {
+			return err
+		}
+
+		t.Message = string(sval)
+	}
+	return nil
+}
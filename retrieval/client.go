@@ -1,12 +1,20 @@
 package retrieval
 
 import (
+	"bytes"
 	"context"
 	"io"
+	"sync"
+	"time"
 
 	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-car"
+	carutil "github.com/ipfs/go-car/util"
 	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	ipld "github.com/ipfs/go-ipld-format"
 	logging "github.com/ipfs/go-log"
+	ipldprime "github.com/ipld/go-ipld-prime"
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
@@ -18,22 +26,87 @@ import (
 	"github.com/filecoin-project/lotus/chain/address"
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/lib/cborutil"
+	"github.com/filecoin-project/lotus/lib/ipldutil"
+	"github.com/filecoin-project/lotus/lib/statestore"
+	"github.com/filecoin-project/lotus/node/impl/full"
 	payapi "github.com/filecoin-project/lotus/node/impl/paych"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/paych"
 	"github.com/filecoin-project/lotus/retrieval/discovery"
 )
 
 var log = logging.Logger("retrieval")
 
+// maxRetrievalAttempts is how many times a retrieval is restarted after
+// failing without making any progress
+const maxRetrievalAttempts = 3
+
+// PaymentTerms are the prices and payment intervals quoted in the miner
+// retrieval ask
+type PaymentTerms struct {
+	PricePerByte            types.BigInt
+	UnsealPrice             types.BigInt
+	PaymentInterval         uint64
+	PaymentIntervalIncrease uint64
+}
+
+// cost returns the price of retrieving size bytes in one deal
+func (pt PaymentTerms) cost(size uint64) types.BigInt {
+	return types.BigAdd(pt.UnsealPrice, types.BigMul(pt.PricePerByte, types.NewInt(size)))
+}
+
+type RetrClientApi interface {
+	StateMinerWorker(context.Context, address.Address, *types.TipSet) (address.Address, error)
+}
+
 type Client struct {
 	h host.Host
 
 	pmgr   *paych.Manager
 	payapi payapi.PaychAPI
+	state  RetrClientApi
+
+	// retrieved blocks are kept here, so interrupted retrievals can resume
+	bs dtypes.ClientBlockstore
+
+	retrievals *statestore.StateStore
+	idLk       sync.Mutex
+	nextID     uint64
 }
 
-func NewClient(h host.Host, pmgr *paych.Manager, payapi payapi.PaychAPI) *Client {
-	return &Client{h: h, pmgr: pmgr, payapi: payapi}
+func NewClient(h host.Host, pmgr *paych.Manager, payapi payapi.PaychAPI, stateapi full.StateAPI, bs dtypes.ClientBlockstore, retrievals dtypes.ClientRetrievalStore) (*Client, error) {
+	c := &Client{
+		h:      h,
+		pmgr:   pmgr,
+		payapi: payapi,
+		state:  &stateapi,
+		bs:     bs,
+
+		retrievals: retrievals,
+		nextID:     1,
+	}
+
+	recs, err := c.List()
+	if err != nil {
+		return nil, xerrors.Errorf("listing retrievals: %w", err)
+	}
+
+	for _, r := range recs {
+		if r.ID >= c.nextID {
+			c.nextID = r.ID + 1
+		}
+
+		if r.State == api.RetrievalNew || r.State == api.RetrievalOngoing {
+			err := c.update(r.ID, api.RetrievalFailed, func(r *ClientRetrieval) {
+				r.Message = "interrupted by node shutdown"
+			})
+			if err != nil {
+				return nil, xerrors.Errorf("updating interrupted retrieval %d: %w", r.ID, err)
+			}
+		}
+	}
+
+	return c, nil
 }
 
 func (c *Client) Query(ctx context.Context, p discovery.RetrievalPeer, data cid.Cid) api.QueryOffer {
@@ -58,31 +131,96 @@ func (c *Client) Query(ctx context.Context, p discovery.RetrievalPeer, data cid.
 		return api.QueryOffer{Err: err.Error(), Miner: p.Address, MinerPeerID: p.ID}
 	}
 
-	return api.QueryOffer{
+	offer := api.QueryOffer{
 		Root:        data,
 		Size:        resp.Size,
 		MinPrice:    resp.MinPrice,
 		Miner:       p.Address, // TODO: check
 		MinerPeerID: p.ID,
 	}
+
+	if resp.Status == Available {
+		if err := c.checkAsk(ctx, resp.Ask, p.Address); err != nil {
+			log.Warn(err)
+			offer.Err = err.Error()
+			return offer
+		}
+
+		offer.PricePerByte = resp.Ask.Ask.PricePerByte
+		offer.UnsealPrice = resp.Ask.Ask.UnsealPrice
+		offer.PaymentInterval = resp.Ask.Ask.PaymentInterval
+		offer.PaymentIntervalIncrease = resp.Ask.Ask.PaymentIntervalIncrease
+	}
+
+	return offer
+}
+
+// checkAsk makes sure the retrieval ask in a query response can be used, and
+// was signed by the miner worker
+func (c *Client) checkAsk(ctx context.Context, ask *types.SignedRetrievalAsk, miner address.Address) error {
+	if ask == nil || ask.Ask == nil {
+		return xerrors.New("query response had no retrieval ask")
+	}
+	if ask.Ask.Miner != miner {
+		return xerrors.Errorf("retrieval ask is for miner %s, expected %s", ask.Ask.Miner, miner)
+	}
+	if ask.Ask.Expiry < uint64(time.Now().Unix()) {
+		return xerrors.Errorf("retrieval ask from %s expired", miner)
+	}
+	if ask.Signature == nil {
+		return xerrors.Errorf("retrieval ask from %s wasn't signed", miner)
+	}
+
+	worker, err := c.state.StateMinerWorker(ctx, miner, nil)
+	if err != nil {
+		return xerrors.Errorf("getting worker for miner %s: %w", miner, err)
+	}
+
+	b, err := cborutil.Dump(ask.Ask)
+	if err != nil {
+		return err
+	}
+
+	if err := ask.Signature.Verify(worker, b); err != nil {
+		return xerrors.Errorf("checking retrieval ask signature: %w", err)
+	}
+	return nil
 }
 
 type clientStream struct {
 	payapi payapi.PaychAPI
+	bs     blockstore.Blockstore
 	stream network.Stream
 	peeker cbg.BytePeeker
 
-	root   cid.Cid
-	size   types.BigInt
-	offset uint64
+	id        uint64
+	miner     peer.ID
+	client    address.Address
+	minerAddr address.Address
+	out       io.Writer
+
+	root     cid.Cid
+	selector []byte // set for selector retrievals
+	offset   uint64
+	end      uint64 // for selector retrievals, the most data paid for
 
 	paych       address.Address
 	lane        uint64
 	total       types.BigInt
 	transferred types.BigInt
+	received    uint64 // bytes fetched from the miner
+
+	// the unseal price is paid once per retrieval, with the first accepted
+	// proposal. The payment interval is reset for every deal stream, and
+	// grows after each payment
+	terms      PaymentTerms
+	interval   uint64
+	unsealOwed bool
 
-	windowSize uint64 // how much we "trust" the peer
-	verifier   BlockVerifier
+	verifier BlockVerifier
+
+	// progress is called after each exchange
+	progress func() error
 }
 
 // C > S
@@ -97,79 +235,357 @@ type clientStream struct {
 // < ..Blocks
 // > DealProposal(...)
 // < ...
-func (c *Client) RetrieveUnixfs(ctx context.Context, root cid.Cid, size uint64, total types.BigInt, miner peer.ID, client, minerAddr address.Address, out io.Writer) error {
-	s, err := c.h.NewStream(ctx, miner, ProtocolID)
-	if err != nil {
-		return err
+//
+// When the offset isn't where the previous proposal ended (or this is the
+// first proposal on the stream), the miner starts by sending the intermediate
+// blocks on the path from the root to the block at the offset.
+
+// RetrieveUnixfs writes length bytes of the file at offset to out. A length of
+// 0 retrieves everything after the offset. Data already in the local
+// blockstore, e.g. from an earlier interrupted retrieval, isn't fetched again.
+func (c *Client) RetrieveUnixfs(ctx context.Context, root cid.Cid, size uint64, offset uint64, length uint64, total types.BigInt, terms PaymentTerms, miner peer.ID, client, minerAddr address.Address, out io.Writer) error {
+	if offset > size {
+		return xerrors.Errorf("retrieval offset %d past the end of the file (%d)", offset, size)
+	}
+	if length == 0 {
+		length = size - offset
+	}
+	end := offset + length
+	if end > size {
+		return xerrors.Errorf("retrieval range %d+%d past the end of the file (%d)", offset, length, size)
 	}
-	defer s.Close()
 
-	initialOffset := uint64(0) // TODO: Check how much data we have locally
-	// TODO: Support in handler
-	// TODO: Allow client to specify this
+	start := offset - offset%build.UnixfsChunkSize
 
-	paych, _, err := c.pmgr.GetPaych(ctx, client, minerAddr, total)
-	if err != nil {
-		return xerrors.Errorf("getting payment channel: %w", err)
+	if terms.PaymentInterval < build.UnixfsChunkSize {
+		return xerrors.Errorf("payment interval must be at least one chunk (%d bytes), got %d", build.UnixfsChunkSize, terms.PaymentInterval)
 	}
-	lane, err := c.pmgr.AllocateLane(paych)
+	cost := terms.cost(end - start)
+	if types.BigCmp(cost, total) > 0 {
+		return xerrors.Errorf("retrieval would cost %s, more than the order total %s", cost, total)
+	}
+
+	id, err := c.begin(&ClientRetrieval{
+		State: api.RetrievalNew,
+
+		Root:   root,
+		Offset: offset,
+		Length: length,
+
+		Client:    client,
+		Miner:     miner,
+		MinerAddr: minerAddr,
+
+		FundsSpent: types.NewInt(0),
+	})
 	if err != nil {
-		return xerrors.Errorf("allocating payment lane: %w", err)
+		return xerrors.Errorf("tracking retrieval: %w", err)
 	}
 
-	cst := clientStream{
+	cst := &clientStream{
 		payapi: c.payapi,
-		stream: s,
-		peeker: cbg.GetPeeker(s),
+		bs:     c.bs,
+
+		id:        id,
+		miner:     miner,
+		client:    client,
+		minerAddr: minerAddr,
+		out:       &rangeWriter{w: out, skip: offset - start, left: length},
 
 		root:   root,
-		size:   types.NewInt(size),
-		offset: initialOffset,
+		offset: start,
+		end:    end,
+
+		total:       cost,
+		transferred: types.NewInt(0),
+
+		terms:      terms,
+		unsealOwed: true,
+	}
+
+	return c.run(ctx, cst)
+}
+
+// RetrieveSelector retrieves the blocks of the DAG under root which are
+// visited by the (dag-cbor encoded) selector, and writes them to out as a CAR
+// file. Size is the most block data paid for, usually the size of the data
+// under root.
+func (c *Client) RetrieveSelector(ctx context.Context, root cid.Cid, selector []byte, size uint64, total types.BigInt, terms PaymentTerms, miner peer.ID, client, minerAddr address.Address, out io.Writer) error {
+	if _, err := ipldutil.ParseSelector(selector); err != nil {
+		return err
+	}
+	if size == 0 {
+		return xerrors.New("retrieval size must be set to calculate payments")
+	}
+	if terms.PaymentInterval == 0 {
+		return xerrors.New("payment interval must be set")
+	}
+	cost := terms.cost(size)
+	if types.BigCmp(cost, total) > 0 {
+		return xerrors.Errorf("retrieval would cost %s, more than the order total %s", cost, total)
+	}
+
+	id, err := c.begin(&ClientRetrieval{
+		State: api.RetrievalNew,
+		Root:  root,
 
-		paych:       paych,
-		lane:        lane,
-		total:       total,
+		Client:    client,
+		Miner:     miner,
+		MinerAddr: minerAddr,
+
+		FundsSpent: types.NewInt(0),
+	})
+	if err != nil {
+		return xerrors.Errorf("tracking retrieval: %w", err)
+	}
+
+	cst := &clientStream{
+		payapi: c.payapi,
+		bs:     c.bs,
+
+		id:        id,
+		miner:     miner,
+		client:    client,
+		minerAddr: minerAddr,
+		out:       out,
+
+		root:     root,
+		selector: selector,
+		end:      size,
+
+		total:       cost,
 		transferred: types.NewInt(0),
 
-		windowSize: build.UnixfsChunkSize,
-		verifier:   &UnixFs0Verifier{Root: root},
+		terms:      terms,
+		unsealOwed: true,
 	}
 
-	for cst.offset != size+initialOffset {
-		toFetch := cst.windowSize
-		if toFetch+cst.offset > size {
-			toFetch = size - cst.offset
+	return c.run(ctx, cst)
+}
+
+func (c *Client) List() ([]ClientRetrieval, error) {
+	var out []ClientRetrieval
+	if err := c.retrievals.List(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// readLocal writes the data available in the local blockstore, starting at
+// start, to out. It returns the offset from which data needs to be retrieved.
+func (c *Client) readLocal(root cid.Cid, start, end uint64, out io.Writer) (uint64, error) {
+	cursor := newUnixfsCursor(root, start)
+	at := start
+
+	for !cursor.done() && at < end {
+		blk, err := c.bs.Get(cursor.next().c)
+		if err == blockstore.ErrNotFound {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		nd, err := ipld.Decode(blk)
+		if err != nil {
+			return 0, err
+		}
+
+		internal, data, err := cursor.visit(nd)
+		if err != nil {
+			return 0, err
+		}
+		if internal {
+			continue
+		}
+
+		if _, err := out.Write(data); err != nil {
+			return 0, err
+		}
+		at += uint64(len(data))
+	}
+
+	return at, nil
+}
+
+// retrieve opens a new deal stream, and fetches the remaining data
+func (cst *clientStream) retrieve(ctx context.Context, h host.Host) error {
+	s, err := h.NewStream(ctx, cst.miner, ProtocolID)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	cst.stream = s
+	cst.peeker = cbg.GetPeeker(s)
+	cst.verifier = NewUnixFs0Verifier(cst.root, cst.offset)
+	cst.interval = cst.terms.PaymentInterval
+
+	for cst.offset < cst.end {
+		// the miner sends whole chunks
+		toFetch := cst.interval - cst.interval%build.UnixfsChunkSize
+		if toFetch+cst.offset > cst.end {
+			toFetch = cst.end - cst.offset
 		}
 		log.Infof("Retrieve %dB @%d", toFetch, cst.offset)
 
-		err := cst.doOneExchange(ctx, toFetch, out)
+		err := cst.doOneExchange(ctx, toFetch, cst.out)
 		if err != nil {
 			return xerrors.Errorf("retrieval exchange: %w", err)
 		}
 
 		cst.offset += toFetch
+		cst.received += toFetch
+
+		if cst.progress != nil {
+			if err := cst.progress(); err != nil {
+				return xerrors.Errorf("recording retrieval progress: %w", err)
+			}
+		}
 	}
-	log.Info("RETRIEVE SUCCESSFUL")
+
 	return nil
 }
 
+// retrieveSelector runs the selector traversal, loading blocks from the deal
+// stream. Each block is checked against the link the traversal expects next.
+func (cst *clientStream) retrieveSelector(ctx context.Context, h host.Host) error {
+	sel, err := ipldutil.ParseSelector(cst.selector)
+	if err != nil {
+		return err
+	}
+
+	s, err := h.NewStream(ctx, cst.miner, ProtocolID)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	cst.stream = s
+	cst.peeker = cbg.GetPeeker(s)
+	cst.interval = cst.terms.PaymentInterval
+
+	if err := car.WriteHeader(&car.CarHeader{Roots: []cid.Cid{cst.root}, Version: 1}, cst.out); err != nil {
+		return xerrors.Errorf("writing car header: %w", err)
+	}
+
+	var paid uint64
+	written := cid.NewSet()
+
+	pay := func() error {
+		if paid >= cst.end {
+			return xerrors.Errorf("selected data exceeds the retrieval size of %d bytes", cst.end)
+		}
+
+		toFetch := cst.interval
+		if paid+toFetch > cst.end {
+			toFetch = cst.end - paid
+		}
+
+		err := cst.propose(ctx, toFetch, RetParams{
+			Selector: &SelectorOffer{
+				Selector: cst.selector,
+				Size:     toFetch,
+			},
+		})
+		if err != nil {
+			return xerrors.Errorf("retrieval exchange: %w", err)
+		}
+		paid += toFetch
+		return nil
+	}
+
+	loader := func(lnk ipldprime.Link, _ ipldprime.LinkContext) (io.Reader, error) {
+		expect, err := ipldutil.LinkCid(lnk)
+		if err != nil {
+			return nil, err
+		}
+
+		if paid == 0 {
+			if err := pay(); err != nil {
+				return nil, err
+			}
+		}
+
+		var msg Block
+		for {
+			if err := cborutil.ReadCborRPC(cst.peeker, &msg); err != nil {
+				return nil, xerrors.Errorf("reading block: %w", err)
+			}
+			// a block without a prefix asks for more payment
+			if len(msg.Prefix) > 0 {
+				break
+			}
+			if err := pay(); err != nil {
+				return nil, err
+			}
+		}
+
+		blk, err := msg.toBlock()
+		if err != nil {
+			return nil, err
+		}
+		if !blk.Cid().Equals(expect) {
+			return nil, xerrors.Errorf("unexpected block: want %s, got %s", expect, blk.Cid())
+		}
+
+		if err := cst.bs.Put(blk); err != nil {
+			return nil, xerrors.Errorf("storing retrieved block: %w", err)
+		}
+		if written.Visit(expect) {
+			if err := carutil.LdWrite(cst.out, expect.Bytes(), blk.RawData()); err != nil {
+				return nil, xerrors.Errorf("writing block %s: %w", expect, err)
+			}
+		}
+
+		cst.received += uint64(len(blk.RawData()))
+		if cst.progress != nil {
+			if err := cst.progress(); err != nil {
+				return nil, xerrors.Errorf("recording retrieval progress: %w", err)
+			}
+		}
+
+		return bytes.NewReader(blk.RawData()), nil
+	}
+
+	return ipldutil.WalkSelector(ctx, cst.root, sel, loader)
+}
+
 func (cst *clientStream) doOneExchange(ctx context.Context, toFetch uint64, out io.Writer) error {
-	payAmount := types.BigDiv(types.BigMul(cst.total, types.NewInt(toFetch)), cst.size)
+	err := cst.propose(ctx, toFetch, RetParams{
+		Unixfs0: &Unixfs0Offer{
+			Offset: cst.offset,
+			Size:   toFetch,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info("Retrieval accepted, fetching blocks")
+
+	return cst.fetchBlocks(toFetch, out)
+}
+
+// propose sends a deal proposal paying for toFetch bytes, and waits for it to
+// be accepted
+func (cst *clientStream) propose(ctx context.Context, toFetch uint64, params RetParams) error {
+	payAmount := types.BigMul(cst.terms.PricePerByte, types.NewInt(toFetch))
+	if cst.unsealOwed {
+		payAmount = types.BigAdd(payAmount, cst.terms.UnsealPrice)
+	}
 
 	payment, err := cst.setupPayment(ctx, payAmount)
 	if err != nil {
 		return xerrors.Errorf("setting up retrieval payment: %w", err)
 	}
+	// vouchers are cumulative, so every later voucher pays for the unseal too
+	cst.unsealOwed = false
 
 	deal := &DealProposal{
 		Payment: payment,
 		Ref:     cst.root,
-		Params: RetParams{
-			Unixfs0: &Unixfs0Offer{
-				Offset: cst.offset,
-				Size:   toFetch,
-			},
-		},
+		Params:  params,
 	}
 
 	if err := cborutil.WriteCborRPC(cst.stream, deal); err != nil {
@@ -183,7 +599,6 @@ func (cst *clientStream) doOneExchange(ctx context.Context, toFetch uint64, out
 	}
 
 	if resp.Status != Accepted {
-		cst.windowSize = build.UnixfsChunkSize
 		// TODO: apply some 'penalty' to miner 'reputation' (needs to be the same in both cases)
 
 		if resp.Status == Error {
@@ -195,11 +610,9 @@ func (cst *clientStream) doOneExchange(ctx context.Context, toFetch uint64, out
 		return xerrors.New("storage deal response had no Accepted section")
 	}
 
-	log.Info("Retrieval accepted, fetching blocks")
-
-	return cst.fetchBlocks(toFetch, out)
+	cst.interval += cst.terms.PaymentIntervalIncrease
 
-	// TODO: maybe increase miner window size after success
+	return nil
 }
 
 func (cst *clientStream) fetchBlocks(toFetch uint64, out io.Writer) error {
@@ -225,14 +638,7 @@ func (cst *clientStream) fetchBlocks(toFetch uint64, out io.Writer) error {
 }
 
 func (cst *clientStream) consumeBlockMessage(block Block, out io.Writer) (uint64, error) {
-	prefix, err := cid.PrefixFromBytes(block.Prefix)
-	if err != nil {
-		return 0, err
-	}
-
-	cid, err := prefix.Sum(block.Data)
-
-	blk, err := blocks.NewBlockWithCid(block.Data, cid)
+	blk, err := block.toBlock()
 	if err != nil {
 		return 0, err
 	}
@@ -243,8 +649,9 @@ func (cst *clientStream) consumeBlockMessage(block Block, out io.Writer) (uint64
 		return 0, err
 	}
 
-	// TODO: Smarter out, maybe add to filestore automagically
-	//  (Also, persist intermediate nodes)
+	if err := cst.bs.Put(blk); err != nil {
+		return 0, xerrors.Errorf("storing retrieved block: %w", err)
+	}
 
 	if internal {
 		return 0, nil
@@ -253,6 +660,20 @@ func (cst *clientStream) consumeBlockMessage(block Block, out io.Writer) (uint64
 	return 1, nil
 }
 
+func (b *Block) toBlock() (blocks.Block, error) {
+	prefix, err := cid.PrefixFromBytes(b.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := prefix.Sum(b.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	return blocks.NewBlockWithCid(b.Data, c)
+}
+
 func (cst *clientStream) setupPayment(ctx context.Context, toSend types.BigInt) (api.PaymentInfo, error) {
 	amount := types.BigAdd(cst.transferred, toSend)
 
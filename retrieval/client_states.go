@@ -0,0 +1,143 @@
+package retrieval
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+type clientHandlerFunc func(ctx context.Context, cst *clientStream) (api.RetrievalState, error)
+
+// run moves the retrieval through its states until it completes or fails
+func (c *Client) run(ctx context.Context, cst *clientStream) error {
+	state := api.RetrievalNew
+
+	for state != api.RetrievalComplete {
+		var handler clientHandlerFunc
+		switch state {
+		case api.RetrievalNew:
+			handler = c.setup
+		case api.RetrievalOngoing:
+			handler = c.transfer
+		default:
+			return xerrors.Errorf("retrieval %d in unexpected state %s", cst.id, api.RetrievalStates[state])
+		}
+
+		next, err := handler(ctx, cst)
+		if err != nil {
+			log.Errorf("retrieval %d failed: %s", cst.id, err)
+			uerr := c.update(cst.id, api.RetrievalFailed, func(r *ClientRetrieval) {
+				r.Message = err.Error()
+			})
+			if uerr != nil {
+				log.Errorf("recording retrieval %d failure: %s", cst.id, uerr)
+			}
+			return err
+		}
+
+		if err := c.update(cst.id, next, nil); err != nil {
+			return xerrors.Errorf("updating retrieval state: %w", err)
+		}
+		state = next
+	}
+
+	log.Info("RETRIEVE SUCCESSFUL")
+	return nil
+}
+
+// setup writes the data already available locally, and prepares payment for
+// the rest
+func (c *Client) setup(ctx context.Context, cst *clientStream) (api.RetrievalState, error) {
+	var err error
+
+	if cst.selector == nil {
+		start := cst.offset
+
+		cst.offset, err = c.readLocal(cst.root, start, cst.end, cst.out)
+		if err != nil {
+			return 0, xerrors.Errorf("reading local data: %w", err)
+		}
+		if cst.offset >= cst.end {
+			log.Infof("retrieval of %s served from the local blockstore", cst.root)
+			return api.RetrievalComplete, nil
+		}
+		if cst.offset > start {
+			log.Infof("resuming retrieval of %s @%d", cst.root, cst.offset)
+		}
+	}
+
+	cst.paych, _, err = c.pmgr.GetPaych(ctx, cst.client, cst.minerAddr, cst.total)
+	if err != nil {
+		return 0, xerrors.Errorf("getting payment channel: %w", err)
+	}
+	cst.lane, err = c.pmgr.AllocateLane(cst.paych)
+	if err != nil {
+		return 0, xerrors.Errorf("allocating payment lane: %w", err)
+	}
+
+	return api.RetrievalOngoing, nil
+}
+
+// transfer fetches the data from the miner, reconnecting if the stream fails
+// after making progress
+func (c *Client) transfer(ctx context.Context, cst *clientStream) (api.RetrievalState, error) {
+	cst.progress = func() error {
+		return c.update(cst.id, api.RetrievalOngoing, func(r *ClientRetrieval) {
+			r.FundsSpent = cst.transferred
+			r.BytesReceived = cst.received
+		})
+	}
+
+	if cst.selector != nil {
+		// selector traversals always start from the root, so there is
+		// nothing to resume
+		if err := cst.retrieveSelector(ctx, c.h); err != nil {
+			return 0, err
+		}
+		return api.RetrievalComplete, nil
+	}
+
+	failures := 0
+	for {
+		before := cst.offset
+
+		err := cst.retrieve(ctx, c.h)
+		if err == nil {
+			return api.RetrievalComplete, nil
+		}
+
+		if cst.offset > before {
+			failures = 0
+		}
+		failures++
+		if failures >= maxRetrievalAttempts || ctx.Err() != nil {
+			return 0, err
+		}
+
+		log.Warnf("retrieval of %s interrupted @%d, resuming: %s", cst.root, cst.offset, err)
+	}
+}
+
+func (c *Client) begin(r *ClientRetrieval) (uint64, error) {
+	c.idLk.Lock()
+	r.ID = c.nextID
+	c.nextID++
+	c.idLk.Unlock()
+
+	if err := c.retrievals.Begin(r.ID, r); err != nil {
+		return 0, err
+	}
+	return r.ID, nil
+}
+
+func (c *Client) update(id uint64, state api.RetrievalState, mut func(*ClientRetrieval)) error {
+	return c.retrievals.Mutate(id, func(r *ClientRetrieval) error {
+		r.State = state
+		if mut != nil {
+			mut(r)
+		}
+		return nil
+	})
+}
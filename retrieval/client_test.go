@@ -0,0 +1,121 @@
+package retrieval
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/address"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/wallet"
+	"github.com/filecoin-project/lotus/lib/cborutil"
+	"github.com/filecoin-project/lotus/lib/statestore"
+	"github.com/filecoin-project/lotus/node/impl/full"
+	payapi "github.com/filecoin-project/lotus/node/impl/paych"
+)
+
+func TestClientRetrievalRecords(t *testing.T) {
+	ctx := context.Background()
+	data, bs, root := testFile(t, 5*testChunkSize)
+	store := statestore.New(datastore.NewMapDatastore())
+
+	client, err := address.NewIDAddress(100)
+	require.NoError(t, err)
+	miner, err := address.NewIDAddress(101)
+	require.NoError(t, err)
+
+	c, err := NewClient(nil, nil, payapi.PaychAPI{}, full.StateAPI{}, bs, store)
+	require.NoError(t, err)
+
+	// everything is available locally, so nothing is fetched or paid for
+	var out bytes.Buffer
+	terms := PaymentTerms{
+		PricePerByte:    types.NewInt(0),
+		UnsealPrice:     types.NewInt(0),
+		PaymentInterval: build.UnixfsChunkSize,
+	}
+	err = c.RetrieveUnixfs(ctx, root, uint64(len(data)), 1000, 2000, types.NewInt(0), terms, "", client, miner, &out)
+	require.NoError(t, err)
+	assert.Equal(t, data[1000:3000], out.Bytes())
+
+	recs, err := c.List()
+	require.NoError(t, err)
+	require.Len(t, recs, 1)
+	assert.Equal(t, api.RetrievalComplete, recs[0].State)
+	assert.Equal(t, uint64(1000), recs[0].Offset)
+	assert.Equal(t, uint64(2000), recs[0].Length)
+	assert.Equal(t, uint64(0), recs[0].BytesReceived)
+
+	// retrievals which were running when the node stopped are marked failed
+	require.NoError(t, c.update(recs[0].ID, api.RetrievalOngoing, nil))
+
+	c, err = NewClient(nil, nil, payapi.PaychAPI{}, full.StateAPI{}, bs, store)
+	require.NoError(t, err)
+
+	recs, err = c.List()
+	require.NoError(t, err)
+	require.Len(t, recs, 1)
+	assert.Equal(t, api.RetrievalFailed, recs[0].State)
+	assert.NotEmpty(t, recs[0].Message)
+	assert.Equal(t, recs[0].ID+1, c.nextID)
+}
+
+type testClientApi struct {
+	worker address.Address
+}
+
+func (ta *testClientApi) StateMinerWorker(context.Context, address.Address, *types.TipSet) (address.Address, error) {
+	return ta.worker, nil
+}
+
+func TestCheckAsk(t *testing.T) {
+	ctx := context.Background()
+
+	w, err := wallet.NewWallet(wallet.NewMemKeyStore())
+	require.NoError(t, err)
+	worker, err := w.GenerateKey(types.KTSecp256k1)
+	require.NoError(t, err)
+	other, err := w.GenerateKey(types.KTSecp256k1)
+	require.NoError(t, err)
+
+	miner, err := address.NewIDAddress(101)
+	require.NoError(t, err)
+
+	sign := func(a *types.RetrievalAsk, signer address.Address) *types.SignedRetrievalAsk {
+		b, err := cborutil.Dump(a)
+		require.NoError(t, err)
+		sig, err := w.Sign(ctx, signer, b)
+		require.NoError(t, err)
+		return &types.SignedRetrievalAsk{Ask: a, Signature: sig}
+	}
+
+	ask := &types.RetrievalAsk{
+		Miner:        miner,
+		PricePerByte: types.NewInt(2),
+		UnsealPrice:  types.NewInt(100),
+		Expiry:       uint64(time.Now().Add(time.Hour).Unix()),
+	}
+
+	c := &Client{state: &testClientApi{worker: worker}}
+
+	assert.NoError(t, c.checkAsk(ctx, sign(ask, worker), miner))
+
+	// signed by someone other than the worker
+	assert.Error(t, c.checkAsk(ctx, sign(ask, other), miner))
+
+	// changed after signing
+	sask := sign(ask, worker)
+	changed := *ask
+	changed.UnsealPrice = types.NewInt(0)
+	sask.Ask = &changed
+	assert.Error(t, c.checkAsk(ctx, sask, miner))
+
+	assert.Error(t, c.checkAsk(ctx, &types.SignedRetrievalAsk{Ask: ask}, miner))
+}
@@ -1,13 +1,17 @@
 package retrieval
 
 import (
+	"bytes"
 	"context"
 	"io"
+	"sync"
 
 	"github.com/ipfs/go-blockservice"
 	"github.com/ipfs/go-cid"
+	datastore "github.com/ipfs/go-datastore"
+	ipld "github.com/ipfs/go-ipld-format"
 	"github.com/ipfs/go-merkledag"
-	unixfile "github.com/ipfs/go-unixfs/file"
+	ipldprime "github.com/ipld/go-ipld-prime"
 	"github.com/libp2p/go-libp2p-core/network"
 	"golang.org/x/xerrors"
 
@@ -16,28 +20,136 @@ import (
 	"github.com/filecoin-project/lotus/chain/address"
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/lib/cborutil"
+	"github.com/filecoin-project/lotus/lib/ipldutil"
+	"github.com/filecoin-project/lotus/lib/statestore"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/storage/sectorblocks"
 )
 
 type RetrMinerApi interface {
 	PaychVoucherAdd(context.Context, address.Address, *types.SignedVoucher, []byte, types.BigInt) (types.BigInt, error)
+	StateMinerWorker(context.Context, address.Address, *types.TipSet) (address.Address, error)
+	WalletSign(context.Context, address.Address, []byte) (*types.Signature, error)
 }
 
 type Miner struct {
 	sectorBlocks *sectorblocks.SectorBlocks
 	full         RetrMinerApi
+	ds           dtypes.MetadataDS
+	actor        address.Address
 
-	pricePerByte types.BigInt
-	// TODO: Unseal price
+	askLk sync.Mutex
+	ask   *types.SignedRetrievalAsk
+
+	retrievals *statestore.StateStore
+	idLk       sync.Mutex
+	nextID     uint64
 }
 
-func NewMiner(sblks *sectorblocks.SectorBlocks, full api.FullNode) *Miner {
-	return &Miner{
+func NewMiner(sblks *sectorblocks.SectorBlocks, full api.FullNode, ds dtypes.MetadataDS, retrievals dtypes.ProviderRetrievalStore) (*Miner, error) {
+	addr, err := ds.Get(datastore.NewKey("miner-address"))
+	if err != nil {
+		return nil, err
+	}
+	minerAddress, err := address.NewFromBytes(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Miner{
 		sectorBlocks: sblks,
 		full:         full,
+		ds:           ds,
+		actor:        minerAddress,
+
+		retrievals: retrievals,
+		nextID:     1,
+	}
+
+	if err := m.tryLoadAsk(); err != nil {
+		return nil, err
+	}
+
+	if m.ask == nil {
+		if err := m.SetAsk(types.NewInt(2), types.NewInt(0), build.UnixfsChunkSize, build.UnixfsChunkSize, 1000000); err != nil {
+			return nil, xerrors.Errorf("failed setting a default retrieval ask: %w", err)
+		}
+	}
+
+	recs, err := m.List()
+	if err != nil {
+		return nil, xerrors.Errorf("listing retrieval deals: %w", err)
+	}
+
+	for _, r := range recs {
+		if r.ID >= m.nextID {
+			m.nextID = r.ID + 1
+		}
+
+		if r.State == api.RetrievalOngoing {
+			err := m.update(r.ID, api.RetrievalFailed, func(r *ProviderRetrieval) {
+				r.Message = "interrupted by node shutdown"
+			})
+			if err != nil {
+				return nil, xerrors.Errorf("updating interrupted retrieval deal %d: %w", r.ID, err)
+			}
+		}
+	}
+
+	return m, nil
+}
+
+func (m *Miner) List() ([]ProviderRetrieval, error) {
+	var out []ProviderRetrieval
+	if err := m.retrievals.List(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (m *Miner) begin(r *ProviderRetrieval) (uint64, error) {
+	m.idLk.Lock()
+	r.ID = m.nextID
+	m.nextID++
+	m.idLk.Unlock()
+
+	if err := m.retrievals.Begin(r.ID, r); err != nil {
+		return 0, err
+	}
+	return r.ID, nil
+}
+
+func (m *Miner) update(id uint64, state api.RetrievalState, mut func(*ProviderRetrieval)) error {
+	return m.retrievals.Mutate(id, func(r *ProviderRetrieval) error {
+		r.State = state
+		if mut != nil {
+			mut(r)
+		}
+		return nil
+	})
+}
+
+// unsealOwed returns the unseal price owed by a new deal stream. Clients pay it
+// once per retrieval, so a stream resuming a retrieval which already paid at
+// least the current unseal price over the same payment channel doesn't owe it
+// again.
+func (m *Miner) unsealOwed(ask *types.RetrievalAsk, paych address.Address, root cid.Cid) (types.BigInt, error) {
+	recs, err := m.List()
+	if err != nil {
+		return types.BigInt{}, xerrors.Errorf("listing retrieval deals: %w", err)
+	}
 
-		pricePerByte: types.NewInt(2), // TODO: allow setting
+	for _, r := range recs {
+		if r.Paych != paych || !r.Root.Equals(root) || r.UnsealPaid.Nil() {
+			continue
+		}
+
+		if types.BigCmp(r.UnsealPaid, ask.UnsealPrice) >= 0 {
+			return types.NewInt(0), nil
+		}
 	}
+
+	return ask.UnsealPrice, nil
 }
 
 func writeErr(stream network.Stream, err error) {
@@ -67,10 +179,13 @@ func (m *Miner) HandleQueryStream(stream network.Stream) {
 		Status: Unavailable,
 	}
 	if err == nil {
+		ask := m.GetAsk()
+
 		answer.Status = Available
+		answer.Ask = ask
 
-		// TODO: get price, look for already unsealed ref to reduce work
-		answer.MinPrice = types.BigMul(types.NewInt(uint64(size)), m.pricePerByte)
+		// TODO: look for already unsealed ref to reduce work
+		answer.MinPrice = types.BigAdd(ask.Ask.UnsealPrice, types.BigMul(types.NewInt(uint64(size)), ask.Ask.PricePerByte))
 		answer.Size = uint64(size) // TODO: verify on intermediate
 	}
 
@@ -84,10 +199,23 @@ type handlerDeal struct {
 	m      *Miner
 	stream network.Stream
 
-	ufsr sectorblocks.UnixfsReader
-	open cid.Cid
-	at   uint64
-	size uint64
+	id uint64 // retrieval deal record, 0 until the first proposal is read
+
+	// ask is the retrieval ask in effect when the deal started. The client
+	// must have paid for everything sent so far, and may only ask for up to
+	// interval bytes at a time. Unseal is the unseal price owed on this
+	// stream, zero when it was paid earlier in the retrieval
+	ask      *types.RetrievalAsk
+	unseal   types.BigInt
+	owed     types.BigInt
+	received types.BigInt
+	interval uint64
+
+	ds     ipld.DAGService
+	cursor *unixfsCursor
+	open   cid.Cid
+	at     uint64
+	size   uint64
 }
 
 func (m *Miner) HandleDealStream(stream network.Stream) {
@@ -106,10 +234,32 @@ func (m *Miner) HandleDealStream(stream network.Stream) {
 		more, err = hnd.handleNext() // TODO: 'more' bool
 		if err != nil {
 			writeErr(stream, err)
-			return
+			break
 		}
 	}
 
+	hnd.finish(err)
+}
+
+// finish records the outcome of the deal stream
+func (hnd *handlerDeal) finish(err error) {
+	if hnd.id == 0 {
+		return
+	}
+
+	state := api.RetrievalComplete
+	var msg string
+	if err != nil {
+		state = api.RetrievalFailed
+		msg = err.Error()
+	}
+
+	uerr := hnd.m.update(hnd.id, state, func(r *ProviderRetrieval) {
+		r.Message = msg
+	})
+	if uerr != nil {
+		log.Errorf("recording retrieval deal %d outcome: %s", hnd.id, uerr)
+	}
 }
 
 func (hnd *handlerDeal) handleNext() (bool, error) {
@@ -121,19 +271,47 @@ func (hnd *handlerDeal) handleNext() (bool, error) {
 		return false, err
 	}
 
-	if deal.Params.Unixfs0 == nil {
+	if deal.Params.Unixfs0 == nil && deal.Params.Selector == nil {
 		return false, xerrors.New("unknown deal type")
 	}
 
-	unixfs0 := deal.Params.Unixfs0
+	if hnd.id == 0 {
+		hnd.ask = hnd.m.GetAsk().Ask
 
-	if len(deal.Payment.Vouchers) != 1 {
-		return false, xerrors.Errorf("expected one signed voucher, got %d", len(deal.Payment.Vouchers))
+		var err error
+		hnd.unseal, err = hnd.m.unsealOwed(hnd.ask, deal.Payment.Channel, deal.Ref)
+		if err != nil {
+			return false, err
+		}
+		hnd.owed = hnd.unseal
+
+		hnd.id, err = hnd.m.begin(&ProviderRetrieval{
+			State:  api.RetrievalOngoing,
+			Root:   deal.Ref,
+			Client: hnd.stream.Conn().RemotePeer(),
+
+			Paych:         deal.Payment.Channel,
+			FundsReceived: types.NewInt(0),
+			UnsealPaid:    types.NewInt(0),
+		})
+		if err != nil {
+			return false, xerrors.Errorf("tracking retrieval deal: %w", err)
+		}
+
+		hnd.received = types.NewInt(0)
+		hnd.interval = hnd.ask.PaymentInterval
+	}
+
+	if deal.Params.Selector != nil {
+		// the whole traversal is served from here, further proposals are
+		// read as more data is paid for
+		return false, hnd.serveSelector(deal)
 	}
 
-	expPayment := types.BigMul(hnd.m.pricePerByte, types.NewInt(deal.Params.Unixfs0.Size))
-	if _, err := hnd.m.full.PaychVoucherAdd(context.TODO(), deal.Payment.Channel, deal.Payment.Vouchers[0], nil, expPayment); err != nil {
-		return false, xerrors.Errorf("processing retrieval payment: %w", err)
+	unixfs0 := deal.Params.Unixfs0
+
+	if err := hnd.processPayment(deal, unixfs0.Size); err != nil {
+		return false, err
 	}
 
 	// If the file isn't open (new deal stream), isn't the right file, or isn't
@@ -149,49 +327,188 @@ func (hnd *handlerDeal) handleNext() (bool, error) {
 		return false, xerrors.Errorf("tried to read too much %d+%d > %d", unixfs0.Offset, unixfs0.Size, hnd.size)
 	}
 
-	err := hnd.accept(deal)
-	if err != nil {
+	if err := hnd.accept(deal); err != nil {
 		return false, err
 	}
+
+	if err := hnd.recordSent(unixfs0.Size); err != nil {
+		return false, err
+	}
+
 	return true, nil
 }
 
-func (hnd *handlerDeal) openFile(deal DealProposal) error {
-	unixfs0 := deal.Params.Unixfs0
+// processPayment checks that the deal pays for size more bytes, on top of
+// everything sent before. The unseal price is owed from the first proposal of
+// a retrieval.
+func (hnd *handlerDeal) processPayment(deal DealProposal, size uint64) error {
+	if size > hnd.interval {
+		return xerrors.Errorf("proposal for %d bytes exceeds the payment interval of %d bytes", size, hnd.interval)
+	}
 
-	if unixfs0.Offset != 0 {
-		// TODO: Implement SeekBlock (like ReadBlock) in go-unixfs
-		return xerrors.New("sending merkle proofs for nonzero offset not supported yet")
+	if len(deal.Payment.Vouchers) != 1 {
+		return xerrors.Errorf("expected one signed voucher, got %d", len(deal.Payment.Vouchers))
+	}
+
+	hnd.owed = types.BigAdd(hnd.owed, types.BigMul(hnd.ask.PricePerByte, types.NewInt(size)))
+
+	expPayment := types.NewInt(0)
+	if types.BigCmp(hnd.owed, hnd.received) > 0 {
+		expPayment = types.BigSub(hnd.owed, hnd.received)
 	}
-	hnd.at = unixfs0.Offset
 
-	bstore := hnd.m.sectorBlocks.SealedBlockstore(func() error {
-		return nil // TODO: approve unsealing based on amount paid
+	received, err := hnd.m.full.PaychVoucherAdd(context.TODO(), deal.Payment.Channel, deal.Payment.Vouchers[0], nil, expPayment)
+	if err != nil {
+		return xerrors.Errorf("processing retrieval payment: %w", err)
+	}
+
+	hnd.received = types.BigAdd(hnd.received, received)
+	if types.BigCmp(hnd.received, hnd.owed) < 0 {
+		return xerrors.Errorf("payment behind: received %s, owed %s", hnd.received, hnd.owed)
+	}
+
+	hnd.interval += hnd.ask.PaymentIntervalIncrease
+
+	err = hnd.m.update(hnd.id, api.RetrievalOngoing, func(r *ProviderRetrieval) {
+		r.FundsReceived = types.BigAdd(r.FundsReceived, received)
+		// the unseal price is owed from the first payment, so it's covered now
+		r.UnsealPaid = hnd.unseal
 	})
+	if err != nil {
+		return xerrors.Errorf("recording retrieval payment: %w", err)
+	}
 
-	ds := merkledag.NewDAGService(blockservice.New(bstore, nil))
-	rootNd, err := ds.Get(context.TODO(), deal.Ref)
+	return nil
+}
+
+func (hnd *handlerDeal) recordSent(size uint64) error {
+	err := hnd.m.update(hnd.id, api.RetrievalOngoing, func(r *ProviderRetrieval) {
+		r.BytesSent += size
+	})
 	if err != nil {
-		return err
+		return xerrors.Errorf("recording retrieval deal progress: %w", err)
 	}
+	return nil
+}
 
-	fsr, err := unixfile.NewUnixfsFile(context.TODO(), ds, rootNd)
+// serveSelector sends the blocks visited by the selector, in traversal order.
+// When the next block doesn't fit in the data paid for, the client is asked for
+// more payment, and the block is only sent once enough was accepted.
+func (hnd *handlerDeal) serveSelector(deal DealProposal) error {
+	sel, err := ipldutil.ParseSelector(deal.Params.Selector.Selector)
 	if err != nil {
 		return err
 	}
 
-	var ok bool
-	hnd.ufsr, ok = fsr.(sectorblocks.UnixfsReader)
-	if !ok {
-		return xerrors.Errorf("file %s didn't implement sectorblocks.UnixfsReader", deal.Ref)
+	bstore := hnd.m.sectorBlocks.SealedBlockstore(hnd.approveUnseal)
+
+	var paid, sent uint64
+
+	acceptNext := func(next DealProposal) error {
+		if next.Ref != deal.Ref || next.Params.Selector == nil || !bytes.Equal(next.Params.Selector.Selector, deal.Params.Selector.Selector) {
+			return xerrors.New("proposal doesn't continue the selector retrieval")
+		}
+		if next.Params.Selector.Size == 0 {
+			return xerrors.New("proposal doesn't pay for any data")
+		}
+
+		if err := hnd.processPayment(next, next.Params.Selector.Size); err != nil {
+			return err
+		}
+		paid += next.Params.Selector.Size
+
+		return cborutil.WriteCborRPC(hnd.stream, &DealResponse{
+			Status: Accepted,
+		})
+	}
+
+	if err := acceptNext(deal); err != nil {
+		return err
 	}
 
-	isize, err := hnd.ufsr.Size()
+	loader := func(lnk ipldprime.Link, _ ipldprime.LinkContext) (io.Reader, error) {
+		c, err := ipldutil.LinkCid(lnk)
+		if err != nil {
+			return nil, err
+		}
+
+		blk, err := bstore.Get(c)
+		if err != nil {
+			return nil, xerrors.Errorf("getting block %s: %w", c, err)
+		}
+
+		for sent+uint64(len(blk.RawData())) > paid {
+			if err := cborutil.WriteCborRPC(hnd.stream, &Block{}); err != nil {
+				return nil, xerrors.Errorf("requesting payment: %w", err)
+			}
+
+			var next DealProposal
+			if err := cborutil.ReadCborRPC(hnd.stream, &next); err != nil {
+				return nil, xerrors.Errorf("reading next deal proposal: %w", err)
+			}
+			if err := acceptNext(next); err != nil {
+				return nil, err
+			}
+		}
+
+		log.Infof("sending block for a deal: %s", c)
+
+		err = cborutil.WriteCborRPC(hnd.stream, &Block{
+			Prefix: c.Prefix().Bytes(),
+			Data:   blk.RawData(),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		sent += uint64(len(blk.RawData()))
+		if err := hnd.recordSent(uint64(len(blk.RawData()))); err != nil {
+			return nil, err
+		}
+
+		return bytes.NewReader(blk.RawData()), nil
+	}
+
+	return ipldutil.WalkSelector(context.TODO(), deal.Ref, sel, loader)
+}
+
+// approveUnseal only allows unsealing the piece once the unseal price has
+// been paid
+func (hnd *handlerDeal) approveUnseal() error {
+	if types.BigCmp(hnd.received, hnd.unseal) < 0 {
+		return xerrors.Errorf("unsealing requires a payment of %s, received %s", hnd.unseal, hnd.received)
+	}
+	return nil
+}
+
+func (hnd *handlerDeal) openFile(deal DealProposal) error {
+	unixfs0 := deal.Params.Unixfs0
+
+	if unixfs0.Offset%build.UnixfsChunkSize != 0 {
+		return xerrors.Errorf("offset %d not aligned to chunk size", unixfs0.Offset)
+	}
+
+	bstore := hnd.m.sectorBlocks.SealedBlockstore(hnd.approveUnseal)
+
+	hnd.ds = merkledag.NewDAGService(blockservice.New(bstore, nil))
+	rootNd, err := hnd.ds.Get(context.TODO(), deal.Ref)
 	if err != nil {
 		return err
 	}
-	hnd.size = uint64(isize)
 
+	hnd.size, err = unixfsFileSize(rootNd)
+	if err != nil {
+		return xerrors.Errorf("getting size of %s: %w", deal.Ref, err)
+	}
+
+	if unixfs0.Offset > hnd.size {
+		return xerrors.Errorf("offset %d past the end of the file (%d)", unixfs0.Offset, hnd.size)
+	}
+
+	// blocks on the path to the first leaf are sent first, as the proof for
+	// the data at the offset
+	hnd.cursor = newUnixfsCursor(deal.Ref, unixfs0.Offset)
+	hnd.at = unixfs0.Offset
 	hnd.open = deal.Ref
 
 	return nil
@@ -210,22 +527,27 @@ func (hnd *handlerDeal) accept(deal DealProposal) error {
 
 	blocksToSend := (unixfs0.Size + build.UnixfsChunkSize - 1) / build.UnixfsChunkSize
 	for i := uint64(0); i < blocksToSend; {
-		data, offset, nd, err := hnd.ufsr.ReadBlock(context.TODO())
+		if hnd.cursor.done() {
+			return xerrors.Errorf("no more blocks to send (%d of %d sent)", i, blocksToSend)
+		}
+
+		offset := hnd.cursor.next().offset
+		nd, err := hnd.ds.Get(context.TODO(), hnd.cursor.next().c)
+		if err != nil {
+			return err
+		}
+
+		internal, data, err := hnd.cursor.visit(nd)
 		if err != nil {
 			return err
 		}
 
 		log.Infof("sending block for a deal: %s", nd.Cid())
 
-		if offset != unixfs0.Offset {
-			return xerrors.Errorf("ReadBlock on wrong offset: want %d, got %d", unixfs0.Offset, offset)
+		if !internal && offset != hnd.at {
+			return xerrors.Errorf("read block at wrong offset: want %d, got %d", hnd.at, offset)
 		}
 
-		/*if uint64(len(data)) != deal.Unixfs0.Size { // TODO: Fix for internal nodes (and any other node too)
-			writeErr(stream, xerrors.Errorf("ReadBlock data with wrong size: want %d, got %d", deal.Unixfs0.Size, len(data)))
-			return
-		}*/
-
 		block := &Block{
 			Prefix: nd.Cid().Prefix().Bytes(),
 			Data:   nd.RawData(),
@@ -235,7 +557,7 @@ func (hnd *handlerDeal) accept(deal DealProposal) error {
 			return err
 		}
 
-		if len(data) > 0 { // don't count internal nodes
+		if !internal { // don't count internal nodes
 			hnd.at += uint64(len(data))
 			i++
 		}
@@ -0,0 +1,117 @@
+package retrieval
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	datastore "github.com/ipfs/go-datastore"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/cborutil"
+)
+
+var retrievalAskKey = datastore.NewKey("retrieval-ask")
+
+// SetAsk signs and stores a new retrieval ask, which is quoted in queries and
+// enforced for new retrieval deals
+func (m *Miner) SetAsk(price, unsealPrice types.BigInt, paymentInterval, paymentIntervalIncrease uint64, ttlsecs int64) error {
+	if paymentInterval < build.UnixfsChunkSize {
+		return xerrors.Errorf("payment interval must be at least one chunk (%d bytes), got %d", build.UnixfsChunkSize, paymentInterval)
+	}
+
+	m.askLk.Lock()
+	defer m.askLk.Unlock()
+
+	var seqno uint64
+	if m.ask != nil {
+		seqno = m.ask.Ask.SeqNo + 1
+	}
+
+	now := time.Now().Unix()
+	ask := &types.RetrievalAsk{
+		PricePerByte:            price,
+		UnsealPrice:             unsealPrice,
+		PaymentInterval:         paymentInterval,
+		PaymentIntervalIncrease: paymentIntervalIncrease,
+
+		Miner:     m.actor,
+		Timestamp: uint64(now),
+		Expiry:    uint64(now + ttlsecs),
+		SeqNo:     seqno,
+	}
+
+	ssa, err := m.signAsk(ask)
+	if err != nil {
+		return err
+	}
+
+	return m.saveAsk(ssa)
+}
+
+// GetAsk returns the current retrieval ask of the miner
+func (m *Miner) GetAsk() *types.SignedRetrievalAsk {
+	m.askLk.Lock()
+	defer m.askLk.Unlock()
+
+	return m.ask
+}
+
+func (m *Miner) tryLoadAsk() error {
+	m.askLk.Lock()
+	defer m.askLk.Unlock()
+
+	askb, err := m.ds.Get(retrievalAskKey)
+	if err == datastore.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return xerrors.Errorf("failed to load retrieval ask from disk: %w", err)
+	}
+
+	var ssa types.SignedRetrievalAsk
+	if err := cborutil.ReadCborRPC(bytes.NewReader(askb), &ssa); err != nil {
+		return err
+	}
+
+	m.ask = &ssa
+	return nil
+}
+
+func (m *Miner) signAsk(a *types.RetrievalAsk) (*types.SignedRetrievalAsk, error) {
+	b, err := cborutil.Dump(a)
+	if err != nil {
+		return nil, err
+	}
+
+	worker, err := m.full.StateMinerWorker(context.TODO(), m.actor, nil)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get worker to sign ask: %w", err)
+	}
+
+	sig, err := m.full.WalletSign(context.TODO(), worker, b)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.SignedRetrievalAsk{
+		Ask:       a,
+		Signature: sig,
+	}, nil
+}
+
+func (m *Miner) saveAsk(a *types.SignedRetrievalAsk) error {
+	b, err := cborutil.Dump(a)
+	if err != nil {
+		return err
+	}
+
+	if err := m.ds.Put(retrievalAskKey, b); err != nil {
+		return err
+	}
+
+	m.ask = a
+	return nil
+}
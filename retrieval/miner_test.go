@@ -0,0 +1,173 @@
+package retrieval
+
+import (
+	"context"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/address"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/statestore"
+)
+
+// testMinerApi accepts any voucher, and returns the amount added since the
+// previous one
+type testMinerApi struct {
+	last types.BigInt
+}
+
+func (ta *testMinerApi) PaychVoucherAdd(ctx context.Context, ch address.Address, sv *types.SignedVoucher, proof []byte, minDelta types.BigInt) (types.BigInt, error) {
+	delta := types.BigSub(sv.Amount, ta.last)
+	ta.last = sv.Amount
+	return delta, nil
+}
+
+func (ta *testMinerApi) StateMinerWorker(context.Context, address.Address, *types.TipSet) (address.Address, error) {
+	return address.Undef, nil
+}
+
+func (ta *testMinerApi) WalletSign(context.Context, address.Address, []byte) (*types.Signature, error) {
+	return &types.Signature{}, nil
+}
+
+func TestProcessPayment(t *testing.T) {
+	m := &Miner{
+		full:       &testMinerApi{last: types.NewInt(0)},
+		retrievals: statestore.New(datastore.NewMapDatastore()),
+		nextID:     1,
+	}
+
+	paych, err := address.NewIDAddress(100)
+	require.NoError(t, err)
+
+	id, err := m.begin(&ProviderRetrieval{
+		State:         api.RetrievalOngoing,
+		Root:          blocks.NewBlock([]byte("test")).Cid(),
+		Paych:         paych,
+		FundsReceived: types.NewInt(0),
+	})
+	require.NoError(t, err)
+
+	ask := &types.RetrievalAsk{
+		PricePerByte:            types.NewInt(2),
+		UnsealPrice:             types.NewInt(100),
+		PaymentInterval:         1000,
+		PaymentIntervalIncrease: 500,
+	}
+	hnd := &handlerDeal{
+		m:  m,
+		id: id,
+
+		ask:      ask,
+		unseal:   ask.UnsealPrice,
+		owed:     ask.UnsealPrice,
+		received: types.NewInt(0),
+		interval: ask.PaymentInterval,
+	}
+
+	pay := func(amount uint64) DealProposal {
+		return DealProposal{
+			Payment: api.PaymentInfo{
+				Vouchers: []*types.SignedVoucher{{Amount: types.NewInt(amount)}},
+			},
+		}
+	}
+
+	// nothing can be unsealed before the unseal price is paid
+	assert.Error(t, hnd.approveUnseal())
+
+	require.NoError(t, hnd.processPayment(pay(100+2000), 1000))
+	assert.NoError(t, hnd.approveUnseal())
+	assert.Equal(t, uint64(1500), hnd.interval)
+
+	// more than the payment interval
+	assert.Error(t, hnd.processPayment(pay(100+2000+3200), 1600))
+
+	recs, err := m.List()
+	require.NoError(t, err)
+	require.Len(t, recs, 1)
+	assert.Equal(t, types.NewInt(2100), recs[0].FundsReceived)
+
+	// vouchers falling behind the data sent stop the deal
+	assert.Error(t, hnd.processPayment(pay(100+2000+2000), 1500))
+}
+
+func TestUnsealPaidOnce(t *testing.T) {
+	m := &Miner{
+		retrievals: statestore.New(datastore.NewMapDatastore()),
+		nextID:     1,
+	}
+
+	paych, err := address.NewIDAddress(100)
+	require.NoError(t, err)
+	other, err := address.NewIDAddress(101)
+	require.NoError(t, err)
+	root := blocks.NewBlock([]byte("test")).Cid()
+
+	ask := &types.RetrievalAsk{UnsealPrice: types.NewInt(100)}
+
+	owed, err := m.unsealOwed(ask, paych, root)
+	require.NoError(t, err)
+	assert.Equal(t, ask.UnsealPrice, owed)
+
+	// a stream which failed before any payment doesn't count
+	_, err = m.begin(&ProviderRetrieval{
+		State:         api.RetrievalFailed,
+		Root:          root,
+		Paych:         paych,
+		FundsReceived: types.NewInt(0),
+		UnsealPaid:    types.NewInt(0),
+	})
+	require.NoError(t, err)
+
+	owed, err = m.unsealOwed(ask, paych, root)
+	require.NoError(t, err)
+	assert.Equal(t, ask.UnsealPrice, owed)
+
+	// neither does one which paid a lower unseal price than the current one
+	_, err = m.begin(&ProviderRetrieval{
+		State:         api.RetrievalFailed,
+		Root:          root,
+		Paych:         paych,
+		FundsReceived: types.NewInt(300),
+		UnsealPaid:    types.NewInt(50),
+	})
+	require.NoError(t, err)
+
+	owed, err = m.unsealOwed(ask, paych, root)
+	require.NoError(t, err)
+	assert.Equal(t, ask.UnsealPrice, owed)
+
+	_, err = m.begin(&ProviderRetrieval{
+		State:         api.RetrievalFailed,
+		Root:          root,
+		Paych:         paych,
+		FundsReceived: types.NewInt(300),
+		UnsealPaid:    types.NewInt(100),
+	})
+	require.NoError(t, err)
+
+	// resuming over the same channel doesn't pay again
+	owed, err = m.unsealOwed(ask, paych, root)
+	require.NoError(t, err)
+	assert.Equal(t, types.NewInt(0), owed)
+
+	hnd := &handlerDeal{m: m, ask: ask, unseal: owed, received: types.NewInt(0)}
+	assert.NoError(t, hnd.approveUnseal())
+
+	// other clients still do
+	owed, err = m.unsealOwed(ask, other, root)
+	require.NoError(t, err)
+	assert.Equal(t, ask.UnsealPrice, owed)
+
+	// and so does the client once the unseal price went up
+	ask = &types.RetrievalAsk{UnsealPrice: types.NewInt(150)}
+	owed, err = m.unsealOwed(ask, paych, root)
+	require.NoError(t, err)
+	assert.Equal(t, ask.UnsealPrice, owed)
+}
@@ -0,0 +1,57 @@
+package retrieval
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/encoding/dagjson"
+	ipldfree "github.com/ipld/go-ipld-prime/impl/free"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/lib/ipldutil"
+)
+
+// selects everything under the root
+const exploreAll = `{"R":{"d":100,":>":{"a":{">":{"@":{}}}}}}`
+
+func TestWalkSelector(t *testing.T) {
+	ctx := context.Background()
+	_, bs, root := testFile(t, 20*testChunkSize+100)
+
+	nd, err := dagjson.Decoder(ipldfree.NodeBuilder(), strings.NewReader(exploreAll))
+	require.NoError(t, err)
+	enc, err := ipldutil.EncodeNode(nd)
+	require.NoError(t, err)
+	sel, err := ipldutil.ParseSelector(enc)
+	require.NoError(t, err)
+
+	var loaded []cid.Cid
+	loader := func(lnk ipld.Link, _ ipld.LinkContext) (io.Reader, error) {
+		c, err := ipldutil.LinkCid(lnk)
+		if err != nil {
+			return nil, err
+		}
+		blk, err := bs.Get(c)
+		if err != nil {
+			return nil, err
+		}
+		loaded = append(loaded, c)
+		return bytes.NewReader(blk.RawData()), nil
+	}
+
+	require.NoError(t, ipldutil.WalkSelector(ctx, root, sel, loader))
+
+	// the whole file is selected, so blocks are visited in the same order as
+	// in unixfs retrievals
+	var expect []cid.Cid
+	for _, blk := range sendBlocks(t, bs, root, 0) {
+		expect = append(expect, blk.Cid())
+	}
+	assert.Equal(t, expect, loaded)
+}
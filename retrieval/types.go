@@ -3,7 +3,9 @@ package retrieval
 import (
 	"github.com/filecoin-project/lotus/api"
 	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
 
+	"github.com/filecoin-project/lotus/chain/address"
 	"github.com/filecoin-project/lotus/chain/types"
 )
 
@@ -33,10 +35,11 @@ type QueryResponse struct {
 	Status QueryResponseStatus
 
 	Size uint64 // TODO: spec
-	// TODO: unseal price (+spec)
+	// Ask holds the prices and payment intervals the miner expects
+	Ask *types.SignedRetrievalAsk
 	// TODO: sectors to unseal
 	// TODO: address to send money for the deal?
-	MinPrice types.BigInt
+	MinPrice types.BigInt // unseal price + price of the whole piece
 }
 
 type Unixfs0Offer struct {
@@ -44,8 +47,17 @@ type Unixfs0Offer struct {
 	Size   uint64
 }
 
+// SelectorOffer is a retrieval of the blocks of the DAG under the deal Ref
+// which are visited by an IPLD selector. Blocks are sent in traversal order,
+// Size is the number of bytes of block data paid for with the proposal.
+type SelectorOffer struct {
+	Selector []byte // dag-cbor encoded
+	Size     uint64
+}
+
 type RetParams struct {
-	Unixfs0 *Unixfs0Offer
+	Unixfs0  *Unixfs0Offer
+	Selector *SelectorOffer
 }
 
 type DealProposal struct {
@@ -60,7 +72,46 @@ type DealResponse struct {
 	Message string
 }
 
+// Block carries a block of the retrieved data. In selector retrievals a Block
+// without a Prefix asks the client to pay for more data, the next block doesn't
+// fit in what was paid for so far.
 type Block struct { // TODO: put in spec
 	Prefix []byte // TODO: fix cid.Prefix marshaling somehow
 	Data   []byte
 }
+
+// ClientRetrieval is the client record of a retrieval
+type ClientRetrieval struct {
+	ID    uint64
+	State api.RetrievalState
+
+	Root   cid.Cid
+	Offset uint64
+	Length uint64
+
+	Client    address.Address
+	Miner     peer.ID
+	MinerAddr address.Address
+
+	FundsSpent    types.BigInt
+	BytesReceived uint64
+
+	Message string
+}
+
+// ProviderRetrieval is the miner record of a retrieval deal stream
+type ProviderRetrieval struct {
+	ID     uint64
+	State  api.RetrievalState
+	Root   cid.Cid
+	Client peer.ID
+
+	Paych         address.Address
+	FundsReceived types.BigInt
+	// UnsealPaid is the unseal price paid by the stream, it's zero when the
+	// unseal price was paid by an earlier stream
+	UnsealPaid types.BigInt
+	BytesSent  uint64
+
+	Message string
+}
@@ -0,0 +1,150 @@
+package retrieval
+
+import (
+	"io"
+
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/go-merkledag"
+	"github.com/ipfs/go-unixfs"
+	pb "github.com/ipfs/go-unixfs/pb"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/build"
+)
+
+type cursorEntry struct {
+	c      cid.Cid
+	offset uint64 // offset of the first byte under the node in the file
+}
+
+// unixfsCursor walks the blocks of a unixfs file which hold the data starting
+// at a given offset, in the order in which they are sent in retrieval deals.
+// Subtrees which only contain data before the offset are skipped, so when
+// starting at a nonzero offset, the internal nodes on the path from the root
+// to the first leaf are the merkle proof for the data which follows.
+type unixfsCursor struct {
+	start uint64
+	stack []cursorEntry
+}
+
+func newUnixfsCursor(root cid.Cid, start uint64) *unixfsCursor {
+	return &unixfsCursor{
+		start: start,
+		stack: []cursorEntry{{c: root}},
+	}
+}
+
+func (uc *unixfsCursor) done() bool {
+	return len(uc.stack) == 0
+}
+
+// next returns the node which should be visited next
+func (uc *unixfsCursor) next() cursorEntry {
+	return uc.stack[len(uc.stack)-1]
+}
+
+// visit checks the node returned by next, and queues its children. Data is
+// returned for leaf nodes
+func (uc *unixfsCursor) visit(nd ipld.Node) (internal bool, data []byte, err error) {
+	if uc.done() {
+		return false, nil, xerrors.New("unixfs cursor: no more nodes expected")
+	}
+
+	at := uc.next()
+	if !at.c.Equals(nd.Cid()) {
+		return false, nil, xerrors.Errorf("unixfs cursor: unexpected node: want %s, got %s", at.c, nd.Cid())
+	}
+	uc.stack = uc.stack[:len(uc.stack)-1]
+
+	// TODO: check size
+	switch nd := nd.(type) {
+	case *merkledag.ProtoNode:
+		fsn, err := unixfs.FSNodeFromBytes(nd.Data())
+		if err != nil {
+			return false, nil, xerrors.Errorf("unixfs.FSNodeFromBytes failed: %w", err)
+		}
+		if fsn.Type() != pb.Data_File {
+			return false, nil, xerrors.New("internal nodes must be a file")
+		}
+		if len(fsn.Data()) > 0 {
+			return false, nil, xerrors.New("internal node with data")
+		}
+		links := nd.Links()
+		if len(links) == 0 {
+			return false, nil, xerrors.New("internal node with no links")
+		}
+		if len(links) > build.UnixfsLinksPerLevel {
+			return false, nil, xerrors.New("too many links in intermediate node")
+		}
+		if fsn.NumChildren() != len(links) {
+			return false, nil, xerrors.Errorf("internal node has %d links, but %d block sizes", len(links), fsn.NumChildren())
+		}
+
+		children := make([]cursorEntry, 0, len(links))
+		offset := at.offset
+		for i, l := range links {
+			end := offset + fsn.BlockSize(i)
+			if end > uc.start {
+				children = append(children, cursorEntry{c: l.Cid, offset: offset})
+			}
+			offset = end
+		}
+
+		for i := len(children) - 1; i >= 0; i-- {
+			uc.stack = append(uc.stack, children[i])
+		}
+
+		return true, nil, nil
+	case *merkledag.RawNode:
+		return false, nd.RawData(), nil
+	default:
+		return false, nil, xerrors.New("unixfs cursor: unknown node type")
+	}
+}
+
+func unixfsFileSize(nd ipld.Node) (uint64, error) {
+	switch nd := nd.(type) {
+	case *merkledag.ProtoNode:
+		fsn, err := unixfs.FSNodeFromBytes(nd.Data())
+		if err != nil {
+			return 0, err
+		}
+		return fsn.FileSize(), nil
+	case *merkledag.RawNode:
+		return uint64(len(nd.RawData())), nil
+	default:
+		return 0, xerrors.New("unknown node type")
+	}
+}
+
+// rangeWriter discards the first skip bytes written to it, and anything
+// written after the following left bytes
+type rangeWriter struct {
+	w    io.Writer
+	skip uint64
+	left uint64
+}
+
+func (rw *rangeWriter) Write(p []byte) (int, error) {
+	n := len(p)
+
+	if rw.skip > 0 {
+		if uint64(len(p)) <= rw.skip {
+			rw.skip -= uint64(len(p))
+			return n, nil
+		}
+		p = p[rw.skip:]
+		rw.skip = 0
+	}
+
+	if uint64(len(p)) > rw.left {
+		p = p[:rw.left]
+	}
+	rw.left -= uint64(len(p))
+
+	if _, err := rw.w.Write(p); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,111 @@
+package retrieval
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	chunker "github.com/ipfs/go-ipfs-chunker"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/go-merkledag"
+	"github.com/ipfs/go-unixfs/importer/balanced"
+	ihelper "github.com/ipfs/go-unixfs/importer/helpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testChunkSize = 1024
+
+func testFile(t *testing.T, size int) ([]byte, blockstore.Blockstore, cid.Cid) {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(5)).Read(data)
+
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	params := ihelper.DagBuilderParams{
+		Maxlinks:  4, // make sure there are a few levels
+		RawLeaves: true,
+		Dagserv:   merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs))),
+	}
+
+	db, err := params.New(chunker.NewSizeSplitter(bytes.NewReader(data), testChunkSize))
+	require.NoError(t, err)
+	nd, err := balanced.Layout(db)
+	require.NoError(t, err)
+
+	return data, bs, nd.Cid()
+}
+
+// sendBlocks returns the blocks the miner sends for a retrieval from offset
+func sendBlocks(t *testing.T, bs blockstore.Blockstore, root cid.Cid, offset uint64) []blocks.Block {
+	var out []blocks.Block
+
+	cursor := newUnixfsCursor(root, offset)
+	for !cursor.done() {
+		blk, err := bs.Get(cursor.next().c)
+		require.NoError(t, err)
+
+		nd, err := ipld.Decode(blk)
+		require.NoError(t, err)
+
+		_, _, err = cursor.visit(nd)
+		require.NoError(t, err)
+
+		out = append(out, blk)
+	}
+
+	return out
+}
+
+func TestUnixFs0VerifierOffset(t *testing.T) {
+	ctx := context.Background()
+	data, bs, root := testFile(t, 20*testChunkSize+100)
+
+	for _, offset := range []uint64{0, 7 * testChunkSize, 20 * testChunkSize} {
+		var out bytes.Buffer
+		v := NewUnixFs0Verifier(root, offset)
+		for _, blk := range sendBlocks(t, bs, root, offset) {
+			_, err := v.Verify(ctx, blk, &out)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, data[offset:], out.Bytes(), "offset %d", offset)
+	}
+
+	// blocks past the offset can't be sent without the proof
+	blks := sendBlocks(t, bs, root, 7*testChunkSize)
+	_, err := NewUnixFs0Verifier(root, 7*testChunkSize).Verify(ctx, blks[len(blks)-1], &bytes.Buffer{})
+	assert.Error(t, err)
+}
+
+func TestReadLocal(t *testing.T) {
+	data, bs, root := testFile(t, 20*testChunkSize+100)
+	c := &Client{bs: bs}
+
+	var out bytes.Buffer
+	at, err := c.readLocal(root, 0, uint64(len(data)), &out)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(len(data)), at)
+	assert.Equal(t, data, out.Bytes())
+
+	// drop a leaf, reading should stop right before it
+	blks := sendBlocks(t, bs, root, 9*testChunkSize)
+	for _, blk := range blks {
+		if blk.Cid().Prefix().Codec == cid.Raw {
+			require.NoError(t, bs.DeleteBlock(blk.Cid()))
+			break
+		}
+	}
+
+	out.Reset()
+	rw := &rangeWriter{w: &out, skip: 100, left: 5000}
+	at, err = c.readLocal(root, 2*testChunkSize, uint64(len(data)), rw)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(9*testChunkSize), at)
+	assert.Equal(t, data[2*testChunkSize+100:2*testChunkSize+5100], out.Bytes())
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// expirationWindow is how long before the on-chain expiration sectors are
+// moved to the Expiring state, giving the miner a chance to extend them
+const expirationWindow = build.ProvingPeriodDuration
+
+const expirationConfidence = 3
+
+// proving schedules moving the sector to Expiring shortly before it expires
+func (m *Miner) proving(ctx context.Context, sector SectorInfo) (func(*SectorInfo), error) {
+	exp, err := m.api.StateMinerSectorExpiration(ctx, m.maddr, sector.SectorID, nil)
+	if err != nil {
+		return nil, xerrors.Errorf("getting sector expiration: %w", err)
+	}
+
+	if exp == 0 {
+		// sectors without deals don't expire
+		return nil, nil
+	}
+
+	at := uint64(0)
+	if exp > expirationWindow {
+		at = exp - expirationWindow
+	}
+
+	return nil, m.updateSectorAt(sector.SectorID, api.Expiring, at)
+}
+
+// expiring checks whether the sector was extended, or removed from the chain,
+// and if neither happened yet, checks again later. Expired sectors are only
+// removed from the sector set when the next PoSt is submitted.
+func (m *Miner) expiring(ctx context.Context, sector SectorInfo) (func(*SectorInfo), error) {
+	head, err := m.api.ChainHead(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	sset, err := m.api.StateMinerSectors(ctx, m.maddr, head)
+	if err != nil {
+		return nil, xerrors.Errorf("getting miner sector set: %w", err)
+	}
+
+	var onChain bool
+	for _, s := range sset {
+		if s.SectorID == sector.SectorID {
+			onChain = true
+			break
+		}
+	}
+
+	if !onChain {
+		log.Infof("sector %d expired", sector.SectorID)
+		m.updateSector(sector.SectorID, api.Expired)
+		return nil, nil
+	}
+
+	exp, err := m.api.StateMinerSectorExpiration(ctx, m.maddr, sector.SectorID, head)
+	if err != nil {
+		return nil, xerrors.Errorf("getting sector expiration: %w", err)
+	}
+
+	if exp == 0 || exp > head.Height()+expirationWindow {
+		log.Infof("sector %d expiration was extended to %d", sector.SectorID, exp)
+		m.updateSector(sector.SectorID, api.Proving)
+		return nil, nil
+	}
+
+	check := head.Height()
+	if exp > check {
+		check = exp
+	}
+
+	return nil, m.updateSectorAt(sector.SectorID, api.Expiring, check+build.ProvingPeriodDuration)
+}
+
+func (m *Miner) expired(ctx context.Context, sector SectorInfo) (func(*SectorInfo), error) {
+	if err := m.sb.RemoveSector(sector.SectorID); err != nil {
+		return nil, xerrors.Errorf("removing sector files: %w", err)
+	}
+
+	log.Infof("removed files of expired sector %d", sector.SectorID)
+	return nil, nil
+}
+
+func (m *Miner) updateSector(id uint64, state api.SectorState) {
+	select {
+	case m.sectorUpdated <- sectorUpdate{
+		newState: state,
+		id:       id,
+	}:
+	case <-m.stop:
+	}
+}
+
+// updateSectorAt moves the sector to the given state once the chain reaches
+// the given height
+func (m *Miner) updateSectorAt(id uint64, state api.SectorState, height uint64) error {
+	err := m.events.ChainAt(func(ctx context.Context, ts *types.TipSet, curH uint64) error {
+		m.updateSector(id, state)
+		return nil
+	}, func(ctx context.Context, ts *types.TipSet) error {
+		log.Warnf("revert while waiting to move sector %d to %s", id, api.SectorStateStr(state))
+		return nil
+	}, expirationConfidence, height)
+	if err != nil {
+		return xerrors.Errorf("scheduling sector %d update: %w", id, err)
+	}
+
+	return nil
+}
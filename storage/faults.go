@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// faultCheckInterval is how often sectors in the proving set are checked
+// for faults, in addition to the check done at the start of each proving
+// period
+const faultCheckInterval = 10 * build.BlockDelay * time.Second
+
+func (m *Miner) faultLoop(ctx context.Context) {
+	t := time.NewTicker(faultCheckInterval)
+	defer t.Stop()
+
+	for {
+		if err := m.checkFaults(ctx); err != nil {
+			log.Errorf("checking sector faults: %+v", err)
+		}
+
+		select {
+		case <-t.C:
+		case <-m.faultCheck:
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// triggerFaultCheck requests a fault check without waiting for it
+func (m *Miner) triggerFaultCheck() {
+	select {
+	case m.faultCheck <- struct{}{}:
+	default:
+	}
+}
+
+// faultDeclaration is a DeclareFaults message which wasn't found on chain
+// yet. It's given up on at the end of the proving period it was sent in, after
+// which the sectors are declared again.
+type faultDeclaration struct {
+	msg      cid.Cid
+	sectors  []uint64
+	deadline uint64
+}
+
+// checkFaults checks that the files of all sectors in the proving set are
+// accessible, and declares faults for the ones which aren't, and weren't
+// declared yet. It doesn't wait for the declarations to land on chain, they
+// are looked up by the following checks.
+func (m *Miner) checkFaults(ctx context.Context) error {
+	ts, err := m.api.ChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	pset, err := m.api.StateMinerProvingSet(ctx, m.maddr, ts)
+	if err != nil {
+		return xerrors.Errorf("getting proving set: %w", err)
+	}
+
+	onChain, err := m.api.StateMinerFaults(ctx, m.maddr, ts)
+	if err != nil {
+		return xerrors.Errorf("getting declared faults: %w", err)
+	}
+
+	declared := map[uint64]bool{}
+	for _, id := range append(onChain.Current, onChain.Next...) {
+		declared[id] = true
+	}
+
+	pending, err := m.checkFaultDeclarations(ctx, ts, declared)
+	if err != nil {
+		return err
+	}
+
+	faults := map[uint64]*api.SectorFault{}
+	var undeclared []uint64
+	for _, sector := range pset {
+		if err := m.sb.CheckSector(sector.SectorID); err != nil {
+			if !m.isFaulty(sector.SectorID) {
+				log.Warnf("sector %d is faulty: %s", sector.SectorID, err)
+			}
+
+			faults[sector.SectorID] = &api.SectorFault{
+				SectorID: sector.SectorID,
+				Err:      err.Error(),
+				Declared: declared[sector.SectorID],
+			}
+			if !declared[sector.SectorID] && !pending[sector.SectorID] {
+				undeclared = append(undeclared, sector.SectorID)
+			}
+		}
+	}
+
+	m.faultsLk.Lock()
+	m.faults = faults
+	m.faultsLk.Unlock()
+
+	if len(undeclared) == 0 {
+		return nil
+	}
+
+	if ts.Height() < build.UpgradeDeclareFaultsHeight {
+		log.Warnf("not declaring faults for sectors %v before height %d", undeclared, build.UpgradeDeclareFaultsHeight)
+		return nil
+	}
+
+	deadline, err := m.api.StateMinerProvingPeriodEnd(ctx, m.maddr, ts)
+	if err != nil {
+		return xerrors.Errorf("getting proving period end: %w", err)
+	}
+
+	mcid, err := m.declareFaults(ctx, undeclared)
+	if err != nil {
+		return xerrors.Errorf("declaring faults: %w", err)
+	}
+
+	m.faultDecls = append(m.faultDecls, &faultDeclaration{
+		msg:      mcid,
+		sectors:  undeclared,
+		deadline: deadline,
+	})
+
+	return nil
+}
+
+// checkFaultDeclarations looks up the pending DeclareFaults messages, marking
+// the sectors of the executed ones as declared. It returns the sectors of the
+// declarations which are still in flight.
+func (m *Miner) checkFaultDeclarations(ctx context.Context, ts *types.TipSet, declared map[uint64]bool) (map[uint64]bool, error) {
+	pending := map[uint64]bool{}
+	var keep []*faultDeclaration
+
+	for _, decl := range m.faultDecls {
+		wait, err := m.api.StateSearchMsg(ctx, decl.msg)
+		if err != nil {
+			return nil, xerrors.Errorf("looking up DeclareFaults message %s: %w", decl.msg, err)
+		}
+
+		switch {
+		case wait != nil && wait.Receipt.ExitCode != 0:
+			log.Errorf("DeclareFaults message %s failed (exit code %d), declaring again", decl.msg, wait.Receipt.ExitCode)
+		case wait != nil:
+			for _, id := range decl.sectors {
+				declared[id] = true
+			}
+		case ts.Height() >= decl.deadline:
+			log.Warnf("DeclareFaults message %s didn't land before the end of the proving period (%d), declaring again", decl.msg, decl.deadline)
+		default:
+			for _, id := range decl.sectors {
+				pending[id] = true
+			}
+			keep = append(keep, decl)
+		}
+	}
+
+	m.faultDecls = keep
+	return pending, nil
+}
+
+func (m *Miner) isFaulty(sectorID uint64) bool {
+	m.faultsLk.Lock()
+	defer m.faultsLk.Unlock()
+
+	_, ok := m.faults[sectorID]
+	return ok
+}
+
+func (m *Miner) declareFaults(ctx context.Context, faults []uint64) (cid.Cid, error) {
+	params := &actors.DeclareFaultsParams{
+		Faults: types.BitFieldFromSet(faults),
+	}
+
+	enc, aerr := actors.SerializeParams(params)
+	if aerr != nil {
+		return cid.Undef, xerrors.Errorf("could not serialize declare faults parameters: %w", aerr)
+	}
+
+	msg := &types.Message{
+		To:     m.maddr,
+		From:   m.worker,
+		Method: actors.MAMethods.DeclareFaults,
+		Params: enc,
+		Value:  types.NewInt(0),
+	}
+
+	// gas is estimated by MpoolPushMessage
+	smsg, err := m.api.MpoolPushMessage(ctx, msg)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("pushing message to mpool: %w", err)
+	}
+
+	log.Warnf("declaring faults for sectors %v (msg %s)", faults, smsg.Cid())
+
+	return smsg.Cid(), nil
+}
+
+// Faults returns the faulty sectors found by the last fault check
+func (m *Miner) Faults() []api.SectorFault {
+	m.faultsLk.Lock()
+	defer m.faultsLk.Unlock()
+
+	out := make([]api.SectorFault, 0, len(m.faults))
+	for _, f := range m.faults {
+		out = append(out, *f)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].SectorID < out[j].SectorID
+	})
+
+	return out
+}
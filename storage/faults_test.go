@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/address"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/sectorbuilder"
+)
+
+// faultsTestApi doesn't implement StateWaitMsg, checking faults must not wait
+// for the declarations to land
+type faultsTestApi struct {
+	storageMinerApi
+
+	t *testing.T
+
+	height    uint64
+	periodEnd uint64
+	pset      []uint64
+	onChain   []uint64
+
+	pushed []*types.SignedMessage
+	landed map[cid.Cid]*api.MsgWait
+}
+
+func (ta *faultsTestApi) ChainHead(context.Context) (*types.TipSet, error) {
+	dummyCid, _ := cid.Parse("bafkqaaa")
+	a, _ := address.NewFromString("t00")
+
+	return types.NewTipSet([]*types.BlockHeader{{
+		Miner:                 a,
+		Height:                ta.height,
+		ParentStateRoot:       dummyCid,
+		Messages:              dummyCid,
+		ParentMessageReceipts: dummyCid,
+		BlockSig:              types.Signature{Type: types.KTBLS},
+		BLSAggregate:          types.Signature{Type: types.KTBLS},
+	}})
+}
+
+func (ta *faultsTestApi) StateMinerProvingSet(context.Context, address.Address, *types.TipSet) ([]*api.ChainSectorInfo, error) {
+	out := make([]*api.ChainSectorInfo, len(ta.pset))
+	for i, id := range ta.pset {
+		out[i] = &api.ChainSectorInfo{SectorID: id}
+	}
+	return out, nil
+}
+
+func (ta *faultsTestApi) StateMinerFaults(context.Context, address.Address, *types.TipSet) (*api.MinerFaults, error) {
+	return &api.MinerFaults{Current: ta.onChain}, nil
+}
+
+func (ta *faultsTestApi) StateMinerProvingPeriodEnd(context.Context, address.Address, *types.TipSet) (uint64, error) {
+	return ta.periodEnd, nil
+}
+
+func (ta *faultsTestApi) MpoolPushMessage(ctx context.Context, msg *types.Message) (*types.SignedMessage, error) {
+	msg.Nonce = uint64(len(ta.pushed))
+	smsg := &types.SignedMessage{
+		Message:   *msg,
+		Signature: types.Signature{Type: types.KTBLS},
+	}
+	ta.pushed = append(ta.pushed, smsg)
+	return smsg, nil
+}
+
+func (ta *faultsTestApi) StateSearchMsg(ctx context.Context, c cid.Cid) (*api.MsgWait, error) {
+	return ta.landed[c], nil
+}
+
+// land executes the last pushed message, applying the declared faults
+func (ta *faultsTestApi) land(exit uint8) {
+	smsg := ta.pushed[len(ta.pushed)-1]
+	ta.landed[smsg.Cid()] = &api.MsgWait{Receipt: types.MessageReceipt{ExitCode: exit}}
+
+	if exit == 0 {
+		ta.onChain = append(ta.onChain, ta.declared(len(ta.pushed)-1)...)
+	}
+}
+
+// declared returns the sectors declared by the i-th pushed message
+func (ta *faultsTestApi) declared(i int) []uint64 {
+	msg := ta.pushed[i].Message
+	require.Equal(ta.t, actors.MAMethods.DeclareFaults, msg.Method)
+
+	var params actors.DeclareFaultsParams
+	require.NoError(ta.t, params.UnmarshalCBOR(bytes.NewReader(msg.Params)))
+	return params.Faults.All()
+}
+
+type faultsTestSB struct {
+	sectorbuilder.Interface
+
+	faulty map[uint64]bool
+}
+
+func (sb *faultsTestSB) CheckSector(sectorID uint64) error {
+	if sb.faulty[sectorID] {
+		return xerrors.Errorf("sector %d files missing", sectorID)
+	}
+	return nil
+}
+
+func TestCheckFaults(t *testing.T) {
+	ctx := context.Background()
+	start := build.UpgradeDeclareFaultsHeight
+
+	ta := &faultsTestApi{
+		t:         t,
+		height:    start + 10,
+		periodEnd: start + 100,
+		pset:      []uint64{1, 2, 3},
+		landed:    map[cid.Cid]*api.MsgWait{},
+	}
+	sb := &faultsTestSB{faulty: map[uint64]bool{}}
+
+	m, err := NewMiner(ta, address.Undef, nil, datastore.NewMapDatastore(), sb, nil, SealingConfig{})
+	require.NoError(t, err)
+
+	declared := func() []bool {
+		var out []bool
+		for _, f := range m.Faults() {
+			out = append(out, f.Declared)
+		}
+		return out
+	}
+
+	// no faults, nothing to declare
+	require.NoError(t, m.checkFaults(ctx))
+	assert.Empty(t, ta.pushed)
+	assert.Empty(t, m.Faults())
+
+	sb.faulty[2] = true
+
+	if start > 0 {
+		ta.height = start - 1
+		require.NoError(t, m.checkFaults(ctx))
+		assert.Empty(t, ta.pushed, "faults can't be declared before the upgrade")
+		ta.height = start + 10
+	}
+
+	// faults are declared without waiting for the message
+	require.NoError(t, m.checkFaults(ctx))
+	require.Len(t, ta.pushed, 1)
+	assert.Equal(t, []uint64{2}, ta.declared(0))
+	assert.Equal(t, []bool{false}, declared())
+
+	// in flight declarations aren't repeated
+	ta.height = start + 20
+	require.NoError(t, m.checkFaults(ctx))
+	assert.Len(t, ta.pushed, 1)
+
+	// only new faults are declared
+	sb.faulty[3] = true
+	require.NoError(t, m.checkFaults(ctx))
+	require.Len(t, ta.pushed, 2)
+	assert.Equal(t, []uint64{3}, ta.declared(1))
+
+	// landed declarations mark the faults as declared
+	ta.land(0)
+	require.NoError(t, m.checkFaults(ctx))
+	assert.Len(t, ta.pushed, 2)
+	assert.Equal(t, []bool{false, true}, declared())
+	assert.Len(t, m.faultDecls, 1)
+
+	// declarations which don't land before the end of the proving period are
+	// sent again
+	ta.height = ta.periodEnd
+	ta.periodEnd = start + 200
+	require.NoError(t, m.checkFaults(ctx))
+	require.Len(t, ta.pushed, 3)
+	assert.Equal(t, []uint64{2}, ta.declared(2))
+
+	// so are failed declarations
+	ta.land(1)
+	require.NoError(t, m.checkFaults(ctx))
+	require.Len(t, ta.pushed, 4)
+	assert.Equal(t, []uint64{2}, ta.declared(3))
+	assert.Equal(t, []bool{false, true}, declared())
+
+	ta.land(0)
+	require.NoError(t, m.checkFaults(ctx))
+	assert.Len(t, ta.pushed, 4)
+	assert.Equal(t, []bool{true, true}, declared())
+	assert.Empty(t, m.faultDecls)
+}
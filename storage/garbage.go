@@ -134,7 +134,7 @@ func (m *Miner) StoreGarbageData() error {
 			return
 		}
 
-		if err := m.newSector(context.TODO(), sid, pieces[0].DealID, pieces[0].Ref, pieces[0].ppi()); err != nil {
+		if err := m.newSector(context.TODO(), sid, api.Packing, pieces...); err != nil {
 			log.Errorf("%+v", err)
 			return
 		}
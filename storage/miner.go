@@ -13,6 +13,7 @@ import (
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors"
 	"github.com/filecoin-project/lotus/chain/address"
 	"github.com/filecoin-project/lotus/chain/events"
 	"github.com/filecoin-project/lotus/chain/store"
@@ -34,13 +35,28 @@ type Miner struct {
 	worker address.Address
 
 	// PoSt
-	postLk    sync.Mutex
-	schedPost uint64
+	postLk      sync.Mutex
+	schedPost   uint64
+	postCancel  context.CancelFunc
+	postRun     uint64
+	postHistory datastore.Datastore
+
+	// Faults
+	faultsLk   sync.Mutex
+	faults     map[uint64]*api.SectorFault
+	faultCheck chan struct{}
+	faultDecls []*faultDeclaration // only used by the fault loop
 
 	// Sealing
-	sb      *sectorbuilder.SectorBuilder
+	sb      sectorbuilder.Interface
 	sectors *statestore.StateStore
 	tktFn   TicketFn
+	sealing SealingConfig
+
+	// Packing, packCond is signalled when a piece is done being added
+	packLk      sync.Mutex
+	packCond    *sync.Cond
+	openSectors map[uint64]*openSector
 
 	sectorIncoming chan *SectorInfo
 	sectorUpdated  chan sectorUpdate
@@ -50,13 +66,17 @@ type Miner struct {
 
 type storageMinerApi interface {
 	// Call a read only method on actors (no interaction with the chain required)
-	StateCall(ctx context.Context, msg *types.Message, ts *types.TipSet) (*types.MessageReceipt, error)
+	StateCall(ctx context.Context, msg *types.Message, ts *types.TipSet) (*api.InvocResult, error)
 	StateMinerWorker(context.Context, address.Address, *types.TipSet) (address.Address, error)
 	StateMinerProvingPeriodEnd(context.Context, address.Address, *types.TipSet) (uint64, error)
 	StateMinerSectors(context.Context, address.Address, *types.TipSet) ([]*api.ChainSectorInfo, error)
 	StateMinerProvingSet(context.Context, address.Address, *types.TipSet) ([]*api.ChainSectorInfo, error)
 	StateMinerSectorSize(context.Context, address.Address, *types.TipSet) (uint64, error)
+	StateMinerFaults(context.Context, address.Address, *types.TipSet) (*api.MinerFaults, error)
+	StateMinerSectorExpiration(context.Context, address.Address, uint64, *types.TipSet) (uint64, error)
+	StateMarketStorageDeal(context.Context, uint64, *types.TipSet) (*actors.OnChainDeal, error)
 	StateWaitMsg(context.Context, cid.Cid) (*api.MsgWait, error) // TODO: removeme eventually
+	StateSearchMsg(context.Context, cid.Cid) (*api.MsgWait, error)
 	StateGetActor(ctx context.Context, actor address.Address, ts *types.TipSet) (*types.Actor, error)
 	StateGetReceipt(context.Context, cid.Cid, *types.TipSet) (*types.MessageReceipt, error)
 
@@ -73,8 +93,8 @@ type storageMinerApi interface {
 	WalletHas(context.Context, address.Address) (bool, error)
 }
 
-func NewMiner(api storageMinerApi, addr address.Address, h host.Host, ds datastore.Batching, sb *sectorbuilder.SectorBuilder, tktFn TicketFn) (*Miner, error) {
-	return &Miner{
+func NewMiner(api storageMinerApi, addr address.Address, h host.Host, ds datastore.Batching, sb sectorbuilder.Interface, tktFn TicketFn, sealing SealingConfig) (*Miner, error) {
+	m := &Miner{
 		api: api,
 
 		maddr: addr,
@@ -82,13 +102,22 @@ func NewMiner(api storageMinerApi, addr address.Address, h host.Host, ds datasto
 		sb:    sb,
 		tktFn: tktFn,
 
-		sectors: statestore.New(namespace.Wrap(ds, datastore.NewKey("/sectors"))),
+		sealing:     sealing,
+		openSectors: map[uint64]*openSector{},
+
+		sectors:     statestore.New(namespace.Wrap(ds, datastore.NewKey("/sectors"))),
+		postHistory: namespace.Wrap(ds, datastore.NewKey("/post/history")),
+
+		faultCheck: make(chan struct{}, 1),
 
 		sectorIncoming: make(chan *SectorInfo),
 		sectorUpdated:  make(chan sectorUpdate),
 		stop:           make(chan struct{}),
 		stopped:        make(chan struct{}),
-	}, nil
+	}
+	m.packCond = sync.NewCond(&m.packLk)
+
+	return m, nil
 }
 
 func (m *Miner) Run(ctx context.Context) error {
@@ -100,6 +129,8 @@ func (m *Miner) Run(ctx context.Context) error {
 
 	go m.beginPosting(ctx)
 	go m.sectorStateLoop(ctx)
+	go m.faultLoop(ctx)
+	go m.packingLoop(ctx)
 	return nil
 }
 
@@ -0,0 +1,325 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+)
+
+// dealSealMargin is how many epochs before the proposal expiration of a deal
+// sealing of the sector containing it is started, so that the deal gets
+// activated in time. Sealing takes a few hours on real hardware
+const dealSealMargin = uint64(6 * 60 * 60 / build.BlockDelay)
+
+type SealingConfig struct {
+	// WaitDealsDelay is how long sectors are kept open for more deals before
+	// they're sealed
+	WaitDealsDelay time.Duration
+}
+
+// openSector is a sector in the WaitDeals state, which more deal pieces can
+// be added to
+type openSector struct {
+	// pieces are the sizes of the pieces space was reserved for, in the order
+	// they're written to the sector. Done counts the pieces which were
+	// written or given up on, added the ones which were added successfully
+	pieces []uint64
+	done   int
+	added  int
+
+	opened time.Time
+
+	// sealBy is the height at which sealing has to start for the deals in the
+	// sector to be activated before their proposals expire
+	sealBy uint64
+
+	// closing is set when no more space can be reserved in the sector, it is
+	// sealed once the last reserved piece is done. Broken is set when adding
+	// a piece failed, the staged sector may then contain a partially written
+	// piece, so nothing more can be added to it until it's trimmed before
+	// adding fillers
+	closing bool
+	broken  bool
+}
+
+// padded converts user bytes to in-sector bytes
+func padded(size uint64) uint64 {
+	return size + size/127
+}
+
+// usedSpace returns the in-sector space taken by pieces
+func usedSpace(pieces []uint64) uint64 {
+	var used uint64
+	for _, piece := range pieces {
+		used += padded(piece)
+	}
+	return used
+}
+
+// pieceFits checks whether a piece can be added to a sector with the given
+// pieces. Pieces are aligned to their size in the sector, so the piece has
+// to start right where the existing pieces end, or sectorbuilder would add
+// padding which isn't accounted for when filling the rest of the sector
+func pieceFits(existing []uint64, size uint64, ssize uint64) bool {
+	used := usedSpace(existing)
+	psize := padded(size)
+	return used%psize == 0 && used+psize <= ssize
+}
+
+func (m *Miner) SealPiece(ctx context.Context, ref string, size uint64, r io.Reader, dealID uint64) (uint64, error) {
+	log.Infof("Seal piece for deal %d", dealID)
+
+	sealBy, err := m.dealSealBy(ctx, dealID)
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		sid, open, idx, err := m.reservePiece(size, sealBy)
+		if err != nil {
+			return 0, err
+		}
+
+		// pieces are written in the order space was reserved for them
+		m.packLk.Lock()
+		for open.done < idx {
+			m.packCond.Wait()
+		}
+		broken := open.broken
+		existing := append([]uint64{}, open.pieces[:idx]...)
+		m.packLk.Unlock()
+
+		if broken {
+			// adding an earlier piece failed, try another sector
+			m.pieceDone(sid, open, false)
+			continue
+		}
+
+		if err := m.addPiece(ctx, sid, idx, existing, ref, size, r, dealID); err != nil {
+			m.pieceDone(sid, open, false)
+			return 0, err
+		}
+
+		m.pieceDone(sid, open, true)
+		return sid, nil
+	}
+}
+
+// addPiece writes the piece to the staged sector, and adds it to the sector
+// state. The first piece creates the sector.
+func (m *Miner) addPiece(ctx context.Context, sid uint64, idx int, existing []uint64, ref string, size uint64, r io.Reader, dealID uint64) error {
+	ppi, err := m.sb.AddPiece(size, sid, r, existing)
+	if err != nil {
+		return xerrors.Errorf("adding piece to sector: %w", err)
+	}
+
+	piece := Piece{
+		DealID: dealID,
+		Ref:    ref,
+
+		Size:  ppi.Size,
+		CommP: ppi.CommP[:],
+	}
+
+	if idx == 0 {
+		return m.newSector(ctx, sid, api.WaitDeals, piece)
+	}
+
+	select {
+	case m.sectorUpdated <- sectorUpdate{
+		newState: api.WaitDeals,
+		id:       sid,
+		mut: func(info *SectorInfo) {
+			info.Pieces = append(info.Pieces, piece)
+		},
+	}:
+		return nil
+	case <-m.stop:
+		return xerrors.Errorf("failed to add piece to sector %d, miner shutting down", sid)
+	}
+}
+
+// reservePiece reserves space for the piece in an open sector with room for
+// it, or in a new sector. It returns the index of the piece in the sector.
+func (m *Miner) reservePiece(size uint64, sealBy uint64) (uint64, *openSector, int, error) {
+	m.packLk.Lock()
+	defer m.packLk.Unlock()
+
+	var sid uint64
+	var open *openSector
+	for id, o := range m.openSectors {
+		if pieceFits(o.pieces, size, m.sb.SectorSize()) {
+			sid, open = id, o
+			break
+		}
+	}
+
+	if open == nil {
+		var err error
+		sid, err = m.sb.AcquireSectorId()
+		if err != nil {
+			return 0, nil, 0, xerrors.Errorf("acquiring sector ID: %w", err)
+		}
+
+		open = &openSector{
+			opened: time.Now(),
+			sealBy: sealBy,
+		}
+		m.openSectors[sid] = open
+	}
+
+	idx := len(open.pieces)
+	open.pieces = append(open.pieces, size)
+	if sealBy < open.sealBy {
+		open.sealBy = sealBy
+	}
+
+	if usedSpace(open.pieces) == m.sb.SectorSize() {
+		m.closeSectorLocked(sid, open)
+	}
+
+	return sid, open, idx, nil
+}
+
+// pieceDone records the outcome of writing the next reserved piece of the
+// sector, and starts sealing it if that was the last piece of a closing
+// sector
+func (m *Miner) pieceDone(sid uint64, open *openSector, added bool) {
+	m.packLk.Lock()
+	open.done++
+	if added {
+		open.added++
+	} else if !open.broken {
+		log.Warnf("adding piece to sector %d failed, sealing it with the pieces it has", sid)
+		open.broken = true
+		m.closeSectorLocked(sid, open)
+	}
+	seal := open.closing && open.done == len(open.pieces)
+	m.packCond.Broadcast()
+	m.packLk.Unlock()
+
+	if seal {
+		m.startSealing(sid, open)
+	}
+}
+
+// dealSealBy returns the height at which sealing has to start for the deal
+// to be activated before its proposal expires
+func (m *Miner) dealSealBy(ctx context.Context, dealID uint64) (uint64, error) {
+	deal, err := m.api.StateMarketStorageDeal(ctx, dealID, nil)
+	if err != nil {
+		return 0, xerrors.Errorf("getting deal %d: %w", dealID, err)
+	}
+
+	exp := deal.Deal.Proposal.ProposalExpiration
+	if exp < dealSealMargin {
+		return 0, nil
+	}
+	return exp - dealSealMargin, nil
+}
+
+// closeSectorLocked stops reserving space in the sector. It returns whether
+// the sector can be sealed right away, otherwise it's sealed when the pieces
+// being added are done. Must be called with packLk held
+func (m *Miner) closeSectorLocked(sid uint64, open *openSector) bool {
+	delete(m.openSectors, sid)
+	open.closing = true
+
+	return open.done == len(open.pieces)
+}
+
+// startSealing moves a closed sector out of the WaitDeals state
+func (m *Miner) startSealing(sid uint64, open *openSector) {
+	if open.added == 0 {
+		// the sector was never created
+		log.Warnf("not sealing sector %d, no pieces were added to it", sid)
+		return
+	}
+
+	select {
+	case m.sectorUpdated <- sectorUpdate{
+		newState: api.Packing,
+		id:       sid,
+	}:
+	case <-m.stop:
+		log.Warnf("failed to start sealing sector %d, miner shutting down", sid)
+	}
+}
+
+// reopenSector tracks a sector in the WaitDeals state again after a restart
+func (m *Miner) reopenSector(ctx context.Context, sector SectorInfo) {
+	open := &openSector{
+		opened: time.Now(),
+		sealBy: ^uint64(0),
+	}
+
+	for _, piece := range sector.Pieces {
+		open.pieces = append(open.pieces, piece.Size)
+		open.done++
+		open.added++
+
+		sealBy, err := m.dealSealBy(ctx, piece.DealID)
+		if err != nil {
+			log.Errorf("reopening sector %d: %+v", sector.SectorID, err)
+			sealBy = 0
+		}
+		if sealBy < open.sealBy {
+			open.sealBy = sealBy
+		}
+	}
+
+	m.packLk.Lock()
+	m.openSectors[sector.SectorID] = open
+	m.packLk.Unlock()
+}
+
+// packingLoop starts sealing open sectors which waited for deals long
+// enough, or have deals which need to be activated soon
+func (m *Miner) packingLoop(ctx context.Context) {
+	t := time.NewTicker(build.BlockDelay * time.Second)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+		case <-m.stop:
+			return
+		}
+
+		head, err := m.api.ChainHead(ctx)
+		if err != nil {
+			log.Errorf("getting chain head: %+v", err)
+			continue
+		}
+
+		m.sealDueSectors(head.Height())
+	}
+}
+
+// sealDueSectors closes the open sectors which waited for deals long enough,
+// or have deals which need to be activated soon
+func (m *Miner) sealDueSectors(height uint64) {
+	seal := map[uint64]*openSector{}
+
+	m.packLk.Lock()
+	for sid, open := range m.openSectors {
+		if time.Since(open.opened) < m.sealing.WaitDealsDelay && height < open.sealBy {
+			continue
+		}
+
+		log.Infof("sealing sector %d with %d deals", sid, len(open.pieces))
+		if m.closeSectorLocked(sid, open) {
+			seal[sid] = open
+		}
+	}
+	m.packLk.Unlock()
+
+	for sid, open := range seal {
+		m.startSealing(sid, open)
+	}
+}
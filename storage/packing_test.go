@@ -0,0 +1,337 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/address"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/sectorbuilder"
+)
+
+func TestPieceFits(t *testing.T) {
+	const ssize = 1024
+
+	// user sizes of 128, 256 and 512 byte in-sector pieces
+	p128 := uint64(127)
+	p256 := uint64(254)
+	p512 := uint64(508)
+
+	assert.True(t, pieceFits(nil, p512, ssize))
+	assert.True(t, pieceFits([]uint64{p512}, p512, ssize))
+	assert.False(t, pieceFits([]uint64{p512, p512}, p128, ssize))
+
+	// pieces must be aligned to their size
+	assert.True(t, pieceFits([]uint64{p128}, p128, ssize))
+	assert.False(t, pieceFits([]uint64{p128}, p256, ssize))
+	assert.True(t, pieceFits([]uint64{p128, p128}, p256, ssize))
+	assert.True(t, pieceFits([]uint64{p256, p256}, p512, ssize))
+
+	assert.Equal(t, uint64(ssize), usedSpace([]uint64{p512, p256, p128, p128}))
+}
+
+// packingTestSB checks pieces are written to sectors in the order space was
+// reserved for them, right after the previous pieces. Pieces starting with
+// "fail" are only written half way
+type packingTestSB struct {
+	sectorbuilder.Interface
+
+	lk      sync.Mutex
+	nextID  uint64
+	written map[uint64][]uint64
+	staged  map[uint64]uint64 // in-sector bytes
+}
+
+func newPackingTestSB() *packingTestSB {
+	return &packingTestSB{
+		written: map[uint64][]uint64{},
+		staged:  map[uint64]uint64{},
+	}
+}
+
+func (sb *packingTestSB) RateLimit() func() {
+	return func() {}
+}
+
+func (sb *packingTestSB) SectorSize() uint64 {
+	return 1024
+}
+
+func (sb *packingTestSB) AcquireSectorId() (uint64, error) {
+	sb.lk.Lock()
+	defer sb.lk.Unlock()
+
+	sb.nextID++
+	return sb.nextID, nil
+}
+
+func (sb *packingTestSB) AddPiece(size uint64, sid uint64, r io.Reader, existing []uint64) (sectorbuilder.PublicPieceInfo, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return sectorbuilder.PublicPieceInfo{}, err
+	}
+
+	sb.lk.Lock()
+	defer sb.lk.Unlock()
+
+	if !assert.ObjectsAreEqual(append([]uint64{}, sb.written[sid]...), append([]uint64{}, existing...)) {
+		return sectorbuilder.PublicPieceInfo{}, xerrors.Errorf("sector %d has pieces %v, expected %v", sid, sb.written[sid], existing)
+	}
+	if sb.staged[sid] != usedSpace(existing) {
+		return sectorbuilder.PublicPieceInfo{}, xerrors.Errorf("staged sector %d has %d bytes, expected %d", sid, sb.staged[sid], usedSpace(existing))
+	}
+
+	if strings.HasPrefix(string(data), "fail") {
+		sb.staged[sid] += padded(size) / 2
+		return sectorbuilder.PublicPieceInfo{}, xerrors.New("write failed")
+	}
+
+	sb.written[sid] = append(sb.written[sid], size)
+	sb.staged[sid] += padded(size)
+
+	return sectorbuilder.PublicPieceInfo{Size: size}, nil
+}
+
+func (sb *packingTestSB) TrimStagedSector(sid uint64, pieces []uint64) error {
+	sb.lk.Lock()
+	defer sb.lk.Unlock()
+
+	if !assert.ObjectsAreEqual(append([]uint64{}, sb.written[sid]...), append([]uint64{}, pieces...)) {
+		return xerrors.Errorf("sector %d has pieces %v, expected %v", sid, sb.written[sid], pieces)
+	}
+	sb.staged[sid] = usedSpace(pieces)
+
+	return nil
+}
+
+type packingTestApi struct {
+	storageMinerApi
+
+	expiration uint64
+
+	lk       sync.Mutex
+	lastMsg  *types.Message
+	nextDeal uint64
+}
+
+func (ta *packingTestApi) WalletSign(context.Context, address.Address, []byte) (*types.Signature, error) {
+	return &types.Signature{Type: types.KTBLS}, nil
+}
+
+func (ta *packingTestApi) MpoolPushMessage(ctx context.Context, msg *types.Message) (*types.SignedMessage, error) {
+	ta.lk.Lock()
+	defer ta.lk.Unlock()
+
+	ta.lastMsg = msg
+	return &types.SignedMessage{Message: *msg}, nil
+}
+
+// StateWaitMsg publishes the deals of the last pushed message
+func (ta *packingTestApi) StateWaitMsg(context.Context, cid.Cid) (*api.MsgWait, error) {
+	ta.lk.Lock()
+	defer ta.lk.Unlock()
+
+	var params actors.PublishStorageDealsParams
+	if err := params.UnmarshalCBOR(bytes.NewReader(ta.lastMsg.Params)); err != nil {
+		return nil, err
+	}
+
+	var resp actors.PublishStorageDealResponse
+	for range params.Deals {
+		ta.nextDeal++
+		resp.DealIDs = append(resp.DealIDs, ta.nextDeal)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := resp.MarshalCBOR(buf); err != nil {
+		return nil, err
+	}
+
+	return &api.MsgWait{Receipt: types.MessageReceipt{Return: buf.Bytes()}}, nil
+}
+
+func (ta *packingTestApi) StateMarketStorageDeal(context.Context, uint64, *types.TipSet) (*actors.OnChainDeal, error) {
+	return &actors.OnChainDeal{
+		Deal: actors.StorageDeal{
+			Proposal: actors.StorageDealProposal{ProposalExpiration: ta.expiration},
+		},
+	}, nil
+}
+
+// packingTestSectors stands in for the sector state loop
+type packingTestSectors struct {
+	lk      sync.Mutex
+	sectors map[uint64]*SectorInfo
+}
+
+func runPackingTestSectors(m *Miner) *packingTestSectors {
+	ts := &packingTestSectors{sectors: map[uint64]*SectorInfo{}}
+
+	go func() {
+		for {
+			select {
+			case si := <-m.sectorIncoming:
+				ts.lk.Lock()
+				ts.sectors[si.SectorID] = si
+				ts.lk.Unlock()
+			case update := <-m.sectorUpdated:
+				ts.lk.Lock()
+				si := ts.sectors[update.id]
+				si.State = update.newState
+				if update.mut != nil {
+					update.mut(si)
+				}
+				ts.lk.Unlock()
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+
+	return ts
+}
+
+// wait waits for the sector to reach the state, and returns its pieces
+func (ts *packingTestSectors) wait(t *testing.T, sid uint64, state api.SectorState) []Piece {
+	timeout := time.After(5 * time.Second)
+	for {
+		ts.lk.Lock()
+		si, ok := ts.sectors[sid]
+		if ok && si.State == state {
+			pieces := append([]Piece{}, si.Pieces...)
+			ts.lk.Unlock()
+			return pieces
+		}
+		ts.lk.Unlock()
+
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-timeout:
+			t.Fatalf("timed out waiting for sector %d to be in state %s", sid, api.SectorStates[state])
+		}
+	}
+}
+
+func TestSealPiecePacking(t *testing.T) {
+	ctx := context.Background()
+
+	ta := &packingTestApi{expiration: 1000000}
+	sb := newPackingTestSB()
+
+	m, err := NewMiner(ta, address.Undef, nil, datastore.NewMapDatastore(), sb, nil, SealingConfig{WaitDealsDelay: time.Hour})
+	require.NoError(t, err)
+	defer close(m.stop)
+
+	sectors := runPackingTestSectors(m)
+
+	// 256 byte in-sector pieces, four fit in a sector
+	const size = 254
+	seal := func(dealID uint64) uint64 {
+		sid, err := m.SealPiece(ctx, "ref", size, strings.NewReader(strings.Repeat("x", size)), dealID)
+		assert.NoError(t, err)
+		return sid
+	}
+
+	// deals added at the same time share the sector
+	var wg sync.WaitGroup
+	sids := make([]uint64, 3)
+	for i := range sids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sids[i] = seal(uint64(i))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, []uint64{1, 1, 1}, sids)
+	assert.Len(t, sectors.wait(t, 1, api.WaitDeals), 3)
+
+	// the sector isn't due for sealing yet
+	m.sealDueSectors(0)
+	assert.Len(t, sectors.wait(t, 1, api.WaitDeals), 3)
+
+	// the sector is sealed as soon as it's full
+	assert.Equal(t, uint64(1), seal(3))
+	assert.Len(t, sectors.wait(t, 1, api.Packing), 4)
+	assert.Equal(t, []uint64{size, size, size, size}, sb.written[1])
+
+	// sectors are sealed when they waited for deals long enough
+	assert.Equal(t, uint64(2), seal(4))
+	assert.Equal(t, uint64(2), seal(5))
+	m.sealing.WaitDealsDelay = 0
+	m.sealDueSectors(0)
+	assert.Len(t, sectors.wait(t, 2, api.Packing), 2)
+
+	// or when a deal needs to be activated soon
+	m.sealing.WaitDealsDelay = time.Hour
+	ta.expiration = dealSealMargin + 100
+	assert.Equal(t, uint64(3), seal(6))
+
+	m.sealDueSectors(99)
+	sectors.wait(t, 3, api.WaitDeals)
+	m.sealDueSectors(100)
+	assert.Len(t, sectors.wait(t, 3, api.Packing), 1)
+}
+
+func TestSealPieceFailedWrite(t *testing.T) {
+	ctx := context.Background()
+
+	ta := &packingTestApi{expiration: 1000000}
+	sb := newPackingTestSB()
+
+	// filler deals are signed, which needs the miner and worker addresses
+	maddr, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+
+	m, err := NewMiner(ta, maddr, nil, datastore.NewMapDatastore(), sb, nil, SealingConfig{WaitDealsDelay: time.Hour})
+	require.NoError(t, err)
+	defer close(m.stop)
+
+	m.worker, err = address.NewIDAddress(1001)
+	require.NoError(t, err)
+
+	sectors := runPackingTestSectors(m)
+
+	const size = 254
+	sid, err := m.SealPiece(ctx, "ref", size, strings.NewReader(strings.Repeat("x", size)), 0)
+	require.NoError(t, err)
+
+	// the second piece is partially written, which breaks the sector, and
+	// starts sealing it with the first piece
+	_, err = m.SealPiece(ctx, "ref", size, strings.NewReader("fail"+strings.Repeat("x", size-4)), 1)
+	require.Error(t, err)
+
+	pieces := sectors.wait(t, sid, api.Packing)
+	require.Len(t, pieces, 1)
+
+	sb.lk.Lock()
+	assert.Equal(t, padded(size)+padded(size)/2, sb.staged[sid])
+	sb.lk.Unlock()
+
+	// the partial piece is dropped before the sector is filled up
+	mut, err := m.finishPacking(ctx, SectorInfo{SectorID: sid, Pieces: pieces})
+	require.NoError(t, err)
+
+	info := SectorInfo{SectorID: sid, Pieces: pieces}
+	mut(&info)
+
+	sb.lk.Lock()
+	defer sb.lk.Unlock()
+	assert.Equal(t, info.existingPieces(), sb.written[sid])
+	assert.Equal(t, m.sb.SectorSize(), sb.staged[sid])
+}
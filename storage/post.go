@@ -14,7 +14,8 @@ import (
 	"github.com/filecoin-project/lotus/lib/sectorbuilder"
 )
 
-const postMsgTimeout = 20
+// postRetryBackoff is how long to wait before retrying a failed PoSt
+const postRetryBackoff = build.BlockDelay * time.Second
 
 func (m *Miner) beginPosting(ctx context.Context) {
 	ts, err := m.api.ChainHead(context.TODO())
@@ -53,11 +54,7 @@ func (m *Miner) beginPosting(ctx context.Context) {
 	}
 
 	log.Infof("Scheduling post at height %d (begin ts: %d, statePPE: %d)", ppe-build.PoStChallangeTime, ts.Height(), sppe)
-	err = m.events.ChainAt(m.computePost(m.schedPost), func(ctx context.Context, ts *types.TipSet) error { // Revert
-		// TODO: Cancel post
-		log.Errorf("TODO: Cancel PoSt, re-run")
-		return nil
-	}, PoStConfidence, ppe-build.PoStChallangeTime)
+	err = m.events.ChainAt(m.computePost(m.schedPost), m.revertPost(m.schedPost), PoStConfidence, ppe-build.PoStChallangeTime)
 	if err != nil {
 		// TODO: This is BAD, figure something out
 		log.Errorf("scheduling PoSt failed: %s", err)
@@ -81,8 +78,8 @@ func (m *Miner) scheduleNextPost(ppe uint64) {
 
 	m.postLk.Lock()
 	if m.schedPost >= ppe {
-		// this probably can't happen
-		log.Errorw("PoSt already scheduled", "schedPost", m.schedPost, "ppe", ppe)
+		// happens when the PoSt of a period was re-run after a reorg
+		log.Infow("PoSt already scheduled", "schedPost", m.schedPost, "ppe", ppe)
 		m.postLk.Unlock()
 		return
 	}
@@ -92,11 +89,7 @@ func (m *Miner) scheduleNextPost(ppe uint64) {
 
 	log.Infow("scheduling PoSt", "post-height", ppe-build.PoStChallangeTime,
 		"height", ts.Height(), "ppe", ppe, "proving-period", provingPeriod)
-	err = m.events.ChainAt(m.computePost(ppe), func(ctx context.Context, ts *types.TipSet) error { // Revert
-		// TODO: Cancel post
-		log.Errorf("TODO: Cancel PoSt, re-run")
-		return nil
-	}, PoStConfidence, ppe-build.PoStChallangeTime)
+	err = m.events.ChainAt(m.computePost(ppe), m.revertPost(ppe), PoStConfidence, ppe-build.PoStChallangeTime)
 	if err != nil {
 		// TODO: This is BAD, figure something out
 		log.Errorf("scheduling PoSt failed: %+v", err)
@@ -111,14 +104,19 @@ type post struct {
 	ts  *types.TipSet
 
 	// prep
-	sset []*api.ChainSectorInfo
-	r    []byte
+	sset   []*api.ChainSectorInfo
+	faults []uint64
+	r      []byte
 
 	// run
-	proof []byte
+	proof   []byte
+	elapsed time.Duration
 
 	// commit
-	smsg *types.SignedMessage
+	smsg    *types.SignedMessage
+	receipt *types.MessageReceipt
+	// replaced is set when the nonce of smsg was used by another message
+	replaced bool
 }
 
 func (p *post) doPost(ctx context.Context) error {
@@ -129,12 +127,19 @@ func (p *post) doPost(ctx context.Context) error {
 		return xerrors.Errorf("prepare: %w", err)
 	}
 
-	if err := p.runPost(ctx); err != nil {
-		return xerrors.Errorf("run: %w", err)
+	// the proof doesn't change between attempts, only submitting it is retried
+	if p.proof == nil {
+		if err := p.runPost(ctx); err != nil {
+			return xerrors.Errorf("run: %w", err)
+		}
 	}
 
-	if err := p.commitPost(ctx); err != nil {
-		return xerrors.Errorf("commit: %w", err)
+	// a message which wasn't seen on chain after an earlier attempt may still
+	// land, it's waited for again instead of submitting another one
+	if p.smsg == nil {
+		if err := p.commitPost(ctx); err != nil {
+			return xerrors.Errorf("commit: %w", err)
+		}
 	}
 
 	if err := p.waitCommit(ctx); err != nil {
@@ -159,6 +164,23 @@ func (p *post) preparePost(ctx context.Context) error {
 
 	p.sset = sset
 
+	// PoSt verification excludes faults declared before the challenge
+	faults, err := p.m.api.StateMinerFaults(ctx, p.m.maddr, p.ts)
+	if err != nil {
+		return xerrors.Errorf("failed to get faults for miner (tsH: %d): %w", p.ts.Height(), err)
+	}
+	p.faults = faults.Current
+
+	declared := map[uint64]bool{}
+	for _, id := range p.faults {
+		declared[id] = true
+	}
+	for _, f := range p.m.Faults() {
+		if !declared[f.SectorID] {
+			log.Errorf("sector %d is faulty, but the fault wasn't declared in time for this PoSt", f.SectorID)
+		}
+	}
+
 	// Compute how many blocks back we have to look from the given tipset for the PoSt challenge
 	challengeLookback := int((int64(p.ts.Height()) - int64(p.ppe)) + int64(build.PoStChallangeTime) + int64(build.PoStRandomnessLookback))
 	r, err := p.m.api.ChainGetRandomness(ctx, p.ts.Key(), nil, challengeLookback)
@@ -191,22 +213,21 @@ func (p *post) runPost(ctx context.Context) error {
 
 	log.Infow("running PoSt", "delayed-by",
 		int64(p.ts.Height())-(int64(p.ppe)-int64(build.PoStChallangeTime)),
-		"chain-random", p.r, "ppe", p.ppe, "height", p.ts.Height(), "sectors", len(p.sset))
+		"chain-random", p.r, "ppe", p.ppe, "height", p.ts.Height(), "sectors", len(p.sset), "faults", len(p.faults))
 
 	tsStart := time.Now()
-	var faults []uint64 // TODO
 
 	var seed [32]byte
 	copy(seed[:], p.r)
 
-	proof, err := p.m.sb.GeneratePoSt(p.sortedSectorInfo(), seed, faults)
+	proof, err := p.m.sb.GeneratePoSt(p.sortedSectorInfo(), seed, p.faults)
 	if err != nil {
 		return xerrors.Errorf("running post failed: %w", err)
 	}
-	elapsed := time.Since(tsStart)
+	p.elapsed = time.Since(tsStart)
 
 	p.proof = proof
-	log.Infow("submitting PoSt", "pLen", len(proof), "elapsed", elapsed)
+	log.Infow("submitting PoSt", "pLen", len(proof), "elapsed", p.elapsed)
 
 	return nil
 }
@@ -251,47 +272,213 @@ func (p *post) waitCommit(ctx context.Context) error {
 
 	log.Infof("Waiting for post %s to appear on chain", p.smsg.Cid())
 
-	err := p.m.events.CalledMsg(ctx, func(msg *types.Message, rec *types.MessageReceipt, ts *types.TipSet, curH uint64) (more bool, err error) {
-		if rec.ExitCode != 0 {
-			log.Warnf("SubmitPoSt EXIT: %d", rec.ExitCode)
-		}
-
-		log.Infof("Post made it on chain! (height=%d)", ts.Height())
-
-		return false, nil
-	}, func(ctx context.Context, ts *types.TipSet) error {
-		log.Warn("post message reverted")
-		return nil
-	}, 3, postMsgTimeout, p.smsg)
+	mw, err := p.m.api.StateWaitMsg(ctx, p.smsg.Cid())
 	if err != nil {
 		return xerrors.Errorf("waiting for post to appear on chain: %w", err)
 	}
+	p.receipt = &mw.Receipt
+
+	if mw.Receipt.ExitCode != 0 {
+		return xerrors.Errorf("SubmitPoSt failed (exit code %d)", mw.Receipt.ExitCode)
+	}
+
+	log.Infof("Post made it on chain! (height=%d)", mw.TipSet.Height())
 
 	return nil
 }
 
+// checkCommit looks up a PoSt message which wasn't seen executing, as waiting
+// for it may have failed after it was included. It returns whether the message
+// was executed successfully
+func (p *post) checkCommit(ctx context.Context) (bool, error) {
+	// the nonce is checked first, so that a message which lands in between
+	// isn't taken for a replaced one
+	worker, err := p.m.api.StateGetActor(ctx, p.m.worker, nil)
+	if err != nil {
+		return false, xerrors.Errorf("getting worker actor: %w", err)
+	}
+
+	mw, err := p.m.api.StateSearchMsg(ctx, p.smsg.Cid())
+	if err != nil {
+		return false, xerrors.Errorf("looking up message: %w", err)
+	}
+
+	if mw != nil {
+		p.receipt = &mw.Receipt
+		return mw.Receipt.ExitCode == 0, nil
+	}
+
+	if worker.Nonce > p.smsg.Message.Nonce {
+		log.Warnw("PoSt message was replaced by another message", "msg", p.smsg.Cid(), "nonce", p.smsg.Message.Nonce)
+		p.replaced = true
+	}
+
+	return false, nil
+}
+
 func (m *Miner) computePost(ppe uint64) func(ctx context.Context, ts *types.TipSet, curH uint64) error {
-	called := 0
 	return func(ctx context.Context, ts *types.TipSet, curH uint64) error {
-		called++
-		if called > 1 {
-			log.Errorw("BUG: computePost callback called again", "ppe", ppe,
-				"height", ts.Height(), "curH", curH, "called", called-1)
-			return nil
+		m.postLk.Lock()
+		if m.postCancel != nil {
+			// the challenge tipset changed after a reorg
+			log.Warnw("restarting PoSt computation", "ppe", ppe, "height", ts.Height(), "curH", curH)
+			m.postCancel()
 		}
 
-		err := (&post{
-			m:   m,
-			ppe: ppe,
-			ts:  ts,
-		}).doPost(ctx)
+		pctx, cancel := context.WithCancel(ctx)
+		m.postCancel = cancel
+		m.postRun++
+		run := m.postRun
+		m.postLk.Unlock()
 
-		m.scheduleNextPost(ppe + build.ProvingPeriodDuration)
+		// don't block chain event processing for the duration of the PoSt
+		go m.runPost(pctx, run, ppe, ts)
+		return nil
+	}
+}
 
-		if err != nil {
-			return xerrors.Errorf("doPost: %w", err)
+func (m *Miner) revertPost(ppe uint64) func(ctx context.Context, ts *types.TipSet) error {
+	return func(ctx context.Context, ts *types.TipSet) error {
+		log.Warnw("chain reverted past PoSt challenge, PoSt will be recomputed", "ppe", ppe, "height", ts.Height())
+
+		m.postLk.Lock()
+		if m.postCancel != nil {
+			m.postCancel()
+			m.postCancel = nil
 		}
+		m.postLk.Unlock()
 
 		return nil
 	}
 }
+
+// runPost computes and submits the PoSt for the proving period ending at ppe,
+// retrying until it succeeds or the proving period ends. Run identifies the
+// computation, so it doesn't clear the cancel func of a newer one.
+func (m *Miner) runPost(ctx context.Context, run uint64, ppe uint64, ts *types.TipSet) {
+	defer func() {
+		m.postLk.Lock()
+		if m.postRun == run {
+			m.postCancel = nil
+		}
+		m.postLk.Unlock()
+	}()
+
+	sppe, err := m.api.StateMinerProvingPeriodEnd(ctx, m.maddr, nil)
+	if err == nil && sppe > ppe {
+		// a reorg re-triggered the PoSt, but it was included in the new chain
+		log.Infow("PoSt already on chain", "ppe", ppe, "statePPE", sppe)
+		return
+	}
+
+	dctx, cancel := m.postDeadline(ctx, ppe)
+	defer cancel()
+
+	rec := &api.PoStRecord{
+		ProvingPeriodEnd: ppe,
+	}
+	if lookback := build.PoStChallangeTime + build.PoStRandomnessLookback; ppe > lookback {
+		rec.RandHeight = ppe - lookback
+	}
+
+	p := &post{
+		m:   m,
+		ppe: ppe,
+		ts:  ts,
+	}
+
+	for {
+		rec.Attempts++
+
+		err := p.doPost(dctx)
+		if err != nil && p.smsg != nil && p.receipt == nil {
+			ok, cerr := p.checkCommit(ctx)
+			switch {
+			case cerr != nil:
+				log.Warnw("checking PoSt message", "msg", p.smsg.Cid(), "error", cerr)
+			case ok:
+				err = nil
+			}
+		}
+
+		rec.Duration = p.elapsed
+		if p.smsg != nil {
+			c := p.smsg.Cid()
+			rec.Message = &c
+		}
+		rec.Receipt = p.receipt
+		rec.Err = ""
+		if err != nil {
+			rec.Err = err.Error()
+		}
+		m.recordPoSt(rec)
+
+		if err == nil {
+			break
+		}
+
+		if ctx.Err() != nil {
+			// reverted or shutting down, the PoSt will be rescheduled if needed
+			log.Warnw("PoSt computation canceled", "ppe", ppe, "error", err)
+			return
+		}
+
+		if dctx.Err() != nil {
+			log.Errorw("failed to submit PoSt before the end of the proving period", "ppe", ppe, "attempts", rec.Attempts, "error", err)
+			break
+		}
+
+		log.Errorw("PoSt attempt failed, retrying", "ppe", ppe, "attempt", rec.Attempts, "error", err)
+
+		// a submission which failed on chain, or was replaced, is retried
+		// with a new message
+		if p.receipt != nil || p.replaced {
+			p.smsg = nil
+			p.receipt = nil
+			p.replaced = false
+		}
+
+		select {
+		case <-time.After(postRetryBackoff):
+		case <-dctx.Done():
+		}
+	}
+
+	m.scheduleNextPost(ppe + build.ProvingPeriodDuration)
+
+	// the fault set is replaced with each PoSt, faults need to be declared
+	// before the next challenge
+	m.triggerFaultCheck()
+}
+
+// postDeadline returns a context which is canceled when the chain reaches the
+// end of the proving period, after which the PoSt can't be submitted
+func (m *Miner) postDeadline(ctx context.Context, ppe uint64) (context.Context, context.CancelFunc) {
+	dctx, cancel := context.WithCancel(ctx)
+
+	go func() {
+		t := time.NewTicker(build.BlockDelay * time.Second)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-t.C:
+			case <-dctx.Done():
+				return
+			}
+
+			head, err := m.api.ChainHead(dctx)
+			if err != nil {
+				log.Warnf("checking PoSt deadline: %s", err)
+				continue
+			}
+
+			if head.Height() >= ppe {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	return dctx, cancel
+}
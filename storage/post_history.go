@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+func postHistoryKey(ppe uint64) datastore.Key {
+	// zero padded so that records sort by proving period
+	return datastore.NewKey(fmt.Sprintf("%020d", ppe))
+}
+
+func (m *Miner) recordPoSt(rec *api.PoStRecord) {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		log.Errorf("marshaling PoSt record: %+v", err)
+		return
+	}
+
+	if err := m.postHistory.Put(postHistoryKey(rec.ProvingPeriodEnd), b); err != nil {
+		log.Errorf("storing PoSt record: %+v", err)
+	}
+}
+
+// PoStHistory returns the records of PoSts computed by this miner, oldest
+// first
+func (m *Miner) PoStHistory() ([]api.PoStRecord, error) {
+	res, err := m.postHistory.Query(query.Query{Orders: []query.Order{query.OrderByKey{}}})
+	if err != nil {
+		return nil, xerrors.Errorf("querying PoSt history: %w", err)
+	}
+	defer res.Close()
+
+	var out []api.PoStRecord
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+
+		var rec api.PoStRecord
+		if err := json.Unmarshal(r.Value, &rec); err != nil {
+			return nil, xerrors.Errorf("unmarshaling PoSt record %s: %w", r.Key, err)
+		}
+		out = append(out, rec)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+func TestPoStHistory(t *testing.T) {
+	m := &Miner{postHistory: datastore.NewMapDatastore()}
+
+	m.recordPoSt(&api.PoStRecord{ProvingPeriodEnd: 1000, Attempts: 1, Err: "failed"})
+	m.recordPoSt(&api.PoStRecord{ProvingPeriodEnd: 200, Attempts: 1, Duration: time.Second})
+	m.recordPoSt(&api.PoStRecord{ProvingPeriodEnd: 1000, Attempts: 2, Duration: time.Minute})
+
+	hist, err := m.PoStHistory()
+	require.NoError(t, err)
+	require.Len(t, hist, 2)
+
+	assert.Equal(t, uint64(200), hist[0].ProvingPeriodEnd)
+	assert.Equal(t, uint64(1000), hist[1].ProvingPeriodEnd)
+	assert.Equal(t, 2, hist[1].Attempts)
+	assert.Equal(t, time.Minute, hist[1].Duration)
+	assert.Empty(t, hist[1].Err)
+}
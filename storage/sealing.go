@@ -2,7 +2,7 @@ package storage
 
 import (
 	"context"
-	"io"
+	"fmt"
 
 	cid "github.com/ipfs/go-cid"
 	xerrors "golang.org/x/xerrors"
@@ -72,6 +72,13 @@ type SectorInfo struct {
 
 	// Committing
 	CommitMessage *cid.Cid
+
+	// Faults
+
+	// Retries counts the failures of the sector, once it gets over
+	// maxSectorRetries the sector is moved to FailedUnrecoverable
+	Retries uint64
+	LastErr string
 }
 
 type sectorUpdate struct {
@@ -81,6 +88,23 @@ type sectorUpdate struct {
 	mut      func(*SectorInfo)
 }
 
+// applyUpdate moves the sector to the new state, counting failures. Retries
+// are reset once the sector makes progress again
+func (t *SectorInfo) applyUpdate(update sectorUpdate) {
+	switch {
+	case update.err != nil:
+		t.Retries++
+		t.LastErr = fmt.Sprintf("%+v", update.err)
+	case t.State != update.newState && !failedState(t.State) && !failedState(update.newState):
+		t.Retries = 0
+	}
+
+	t.State = update.newState
+	if update.mut != nil {
+		update.mut(t)
+	}
+}
+
 func (t *SectorInfo) pieceInfos() []sectorbuilder.PublicPieceInfo {
 	out := make([]sectorbuilder.PublicPieceInfo, len(t.Pieces))
 	for i, piece := range t.Pieces {
@@ -130,6 +154,12 @@ func (m *Miner) sectorStateLoop(ctx context.Context) error {
 		return err
 	}
 
+	for _, si := range toRestart {
+		if si.State == api.WaitDeals {
+			m.reopenSector(ctx, si)
+		}
+	}
+
 	go func() {
 		for _, si := range toRestart {
 			select {
@@ -153,7 +183,7 @@ func (m *Miner) sectorStateLoop(ctx context.Context) error {
 		for {
 			select {
 			case sector := <-m.sectorIncoming:
-				m.onSectorIncoming(sector)
+				m.onSectorIncoming(ctx, sector)
 			case update := <-m.sectorUpdated:
 				m.onSectorUpdated(ctx, update)
 			case <-m.stop:
@@ -165,7 +195,7 @@ func (m *Miner) sectorStateLoop(ctx context.Context) error {
 	return nil
 }
 
-func (m *Miner) onSectorIncoming(sector *SectorInfo) {
+func (m *Miner) onSectorIncoming(ctx context.Context, sector *SectorInfo) {
 	has, err := m.sectors.Has(sector.SectorID)
 	if err != nil {
 		return
@@ -182,53 +212,64 @@ func (m *Miner) onSectorIncoming(sector *SectorInfo) {
 		return
 	}
 
-	go func() {
-		select {
-		case m.sectorUpdated <- sectorUpdate{
-			newState: api.Packing,
-			id:       sector.SectorID,
-		}:
-		case <-m.stop:
-			log.Warn("failed to send incoming sector update, miner shutting down")
-		}
-	}()
+	m.onSectorUpdated(ctx, sectorUpdate{
+		newState: sector.State,
+		id:       sector.SectorID,
+	})
 }
 
 func (m *Miner) onSectorUpdated(ctx context.Context, update sectorUpdate) {
 	log.Infof("Sector %d updated state to %s", update.id, api.SectorStateStr(update.newState))
+	if update.err != nil {
+		log.Errorf("sector %d failed: %+v", update.id, update.err)
+	}
+
 	var sector SectorInfo
 	err := m.sectors.Mutate(update.id, func(s *SectorInfo) error {
-		s.State = update.newState
-		if update.mut != nil {
-			update.mut(s)
-		}
+		s.applyUpdate(update)
 		sector = *s
 		return nil
 	})
-	if update.err != nil {
-		log.Errorf("sector %d failed: %s", update.id, update.err)
-		m.failSector(update.id, update.err)
-		return
-	}
 	if err != nil {
 		m.failSector(update.id, err)
 		return
 	}
 
 	switch update.newState {
+	case api.WaitDeals:
+		// open sectors are sealed by packingLoop, or once they are full
 	case api.Packing:
-		m.handle(ctx, sector, m.finishPacking, api.Unsealed)
+		m.handle(ctx, sector, m.finishPacking, api.Unsealed, api.PackingFailed)
 	case api.Unsealed:
-		m.handle(ctx, sector, m.sealPreCommit, api.PreCommitting)
+		m.handle(ctx, sector, m.sealPreCommit, api.PreCommitting, api.SealFailed)
 	case api.PreCommitting:
-		m.handle(ctx, sector, m.preCommit, api.PreCommitted)
+		m.handle(ctx, sector, m.preCommit, api.PreCommitted, api.PreCommitFailed)
 	case api.PreCommitted:
-		m.handle(ctx, sector, m.preCommitted, api.SectorNoUpdate)
+		m.handle(ctx, sector, m.preCommitted, api.SectorNoUpdate, api.PreCommitFailed)
 	case api.Committing:
-		m.handle(ctx, sector, m.committing, api.Proving)
+		m.handle(ctx, sector, m.committing, api.CommitWait, api.CommitFailed)
+	case api.CommitWait:
+		m.handle(ctx, sector, m.commitWait, api.Proving, api.CommitFailed)
 	case api.Proving:
-		// TODO: track sector health / expiration
 		log.Infof("Proving sector %d", update.id)
+		m.handle(ctx, sector, m.proving, api.SectorNoUpdate, api.SectorNoUpdate)
+	case api.Expiring:
+		m.handle(ctx, sector, m.expiring, api.SectorNoUpdate, api.SectorNoUpdate)
+	case api.Expired:
+		m.handle(ctx, sector, m.expired, api.SectorNoUpdate, api.FailedUnrecoverable)
+
+	// Failure modes
+	case api.PackingFailed:
+		m.handleFailed(ctx, sector, m.retryPacking)
+	case api.SealFailed:
+		m.handleFailed(ctx, sector, m.retrySeal)
+	case api.PreCommitFailed:
+		m.handleFailed(ctx, sector, m.retryPreCommit)
+	case api.CommitFailed:
+		m.handleFailed(ctx, sector, m.retryCommit)
+	case api.FailedUnrecoverable:
+		log.Errorf("sector %d failed unrecoverably, last error: %s", update.id, sector.LastErr)
+
 	case api.SectorNoUpdate: // noop
 	default:
 		log.Errorf("unexpected sector update state: %d", update.newState)
@@ -239,35 +280,41 @@ func (m *Miner) failSector(id uint64, err error) {
 	log.Errorf("sector %d error: %+v", id, err)
 }
 
-func (m *Miner) SealPiece(ctx context.Context, ref string, size uint64, r io.Reader, dealID uint64) (uint64, error) {
-	log.Infof("Seal piece for deal %d", dealID)
-
-	sid, err := m.sb.AcquireSectorId() // TODO: Put more than one thing in a sector
-	if err != nil {
-		return 0, xerrors.Errorf("acquiring sector ID: %w", err)
+// ForceSectorState moves the sector to the given state, resetting its retry
+// count, and restarts processing it from there
+func (m *Miner) ForceSectorState(ctx context.Context, id uint64, state api.SectorState) error {
+	if state == api.SectorNoUpdate || state >= api.SectorState(len(api.SectorStates)) {
+		return xerrors.Errorf("invalid sector state %d", state)
 	}
 
-	ppi, err := m.sb.AddPiece(size, sid, r, []uint64{})
+	has, err := m.sectors.Has(id)
 	if err != nil {
-		return 0, xerrors.Errorf("adding piece to sector: %w", err)
+		return err
+	}
+	if !has {
+		return xerrors.Errorf("sector %d not found", id)
 	}
 
-	return sid, m.newSector(ctx, sid, dealID, ref, ppi)
+	select {
+	case m.sectorUpdated <- sectorUpdate{
+		newState: state,
+		id:       id,
+		mut: func(info *SectorInfo) {
+			info.Retries = 0
+		},
+	}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-func (m *Miner) newSector(ctx context.Context, sid uint64, dealID uint64, ref string, ppi sectorbuilder.PublicPieceInfo) error {
+func (m *Miner) newSector(ctx context.Context, sid uint64, state api.SectorState, pieces ...Piece) error {
 	si := &SectorInfo{
+		State:    state,
 		SectorID: sid,
 
-		Pieces: []Piece{
-			{
-				DealID: dealID,
-				Ref:    ref,
-
-				Size:  ppi.Size,
-				CommP: ppi.CommP[:],
-			},
-		},
+		Pieces: pieces,
 	}
 	select {
 	case m.sectorIncoming <- si:
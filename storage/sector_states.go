@@ -14,13 +14,20 @@ import (
 
 type providerHandlerFunc func(ctx context.Context, deal SectorInfo) (func(*SectorInfo), error)
 
-func (m *Miner) handle(ctx context.Context, sector SectorInfo, cb providerHandlerFunc, next api.SectorState) {
+func (m *Miner) handle(ctx context.Context, sector SectorInfo, cb providerHandlerFunc, next api.SectorState, failed api.SectorState) {
 	go func() {
 		mut, err := cb(ctx, sector)
 
 		if err == nil && next == api.SectorNoUpdate {
 			return
 		}
+		if err != nil {
+			if failed == api.SectorNoUpdate {
+				log.Errorf("sector %d: %+v", sector.SectorID, err)
+				return
+			}
+			next = failed
+		}
 
 		select {
 		case m.sectorUpdated <- sectorUpdate{
@@ -37,6 +44,12 @@ func (m *Miner) handle(ctx context.Context, sector SectorInfo, cb providerHandle
 func (m *Miner) finishPacking(ctx context.Context, sector SectorInfo) (func(*SectorInfo), error) {
 	log.Infow("performing filling up rest of the sector...", "sector", sector.SectorID)
 
+	// piece writes which failed, or weren't recorded in the sector, can leave
+	// data after the pieces of the sector, which fillers can't be added after
+	if err := m.sb.TrimStagedSector(sector.SectorID, sector.existingPieces()); err != nil {
+		return nil, xerrors.Errorf("trimming staged sector: %w", err)
+	}
+
 	var allocated uint64
 	for _, piece := range sector.Pieces {
 		allocated += piece.Size
@@ -92,6 +105,15 @@ func (m *Miner) sealPreCommit(ctx context.Context, sector SectorInfo) (func(*Sec
 }
 
 func (m *Miner) preCommit(ctx context.Context, sector SectorInfo) (func(*SectorInfo), error) {
+	head, err := m.api.ChainHead(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	if ticketExpired(sector.Ticket, head.Height()+1) {
+		return nil, xerrors.Errorf("ticket from height %d expired at height %d", sector.Ticket.BlockHeight, head.Height())
+	}
+
 	params := &actors.SectorPreCommitInfo{
 		SectorNumber: sector.SectorID,
 
@@ -135,8 +157,7 @@ func (m *Miner) preCommitted(ctx context.Context, sector SectorInfo) (func(*Sect
 	}
 
 	if mw.Receipt.ExitCode != 0 {
-		log.Error("sector precommit failed: ", mw.Receipt.ExitCode)
-		return nil, err
+		return nil, xerrors.Errorf("sector precommit failed (exit code %d)", mw.Receipt.ExitCode)
 	}
 	log.Info("precommit message landed on chain: ", sector.SectorID)
 
@@ -205,26 +226,31 @@ func (m *Miner) committing(ctx context.Context, sector SectorInfo) (func(*Sector
 
 	smsg, err := m.api.MpoolPushMessage(ctx, msg)
 	if err != nil {
-		log.Error(xerrors.Errorf("pushing message to mpool: %w", err))
+		return nil, xerrors.Errorf("pushing message to mpool: %w", err)
 	}
 
-	// TODO: Separate state before this wait, so we persist message cid?
+	return func(info *SectorInfo) {
+		mcid := smsg.Cid()
+		info.CommitMessage = &mcid
+		info.Proof = proof
+	}, nil
+}
+
+func (m *Miner) commitWait(ctx context.Context, sector SectorInfo) (func(*SectorInfo), error) {
+	if sector.CommitMessage == nil {
+		return nil, xerrors.Errorf("sector %d has no commit message", sector.SectorID)
+	}
 
-	mw, err := m.api.StateWaitMsg(ctx, smsg.Cid())
+	mw, err := m.api.StateWaitMsg(ctx, *sector.CommitMessage)
 	if err != nil {
 		return nil, xerrors.Errorf("failed to wait for porep inclusion: %w", err)
 	}
 
 	if mw.Receipt.ExitCode != 0 {
-		log.Errorf("UNHANDLED: submitting sector proof failed (exit=%d, msg=%s) (t:%x; s:%x(%d); p:%x)", mw.Receipt.ExitCode, smsg.Cid(), sector.Ticket.TicketBytes, sector.Seed.TicketBytes, sector.Seed.BlockHeight, params.Proof)
-		return nil, xerrors.New("UNHANDLED: submitting sector proof failed")
+		return nil, xerrors.Errorf("submitting sector proof failed (exit=%d, msg=%s) (t:%x; s:%x(%d); p:%x)", mw.Receipt.ExitCode, *sector.CommitMessage, sector.Ticket.TicketBytes, sector.Seed.TicketBytes, sector.Seed.BlockHeight, sector.Proof)
 	}
 
 	m.beginPosting(ctx)
 
-	return func(info *SectorInfo) {
-		mcid := smsg.Cid()
-		info.CommitMessage = &mcid
-		info.Proof = proof
-	}, nil
+	return nil, nil
 }
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+)
+
+const (
+	// maxSectorRetries is the number of times a sector is allowed to fail
+	// before it's moved to FailedUnrecoverable
+	maxSectorRetries = 10
+
+	minRetryBackoff = 30 * time.Second
+	maxRetryBackoff = 30 * time.Minute
+)
+
+// failedState checks whether sectors in the state are retried
+func failedState(state api.SectorState) bool {
+	switch state {
+	case api.PackingFailed, api.SealFailed, api.PreCommitFailed, api.CommitFailed, api.FailedUnrecoverable:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryFunc returns the state a failed sector should be retried from
+type retryFunc func(ctx context.Context, sector SectorInfo) (api.SectorState, func(*SectorInfo), error)
+
+// retryBackoff returns how long to wait before retrying a sector which failed
+// the given number of times
+func retryBackoff(retries uint64) time.Duration {
+	backoff := minRetryBackoff
+	for i := uint64(1); i < retries && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+	return backoff
+}
+
+// ticketExpired checks whether a pre-commit using the ticket would be rejected
+// by the miner actor when included at the given height
+func ticketExpired(tkt SealTicket, height uint64) bool {
+	return height+build.SealRandomnessLookback > tkt.BlockHeight+build.SealRandomnessLookbackLimit
+}
+
+func (m *Miner) handleFailed(ctx context.Context, sector SectorInfo, retry retryFunc) {
+	if sector.Retries > maxSectorRetries {
+		go func() {
+			select {
+			case m.sectorUpdated <- sectorUpdate{
+				newState: api.FailedUnrecoverable,
+				id:       sector.SectorID,
+			}:
+			case <-m.stop:
+			}
+		}()
+		return
+	}
+
+	backoff := retryBackoff(sector.Retries)
+	log.Warnf("sector %d failed (%d/%d), retrying in %s", sector.SectorID, sector.Retries, maxSectorRetries, backoff)
+
+	go func() {
+		select {
+		case <-time.After(backoff):
+		case <-m.stop:
+			return
+		}
+
+		next, mut, err := retry(ctx, sector)
+		if err != nil {
+			// stay in the failed state, the error counts as another failure
+			next = sector.State
+		}
+
+		select {
+		case m.sectorUpdated <- sectorUpdate{
+			newState: next,
+			id:       sector.SectorID,
+			err:      err,
+			mut:      mut,
+		}:
+		case <-m.stop:
+		}
+	}()
+}
+
+func (m *Miner) retryPacking(ctx context.Context, sector SectorInfo) (api.SectorState, func(*SectorInfo), error) {
+	return api.Packing, nil, nil
+}
+
+func (m *Miner) retrySeal(ctx context.Context, sector SectorInfo) (api.SectorState, func(*SectorInfo), error) {
+	// sealPreCommit gets a fresh ticket
+	return api.Unsealed, nil, nil
+}
+
+func (m *Miner) retryPreCommit(ctx context.Context, sector SectorInfo) (api.SectorState, func(*SectorInfo), error) {
+	if sector.PreCommitMessage != nil {
+		mw, err := m.api.StateSearchMsg(ctx, *sector.PreCommitMessage)
+		if err != nil {
+			return 0, nil, xerrors.Errorf("looking up precommit message: %w", err)
+		}
+
+		if mw != nil && mw.Receipt.ExitCode == 0 {
+			// the precommit made it on chain, only waiting for it failed
+			return api.PreCommitted, nil, nil
+		}
+	}
+
+	head, err := m.api.ChainHead(ctx)
+	if err != nil {
+		return 0, nil, xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	clearPreCommit := func(info *SectorInfo) {
+		info.PreCommitMessage = nil
+	}
+
+	if ticketExpired(sector.Ticket, head.Height()+1) {
+		log.Warnf("ticket of sector %d expired, sealing it again", sector.SectorID)
+		return api.Unsealed, clearPreCommit, nil
+	}
+
+	return api.PreCommitting, clearPreCommit, nil
+}
+
+func (m *Miner) retryCommit(ctx context.Context, sector SectorInfo) (api.SectorState, func(*SectorInfo), error) {
+	if sector.CommitMessage != nil {
+		mw, err := m.api.StateSearchMsg(ctx, *sector.CommitMessage)
+		if err != nil {
+			return 0, nil, xerrors.Errorf("looking up commit message: %w", err)
+		}
+
+		if mw != nil && mw.Receipt.ExitCode == 0 {
+			// the commit made it on chain, only waiting for it failed
+			return api.CommitWait, nil, nil
+		}
+	}
+
+	return api.Committing, func(info *SectorInfo) {
+		info.CommitMessage = nil
+	}, nil
+}
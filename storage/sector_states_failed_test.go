@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	assert.Equal(t, minRetryBackoff, retryBackoff(0))
+	assert.Equal(t, minRetryBackoff, retryBackoff(1))
+	assert.Equal(t, 2*minRetryBackoff, retryBackoff(2))
+	assert.Equal(t, 8*minRetryBackoff, retryBackoff(4))
+	assert.Equal(t, maxRetryBackoff, retryBackoff(maxSectorRetries))
+	assert.Equal(t, maxRetryBackoff, retryBackoff(1000))
+}
+
+func TestTicketExpired(t *testing.T) {
+	tkt := SealTicket{BlockHeight: 100}
+	limit := tkt.BlockHeight + build.SealRandomnessLookbackLimit - build.SealRandomnessLookback
+
+	assert.False(t, ticketExpired(tkt, tkt.BlockHeight+1))
+	assert.False(t, ticketExpired(tkt, limit))
+	assert.True(t, ticketExpired(tkt, limit+1))
+}
+
+func TestSectorRetries(t *testing.T) {
+	si := &SectorInfo{State: api.Committing}
+
+	si.applyUpdate(sectorUpdate{newState: api.CommitFailed, err: xerrors.New("fail")})
+	assert.Equal(t, uint64(1), si.Retries)
+	assert.Contains(t, si.LastErr, "fail")
+
+	// retrying doesn't count as progress
+	si.applyUpdate(sectorUpdate{newState: api.Committing})
+	assert.Equal(t, uint64(1), si.Retries)
+
+	si.applyUpdate(sectorUpdate{newState: api.CommitFailed, err: xerrors.New("fail again")})
+	assert.Equal(t, uint64(2), si.Retries)
+
+	si.applyUpdate(sectorUpdate{newState: api.Committing})
+	// restarting in the same state isn't either
+	si.applyUpdate(sectorUpdate{newState: api.Committing})
+	assert.Equal(t, uint64(2), si.Retries)
+
+	si.applyUpdate(sectorUpdate{newState: api.CommitWait})
+	assert.Equal(t, uint64(0), si.Retries)
+	assert.Equal(t, api.CommitWait, si.State)
+}
+
+func TestSectorInfoDecodeWithoutRetries(t *testing.T) {
+	si := &SectorInfo{
+		State:    api.Proving,
+		SectorID: 5,
+		Pieces:   []Piece{{DealID: 1, Ref: "ref", Size: 1016, CommP: []byte{1, 2, 3}}},
+		CommD:    []byte{4, 5, 6},
+	}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, si.MarshalCBOR(buf))
+	enc := buf.Bytes()
+
+	// drop the trailing Retries (0) and LastErr ("") fields, as stored
+	// before they were added
+	require.Equal(t, []byte{0x00, 0x60}, enc[len(enc)-2:])
+	old := append([]byte{128 + sectorInfoFields - 2}, enc[1:len(enc)-2]...)
+
+	var out SectorInfo
+	require.NoError(t, out.UnmarshalCBOR(bytes.NewReader(old)))
+	assert.Equal(t, si.SectorID, out.SectorID)
+	assert.Equal(t, si.State, out.State)
+	assert.Equal(t, si.Pieces, out.Pieces)
+	assert.Equal(t, uint64(0), out.Retries)
+
+	out = SectorInfo{}
+	require.NoError(t, out.UnmarshalCBOR(bytes.NewReader(enc)))
+	assert.Equal(t, si.SectorID, out.SectorID)
+}
@@ -47,7 +47,7 @@ type SectorBlocks struct {
 	keyLk    sync.Mutex
 }
 
-func NewSectorBlocks(miner *storage.Miner, ds dtypes.MetadataDS, sb *sectorbuilder.SectorBuilder) *SectorBlocks {
+func NewSectorBlocks(miner *storage.Miner, ds dtypes.MetadataDS, sb sectorbuilder.Interface) *SectorBlocks {
 	sbc := &SectorBlocks{
 		Miner: miner,
 
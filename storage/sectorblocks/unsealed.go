@@ -14,7 +14,7 @@ var log = logging.Logger("sectorblocks")
 
 type unsealedBlocks struct {
 	lk sync.Mutex
-	sb *sectorbuilder.SectorBuilder
+	sb sectorbuilder.Interface
 
 	// TODO: Treat this as some sort of cache, one with rather aggressive GC
 	// TODO: This REALLY, REALLY needs to be on-disk